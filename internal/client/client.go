@@ -1,9 +1,17 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/authzed-go/v1"
@@ -13,6 +21,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
 	zgrpcutil "github.com/authzed/zed/internal/grpcutil"
@@ -40,6 +49,11 @@ func newClientForCurrentContext(cmd *cobra.Command) (Client, error) {
 		return nil, err
 	}
 
+	token, err = tokenWithResolvedEndpoint(cmd.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+
 	dialOpts, err := DialOptsFromFlags(cmd, token)
 	if err != nil {
 		return nil, err
@@ -64,6 +78,11 @@ func newClientForContext(cmd *cobra.Command, contextName string, secretStore sto
 		return nil, err
 	}
 
+	token, err = tokenWithResolvedEndpoint(cmd.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+
 	dialOpts, err := DialOptsFromFlags(cmd, token)
 	if err != nil {
 		return nil, err
@@ -72,8 +91,127 @@ func newClientForContext(cmd *cobra.Command, contextName string, secretStore sto
 	return authzed.NewClient(token.Endpoint, dialOpts...)
 }
 
-// GetCurrentTokenWithCLIOverride returns the current token, but overridden by any parameter specified via CLI args
+// tokenWithResolvedEndpoint returns the token with its endpoint parsed, if it
+// is a URL, and resolved, if it names DNS SRV records.
+func tokenWithResolvedEndpoint(ctx context.Context, token storage.Token) (storage.Token, error) {
+	endpoint, insecure, err := parseEndpointURL(token.Endpoint)
+	if err != nil {
+		return storage.Token{}, err
+	}
+	if insecure != nil {
+		log.Debug().Str("endpoint", token.Endpoint).Bool("insecure", *insecure).Msg("connection security set by endpoint scheme")
+		token.Insecure = insecure
+	}
+
+	token.Endpoint, err = resolveEndpoint(ctx, endpoint)
+	if err != nil {
+		return storage.Token{}, err
+	}
+	return token, nil
+}
+
+const srvEndpointPrefix = "srv://"
+
+// parseEndpointURL parses endpoints given as URLs, returning the gRPC target
+// to dial and whether the URL's scheme requires a plaintext connection.
+// Endpoints without a scheme, and srv:// endpoints, are returned unchanged.
+func parseEndpointURL(endpoint string) (string, *bool, error) {
+	scheme, rest, ok := strings.Cut(endpoint, "://")
+	if !ok || scheme == "srv" {
+		return endpoint, nil, nil
+	}
+
+	plaintext, secure := true, false
+	switch scheme {
+	case "grpc+unix", "unix":
+		if rest == "" {
+			return "", nil, fmt.Errorf("endpoint %q is missing the path to the unix socket", endpoint)
+		}
+		return "unix://" + rest, &plaintext, nil
+
+	case "grpc":
+		target, err := endpointURLTarget(endpoint, "50051")
+		return target, &plaintext, err
+
+	case "grpcs", "https":
+		target, err := endpointURLTarget(endpoint, "443")
+		return target, &secure, err
+
+	case "http":
+		return "", nil, fmt.Errorf("endpoint %q uses http://, but zed connects to the gRPC API of SpiceDB: use grpc:// for a plaintext connection or https:// for TLS", endpoint)
+
+	default:
+		return "", nil, fmt.Errorf("endpoint %q has unsupported scheme %q: expected one of grpc, grpcs, https, grpc+unix or srv", endpoint, scheme)
+	}
+}
+
+// endpointURLTarget returns the host:port of the given URL, using the default
+// port if the URL does not have one.
+func endpointURLTarget(endpoint, defaultPort string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("endpoint %q is missing a host", endpoint)
+	}
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+		return "", fmt.Errorf("endpoint %q has a path, but the gRPC API is served at the root: if this is the URL of the HTTP API, use the gRPC port instead", endpoint)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// lookupSRV defines an (overridable) means of resolving DNS SRV records.
+var lookupSRV = net.DefaultResolver.LookupSRV
+
+// resolveEndpoint resolves endpoints of the form srv://<name> to the
+// host:port of the most preferred DNS SRV record for the name. Any other
+// endpoint is returned unchanged.
+func resolveEndpoint(ctx context.Context, endpoint string) (string, error) {
+	name, ok := strings.CutPrefix(endpoint, srvEndpointPrefix)
+	if !ok {
+		return endpoint, nil
+	}
+
+	_, records, err := lookupSRV(ctx, "", "", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve SRV records for %s: %w", name, err)
+	}
+	if len(records) == 0 {
+		return "", fmt.Errorf("no SRV records found for %s", name)
+	}
+
+	// LookupSRV sorts the records by priority and randomizes them by weight.
+	resolved := net.JoinHostPort(strings.TrimSuffix(records[0].Target, "."), strconv.Itoa(int(records[0].Port)))
+	log.Debug().Str("endpoint", endpoint).Str("resolved", resolved).Msg("resolved SRV endpoint")
+	return resolved, nil
+}
+
+// GetCurrentTokenWithCLIOverride returns the current token, but overridden by any parameter specified via CLI args.
+// If a context is named via --context, its token is used in place of the current one.
 func GetCurrentTokenWithCLIOverride(cmd *cobra.Command, configStore storage.ConfigStore, secretStore storage.SecretStore) (storage.Token, error) {
+	if contextName := contextNameFromCli(cmd); contextName != "" {
+		exists, err := storage.TokenExists(contextName, secretStore)
+		if err != nil {
+			return storage.Token{}, err
+		}
+		if !exists {
+			return storage.Token{}, fmt.Errorf("context %q: %w", contextName, storage.ErrTokenNotFound)
+		}
+
+		token, err := storage.GetTokenIfExists(contextName, secretStore)
+		if err != nil {
+			return storage.Token{}, err
+		}
+
+		return GetTokenWithCLIOverride(cmd, token)
+	}
+
 	// Handle the no-config case separately
 	configExists, err := configStore.Exists()
 	if err != nil {
@@ -113,6 +251,15 @@ func GetTokenWithCLIOverride(cmd *cobra.Command, token storage.Token) (storage.T
 	return result, nil
 }
 
+// contextNameFromCli returns the value of the --context flag, if the command
+// has one.
+func contextNameFromCli(cmd *cobra.Command) string {
+	if flag := cmd.Flags().Lookup("context"); flag != nil {
+		return flag.Value.String()
+	}
+	return ""
+}
+
 func tokenFromCli(cmd *cobra.Command) (storage.Token, error) {
 	certPath := cobrautil.MustGetStringExpanded(cmd, "certificate-path")
 	var certBytes []byte
@@ -137,8 +284,20 @@ func tokenFromCli(cmd *cobra.Command) (storage.Token, error) {
 		nvc := cobrautil.MustGetBool(cmd, "no-verify-ca")
 		notVerifyCA = &nvc
 	}
+	apiToken := cobrautil.MustGetString(cmd, "token")
+	if command := tokenCommandFromCli(cmd); command != "" {
+		if apiToken != "" {
+			return storage.Token{}, errors.New("--token and --token-command cannot be used together")
+		}
+
+		apiToken, err = tokenFromCommand(cmd.Context(), command)
+		if err != nil {
+			return storage.Token{}, err
+		}
+	}
+
 	overrideToken := storage.Token{
-		APIToken:   cobrautil.MustGetString(cmd, "token"),
+		APIToken:   apiToken,
 		Endpoint:   cobrautil.MustGetString(cmd, "endpoint"),
 		Insecure:   notSecure,
 		NoVerifyCA: notVerifyCA,
@@ -160,10 +319,21 @@ func DefaultStorage() (storage.ConfigStore, storage.SecretStore) {
 		&storage.KeychainSecretStore{ConfigPath: home}
 }
 
-func certOption(token storage.Token) (opt grpc.DialOption, err error) {
+func certOption(token storage.Token, skipVerifyHost, serverName string) (opt grpc.DialOption, err error) {
 	verification := grpcutil.VerifyCA
 	if token.HasNoVerifyCA() {
 		verification = grpcutil.SkipVerifyCA
+	} else if SkipsHostVerification(token, skipVerifyHost) {
+		log.Debug().Str("host", skipVerifyHost).Msg("skipping verification of the server certificate's host name")
+		return skipHostVerificationOption(token, serverName)
+	}
+
+	if serverName != "" {
+		config, err := serverNameTLSConfig(token, serverName)
+		if err != nil {
+			return nil, err
+		}
+		return grpc.WithTransportCredentials(credentials.NewTLS(config)), nil
 	}
 
 	if certBytes, ok := token.Certificate(); ok {
@@ -173,6 +343,102 @@ func certOption(token storage.Token) (opt grpc.DialOption, err error) {
 	return grpcutil.WithSystemCerts(verification)
 }
 
+// SkipsHostVerification returns whether TLS connections made with the token
+// verify the server's certificate chain but not the host name it was issued
+// for, as requested by --tls-skip-verify-host.
+func SkipsHostVerification(token storage.Token, skipVerifyHost string) bool {
+	return !token.HasNoVerifyCA() && skipVerifyHost != "" && endpointHost(token.Endpoint) == skipVerifyHost
+}
+
+func endpointHost(endpoint string) string {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	return host
+}
+
+// rootCertPool returns the token's certificate authority, if it has one, or
+// the system certificates otherwise.
+func rootCertPool(token storage.Token) (*x509.CertPool, error) {
+	if certBytes, ok := token.Certificate(); ok {
+		roots := x509.NewCertPool()
+		if ok := roots.AppendCertsFromPEM(certBytes); !ok {
+			return nil, errors.New("failed to append certs from CA PEM")
+		}
+		return roots, nil
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load system certificates: %w", err)
+	}
+	return roots, nil
+}
+
+// serverNameTLSConfig returns the TLS config for connections that send and
+// verify serverName, rather than the host being dialed.
+func serverNameTLSConfig(token storage.Token, serverName string) (*tls.Config, error) {
+	roots, err := rootCertPool(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		RootCAs:            roots,
+		ServerName:         serverName,
+		InsecureSkipVerify: token.HasNoVerifyCA(), // nolint:gosec
+		MinVersion:         tls.VersionTLS12,
+	}, nil
+}
+
+// skipHostVerificationOption returns a dial option for TLS connections that
+// verify the server's certificate chain, but not the host name the
+// certificate was issued for.
+func skipHostVerificationOption(token storage.Token, serverName string) (grpc.DialOption, error) {
+	roots, err := rootCertPool(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		ServerName: serverName,
+		// The default verification, which includes the host name, is replaced
+		// by verifyCertificateChain.
+		InsecureSkipVerify: true, // nolint:gosec
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyCertificateChain(rawCerts, roots)
+		},
+	})), nil
+}
+
+// verifyCertificateChain verifies that the certificates presented by a server
+// chain up to one of the given roots.
+func verifyCertificateChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("server presented no certificates")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, rawCert := range rawCerts {
+		cert, err := x509.ParseCertificate(rawCert)
+		if err != nil {
+			return fmt.Errorf("failed to parse server certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+		return fmt.Errorf("failed to verify server certificate: %w", err)
+	}
+	return nil
+}
+
 // DialOptsFromFlags returns the dial options from the CLI-specified flags.
 func DialOptsFromFlags(cmd *cobra.Command, token storage.Token) ([]grpc.DialOption, error) {
 	interceptors := []grpc.UnaryClientInterceptor{
@@ -183,17 +449,30 @@ func DialOptsFromFlags(cmd *cobra.Command, token storage.Token) ([]grpc.DialOpti
 		interceptors = append(interceptors, zgrpcutil.CheckServerVersion)
 	}
 
+	streamInterceptors := []grpc.StreamClientInterceptor{
+		zgrpcutil.StreamLogDispatchTrailers,
+	}
+
+	if cobrautil.MustGetBool(cmd, "auto-grow-message-size") {
+		interceptors = append(interceptors, zgrpcutil.UnaryAutoGrowMessageSize)
+		streamInterceptors = append(streamInterceptors, zgrpcutil.StreamAutoGrowMessageSize)
+	}
+
 	opts := []grpc.DialOption{
 		grpc.WithChainUnaryInterceptor(interceptors...),
-		grpc.WithChainStreamInterceptor(zgrpcutil.StreamLogDispatchTrailers),
+		grpc.WithChainStreamInterceptor(streamInterceptors...),
 	}
 
+	serverName := cobrautil.MustGetString(cmd, "tls-server-name")
 	if token.IsInsecure() {
+		if serverName != "" {
+			return nil, errors.New("--tls-server-name cannot be used with a plaintext connection")
+		}
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 		opts = append(opts, grpcutil.WithInsecureBearerToken(token.APIToken))
 	} else {
 		opts = append(opts, grpcutil.WithBearerToken(token.APIToken))
-		certOpt, err := certOption(token)
+		certOpt, err := certOption(token, cobrautil.MustGetString(cmd, "tls-skip-verify-host"), serverName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to configure TLS cert: %w", err)
 		}
@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/zed/internal/storage"
+)
+
+func TestResolveEndpoint(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		endpoint    string
+		records     []*net.SRV
+		lookupErr   error
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "plain endpoint",
+			endpoint: "localhost:50051",
+			expected: "localhost:50051",
+		},
+		{
+			name:     "srv endpoint",
+			endpoint: "srv://_grpc._tcp.spicedb.example.com",
+			records: []*net.SRV{
+				{Target: "spicedb-0.example.com.", Port: 50051, Priority: 10},
+				{Target: "spicedb-1.example.com.", Port: 50052, Priority: 20},
+			},
+			expected: "spicedb-0.example.com:50051",
+		},
+		{
+			name:        "no srv records",
+			endpoint:    "srv://_grpc._tcp.spicedb.example.com",
+			expectedErr: "no SRV records found for _grpc._tcp.spicedb.example.com",
+		},
+		{
+			name:        "lookup failure",
+			endpoint:    "srv://_grpc._tcp.spicedb.example.com",
+			lookupErr:   errors.New("no such host"),
+			expectedErr: "failed to resolve SRV records for _grpc._tcp.spicedb.example.com: no such host",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			originalLookupSRV := lookupSRV
+			defer func() {
+				lookupSRV = originalLookupSRV
+			}()
+			lookupSRV = func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				require.Empty(t, service)
+				require.Empty(t, proto)
+				require.Equal(t, "_grpc._tcp.spicedb.example.com", name)
+				return name, tt.records, tt.lookupErr
+			}
+
+			resolved, err := resolveEndpoint(context.Background(), tt.endpoint)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, resolved)
+		})
+	}
+}
+
+func TestEndpointHost(t *testing.T) {
+	require.Equal(t, "spicedb.internal", endpointHost("spicedb.internal:50051"))
+	require.Equal(t, "::1", endpointHost("[::1]:50051"))
+	require.Equal(t, "spicedb.internal", endpointHost("spicedb.internal"))
+}
+
+func createTestCertificate(t *testing.T, commonName string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestVerifyCertificateChain(t *testing.T) {
+	ca, caKey := createTestCertificate(t, "test-ca", true, nil, nil)
+	intermediate, intermediateKey := createTestCertificate(t, "test-intermediate", true, ca, caKey)
+	// The leaf is issued for a host other than the one being connected to.
+	leaf, _ := createTestCertificate(t, "some-other-host.internal", false, intermediate, intermediateKey)
+	otherCA, _ := createTestCertificate(t, "other-ca", true, nil, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(otherCA)
+
+	require.NoError(t, verifyCertificateChain([][]byte{leaf.Raw, intermediate.Raw}, roots))
+	require.ErrorContains(t, verifyCertificateChain([][]byte{leaf.Raw}, roots), "failed to verify server certificate")
+	require.ErrorContains(t, verifyCertificateChain([][]byte{leaf.Raw, intermediate.Raw}, otherRoots), "failed to verify server certificate")
+	require.ErrorContains(t, verifyCertificateChain(nil, roots), "server presented no certificates")
+	require.ErrorContains(t, verifyCertificateChain([][]byte{[]byte("garbage")}, roots), "failed to parse server certificate")
+}
+
+func TestServerNameTLSConfig(t *testing.T) {
+	ca, _ := createTestCertificate(t, "test-ca", true, nil, nil)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+
+	config, err := serverNameTLSConfig(storage.Token{CACert: caPEM}, "spicedb.internal")
+	require.NoError(t, err)
+	require.Equal(t, "spicedb.internal", config.ServerName)
+	require.False(t, config.InsecureSkipVerify)
+	require.NotNil(t, config.RootCAs)
+
+	config, err = serverNameTLSConfig(storage.Token{CACert: caPEM, NoVerifyCA: ptr(true)}, "spicedb.internal")
+	require.NoError(t, err)
+	require.Equal(t, "spicedb.internal", config.ServerName)
+	require.True(t, config.InsecureSkipVerify)
+
+	_, err = serverNameTLSConfig(storage.Token{CACert: []byte("garbage")}, "spicedb.internal")
+	require.ErrorContains(t, err, "failed to append certs from CA PEM")
+}
+
+func TestParseEndpointURL(t *testing.T) {
+	for _, tt := range []struct {
+		endpoint         string
+		expectedTarget   string
+		expectedInsecure *bool
+		expectedErr      string
+	}{
+		{endpoint: "localhost:50051", expectedTarget: "localhost:50051"},
+		{endpoint: "srv://_grpc._tcp.spicedb.example.com", expectedTarget: "srv://_grpc._tcp.spicedb.example.com"},
+		{endpoint: "https://grpc.authzed.com:443", expectedTarget: "grpc.authzed.com:443", expectedInsecure: ptr(false)},
+		{endpoint: "https://grpc.authzed.com", expectedTarget: "grpc.authzed.com:443", expectedInsecure: ptr(false)},
+		{endpoint: "grpcs://spicedb.internal:50051/", expectedTarget: "spicedb.internal:50051", expectedInsecure: ptr(false)},
+		{endpoint: "grpc://localhost", expectedTarget: "localhost:50051", expectedInsecure: ptr(true)},
+		{endpoint: "grpc://[::1]:50052", expectedTarget: "[::1]:50052", expectedInsecure: ptr(true)},
+		{endpoint: "grpc+unix:///var/run/spicedb.sock", expectedTarget: "unix:///var/run/spicedb.sock", expectedInsecure: ptr(true)},
+		{endpoint: "grpc+unix://", expectedErr: "missing the path to the unix socket"},
+		{endpoint: "http://localhost:8443", expectedErr: "use grpc:// for a plaintext connection"},
+		{endpoint: "https://localhost:8443/v1/permissions/check", expectedErr: "use the gRPC port instead"},
+		{endpoint: "https://:443", expectedErr: "missing a host"},
+		{endpoint: "tcp://localhost:50051", expectedErr: `unsupported scheme "tcp"`},
+	} {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			target, insecure, err := parseEndpointURL(tt.endpoint)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTarget, target)
+			require.Equal(t, tt.expectedInsecure, insecure)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestTokenFromCommand(t *testing.T) {
+	var runs int
+	originalRun := runTokenCommand
+	runTokenCommand = func(ctx context.Context, command string) ([]byte, error) {
+		runs++
+		return originalRun(ctx, command)
+	}
+	defer func() {
+		runTokenCommand = originalRun
+	}()
+
+	token, err := tokenFromCommand(context.Background(), "echo '  sometoken  '")
+	require.NoError(t, err)
+	require.Equal(t, "sometoken", token)
+
+	// The token is cached for the rest of the invocation.
+	token, err = tokenFromCommand(context.Background(), "echo '  sometoken  '")
+	require.NoError(t, err)
+	require.Equal(t, "sometoken", token)
+	require.Equal(t, 1, runs)
+
+	_, err = tokenFromCommand(context.Background(), "echo 'vault is sealed' >&2; exit 3")
+	require.ErrorContains(t, err, "vault is sealed")
+
+	_, err = tokenFromCommand(context.Background(), "true")
+	require.ErrorContains(t, err, "printed no token")
+}
@@ -1,10 +1,17 @@
 package client_test
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/authzed/zed/internal/client"
 	"github.com/authzed/zed/internal/storage"
 	zedtesting "github.com/authzed/zed/internal/testing"
@@ -119,3 +126,128 @@ func TestGetCurrentTokenWithCLIOverrideWithoutSecretFile(t *testing.T) {
 	require.Equal("e1", token.Endpoint)
 	require.Equal(&bTrue, token.Insecure)
 }
+
+type mockConfigStore struct {
+	cfg storage.Config
+}
+
+func (m *mockConfigStore) Get() (storage.Config, error) { return m.cfg, nil }
+func (m *mockConfigStore) Put(cfg storage.Config) error { m.cfg = cfg; return nil }
+func (m *mockConfigStore) Exists() (bool, error)        { return true, nil }
+
+type mockSecretStore struct {
+	secrets storage.Secrets
+}
+
+func (m *mockSecretStore) Get() (storage.Secrets, error) { return m.secrets, nil }
+func (m *mockSecretStore) Put(s storage.Secrets) error   { m.secrets = s; return nil }
+
+func TestGetCurrentTokenWithContextOverride(t *testing.T) {
+	configStore := &mockConfigStore{cfg: storage.Config{Version: "v1", CurrentToken: "current"}}
+	secretStore := &mockSecretStore{secrets: storage.Secrets{Tokens: []storage.Token{
+		{Name: "current", Endpoint: "e1", APIToken: "t1"},
+		{Name: "other", Endpoint: "e2", APIToken: "t2"},
+	}}}
+
+	for _, tt := range []struct {
+		name             string
+		context          string
+		endpoint         string
+		expectedEndpoint string
+		expectedToken    string
+		expectedErr      string
+	}{
+		{name: "current context", expectedEndpoint: "e1", expectedToken: "t1"},
+		{name: "named context", context: "other", expectedEndpoint: "e2", expectedToken: "t2"},
+		{name: "named context with override", context: "other", endpoint: "e3", expectedEndpoint: "e3", expectedToken: "t2"},
+		{name: "missing context", context: "missing", expectedErr: `context "missing": token does not exist`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.StringFlag{FlagName: "context", FlagValue: tt.context, Changed: tt.context != ""},
+				zedtesting.StringFlag{FlagName: "token"},
+				zedtesting.StringFlag{FlagName: "endpoint", FlagValue: tt.endpoint, Changed: tt.endpoint != ""},
+				zedtesting.StringFlag{FlagName: "certificate-path"},
+				zedtesting.BoolFlag{FlagName: "insecure"},
+				zedtesting.BoolFlag{FlagName: "no-verify-ca"},
+			)
+
+			token, err := client.GetCurrentTokenWithCLIOverride(cmd, configStore, secretStore)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				require.ErrorIs(t, err, storage.ErrTokenNotFound)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedEndpoint, token.Endpoint)
+			require.Equal(t, tt.expectedToken, token.APIToken)
+		})
+	}
+}
+
+func TestDialOptsFromFlagsServerNameRequiresTLS(t *testing.T) {
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.BoolFlag{FlagName: "skip-version-check", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "auto-grow-message-size"},
+		zedtesting.StringFlag{FlagName: "tls-skip-verify-host"},
+		zedtesting.StringFlag{FlagName: "tls-server-name", FlagValue: "spicedb.internal"},
+		zedtesting.StringFlag{FlagName: "hostname-override"},
+		zedtesting.IntFlag{FlagName: "max-message-size"},
+	)
+
+	bTrue := true
+	bFalse := false
+
+	_, err := client.DialOptsFromFlags(cmd, storage.Token{Endpoint: "10.0.0.1:50051", Insecure: &bTrue})
+	require.ErrorContains(t, err, "--tls-server-name cannot be used with a plaintext connection")
+
+	opts, err := client.DialOptsFromFlags(cmd, storage.Token{Endpoint: "10.0.0.1:50051", Insecure: &bFalse})
+	require.NoError(t, err)
+	require.NotEmpty(t, opts)
+}
+
+func TestDialOptsFromFlagsMaxMessageSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	var schema strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&schema, "definition resource%d {}\n\n", i)
+	}
+	_, err = v1.NewSchemaServiceClient(conn).WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: schema.String()})
+	require.NoError(t, err)
+
+	bTrue := true
+	readSchema := func(maxMessageSize string) error {
+		cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+			zedtesting.BoolFlag{FlagName: "skip-version-check", FlagValue: true},
+			zedtesting.BoolFlag{FlagName: "auto-grow-message-size"},
+			zedtesting.StringFlag{FlagName: "tls-skip-verify-host"},
+			zedtesting.StringFlag{FlagName: "tls-server-name"},
+			zedtesting.StringFlag{FlagName: "hostname-override"},
+			zedtesting.IntFlag{FlagName: "max-message-size"},
+		)
+		require.NoError(t, cmd.Flags().Set("max-message-size", maxMessageSize))
+
+		opts, err := client.DialOptsFromFlags(cmd, storage.Token{Insecure: &bTrue})
+		require.NoError(t, err)
+		conn, err := srv.GRPCDialContext(ctx, opts...)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = v1.NewSchemaServiceClient(conn).ReadSchema(ctx, &v1.ReadSchemaRequest{})
+		return err
+	}
+
+	// The schema is larger than a 1KB limit, but fits once it is raised.
+	err = readSchema("1024")
+	require.Equal(t, codes.ResourceExhausted, status.Code(err), err)
+	require.NoError(t, readSchema("1048576"))
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// tokenCommandTimeout bounds how long a --token-command may take to print a token.
+const tokenCommandTimeout = 30 * time.Second
+
+var (
+	// runTokenCommand defines an (overridable) means of running a token
+	// command through the shell, returning its stdout.
+	runTokenCommand = func(ctx context.Context, command string) ([]byte, error) {
+		shell, flag := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, flag = "cmd", "/C"
+		}
+		return exec.CommandContext(ctx, shell, flag, command).Output()
+	}
+
+	// tokenCommandTokens caches the token printed by each command, so that it
+	// is run at most once per invocation.
+	tokenCommandTokens   = map[string]string{}
+	tokenCommandTokensMu sync.Mutex
+)
+
+// tokenCommandFromCli returns the value of the --token-command flag, if the
+// command has one.
+func tokenCommandFromCli(cmd *cobra.Command) string {
+	if flag := cmd.Flags().Lookup("token-command"); flag != nil {
+		return flag.Value.String()
+	}
+	return ""
+}
+
+// tokenFromCommand runs the command and returns the token it printed.
+func tokenFromCommand(ctx context.Context, command string) (string, error) {
+	tokenCommandTokensMu.Lock()
+	defer tokenCommandTokensMu.Unlock()
+
+	if token, ok := tokenCommandTokens[command]; ok {
+		return token, nil
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, tokenCommandTimeout)
+	defer cancel()
+
+	log.Trace().Str("command", command).Msg("running token command")
+	out, err := runTokenCommand(ctx, command)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("token command failed: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("token command failed: %w", err)
+	}
+
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", errors.New("token command printed no token")
+	}
+
+	tokenCommandTokens[command] = token
+	return token, nil
+}
@@ -1,13 +1,13 @@
 package cmd
 
 import (
-	"context"
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -15,7 +15,9 @@ import (
 	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
 	"github.com/authzed/spicedb/pkg/schemadsl/generator"
 	"github.com/authzed/spicedb/pkg/tuple"
-	"github.com/authzed/spicedb/pkg/typesystem"
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/hamba/avro/v2/ocf"
 	"github.com/jzelinskie/cobrautil/v2"
 	"github.com/mattn/go-isatty"
 	"github.com/rodaine/table"
@@ -25,10 +27,12 @@ import (
 	"golang.org/x/exp/maps"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
 
 	"github.com/authzed/zed/internal/client"
 	"github.com/authzed/zed/internal/commands"
 	"github.com/authzed/zed/internal/console"
+	"github.com/authzed/zed/internal/grpcutil"
 	"github.com/authzed/zed/pkg/backupformat"
 )
 
@@ -49,9 +53,10 @@ var (
 	}
 
 	backupRestoreCmd = &cobra.Command{
-		Use:   "restore <filename>",
+		Use:   "restore <filename>...",
 		Short: "Restore a permission system from a file",
-		Args:  commands.StdinOrExactArgs(1),
+		Long:  "Restore a permission system from a backup file, or from the parts of a backup created with --split-size, given as a list of files or a glob (e.g. \"name.*.zedbackup\"). All files are checked to share the same revision and schema before any relationships are written.",
+		Args:  commands.StdinOrMinimumArgs(1),
 		RunE:  backupRestoreCmdFunc,
 	}
 
@@ -73,6 +78,13 @@ var (
 		},
 	}
 
+	backupSplitCmd = &cobra.Command{
+		Use:   "split <filename>",
+		Short: "Extract the schema and relationships from a backup file to separate files, in a single pass",
+		Args:  cobra.ExactArgs(1),
+		RunE:  backupSplitCmdFunc,
+	}
+
 	backupParseRelsCmd = &cobra.Command{
 		Use:   "parse-relationships <filename>",
 		Short: "Extract the relationships from a backup file",
@@ -90,6 +102,32 @@ var (
 			return backupRedactCmdFunc(cmd, args)
 		},
 	}
+
+	backupToValidationFileCmd = &cobra.Command{
+		Use:   "to-validation-file <filename> [output-filename]",
+		Short: "Convert a backup file into a validation file (YAML)",
+		Long:  "Convert a backup file into a validation file (YAML) containing its schema and relationships, for use with `zed validate` or the Playground. Writes to stdout when no output file is given.",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			out := io.Writer(os.Stdout)
+			if len(args) > 1 {
+				f, err := os.Create(args[1])
+				if err != nil {
+					return fmt.Errorf("unable to create validation file: %w", err)
+				}
+				defer func(e *error) { *e = errors.Join(*e, f.Close()) }(&err)
+				out = f
+			}
+			return backupToValidationFileCmdFunc(cmd, out, args[:1])
+		},
+	}
+
+	backupCompressCmd = &cobra.Command{
+		Use:   "compress <input-filename> <output-filename>",
+		Short: "Rewrite a backup file with a different compression codec",
+		Args:  cobra.ExactArgs(2),
+		RunE:  backupCompressCmdFunc,
+	}
 )
 
 func registerBackupCmd(rootCmd *cobra.Command) {
@@ -102,12 +140,21 @@ func registerBackupCmd(rootCmd *cobra.Command) {
 	backupCmd.AddCommand(backupRestoreCmd)
 	registerBackupRestoreFlags(backupRestoreCmd)
 
+	backupCmd.AddCommand(backupRestoreIncrementalCmd)
+	backupRestoreIncrementalCmd.Flags().Uint("batch-size", 1_000, "restore relationship write batch size")
+	backupRestoreIncrementalCmd.Flags().String("prefix-filter", "", "include only schema and relationships with a given prefix")
+
 	backupCmd.AddCommand(backupRedactCmd)
 	backupRedactCmd.Flags().Bool("redact-definitions", true, "redact definitions")
 	backupRedactCmd.Flags().Bool("redact-relations", true, "redact relations")
 	backupRedactCmd.Flags().Bool("redact-object-ids", true, "redact object IDs")
 	backupRedactCmd.Flags().Bool("print-redacted-object-ids", false, "prints the redacted object IDs")
 
+	backupCmd.AddCommand(backupCompressCmd)
+	backupCompressCmd.Flags().String("compression", "zstd", "compression codec used for the output file. Possible values: zstd, snappy, deflate")
+	backupCompressCmd.Flags().Bool("decompress", false, "write the output file without compression")
+	backupCompressCmd.MarkFlagsMutuallyExclusive("compression", "decompress")
+
 	// Restore used to be on the root, so add it there too, but hidden.
 	restoreCmd := &cobra.Command{
 		Use:    "restore <filename>",
@@ -122,10 +169,23 @@ func registerBackupCmd(rootCmd *cobra.Command) {
 	backupCmd.AddCommand(backupParseSchemaCmd)
 	backupParseSchemaCmd.Flags().String("prefix-filter", "", "include only schema and relationships with a given prefix")
 	backupParseSchemaCmd.Flags().Bool("rewrite-legacy", false, "potentially modify the schema to exclude legacy/broken syntax")
+	backupParseSchemaCmd.Flags().String("output-file", "", "write the schema to this file (created with 0600 permissions) instead of stdout")
+
+	backupCmd.AddCommand(backupToValidationFileCmd)
+	backupToValidationFileCmd.Flags().Uint("max-relationships", 0, "maximum number of relationships to include; 0 includes all of them")
 
 	backupCmd.AddCommand(backupParseRevisionCmd)
 	backupCmd.AddCommand(backupParseRelsCmd)
 	backupParseRelsCmd.Flags().String("prefix-filter", "", "Include only relationships with a given prefix")
+
+	backupCmd.AddCommand(backupSplitCmd)
+	backupSplitCmd.Flags().String("schema-out", "", "file to write the schema to")
+	backupSplitCmd.Flags().String("rels-out", "", "file to write the relationships to, one per line as with parse-relationships")
+	backupSplitCmd.Flags().String("prefix-filter", "", "include only schema and relationships with a given prefix")
+	backupSplitCmd.Flags().Bool("rewrite-legacy", false, "potentially modify the schema to exclude legacy/broken syntax")
+	backupSplitCmd.Flags().Bool("json", false, "write each relationship as a single line of JSON")
+	_ = backupSplitCmd.MarkFlagRequired("schema-out")
+	_ = backupSplitCmd.MarkFlagRequired("rels-out")
 }
 
 func registerBackupRestoreFlags(cmd *cobra.Command) {
@@ -135,12 +195,20 @@ func registerBackupRestoreFlags(cmd *cobra.Command) {
 	cmd.Flags().Bool("disable-retries", false, "retries when an errors is determined to be retryable (e.g. serialization errors)")
 	cmd.Flags().String("prefix-filter", "", "include only schema and relationships with a given prefix")
 	cmd.Flags().Bool("rewrite-legacy", false, "potentially modify the schema to exclude legacy/broken syntax")
+	cmd.Flags().String("transform-map", "", "file mapping object types to rename during restore, one oldType:newType per line, applied to the schema and to both sides of every relationship")
 	cmd.Flags().Duration("request-timeout", 30*time.Second, "timeout for each request performed during restore")
+	cmd.Flags().Bool("pause-on-error", false, "on a non-retryable error, display the failed batch and prompt to skip, retry or abort, or with --conflict-strategy=fail to overwrite it with touch")
+	cmd.Flags().Bool("adaptive-batching", false, "when a batch is rejected for being too large, split it in half and retry the halves, down to single relationships")
 }
 
 func registerBackupCreateFlags(cmd *cobra.Command) {
 	cmd.Flags().String("prefix-filter", "", "include only schema and relationships with a given prefix")
 	cmd.Flags().Bool("rewrite-legacy", false, "potentially modify the schema to exclude legacy/broken syntax")
+	cmd.Flags().String("split-size", "", "start a new numbered backup file (name.0001.zedbackup, name.0002.zedbackup, ...) once the current one exceeds this size, e.g. 10GB")
+	cmd.Flags().String("since", "", "create an incremental backup of the changes made since the revision of this backup file, or of this zedtoken")
+	cmd.Flags().Duration("since-idle-timeout", 5*time.Second, "with --since, stop watching for changes once none have been received for this long")
+	cmd.Flags().Duration("since-max-duration", time.Minute, "with --since, stop watching for changes after this long, leaving later changes to the next incremental backup")
+	cmd.Flags().Uint("min-relationships", 0, "fail and delete the backup if fewer than this many relationships were exported, to guard against backing up a partially-wiped system")
 }
 
 func createBackupFile(filename string) (*os.File, error) {
@@ -207,25 +275,9 @@ func filterSchemaDefs(schema, prefix string) (filteredSchema string, err error)
 		return "", fmt.Errorf("error generating filtered schema: %w", err)
 	}
 
-	// Validate that the type system for the generated schema is comprehensive.
-	compiledFilteredSchema, err := compiler.Compile(
-		compiler.InputSchema{Source: "generated-schema", SchemaString: filteredSchema},
-		compiler.AllowUnprefixedObjectType(),
-	)
-	if err != nil {
-		return "", fmt.Errorf("generated invalid schema: %w", err)
+	if err := validateSchema(filteredSchema, "generated invalid schema"); err != nil {
+		return "", err
 	}
-
-	for _, def := range compiledFilteredSchema.ObjectDefinitions {
-		ts, err := typesystem.NewNamespaceTypeSystem(def, typesystem.ResolverForSchema(*compiledFilteredSchema))
-		if err != nil {
-			return "", fmt.Errorf("generated invalid schema: %w", err)
-		}
-		if _, err := ts.Validate(context.Background()); err != nil {
-			return "", fmt.Errorf("generated invalid schema: %w", err)
-		}
-	}
-
 	return
 }
 
@@ -236,13 +288,44 @@ func hasRelPrefix(rel *v1.Relationship, prefix string) bool {
 }
 
 func backupCreateCmdFunc(cmd *cobra.Command, args []string) (err error) {
-	f, err := createBackupFile(args[0])
+	splitSize, err := backupSplitSize(cmd)
 	if err != nil {
 		return err
 	}
 
-	defer func(e *error) { *e = errors.Join(*e, f.Close()) }(&err)
-	defer func(e *error) { *e = errors.Join(*e, f.Sync()) }(&err)
+	minRelationships := cobrautil.MustGetUint(cmd, "min-relationships")
+	if since := cobrautil.MustGetString(cmd, "since"); since != "" {
+		if splitSize > 0 {
+			return errors.New("--split-size cannot be used with --since")
+		}
+		if minRelationships > 0 {
+			return errors.New("--min-relationships cannot be used with --since")
+		}
+		return backupCreateIncrementalCmdFunc(cmd, args[0], since)
+	}
+
+	// Registered first, so that the backup files have been closed by the
+	// time they are removed.
+	var tooFewRelationships bool
+	var encoder relationshipEncoder
+	defer func(e *error) {
+		if tooFewRelationships && args[0] != "-" {
+			*e = errors.Join(*e, removeBackupFiles(args[0], encoder))
+		}
+	}(&err)
+
+	var f *os.File
+	if splitSize == 0 {
+		f, err = createBackupFile(args[0])
+		if err != nil {
+			return err
+		}
+
+		defer func(e *error) { *e = errors.Join(*e, f.Close()) }(&err)
+		defer func(e *error) { *e = errors.Join(*e, f.Sync()) }(&err)
+	} else if args[0] == "-" {
+		return errors.New("--split-size cannot be used when writing the backup to stdout")
+	}
 
 	c, err := client.NewClient(cmd)
 	if err != nil {
@@ -273,7 +356,11 @@ func backupCreateCmdFunc(cmd *cobra.Command, args []string) (err error) {
 		}
 	}
 
-	encoder, err := backupformat.NewEncoder(f, schema, schemaResp.ReadAt)
+	if splitSize > 0 {
+		encoder, err = newSplitBackupEncoder(args[0], schema, schemaResp.ReadAt, splitSize)
+	} else {
+		encoder, err = backupformat.NewEncoder(f, schema, schemaResp.ReadAt)
+	}
 	if err != nil {
 		return fmt.Errorf("error creating backup file encoder: %w", err)
 	}
@@ -294,6 +381,20 @@ func backupCreateCmdFunc(cmd *cobra.Command, args []string) (err error) {
 
 	bar := console.CreateProgressBar("processing backup")
 	var relsEncoded, relsProcessed uint
+
+	// Relationships are exported grouped by resource type, so a line is
+	// emitted each time the type changes to show which definitions are done.
+	var currentType string
+	var currentTypeEncoded int64
+	reportTypeProgress := func() {
+		if currentType == "" || !console.ProgressEnabled() {
+			return
+		}
+
+		_ = bar.Clear()
+		console.Errorf("backed up %s: %s %s\n", currentType, humanize.Comma(currentTypeEncoded), english.PluralWord(int(currentTypeEncoded), "relationship", ""))
+	}
+
 	for {
 		if err := ctx.Err(); err != nil {
 			return fmt.Errorf("aborted backup: %w", err)
@@ -314,6 +415,13 @@ func backupCreateCmdFunc(cmd *cobra.Command, args []string) (err error) {
 				}
 				relsEncoded++
 
+				if rel.Resource.ObjectType != currentType {
+					reportTypeProgress()
+					currentType = rel.Resource.ObjectType
+					currentTypeEncoded = 0
+				}
+				currentTypeEncoded++
+
 				if relsEncoded%100_000 == 0 && !isatty.IsTerminal(os.Stderr.Fd()) {
 					log.Trace().
 						Uint("encoded", relsEncoded).
@@ -328,11 +436,18 @@ func backupCreateCmdFunc(cmd *cobra.Command, args []string) (err error) {
 		}
 	}
 	totalTime := time.Since(relationshipReadStart)
+	reportTypeProgress()
 
 	if err := bar.Finish(); err != nil {
 		return fmt.Errorf("error finalizing progress bar: %w", err)
 	}
 
+	if relsEncoded < minRelationships {
+		tooFewRelationships = true
+		return fmt.Errorf("only %d %s exported, fewer than --min-relationships %d",
+			relsEncoded, english.PluralWord(int(relsEncoded), "relationship", ""), minRelationships)
+	}
+
 	log.Info().
 		Uint("encoded", relsEncoded).
 		Uint("processed", relsProcessed).
@@ -343,6 +458,137 @@ func backupCreateCmdFunc(cmd *cobra.Command, args []string) (err error) {
 	return nil
 }
 
+// removeBackupFiles removes the file, or every part of a split backup,
+// written for the backup.
+func removeBackupFiles(filename string, encoder relationshipEncoder) error {
+	split, ok := encoder.(*splitBackupEncoder)
+	if !ok {
+		return os.Remove(filename)
+	}
+
+	var err error
+	for part := 1; part <= split.part; part++ {
+		err = errors.Join(err, os.Remove(backupPartFilename(filename, part)))
+	}
+	return err
+}
+
+func backupSplitSize(cmd *cobra.Command) (uint64, error) {
+	splitSize := cobrautil.MustGetString(cmd, "split-size")
+	if splitSize == "" {
+		return 0, nil
+	}
+
+	size, err := humanize.ParseBytes(splitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --split-size %q: %w", splitSize, err)
+	}
+	return size, nil
+}
+
+type relationshipEncoder interface {
+	Append(rel *v1.Relationship) error
+	Close() error
+}
+
+const backupFileExtension = ".zedbackup"
+
+// backupPartFilename returns the filename of the given part of a split
+// backup, e.g. name.0001.zedbackup for name.zedbackup.
+func backupPartFilename(filename string, part int) string {
+	return fmt.Sprintf("%s.%04d%s", strings.TrimSuffix(filename, backupFileExtension), part, backupFileExtension)
+}
+
+type countingWriter struct {
+	w       io.Writer
+	written uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.written += uint64(n)
+	return n, err
+}
+
+// splitBackupEncoder writes a backup as a sequence of numbered part files,
+// starting a new part once the current one exceeds splitSize bytes. Every
+// part begins with the schema and revision, so each can be inspected on its
+// own.
+type splitBackupEncoder struct {
+	filename  string
+	schema    string
+	revision  *v1.ZedToken
+	splitSize uint64
+
+	part          int
+	partRelsCount uint
+	file          *os.File
+	counter       *countingWriter
+	encoder       *backupformat.Encoder
+}
+
+func newSplitBackupEncoder(filename, schema string, revision *v1.ZedToken, splitSize uint64) (*splitBackupEncoder, error) {
+	e := &splitBackupEncoder{
+		filename:  filename,
+		schema:    schema,
+		revision:  revision,
+		splitSize: splitSize,
+	}
+	if err := e.nextPart(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *splitBackupEncoder) nextPart() error {
+	if err := e.closePart(); err != nil {
+		return err
+	}
+
+	f, err := createBackupFile(backupPartFilename(e.filename, e.part+1))
+	if err != nil {
+		return err
+	}
+
+	counter := &countingWriter{w: f}
+	encoder, err := backupformat.NewEncoder(counter, e.schema, e.revision)
+	if err != nil {
+		return errors.Join(err, f.Close())
+	}
+
+	e.part++
+	e.partRelsCount = 0
+	e.file, e.counter, e.encoder = f, counter, encoder
+	return nil
+}
+
+func (e *splitBackupEncoder) closePart() error {
+	if e.file == nil {
+		return nil
+	}
+
+	err := errors.Join(e.encoder.Close(), e.file.Sync(), e.file.Close())
+	e.file, e.counter, e.encoder = nil, nil, nil
+	return err
+}
+
+func (e *splitBackupEncoder) Append(rel *v1.Relationship) error {
+	// Every part holds at least one relationship, even if the schema alone
+	// exceeds the split size.
+	if e.partRelsCount > 0 && e.counter.written >= e.splitSize {
+		if err := e.nextPart(); err != nil {
+			return fmt.Errorf("unable to start backup part %d: %w", e.part+1, err)
+		}
+	}
+
+	e.partRelsCount++
+	return e.encoder.Append(rel)
+}
+
+func (e *splitBackupEncoder) Close() error {
+	return e.closePart()
+}
+
 func openRestoreFile(filename string) (*os.File, int64, error) {
 	if filename == "" {
 		log.Trace().Str("filename", "(stdin)").Send()
@@ -365,19 +611,30 @@ func openRestoreFile(filename string) (*os.File, int64, error) {
 }
 
 func backupRestoreCmdFunc(cmd *cobra.Command, args []string) error {
-	decoder, closer, err := decoderFromArgs(args...)
+	pauseOnError := cobrautil.MustGetBool(cmd, "pause-on-error")
+	if pauseOnError {
+		switch {
+		case len(args) == 0:
+			return errors.New("--pause-on-error cannot be used when reading the backup from stdin")
+		case cobrautil.MustGetBool(cmd, "yes"):
+			return errors.New("--pause-on-error prompts to skip, retry or abort each failed batch and cannot be answered by --yes")
+		case !commands.ConfirmInteractive():
+			return errors.New("--pause-on-error prompts to skip, retry or abort each failed batch and requires a terminal")
+		}
+	}
+
+	decoders, closeDecoders, err := decodersFromArgs(args)
 	if err != nil {
 		return err
 	}
 
-	defer func(e *error) { *e = errors.Join(*e, closer.Close()) }(&err)
-	defer func(e *error) { *e = errors.Join(*e, decoder.Close()) }(&err)
+	defer func(e *error) { *e = errors.Join(*e, closeDecoders()) }(&err)
 
-	if loadedToken := decoder.ZedToken(); loadedToken != nil {
-		log.Debug().Str("revision", loadedToken.Token).Msg("parsed revision")
+	if loadedToken := decoders[0].ZedToken(); loadedToken != nil {
+		log.Debug().Str("revision", loadedToken.Token).Int("parts", len(decoders)).Msg("parsed revision")
 	}
 
-	schema := decoder.Schema()
+	schema := decoders[0].Schema()
 
 	// Remove any invalid relations generated from old, backwards-incompat
 	// Serverless permission systems.
@@ -385,6 +642,21 @@ func backupRestoreCmdFunc(cmd *cobra.Command, args []string) error {
 		schema = rewriteLegacy(schema)
 	}
 
+	// Rename the object types of the transform map, before filtering, so the
+	// prefix filter applies to the new names.
+	var decoder relationshipDecoder = &multiPartDecoder{decoders}
+	if transformMapFile := cobrautil.MustGetString(cmd, "transform-map"); transformMapFile != "" {
+		transforms, err := readTypeTransformMap(transformMapFile)
+		if err != nil {
+			return err
+		}
+		schema, err = transformSchemaTypes(schema, transforms)
+		if err != nil {
+			return err
+		}
+		decoder = &typeTransformDecoder{decoder, transforms}
+	}
+
 	// Skip any definitions without the provided prefix
 	prefixFilter := cobrautil.MustGetString(cmd, "prefix-filter")
 	if prefixFilter != "" {
@@ -407,11 +679,19 @@ func backupRestoreCmdFunc(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if strategy == Touch {
+		if err := commands.ConfirmDestructive(cmd, "overwrite conflicting relationships with --conflict-strategy=touch"); err != nil {
+			return err
+		}
+	}
 	disableRetries := cobrautil.MustGetBool(cmd, "disable-retries")
 	requestTimeout := cobrautil.MustGetDuration(cmd, "request-timeout")
+	adaptiveBatching := cobrautil.MustGetBool(cmd, "adaptive-batching")
 
 	return newRestorer(schema, decoder, c, prefixFilter, batchSize, batchesPerTransaction, strategy,
-		disableRetries, requestTimeout).restoreFromDecoder(cmd.Context())
+		disableRetries, requestTimeout, pauseOnError, adaptiveBatching, func(operation string) error {
+			return commands.ConfirmDestructive(cmd, operation)
+		}).restoreFromDecoder(cmd.Context())
 }
 
 // GetEnum is a helper for getting an enum value from a string cobra flag.
@@ -451,10 +731,93 @@ func backupParseSchemaCmdFunc(cmd *cobra.Command, out io.Writer, args []string)
 		}
 	}
 
+	if outputFile := cobrautil.MustGetString(cmd, "output-file"); outputFile != "" {
+		if err := os.WriteFile(outputFile, []byte(schema+"\n"), 0o600); err != nil {
+			return fmt.Errorf("failed to write schema to %s: %w", outputFile, err)
+		}
+		return nil
+	}
+
 	_, err = fmt.Fprintln(out, schema)
 	return err
 }
 
+func backupSplitCmdFunc(cmd *cobra.Command, args []string) (err error) {
+	decoder, closer, err := decoderFromArgs(args...)
+	if err != nil {
+		return err
+	}
+
+	defer func(e *error) { *e = errors.Join(*e, closer.Close()) }(&err)
+	defer func(e *error) { *e = errors.Join(*e, decoder.Close()) }(&err)
+
+	schema := decoder.Schema()
+	if cobrautil.MustGetBool(cmd, "rewrite-legacy") {
+		schema = rewriteLegacy(schema)
+	}
+
+	prefixFilter := cobrautil.MustGetString(cmd, "prefix-filter")
+	schema, err = filterSchemaDefs(schema, prefixFilter)
+	if err != nil {
+		return err
+	}
+
+	schemaOut := cobrautil.MustGetString(cmd, "schema-out")
+	if err := os.WriteFile(schemaOut, []byte(schema+"\n"), 0o600); err != nil {
+		return fmt.Errorf("failed to write schema to %s: %w", schemaOut, err)
+	}
+
+	relsOut := cobrautil.MustGetString(cmd, "rels-out")
+	f, err := os.OpenFile(relsOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", relsOut, err)
+	}
+	defer func(e *error) { *e = errors.Join(*e, f.Close()) }(&err)
+
+	asJSON := cobrautil.MustGetBool(cmd, "json")
+	w := bufio.NewWriter(f)
+	var count uint
+	for {
+		rel, err := decoder.Next()
+		if err != nil {
+			return fmt.Errorf("error reading relationships: %w", err)
+		}
+		if rel == nil {
+			break
+		}
+		if !hasRelPrefix(rel, prefixFilter) {
+			continue
+		}
+
+		var line string
+		if asJSON {
+			encoded, err := commands.CompactProto(rel)
+			if err != nil {
+				return err
+			}
+			line = string(encoded)
+		} else {
+			relString, err := tuple.V1StringRelationship(rel)
+			if err != nil {
+				return err
+			}
+			line = replaceRelString(relString)
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return fmt.Errorf("failed to write relationships to %s: %w", relsOut, err)
+		}
+		count++
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write relationships to %s: %w", relsOut, err)
+	}
+
+	log.Info().Str("schema", schemaOut).Str("relationships", relsOut).Uint("count", count).Msg("split backup")
+	return nil
+}
+
 func backupParseRevisionCmdFunc(_ *cobra.Command, out io.Writer, args []string) error {
 	decoder, closer, err := decoderFromArgs(args...)
 	if err != nil {
@@ -566,6 +929,77 @@ func backupRedactCmdFunc(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+var backupCompressionCodecs = map[string]ocf.CodecName{
+	"zstd":    ocf.ZStandard,
+	"snappy":  ocf.Snappy,
+	"deflate": ocf.Deflate,
+}
+
+func backupCompressCmdFunc(cmd *cobra.Command, args []string) (err error) {
+	codec := ocf.Null
+	if !cobrautil.MustGetBool(cmd, "decompress") {
+		value := strings.TrimSpace(strings.ToLower(cobrautil.MustGetString(cmd, "compression")))
+		var ok bool
+		codec, ok = backupCompressionCodecs[value]
+		if !ok {
+			return fmt.Errorf("unexpected flag 'compression' value '%s': should be one of %v", value, maps.Keys(backupCompressionCodecs))
+		}
+	}
+
+	decoder, closer, err := decoderFromArgs(args[0])
+	if err != nil {
+		return err
+	}
+
+	defer func(e *error) { *e = errors.Join(*e, closer.Close()) }(&err)
+	defer func(e *error) { *e = errors.Join(*e, decoder.Close()) }(&err)
+
+	writer, err := createBackupFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	defer func(e *error) { *e = errors.Join(*e, writer.Close()) }(&err)
+
+	encoder, err := backupformat.NewEncoderWithCodec(writer, decoder.Schema(), decoder.ZedToken(), codec)
+	if err != nil {
+		return fmt.Errorf("error creating backup file encoder: %w", err)
+	}
+
+	defer func(e *error) { *e = errors.Join(*e, encoder.Close()) }(&err)
+
+	bar := console.CreateProgressBar("compressing backup")
+	var written int64
+	for {
+		if err := cmd.Context().Err(); err != nil {
+			return fmt.Errorf("aborted compression: %w", err)
+		}
+
+		rel, err := decoder.Next()
+		if err != nil {
+			return fmt.Errorf("error reading relationship: %w", err)
+		} else if rel == nil {
+			break
+		}
+
+		if err := encoder.Append(rel); err != nil {
+			return fmt.Errorf("error writing relationship: %w", err)
+		}
+
+		written++
+		if err := bar.Set64(written); err != nil {
+			return fmt.Errorf("error incrementing progress bar: %w", err)
+		}
+	}
+
+	if err := bar.Finish(); err != nil {
+		return fmt.Errorf("error finalizing progress bar: %w", err)
+	}
+
+	log.Info().Int64("relationships", written).Str("codec", string(codec)).Str("filename", args[1]).Msg("finished rewriting backup")
+	return nil
+}
+
 func backupParseRelsCmdFunc(cmd *cobra.Command, out io.Writer, args []string) error {
 	prefix := cobrautil.MustGetString(cmd, "prefix-filter")
 	decoder, closer, err := decoderFromArgs(args...)
@@ -594,6 +1028,63 @@ func backupParseRelsCmdFunc(cmd *cobra.Command, out io.Writer, args []string) er
 	return nil
 }
 
+func backupToValidationFileCmdFunc(cmd *cobra.Command, out io.Writer, args []string) (err error) {
+	maxRelationships := cobrautil.MustGetUint(cmd, "max-relationships")
+	decoder, closer, err := decoderFromArgs(args...)
+	if err != nil {
+		return err
+	}
+
+	defer func(e *error) { *e = errors.Join(*e, closer.Close()) }(&err)
+	defer func(e *error) { *e = errors.Join(*e, decoder.Close()) }(&err)
+
+	schemaYAML, err := yaml.Marshal(map[string]string{"schema": decoder.Schema()})
+	if err != nil {
+		return err
+	}
+
+	if _, err := out.Write(schemaYAML); err != nil {
+		return err
+	}
+
+	// Relationships are streamed as a block scalar, rather than marshaled,
+	// so that large backups are never held in memory.
+	var relCount uint
+	for {
+		rel, err := decoder.Next()
+		if err != nil {
+			return fmt.Errorf("error reading relationships: %w", err)
+		}
+		if rel == nil {
+			break
+		}
+
+		if maxRelationships > 0 && relCount == maxRelationships {
+			console.Errorf("stopped after %d relationships; the validation file is incomplete\n", maxRelationships)
+			break
+		}
+
+		relString, err := tuple.V1StringRelationship(rel)
+		if err != nil {
+			return err
+		}
+
+		header := ""
+		if relCount == 0 {
+			header = "relationships: |-\n"
+		}
+		if _, err := fmt.Fprintf(out, "%s  %s\n", header, relString); err != nil {
+			return err
+		}
+		relCount++
+	}
+
+	if relCount == 0 {
+		_, err = fmt.Fprintln(out, `relationships: ""`)
+	}
+	return err
+}
+
 func decoderFromArgs(args ...string) (*backupformat.Decoder, io.Closer, error) {
 	filename := "" // Default to stdin.
 	if len(args) > 0 {
@@ -613,6 +1104,96 @@ func decoderFromArgs(args ...string) (*backupformat.Decoder, io.Closer, error) {
 	return decoder, f, nil
 }
 
+// backupPartsFromArgs expands any glob patterns in the given arguments, e.g.
+// "name.*.zedbackup", into the backup files they match.
+func backupPartsFromArgs(args []string) ([]string, error) {
+	filenames := make([]string, 0, len(args))
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup file pattern %q: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			// Not a pattern, or one without matches: surface the error on open.
+			matches = []string{arg}
+		}
+		filenames = append(filenames, matches...)
+	}
+	return filenames, nil
+}
+
+// decodersFromArgs opens a decoder for each backup file given in the
+// arguments, or for stdin when there are none, and verifies that they belong
+// to the same backup. The returned function closes all of them.
+func decodersFromArgs(args []string) ([]*backupformat.Decoder, func() error, error) {
+	filenames, err := backupPartsFromArgs(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var closers []func() error
+	closeAll := func() error {
+		var err error
+		for _, closer := range closers {
+			err = errors.Join(err, closer())
+		}
+		return err
+	}
+
+	if len(filenames) == 0 {
+		filenames = []string{""} // Default to stdin.
+	}
+
+	decoders := make([]*backupformat.Decoder, 0, len(filenames))
+	for _, filename := range filenames {
+		decoder, closer, err := decoderFromArgs(filename)
+		if err != nil {
+			return nil, nil, errors.Join(err, closeAll())
+		}
+
+		decoders = append(decoders, decoder)
+		closers = append(closers, decoder.Close, closer.Close)
+	}
+
+	if err := verifyBackupParts(filenames, decoders); err != nil {
+		return nil, nil, errors.Join(err, closeAll())
+	}
+	return decoders, closeAll, nil
+}
+
+// verifyBackupParts ensures that all parts of a backup were taken at the same
+// revision with the same schema, so that no relationships get written from a
+// mismatched set of files.
+func verifyBackupParts(filenames []string, decoders []*backupformat.Decoder) error {
+	first := decoders[0]
+	for i, decoder := range decoders[1:] {
+		if decoder.ZedToken().GetToken() != first.ZedToken().GetToken() {
+			return fmt.Errorf("backup file %s was taken at revision %q, but %s was taken at revision %q",
+				filenames[i+1], decoder.ZedToken().GetToken(), filenames[0], first.ZedToken().GetToken())
+		}
+		if decoder.Schema() != first.Schema() {
+			return fmt.Errorf("backup file %s has a different schema than %s", filenames[i+1], filenames[0])
+		}
+	}
+	return nil
+}
+
+// multiPartDecoder reads the relationships of each decoder in turn.
+type multiPartDecoder struct {
+	decoders []*backupformat.Decoder
+}
+
+func (d *multiPartDecoder) Next() (*v1.Relationship, error) {
+	for len(d.decoders) > 0 {
+		rel, err := d.decoders[0].Next()
+		if rel != nil || err != nil {
+			return rel, err
+		}
+		d.decoders = d.decoders[1:]
+	}
+	return nil, nil
+}
+
 func replaceRelString(rel string) string {
 	rel = strings.Replace(rel, "@", " ", 1)
 	return strings.Replace(rel, "#", " ", 1)
@@ -623,8 +1204,6 @@ func rewriteLegacy(schema string) string {
 	return string(shortRelations.ReplaceAll([]byte(schema), []byte("\n/* deleted short relation name */")))
 }
 
-var sizeErrorRegEx = regexp.MustCompile(`received message larger than max \((\d+) vs. (\d+)\)`)
-
 func addSizeErrInfo(err error) error {
 	if err == nil {
 		return nil
@@ -639,13 +1218,8 @@ func addSizeErrInfo(err error) error {
 		return err
 	}
 
-	matches := sizeErrorRegEx.FindStringSubmatch(err.Error())
-	if len(matches) != 3 {
-		return fmt.Errorf("%w: set flag --max-message-size=bytecounthere to increase the maximum allowable size", err)
-	}
-
-	necessaryByteCount, err := strconv.Atoi(matches[1])
-	if err != nil {
+	necessaryByteCount, ok := grpcutil.NecessaryMessageSize(err)
+	if !ok {
 		return fmt.Errorf("%w: set flag --max-message-size=bytecounthere to increase the maximum allowable size", err)
 	}
 
@@ -0,0 +1,362 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/pkg/backupformat"
+)
+
+var backupRestoreIncrementalCmd = &cobra.Command{
+	Use:   "restore-incremental <filename>...",
+	Short: "Apply incremental backups on top of a restored backup",
+	Long: `Apply the changes recorded in incremental backups, created with "backup create --since", in the order given.
+
+Each incremental backup must have been created since the revision of the one before it, and the first
+since the revision of the backup the permissions system was restored from. The schema recorded in each
+incremental backup is written after the relationships it deletes are deleted, and before the ones it
+creates or touches are written.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: backupRestoreIncrementalCmdFunc,
+}
+
+// sinceRevision returns the revision of the backup file at the given path,
+// or the given value as a zedtoken if there is no such file.
+func sinceRevision(value string) (*v1.ZedToken, error) {
+	f, err := os.Open(value)
+	if errors.Is(err, os.ErrNotExist) {
+		return &v1.ZedToken{Token: value}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to open backup file: %w", err)
+	}
+	defer f.Close()
+
+	decoder, err := backupformat.NewDecoder(f)
+	if errors.Is(err, backupformat.ErrIncrementalBackup) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("unable to read backup file: %w", err)
+		}
+
+		incrementalDecoder, err := backupformat.NewIncrementalDecoder(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read revision of %s: %w", value, err)
+		}
+		return incrementalDecoder.ZedToken(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read revision of %s: %w", value, err)
+	}
+
+	if decoder.ZedToken() == nil {
+		return nil, fmt.Errorf("backup file %s has no revision", value)
+	}
+	return decoder.ZedToken(), nil
+}
+
+func backupCreateIncrementalCmdFunc(cmd *cobra.Command, filename, sinceArg string) (err error) {
+	since, err := sinceRevision(sinceArg)
+	if err != nil {
+		return err
+	}
+
+	c, err := client.NewClient(cmd)
+	if err != nil {
+		return fmt.Errorf("unable to initialize client: %w", err)
+	}
+
+	ctx := cmd.Context()
+	schemaResp, err := c.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+	if err != nil {
+		return fmt.Errorf("error reading schema: %w", addSizeErrInfo(err))
+	}
+	schema := schemaResp.SchemaText
+
+	// Remove any invalid relations generated from old, backwards-incompat
+	// Serverless permission systems.
+	if cobrautil.MustGetBool(cmd, "rewrite-legacy") {
+		schema = rewriteLegacy(schema)
+	}
+
+	// Skip any definitions without the provided prefix
+	prefixFilter := cobrautil.MustGetString(cmd, "prefix-filter")
+	if prefixFilter != "" {
+		schema, err = filterSchemaDefs(schema, prefixFilter)
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := createBackupFile(filename)
+	if err != nil {
+		return err
+	}
+	defer func(e *error) { *e = errors.Join(*e, out.Close()) }(&err)
+
+	// The revision is written into the header once every update is, so a
+	// backup to a pipe such as stdout is first written to a temporary file.
+	f := out
+	if _, err := out.Seek(0, io.SeekCurrent); err != nil {
+		f, err = os.CreateTemp("", "zed-incremental-backup-")
+		if err != nil {
+			return fmt.Errorf("unable to create temporary backup file: %w", err)
+		}
+		defer func() {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}()
+	}
+
+	encoder, err := backupformat.NewIncrementalEncoder(f, schema, since)
+	if err != nil {
+		return fmt.Errorf("error creating backup file encoder: %w", err)
+	}
+
+	updates, through, err := watchChangesSince(ctx, c, since, cobrautil.MustGetDuration(cmd, "since-idle-timeout"), cobrautil.MustGetDuration(cmd, "since-max-duration"), prefixFilter, encoder.Append)
+	if err != nil {
+		return err
+	}
+
+	encoder.SetZedToken(through)
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("error storing relationship updates: %w", err)
+	}
+
+	if f != out {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to read temporary backup file: %w", err)
+		}
+		if _, err := io.Copy(out, f); err != nil {
+			return fmt.Errorf("unable to write backup: %w", err)
+		}
+	} else if err := out.Sync(); err != nil {
+		return fmt.Errorf("unable to write backup: %w", err)
+	}
+
+	log.Info().
+		Uint("updates", updates).
+		Str("since", since.Token).
+		Str("through", through.Token).
+		Msg("finished incremental backup")
+
+	return nil
+}
+
+// watchChangesSince passes the relationship updates made after the since
+// revision to appendUpdate as they are received, in order, and returns how
+// many there were and the revision through which they were received. As
+// watching never completes, it stops once no changes have been received for
+// idleTimeout, or once it has been watching for maxDuration; later changes
+// are left to the next incremental backup.
+func watchChangesSince(ctx context.Context, c client.Client, since *v1.ZedToken, idleTimeout, maxDuration time.Duration, prefixFilter string, appendUpdate func(*v1.RelationshipUpdate) error) (uint, *v1.ZedToken, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	request := &v1.WatchRequest{OptionalStartCursor: since}
+	log.Trace().Interface("request", request).Msg("watching for changes")
+	stream, err := c.Watch(ctx, request)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error watching for changes: %w", err)
+	}
+
+	type watchResult struct {
+		resp *v1.WatchResponse
+		err  error
+	}
+	results := make(chan watchResult)
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			select {
+			case results <- watchResult{resp, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var updates uint
+	through := since
+
+	// When nothing has changed since the revision, the server sends nothing,
+	// so the idle timer starts before the first response.
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+	deadline := time.NewTimer(maxDuration)
+	defer deadline.Stop()
+	for {
+		select {
+		case result := <-results:
+			switch {
+			case errors.Is(result.err, io.EOF):
+				return updates, through, nil
+			case result.err != nil:
+				return 0, nil, fmt.Errorf("error receiving changes: %w", result.err)
+			}
+
+			for _, update := range result.resp.Updates {
+				if !hasRelPrefix(update.Relationship, prefixFilter) {
+					continue
+				}
+				if err := appendUpdate(update); err != nil {
+					return 0, nil, fmt.Errorf("error storing relationship update: %w", err)
+				}
+				updates++
+			}
+			if result.resp.ChangesThrough != nil {
+				through = result.resp.ChangesThrough
+			}
+			idle.Reset(idleTimeout)
+
+		case <-idle.C:
+			return updates, through, nil
+
+		case <-deadline.C:
+			return updates, through, nil
+
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		}
+	}
+}
+
+func backupRestoreIncrementalCmdFunc(cmd *cobra.Command, args []string) (err error) {
+	filenames, err := backupPartsFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	decoders := make([]*backupformat.IncrementalDecoder, 0, len(filenames))
+	for i, filename := range filenames {
+		f, _, err := openRestoreFile(filename)
+		if err != nil {
+			return err
+		}
+		defer func(e *error) { *e = errors.Join(*e, f.Close()) }(&err)
+
+		decoder, err := backupformat.NewIncrementalDecoder(f)
+		if err != nil {
+			return fmt.Errorf("error creating restore file decoder for %s: %w", filename, err)
+		}
+
+		if i > 0 && decoder.SinceZedToken().Token != decoders[i-1].ZedToken().Token {
+			return fmt.Errorf("incremental backup %s was taken since revision %q, but %s was taken through revision %q",
+				filename, decoder.SinceZedToken().Token, filenames[i-1], decoders[i-1].ZedToken().Token)
+		}
+		decoders = append(decoders, decoder)
+	}
+
+	c, err := client.NewClient(cmd)
+	if err != nil {
+		return fmt.Errorf("unable to initialize client: %w", err)
+	}
+
+	batchSize := cobrautil.MustGetUint(cmd, "batch-size")
+	prefixFilter := cobrautil.MustGetString(cmd, "prefix-filter")
+	for i, decoder := range decoders {
+		applied, err := applyIncrementalBackup(cmd.Context(), c, decoder, batchSize, prefixFilter)
+		if err != nil {
+			return fmt.Errorf("error applying incremental backup %s: %w", filenames[i], err)
+		}
+
+		log.Info().
+			Str("file", filenames[i]).
+			Uint("updates", applied).
+			Str("through", decoder.ZedToken().Token).
+			Msg("applied incremental backup")
+	}
+
+	return nil
+}
+
+// applyIncrementalBackup applies the relationship updates of the incremental
+// backup in batches, along with its schema. Only the last update to each
+// relationship is kept, so that its deletes can be applied before the schema
+// is written, in case the schema no longer allows the deleted relationships,
+// and its creates and touches after. Creates are applied as touches, so that
+// an incremental backup can be applied more than once.
+func applyIncrementalBackup(ctx context.Context, c client.Client, decoder *backupformat.IncrementalDecoder, batchSize uint, prefixFilter string) (uint, error) {
+	schema, err := filterSchemaDefs(decoder.Schema(), prefixFilter)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []string
+	last := make(map[string]*v1.RelationshipUpdate)
+	for {
+		update, err := decoder.Next()
+		if err != nil {
+			return 0, err
+		}
+		if update == nil {
+			break
+		}
+
+		if !hasRelPrefix(update.Relationship, prefixFilter) {
+			continue
+		}
+		if update.Operation == v1.RelationshipUpdate_OPERATION_CREATE {
+			update.Operation = v1.RelationshipUpdate_OPERATION_TOUCH
+		}
+
+		key := tuple.V1StringRelationshipWithoutCaveatOrExpiration(update.Relationship)
+		if _, ok := last[key]; !ok {
+			keys = append(keys, key)
+		}
+		last[key] = update
+	}
+
+	var applied uint
+	writeUpdates := func(operation v1.RelationshipUpdate_Operation) error {
+		batch := make([]*v1.RelationshipUpdate, 0, batchSize)
+		writeBatch := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+
+			request := &v1.WriteRelationshipsRequest{Updates: batch}
+			log.Trace().Interface("request", request).Msg("writing relationship updates")
+			if _, err := c.WriteRelationships(ctx, request); err != nil {
+				return err
+			}
+
+			applied += uint(len(batch))
+			batch = make([]*v1.RelationshipUpdate, 0, batchSize)
+			return nil
+		}
+
+		for _, key := range keys {
+			if update := last[key]; update.Operation == operation {
+				batch = append(batch, update)
+				if uint(len(batch)) == batchSize {
+					if err := writeBatch(); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return writeBatch()
+	}
+
+	if err := writeUpdates(v1.RelationshipUpdate_OPERATION_DELETE); err != nil {
+		return applied, err
+	}
+
+	if _, err := c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: schema}); err != nil {
+		return applied, fmt.Errorf("unable to write schema: %w", err)
+	}
+
+	return applied, writeUpdates(v1.RelationshipUpdate_OPERATION_TOUCH)
+}
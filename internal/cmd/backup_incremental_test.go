@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/zed/internal/client"
+	zedtesting "github.com/authzed/zed/internal/testing"
+	"github.com/authzed/zed/pkg/backupformat"
+)
+
+func newIncrementalTestClient(ctx context.Context, t *testing.T) client.Client {
+	t.Helper()
+
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	c, err := zedtesting.ClientFromConn(conn)(nil)
+	require.NoError(t, err)
+	client.NewClient = zedtesting.ClientFromConn(conn)
+	return c
+}
+
+func writeIncrementalTestRelationships(ctx context.Context, t *testing.T, c client.Client, operation v1.RelationshipUpdate_Operation, rels ...string) *v1.ZedToken {
+	t.Helper()
+
+	updates := make([]*v1.RelationshipUpdate, 0, len(rels))
+	for _, rel := range rels {
+		updates = append(updates, &v1.RelationshipUpdate{Operation: operation, Relationship: tuple.MustParseV1Rel(rel)})
+	}
+	resp, err := c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+	require.NoError(t, err)
+	return resp.WrittenAt
+}
+
+func readIncrementalTestRelationships(ctx context.Context, t *testing.T, c client.Client, resourceType string) []string {
+	t.Helper()
+
+	resp, err := c.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+		Consistency:        &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: resourceType},
+	})
+	require.NoError(t, err)
+	var rels []string
+	for {
+		rel, err := resp.Recv()
+		if err != nil {
+			break
+		}
+		rels = append(rels, tuple.MustV1StringRelationship(rel.Relationship))
+	}
+	return rels
+}
+
+func createIncrementalTestBackup(t *testing.T, since *v1.ZedToken) string {
+	t.Helper()
+
+	backupName := filepath.Join(t.TempDir(), "incremental.zedbackup")
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "prefix-filter"},
+		zedtesting.BoolFlag{FlagName: "rewrite-legacy"},
+		zedtesting.StringFlag{FlagName: "split-size"},
+		zedtesting.StringFlag{FlagName: "since", FlagValue: since.Token},
+		zedtesting.DurationFlag{FlagName: "since-idle-timeout", FlagValue: 500 * time.Millisecond},
+		zedtesting.DurationFlag{FlagName: "since-max-duration", FlagValue: time.Minute},
+		zedtesting.UintFlag{FlagName: "min-relationships"})
+	require.NoError(t, backupCreateCmdFunc(cmd, []string{backupName}))
+	return backupName
+}
+
+func restoreIncrementalTestBackup(t *testing.T, backupName string) {
+	t.Helper()
+
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.UintFlag{FlagName: "batch-size", FlagValue: 1},
+		zedtesting.StringFlag{FlagName: "prefix-filter"})
+	require.NoError(t, backupRestoreIncrementalCmdFunc(cmd, []string{backupName}))
+}
+
+func TestBackupCreateAndRestoreIncremental(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	// Take an incremental backup of the changes made after a first write.
+	source := newIncrementalTestClient(ctx, t)
+	_, err := source.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+	since := writeIncrementalTestRelationships(ctx, t, source, v1.RelationshipUpdate_OPERATION_TOUCH, "test/resource:1#reader@test/user:1", "test/resource:1#reader@test/user:2")
+	writeIncrementalTestRelationships(ctx, t, source, v1.RelationshipUpdate_OPERATION_TOUCH, "test/resource:2#reader@test/user:3")
+	writeIncrementalTestRelationships(ctx, t, source, v1.RelationshipUpdate_OPERATION_DELETE, "test/resource:1#reader@test/user:1")
+
+	backupName := createIncrementalTestBackup(t, since)
+
+	f, err := os.Open(backupName)
+	require.NoError(t, err)
+	defer f.Close()
+	decoder, err := backupformat.NewIncrementalDecoder(f)
+	require.NoError(t, err)
+	require.Equal(t, since.Token, decoder.SinceZedToken().Token)
+
+	var changes []string
+	for {
+		update, err := decoder.Next()
+		require.NoError(t, err)
+		if update == nil {
+			break
+		}
+		changes = append(changes, update.Operation.String()+" "+tuple.MustV1StringRelationship(update.Relationship))
+	}
+	require.Equal(t, []string{
+		"OPERATION_TOUCH test/resource:2#reader@test/user:3",
+		"OPERATION_DELETE test/resource:1#reader@test/user:1",
+	}, changes)
+
+	revision, err := sinceRevision(backupName)
+	require.NoError(t, err)
+	require.Equal(t, decoder.ZedToken().Token, revision.Token)
+
+	// Apply it on top of a permissions system restored to the first write.
+	target := newIncrementalTestClient(ctx, t)
+	_, err = target.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+	writeIncrementalTestRelationships(ctx, t, target, v1.RelationshipUpdate_OPERATION_TOUCH, "test/resource:1#reader@test/user:1", "test/resource:1#reader@test/user:2")
+
+	restoreIncrementalTestBackup(t, backupName)
+
+	require.ElementsMatch(t, []string{"test/resource:1#reader@test/user:2", "test/resource:2#reader@test/user:3"},
+		readIncrementalTestRelationships(ctx, t, target, "test/resource"))
+}
+
+func TestBackupCreateIncrementalWithoutChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	source := newIncrementalTestClient(ctx, t)
+	_, err := source.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+	since := writeIncrementalTestRelationships(ctx, t, source, v1.RelationshipUpdate_OPERATION_TOUCH, "test/resource:1#reader@test/user:1")
+
+	backupName := createIncrementalTestBackup(t, since)
+
+	f, err := os.Open(backupName)
+	require.NoError(t, err)
+	defer f.Close()
+	decoder, err := backupformat.NewIncrementalDecoder(f)
+	require.NoError(t, err)
+	require.Equal(t, since.Token, decoder.SinceZedToken().Token)
+	require.Equal(t, since.Token, decoder.ZedToken().Token)
+
+	update, err := decoder.Next()
+	require.NoError(t, err)
+	require.Nil(t, update)
+}
+
+func TestBackupRestoreIncrementalSchemaRemovesRelation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	schemaWithWriter := `definition test/resource {
+	relation reader: test/user
+	relation writer: test/user
+}
+
+definition test/user {}`
+	initial := []string{"test/resource:1#reader@test/user:1", "test/resource:1#writer@test/user:2"}
+
+	// Drop the writer relation after the revision of the backup.
+	source := newIncrementalTestClient(ctx, t)
+	_, err := source.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: schemaWithWriter})
+	require.NoError(t, err)
+	since := writeIncrementalTestRelationships(ctx, t, source, v1.RelationshipUpdate_OPERATION_TOUCH, initial...)
+	writeIncrementalTestRelationships(ctx, t, source, v1.RelationshipUpdate_OPERATION_DELETE, "test/resource:1#writer@test/user:2")
+	_, err = source.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+	writeIncrementalTestRelationships(ctx, t, source, v1.RelationshipUpdate_OPERATION_TOUCH, "test/resource:2#reader@test/user:3")
+
+	backupName := createIncrementalTestBackup(t, since)
+
+	target := newIncrementalTestClient(ctx, t)
+	_, err = target.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: schemaWithWriter})
+	require.NoError(t, err)
+	writeIncrementalTestRelationships(ctx, t, target, v1.RelationshipUpdate_OPERATION_TOUCH, initial...)
+
+	restoreIncrementalTestBackup(t, backupName)
+
+	resp, err := target.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+	require.NoError(t, err)
+	require.NotContains(t, resp.SchemaText, "writer")
+	require.ElementsMatch(t, []string{"test/resource:1#reader@test/user:1", "test/resource:2#reader@test/user:3"},
+		readIncrementalTestRelationships(ctx, t, target, "test/resource"))
+}
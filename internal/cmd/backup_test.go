@@ -3,6 +3,8 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,12 +15,16 @@ import (
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/validationfile"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 
 	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/commands"
+	"github.com/authzed/zed/internal/console"
 	zedtesting "github.com/authzed/zed/internal/testing"
+	"github.com/authzed/zed/pkg/backupformat"
 )
 
 func init() {
@@ -247,7 +253,8 @@ func TestBackupParseSchemaCmdFunc(t *testing.T) {
 
 			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
 				zedtesting.StringFlag{FlagName: "prefix-filter", FlagValue: tt.filter},
-				zedtesting.BoolFlag{FlagName: "rewrite-legacy", FlagValue: tt.rewriteLegacy})
+				zedtesting.BoolFlag{FlagName: "rewrite-legacy", FlagValue: tt.rewriteLegacy},
+				zedtesting.StringFlag{FlagName: "output-file"})
 			backupName := createTestBackup(t, tt.schema, nil)
 			f, err := os.CreateTemp("", "parse-output")
 			require.NoError(t, err)
@@ -267,10 +274,119 @@ func TestBackupParseSchemaCmdFunc(t *testing.T) {
 	}
 }
 
+func TestBackupParseSchemaOutputFile(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "schema.zed")
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "prefix-filter", FlagValue: "test"},
+		zedtesting.BoolFlag{FlagName: "rewrite-legacy"},
+		zedtesting.StringFlag{FlagName: "output-file", FlagValue: outputFile})
+	backupName := createTestBackup(t, "definition test/user {}\n\ndefinition foo/user {}", nil)
+
+	var out strings.Builder
+	err := backupParseSchemaCmdFunc(cmd, &out, []string{backupName})
+	require.NoError(t, err)
+	require.Empty(t, out.String())
+
+	require.Equal(t, []string{"definition test/user {}"}, readLines(t, outputFile))
+	info, err := os.Stat(outputFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestBackupSplitCmdFunc(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		json         bool
+		expectedRels []string
+	}{
+		{
+			name:         "text",
+			expectedRels: mapRelationshipTuplesToCLIOutput(t, testRelationships),
+		},
+		{
+			name: "json",
+			json: true,
+			expectedRels: []string{
+				`{"resource":{"objectType":"test/resource","objectId":"1"},"relation":"reader","subject":{"object":{"objectType":"test/user","objectId":"1"}}}`,
+				`{"resource":{"objectType":"test/resource","objectId":"2"},"relation":"reader","subject":{"object":{"objectType":"test/user","objectId":"2"}}}`,
+				`{"resource":{"objectType":"test/resource","objectId":"3"},"relation":"reader","subject":{"object":{"objectType":"test/user","objectId":"3"}}}`,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			schemaOut := filepath.Join(dir, "schema.zed")
+			relsOut := filepath.Join(dir, "rels.jsonl")
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.StringFlag{FlagName: "schema-out", FlagValue: schemaOut},
+				zedtesting.StringFlag{FlagName: "rels-out", FlagValue: relsOut},
+				zedtesting.StringFlag{FlagName: "prefix-filter", FlagValue: "test"},
+				zedtesting.BoolFlag{FlagName: "rewrite-legacy"},
+				zedtesting.BoolFlag{FlagName: "json", FlagValue: tt.json})
+			backupName := createTestBackup(t, testSchema+"\n\ndefinition foo/user {}",
+				append([]string{"foo/user:0#reader@foo/user:1"}, testRelationships...))
+
+			require.NoError(t, backupSplitCmdFunc(cmd, []string{backupName}))
+			require.Equal(t, strings.Split(testSchema, "\n"), readLines(t, schemaOut))
+			require.Equal(t, tt.expectedRels, readLines(t, relsOut))
+		})
+	}
+}
+
+func TestBackupToValidationFileCmdFunc(t *testing.T) {
+	for _, tt := range []struct {
+		name             string
+		relationships    []string
+		maxRelationships uint
+		expected         []string
+	}{
+		{
+			name:          "all relationships",
+			relationships: testRelationships,
+			expected:      testRelationships,
+		},
+		{
+			name:             "capped relationships",
+			relationships:    testRelationships,
+			maxRelationships: 2,
+			expected:         testRelationships[:2],
+		},
+		{
+			name:          "no relationships",
+			relationships: nil,
+			expected:      nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.UintFlag{FlagName: "max-relationships", FlagValue: tt.maxRelationships})
+			backupName := createTestBackup(t, testSchema, tt.relationships)
+
+			var out strings.Builder
+			require.NoError(t, backupToValidationFileCmdFunc(cmd, &out, []string{backupName}))
+
+			parsed, err := validationfile.DecodeValidationFile([]byte(out.String()))
+			require.NoError(t, err)
+			require.Equal(t, testSchema, parsed.Schema.Schema)
+
+			var relStrings []string
+			for _, rel := range parsed.Relationships.Relationships {
+				relStrings = append(relStrings, tuple.MustString(rel))
+			}
+			require.Equal(t, tt.expected, relStrings)
+		})
+	}
+}
+
 func TestBackupCreateCmdFunc(t *testing.T) {
 	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
 		zedtesting.StringFlag{FlagName: "prefix-filter"},
-		zedtesting.BoolFlag{FlagName: "rewrite-legacy"})
+		zedtesting.BoolFlag{FlagName: "rewrite-legacy"},
+		zedtesting.StringFlag{FlagName: "split-size"},
+		zedtesting.StringFlag{FlagName: "since"},
+		zedtesting.DurationFlag{FlagName: "since-idle-timeout"},
+		zedtesting.DurationFlag{FlagName: "since-max-duration"},
+		zedtesting.UintFlag{FlagName: "min-relationships"})
 	f := filepath.Join(os.TempDir(), uuid.NewString())
 	_, err := os.Stat(f)
 	require.Error(t, err)
@@ -311,8 +427,18 @@ func TestBackupCreateCmdFunc(t *testing.T) {
 	})
 	require.NoError(t, err)
 
+	previousErrorf := console.Errorf
+	defer func() {
+		console.Errorf = previousErrorf
+	}()
+	var progressLines []string
+	console.Errorf = func(format string, a ...any) {
+		progressLines = append(progressLines, fmt.Sprintf(format, a...))
+	}
+
 	err = backupCreateCmdFunc(cmd, []string{f})
 	require.NoError(t, err)
+	require.Equal(t, []string{"backed up test/resource: 1 relationship\n"}, progressLines)
 
 	d, closer, err := decoderFromArgs(f)
 	require.NoError(t, err)
@@ -326,17 +452,228 @@ func TestBackupCreateCmdFunc(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, testRel, tuple.MustV1StringRelationship(rel))
 	require.Equal(t, resp.WrittenAt.Token, d.ZedToken().Token)
+
+	// A backup with fewer relationships than --min-relationships fails and
+	// is deleted.
+	require.NoError(t, cmd.Flags().Set("min-relationships", "2"))
+	guarded := filepath.Join(t.TempDir(), "guarded.zedbackup")
+	err = backupCreateCmdFunc(cmd, []string{guarded})
+	require.ErrorContains(t, err, "only 1 relationship exported, fewer than --min-relationships 2")
+	require.NoFileExists(t, guarded)
+
+	require.NoError(t, cmd.Flags().Set("split-size", "1B"))
+	err = backupCreateCmdFunc(cmd, []string{guarded})
+	require.ErrorContains(t, err, "fewer than --min-relationships 2")
+	require.NoFileExists(t, backupPartFilename(guarded, 1))
+}
+
+func TestBackupPartFilename(t *testing.T) {
+	require.Equal(t, "backup.0001.zedbackup", backupPartFilename("backup.zedbackup", 1))
+	require.Equal(t, "backup.0012.zedbackup", backupPartFilename("backup", 12))
+	require.Equal(t, "dir/backup.tar.0002.zedbackup", backupPartFilename("dir/backup.tar", 2))
+}
+
+func TestBackupCreateCmdFuncSplit(t *testing.T) {
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "prefix-filter"},
+		zedtesting.BoolFlag{FlagName: "rewrite-legacy"},
+		zedtesting.StringFlag{FlagName: "transform-map"},
+		zedtesting.StringFlag{FlagName: "split-size", FlagValue: "1B"},
+		zedtesting.StringFlag{FlagName: "since"},
+		zedtesting.DurationFlag{FlagName: "since-idle-timeout"},
+		zedtesting.DurationFlag{FlagName: "since-max-duration"},
+		zedtesting.UintFlag{FlagName: "min-relationships"},
+		zedtesting.StringFlag{FlagName: "conflict-strategy", FlagValue: "fail"},
+		zedtesting.BoolFlag{FlagName: "disable-retries"},
+		zedtesting.UintFlag{FlagName: "batch-size", FlagValue: 100},
+		zedtesting.UintFlag{FlagName: "batches-per-transaction", FlagValue: 10},
+		zedtesting.DurationFlag{FlagName: "request-timeout"},
+		zedtesting.BoolFlag{FlagName: "pause-on-error"},
+		zedtesting.BoolFlag{FlagName: "adaptive-batching"},
+	)
+	backupName := filepath.Join(t.TempDir(), "backup.zedbackup")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	client.NewClient = zedtesting.ClientFromConn(conn)
+
+	c, err := zedtesting.ClientFromConn(conn)(cmd)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	updates := make([]*v1.RelationshipUpdate, 0, len(testRelationships))
+	for _, rel := range testRelationships {
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.MustParseV1Rel(rel),
+		})
+	}
+	resp, err := c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+	require.NoError(t, err)
+
+	err = backupCreateCmdFunc(cmd, []string{backupName})
+	require.NoError(t, err)
+	require.NoFileExists(t, backupName)
+
+	// Any part larger than a byte is rolled over, so each relationship ends
+	// up in a part of its own, which carries the schema and revision.
+	for i, expectedRel := range testRelationships {
+		partName := backupPartFilename(backupName, i+1)
+		d, closer, err := decoderFromArgs(partName)
+		require.NoError(t, err)
+
+		require.Equal(t, testSchema, d.Schema())
+		require.Equal(t, resp.WrittenAt.Token, d.ZedToken().Token)
+
+		rel, err := d.Next()
+		require.NoError(t, err)
+		require.Equal(t, expectedRel, tuple.MustV1StringRelationship(rel))
+
+		rel, err = d.Next()
+		require.NoError(t, err)
+		require.Nil(t, rel)
+
+		require.NoError(t, d.Close())
+		require.NoError(t, closer.Close())
+	}
+	require.NoFileExists(t, backupPartFilename(backupName, len(testRelationships)+1))
+
+	// The parts can be restored together from a glob.
+	_, err = c.DeleteRelationships(ctx, &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: "test/resource"},
+	})
+	require.NoError(t, err)
+
+	err = backupRestoreCmdFunc(cmd, []string{filepath.Join(filepath.Dir(backupName), "backup.*.zedbackup")})
+	require.NoError(t, err)
+	assertRelationshipsRestored(ctx, t, c, testRelationships)
+}
+
+func TestDecodersFromArgs(t *testing.T) {
+	writePart := func(t *testing.T, dir, name, schema, revision string, rels ...string) string {
+		filename := filepath.Join(dir, name)
+		f, err := os.Create(filename)
+		require.NoError(t, err)
+		defer f.Close()
+
+		encoder, err := backupformat.NewEncoder(f, schema, &v1.ZedToken{Token: revision})
+		require.NoError(t, err)
+		for _, rel := range rels {
+			require.NoError(t, encoder.Append(tuple.MustParseV1Rel(rel)))
+		}
+		require.NoError(t, encoder.Close())
+		return filename
+	}
+
+	for _, tt := range []struct {
+		name        string
+		parts       func(t *testing.T, dir string) []string
+		expectedErr string
+	}{
+		{
+			name: "matching parts",
+			parts: func(t *testing.T, dir string) []string {
+				writePart(t, dir, "b.0001.zedbackup", testSchema, "rev", testRelationships[0])
+				writePart(t, dir, "b.0002.zedbackup", testSchema, "rev", testRelationships[1:]...)
+				return []string{filepath.Join(dir, "b.*.zedbackup")}
+			},
+		},
+		{
+			name: "mismatched revision",
+			parts: func(t *testing.T, dir string) []string {
+				return []string{
+					writePart(t, dir, "b.0001.zedbackup", testSchema, "rev", testRelationships[0]),
+					writePart(t, dir, "c.0001.zedbackup", testSchema, "other", testRelationships[1:]...),
+				}
+			},
+			expectedErr: `c.0001.zedbackup was taken at revision "other"`,
+		},
+		{
+			name: "mismatched schema",
+			parts: func(t *testing.T, dir string) []string {
+				return []string{
+					writePart(t, dir, "b.0001.zedbackup", testSchema, "rev", testRelationships[0]),
+					writePart(t, dir, "c.0001.zedbackup", "definition test/user {}", "rev", testRelationships[1:]...),
+				}
+			},
+			expectedErr: "c.0001.zedbackup has a different schema",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			decoders, closeDecoders, err := decodersFromArgs(tt.parts(t, t.TempDir()))
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, closeDecoders())
+			}()
+
+			decoder := &multiPartDecoder{decoders}
+			var rels []string
+			for rel, err := decoder.Next(); rel != nil; rel, err = decoder.Next() {
+				require.NoError(t, err)
+				rels = append(rels, tuple.MustV1StringRelationship(rel))
+			}
+			require.Equal(t, testRelationships, rels)
+		})
+	}
+}
+
+func assertRelationshipsRestored(ctx context.Context, t *testing.T, c client.Client, expected []string) {
+	t.Helper()
+
+	rrCli, err := c.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_FullyConsistent{
+				FullyConsistent: true,
+			},
+		},
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType: "test/resource",
+		},
+	})
+	require.NoError(t, err)
+
+	var restored []string
+	for {
+		rrResp, err := rrCli.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		restored = append(restored, tuple.MustV1StringRelationship(rrResp.Relationship))
+	}
+	require.ElementsMatch(t, expected, restored)
 }
 
 func TestBackupRestoreCmdFunc(t *testing.T) {
 	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
 		zedtesting.StringFlag{FlagName: "prefix-filter", FlagValue: "test"},
 		zedtesting.BoolFlag{FlagName: "rewrite-legacy"},
+		zedtesting.StringFlag{FlagName: "transform-map"},
 		zedtesting.StringFlag{FlagName: "conflict-strategy", FlagValue: "fail"},
 		zedtesting.BoolFlag{FlagName: "disable-retries"},
 		zedtesting.UintFlag{FlagName: "batch-size", FlagValue: 100},
 		zedtesting.UintFlag{FlagName: "batches-per-transaction", FlagValue: 10},
 		zedtesting.DurationFlag{FlagName: "request-timeout"},
+		zedtesting.BoolFlag{FlagName: "pause-on-error"},
+		zedtesting.BoolFlag{FlagName: "adaptive-batching"},
 	)
 	backupName := createTestBackup(t, testSchema, testRelationships)
 
@@ -384,6 +721,79 @@ func TestBackupRestoreCmdFunc(t *testing.T) {
 	require.Equal(t, "test/resource:1#reader@test/user:1", tuple.MustV1StringRelationship(rrResp.Relationship))
 }
 
+func TestBackupRestoreCmdFuncPauseOnErrorPrompt(t *testing.T) {
+	previousInteractive := commands.ConfirmInteractive
+	defer func() {
+		commands.ConfirmInteractive = previousInteractive
+	}()
+	backupName := createTestBackup(t, testSchema, testRelationships)
+
+	for _, tt := range []struct {
+		name        string
+		yes         bool
+		interactive bool
+		expectedErr string
+	}{
+		{"with --yes", true, true, "cannot be answered by --yes"},
+		{"without a terminal", false, false, "requires a terminal"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			commands.ConfirmInteractive = func() bool { return tt.interactive }
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.BoolFlag{FlagName: "pause-on-error", FlagValue: true},
+				zedtesting.BoolFlag{FlagName: "yes", FlagValue: tt.yes})
+
+			require.ErrorContains(t, backupRestoreCmdFunc(cmd, []string{backupName}), tt.expectedErr)
+		})
+	}
+}
+
+func TestBackupCompressCmdFunc(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		compression string
+		decompress  bool
+		err         string
+	}{
+		{name: "zstd", compression: "zstd"},
+		{name: "snappy", compression: "snappy"},
+		{name: "deflate", compression: "deflate"},
+		{name: "decompress", decompress: true},
+		{name: "unknown codec", compression: "lz4", err: "unexpected flag 'compression' value 'lz4'"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.StringFlag{FlagName: "compression", FlagValue: tt.compression},
+				zedtesting.BoolFlag{FlagName: "decompress", FlagValue: tt.decompress})
+			backupName := createTestBackup(t, testSchema, testRelationships)
+			out := filepath.Join(t.TempDir(), "compressed.zedbackup")
+
+			err := backupCompressCmdFunc(cmd, []string{backupName, out})
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			d, closer, err := decoderFromArgs(out)
+			require.NoError(t, err)
+			defer func() {
+				_ = d.Close()
+				_ = closer.Close()
+			}()
+
+			require.Equal(t, testSchema, d.Schema())
+			require.Equal(t, "test", d.ZedToken().Token)
+
+			var rels []string
+			for rel, err := d.Next(); rel != nil && err == nil; rel, err = d.Next() {
+				rels = append(rels, tuple.MustV1StringRelationship(rel))
+			}
+			require.Equal(t, testRelationships, rels)
+		})
+	}
+}
+
 func TestAddSizeErrInfo(t *testing.T) {
 	tcs := []struct {
 		name          string
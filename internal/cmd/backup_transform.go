@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/generator"
+)
+
+// readTypeTransformMap reads a file mapping object types to their new names,
+// one oldType:newType per line. Empty lines and lines starting with // are
+// ignored.
+func readTypeTransformMap(filename string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transform map: %w", err)
+	}
+	defer f.Close()
+
+	transforms := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		oldType, newType, ok := strings.Cut(line, ":")
+		oldType, newType = strings.TrimSpace(oldType), strings.TrimSpace(newType)
+		if !ok || oldType == "" || newType == "" {
+			return nil, fmt.Errorf("invalid transform map line %d: expected oldType:newType, got %q", lineNumber, line)
+		}
+		if _, ok := transforms[oldType]; ok {
+			return nil, fmt.Errorf("invalid transform map line %d: %s is mapped more than once", lineNumber, oldType)
+		}
+		transforms[oldType] = newType
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transform map: %w", err)
+	}
+	return transforms, nil
+}
+
+// transformSchemaTypes renames the object definitions of the schema found in
+// the transform map, along with every reference to them as an allowed subject
+// type, and validates that the resulting schema still compiles.
+func transformSchemaTypes(schema string, transforms map[string]string) (string, error) {
+	if schema == "" || len(transforms) == 0 {
+		return schema, nil
+	}
+
+	compiledSchema, err := compiler.Compile(
+		compiler.InputSchema{Source: "schema", SchemaString: schema},
+		compiler.AllowUnprefixedObjectType(),
+		compiler.SkipValidation(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error reading schema: %w", err)
+	}
+
+	for _, def := range compiledSchema.ObjectDefinitions {
+		if newName, ok := transforms[def.Name]; ok {
+			def.Name = newName
+		}
+		for _, relation := range def.Relation {
+			for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+				if newName, ok := transforms[allowed.Namespace]; ok {
+					allowed.Namespace = newName
+				}
+			}
+		}
+	}
+
+	transformedSchema, _, err := generator.GenerateSchema(compiledSchema.OrderedDefinitions)
+	if err != nil {
+		return "", fmt.Errorf("error generating transformed schema: %w", err)
+	}
+	if err := validateSchema(transformedSchema, "generated invalid schema"); err != nil {
+		return "", err
+	}
+	return transformedSchema, nil
+}
+
+// typeTransformDecoder renames the resource and subject types of the
+// relationships of its decoder found in the transform map.
+type typeTransformDecoder struct {
+	relationshipDecoder
+	transforms map[string]string
+}
+
+func (d *typeTransformDecoder) Next() (*v1.Relationship, error) {
+	rel, err := d.relationshipDecoder.Next()
+	if rel == nil || err != nil {
+		return rel, err
+	}
+
+	if newType, ok := d.transforms[rel.Resource.ObjectType]; ok {
+		rel.Resource.ObjectType = newType
+	}
+	if newType, ok := d.transforms[rel.Subject.Object.ObjectType]; ok {
+		rel.Subject.Object.ObjectType = newType
+	}
+	return rel, nil
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/zed/internal/client"
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+func TestReadTypeTransformMap(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		contents string
+		expected map[string]string
+		err      string
+	}{
+		{"empty", "", map[string]string{}, ""},
+		{"mappings", "// comment\nold/user:new/user\n\n old/doc : new/doc \n", map[string]string{"old/user": "new/user", "old/doc": "new/doc"}, ""},
+		{"missing separator", "old/user", nil, "expected oldType:newType"},
+		{"missing new type", "old/user:", nil, "expected oldType:newType"},
+		{"mapped twice", "a:b\na:c", nil, "a is mapped more than once"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "transforms")
+			require.NoError(t, os.WriteFile(filename, []byte(tt.contents), 0o600))
+
+			transforms, err := readTypeTransformMap(filename)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, transforms)
+		})
+	}
+}
+
+func TestTransformSchemaTypes(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		schema     string
+		transforms map[string]string
+		expected   string
+		err        string
+	}{
+		{
+			name:       "no transforms returns as is",
+			schema:     testSchema,
+			transforms: map[string]string{},
+			expected:   testSchema,
+		},
+		{
+			name:       "renames definitions and subject types",
+			schema:     testSchema,
+			transforms: map[string]string{"test/resource": "other/resource", "test/user": "other/user"},
+			expected:   "definition other/resource {\n\trelation reader: other/user\n}\n\ndefinition other/user {}",
+		},
+		{
+			name:       "unmapped types are kept",
+			schema:     testSchema,
+			transforms: map[string]string{"test/user": "other/user"},
+			expected:   "definition test/resource {\n\trelation reader: other/user\n}\n\ndefinition other/user {}",
+		},
+		{
+			name:       "remapped schema must compile",
+			schema:     testSchema,
+			transforms: map[string]string{"test/resource": "test/user"},
+			err:        "generated invalid schema",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := transformSchemaTypes(tt.schema, tt.transforms)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, schema)
+		})
+	}
+}
+
+func TestBackupRestoreCmdFuncTransformMap(t *testing.T) {
+	transformMap := filepath.Join(t.TempDir(), "transforms")
+	require.NoError(t, os.WriteFile(transformMap, []byte("test/resource:other/resource\ntest/user:other/user\n"), 0o600))
+
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "prefix-filter"},
+		zedtesting.BoolFlag{FlagName: "rewrite-legacy"},
+		zedtesting.StringFlag{FlagName: "transform-map", FlagValue: transformMap},
+		zedtesting.StringFlag{FlagName: "conflict-strategy", FlagValue: "fail"},
+		zedtesting.BoolFlag{FlagName: "disable-retries"},
+		zedtesting.UintFlag{FlagName: "batch-size", FlagValue: 100},
+		zedtesting.UintFlag{FlagName: "batches-per-transaction", FlagValue: 10},
+		zedtesting.DurationFlag{FlagName: "request-timeout"},
+		zedtesting.BoolFlag{FlagName: "pause-on-error"},
+		zedtesting.BoolFlag{FlagName: "adaptive-batching"},
+	)
+	backupName := createTestBackup(t, testSchema, testRelationships)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+	client.NewClient = zedtesting.ClientFromConn(conn)
+
+	c, err := zedtesting.ClientFromConn(conn)(cmd)
+	require.NoError(t, err)
+	require.NoError(t, backupRestoreCmdFunc(cmd, []string{backupName}))
+
+	resp, err := c.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "definition other/resource {\n\trelation reader: other/user\n}\n\ndefinition other/user {}", resp.SchemaText)
+
+	rrCli, err := c.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+		Consistency:        &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: "other/resource"},
+	})
+	require.NoError(t, err)
+
+	var count int
+	for {
+		rrResp, err := rrCli.Recv()
+		if err != nil {
+			break
+		}
+		require.Equal(t, "other/user", rrResp.Relationship.Subject.Object.ObjectType)
+		count++
+	}
+	require.Equal(t, len(testRelationships), count)
+}
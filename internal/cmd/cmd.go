@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/jzelinskie/cobrautil/v2"
@@ -14,8 +17,14 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/authzed/zed/internal/commands"
+	"github.com/authzed/zed/internal/console"
 )
 
 var (
@@ -48,11 +57,14 @@ func Run() {
 	rootCmd := &cobra.Command{
 		Use:   "zed",
 		Short: "SpiceDB client, by AuthZed",
-		Long:  "A command-line client for managing SpiceDB clusters, built by AuthZed",
+		Long:  rootCmdHelpLong,
 		PersistentPreRunE: cobrautil.CommandStack(
 			zl.RunE(),
+			syncCommandScopedFlagsCmdFunc,
 			SyncFlagsCmdFunc,
 			commands.InjectRequestID,
+			setProgressModeCmdFunc,
+			validateErrorFormatCmdFunc,
 		),
 		SilenceErrors: true,
 		SilenceUsage:  false,
@@ -65,17 +77,26 @@ func Run() {
 
 	zl.RegisterFlags(rootCmd.PersistentFlags())
 
-	rootCmd.PersistentFlags().String("endpoint", "", "spicedb gRPC API endpoint")
+	rootCmd.PersistentFlags().String("endpoint", "", "spicedb gRPC API endpoint, as host:port or a grpc://, grpcs://, https:// or grpc+unix:// URL that also sets whether to use TLS, or srv://<name> to discover it via DNS SRV records")
 	rootCmd.PersistentFlags().String("permissions-system", "", "permissions system to query")
+	rootCmd.PersistentFlags().String("context", "", "name of a saved context to use for this command instead of the current context")
+	_ = rootCmd.RegisterFlagCompletionFunc("context", ContextGet)
 	rootCmd.PersistentFlags().String("hostname-override", "", "override the hostname used in the connection to the endpoint")
 	rootCmd.PersistentFlags().String("token", "", "token used to authenticate to SpiceDB")
+	rootCmd.PersistentFlags().String("token-command", "", "shell command run once per invocation whose output is used as the token to authenticate to SpiceDB, e.g. to fetch a short-lived token")
 	rootCmd.PersistentFlags().String("certificate-path", "", "path to certificate authority used to verify secure connections")
 	rootCmd.PersistentFlags().Bool("insecure", false, "connect over a plaintext connection")
 	rootCmd.PersistentFlags().Bool("skip-version-check", false, "if true, no version check is performed against the server")
 	rootCmd.PersistentFlags().Bool("no-verify-ca", false, "do not attempt to verify the server's certificate chain and host name")
+	rootCmd.PersistentFlags().String("tls-skip-verify-host", "", "when connecting to this host, verify the server's certificate chain but not the host name it was issued for")
+	rootCmd.PersistentFlags().String("tls-server-name", "", "server name sent and verified in the TLS handshake, when it differs from the host being dialed")
 	rootCmd.PersistentFlags().Bool("debug", false, "enable debug logging")
 	rootCmd.PersistentFlags().String("request-id", "", "optional id to send along with SpiceDB requests for tracing")
 	rootCmd.PersistentFlags().Int("max-message-size", 0, "maximum size *in bytes* (defaults to 4_194_304 bytes ~= 4MB) of a gRPC message that can be sent or received by zed")
+	rootCmd.PersistentFlags().Bool("auto-grow-message-size", false, "retry calls that receive a message larger than the maximum message size with a larger maximum; streaming calls are only retried if their first message is too large")
+	rootCmd.PersistentFlags().String("progress", string(console.ProgressAuto), "where to render progress bars. Possible values: auto (stderr, if it is a terminal), none, stderr")
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "skip the confirmation of destructive operations, which is required when not running in a terminal")
+	rootCmd.PersistentFlags().String("error-format", errorFormatText, "format of the error printed when a command fails. Possible values: text, json (a single object on stderr with code, message, grpc_code and details)")
 	_ = rootCmd.PersistentFlags().MarkHidden("debug") // This cannot return its error.
 
 	versionCmd := &cobra.Command{
@@ -85,22 +106,26 @@ func Run() {
 	}
 	cobrautil.RegisterVersionFlags(versionCmd.Flags())
 	versionCmd.Flags().Bool("include-remote-version", true, "whether to display the version of Authzed or SpiceDB for the current context")
+	versionCmd.Flags().Bool("check-update", false, "check whether a newer version of zed has been released, exiting with status 2 if so")
 	rootCmd.AddCommand(versionCmd)
 
 	// Register root-level aliases
-	rootCmd.AddCommand(&cobra.Command{
+	useCmd := &cobra.Command{
 		Use:               "use <context>",
 		Short:             "Alias for `zed context use`",
 		Args:              cobra.MaximumNArgs(1),
 		RunE:              contextUseCmdFunc,
 		ValidArgsFunction: ContextGet,
-	})
+	}
+	registerContextUseFlags(useCmd)
+	rootCmd.AddCommand(useCmd)
 
 	// Register CLI-only commands.
 	registerContextCmd(rootCmd)
 	registerImportCmd(rootCmd)
 	registerValidateCmd(rootCmd)
 	registerBackupCmd(rootCmd)
+	registerDoctorCmd(rootCmd)
 
 	// Register shared commands.
 	commands.RegisterPermissionCmd(rootCmd)
@@ -132,10 +157,136 @@ func Run() {
 	}()
 
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
-		if !errors.Is(err, errParsing) {
+		if errors.Is(err, errUpdateAvailable) {
+			os.Exit(updateAvailableExitCode)
+		}
+
+		if errors.Is(err, errParsing) {
+			os.Exit(1)
+		}
+
+		if errorFormat, _ := rootCmd.PersistentFlags().GetString("error-format"); errorFormat == errorFormatJSON {
+			printErrorJSON(err)
+		} else {
 			log.Err(err).Msg("terminated with errors")
 		}
 
 		os.Exit(1)
 	}
 }
+
+const rootCmdHelpLong = `A command-line client for managing SpiceDB clusters, built by AuthZed
+
+Any flag not given on the command line is read from an environment variable named
+after it, prefixed with ZED_: --batch-size from $ZED_BATCH_SIZE. Flags specific to a
+command are also read from a variable scoped to the command, which takes precedence:
+"zed import --batch-size" from $ZED_IMPORT_BATCH_SIZE, and "zed relationship
+bulk-delete --optional-limit" from $ZED_RELATIONSHIP_BULK_DELETE_OPTIONAL_LIMIT.`
+
+// syncCommandScopedFlagsCmdFunc sets the flags specific to the command that
+// were not given on the command line from the environment variables scoped to
+// the command, such as $ZED_IMPORT_BATCH_SIZE for "zed import --batch-size".
+// It runs before SyncFlagsCmdFunc, so these take precedence over the unscoped
+// variables, such as $ZED_BATCH_SIZE, which flags of other commands may share.
+func syncCommandScopedFlagsCmdFunc(cmd *cobra.Command, _ []string) error {
+	if cobrautil.IsBuiltinCommand(cmd) {
+		return nil
+	}
+
+	prefix := commandEnvPrefix(cmd)
+	var err error
+	cmd.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+
+		name := prefix + "_" + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, ok := os.LookupEnv(name); ok {
+			if setErr := cmd.Flags().Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value for $%s: %w", name, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// commandEnvPrefix returns the prefix of the environment variables scoped to
+// the command: ZED_ followed by its path below the root command, such as
+// ZED_RELATIONSHIP_BULK_DELETE for "zed relationship bulk-delete".
+func commandEnvPrefix(cmd *cobra.Command) string {
+	var path []string
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		path = append([]string{c.Name()}, path...)
+	}
+	return strings.ToUpper(strings.ReplaceAll(strings.Join(append([]string{"zed"}, path...), "_"), "-", "_"))
+}
+
+func setProgressModeCmdFunc(cmd *cobra.Command, _ []string) error {
+	return console.SetProgressMode(cobrautil.MustGetString(cmd, "progress"))
+}
+
+const (
+	errorFormatText = "text"
+	errorFormatJSON = "json"
+)
+
+func validateErrorFormatCmdFunc(cmd *cobra.Command, _ []string) error {
+	switch errorFormat := cobrautil.MustGetString(cmd, "error-format"); errorFormat {
+	case errorFormatText, errorFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("unknown error format %q: should be one of text, json", errorFormat)
+	}
+}
+
+// structuredError is the machine-readable form of a command's error, printed
+// with --error-format=json.
+type structuredError struct {
+	// Code is the reason from the error's ErrorInfo, if it has one, or else
+	// the name of its gRPC status code, which is UNKNOWN for errors that did
+	// not come from SpiceDB.
+	Code     string            `json:"code"`
+	Message  string            `json:"message"`
+	GRPCCode string            `json:"grpc_code,omitempty"`
+	Details  []json.RawMessage `json:"details,omitempty"`
+}
+
+func newStructuredError(err error) structuredError {
+	structured := structuredError{
+		Code:    code.Code_name[int32(status.Code(err))],
+		Message: err.Error(),
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return structured
+	}
+
+	structured.GRPCCode = s.Code().String()
+	for _, detail := range s.Details() {
+		if errorInfo, ok := detail.(*errdetails.ErrorInfo); ok && errorInfo.GetReason() != "" {
+			structured.Code = errorInfo.GetReason()
+			break
+		}
+	}
+
+	for _, detail := range s.Proto().GetDetails() {
+		detailJSON, err := protojson.Marshal(detail)
+		if err != nil {
+			log.Debug().Err(err).Str("type", detail.GetTypeUrl()).Msg("skipping error detail that could not be marshaled")
+			continue
+		}
+		structured.Details = append(structured.Details, detailJSON)
+	}
+
+	return structured
+}
+
+func printErrorJSON(err error) {
+	errorJSON, marshalErr := json.Marshal(newStructuredError(err))
+	if marshalErr != nil {
+		log.Err(err).Msg("terminated with errors")
+		return
+	}
+	console.Errorf("%s\n", errorJSON)
+}
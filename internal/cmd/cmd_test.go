@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewStructuredError(t *testing.T) {
+	withErrorInfo, err := status.New(codes.InvalidArgument, "error parsing schema").WithDetails(&errdetails.ErrorInfo{
+		Reason:   "ERROR_REASON_SCHEMA_PARSE_ERROR",
+		Domain:   "authzed.com",
+		Metadata: map[string]string{"start_line_number": "1"},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			"plain error",
+			errors.New("no context found"),
+			`{"code":"UNKNOWN","message":"no context found"}`,
+		},
+		{
+			"wrapped status",
+			fmt.Errorf("error reading schema: %w", addSizeErrInfo(status.Error(codes.ResourceExhausted, "grpc: received message larger than max (5000 vs. 4000)"))),
+			`{"code":"RESOURCE_EXHAUSTED","message":"error reading schema: rpc error: code = ResourceExhausted desc = grpc: received message larger than max (5000 vs. 4000): set flag --max-message-size=10000 to increase the maximum allowable size","grpc_code":"ResourceExhausted"}`,
+		},
+		{
+			"status with error info",
+			withErrorInfo.Err(),
+			`{"code":"ERROR_REASON_SCHEMA_PARSE_ERROR","message":"rpc error: code = InvalidArgument desc = error parsing schema","grpc_code":"InvalidArgument","details":[{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"ERROR_REASON_SCHEMA_PARSE_ERROR","domain":"authzed.com","metadata":{"start_line_number":"1"}}]}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := json.Marshal(newStructuredError(tt.err))
+			require.NoError(t, err)
+			require.JSONEq(t, tt.expected, string(out))
+		})
+	}
+}
+
+func TestSyncCommandScopedFlagsCmdFunc(t *testing.T) {
+	newCommands := func() (*cobra.Command, *cobra.Command) {
+		rootCmd := &cobra.Command{Use: "zed"}
+		rootCmd.PersistentFlags().String("endpoint", "", "")
+		relationshipCmd := &cobra.Command{Use: "relationship"}
+		bulkDeleteCmd := &cobra.Command{Use: "bulk-delete", Run: func(*cobra.Command, []string) {}}
+		bulkDeleteCmd.Flags().Uint32("optional-limit", 1000, "")
+		bulkDeleteCmd.Flags().Bool("force", false, "")
+		rootCmd.AddCommand(relationshipCmd)
+		relationshipCmd.AddCommand(bulkDeleteCmd)
+		return rootCmd, bulkDeleteCmd
+	}
+
+	_, cmd := newCommands()
+	require.Equal(t, "ZED_RELATIONSHIP_BULK_DELETE", commandEnvPrefix(cmd))
+
+	t.Setenv("ZED_RELATIONSHIP_BULK_DELETE_OPTIONAL_LIMIT", "50")
+	t.Setenv("ZED_RELATIONSHIP_BULK_DELETE_FORCE", "true")
+	t.Setenv("ZED_RELATIONSHIP_BULK_DELETE_ENDPOINT", "ignored:50051")
+	require.NoError(t, syncCommandScopedFlagsCmdFunc(cmd, nil))
+	require.Equal(t, "50", cmd.Flags().Lookup("optional-limit").Value.String())
+	require.Equal(t, "true", cmd.Flags().Lookup("force").Value.String())
+	require.Empty(t, cmd.Flags().Lookup("endpoint").Value.String())
+
+	// Flags given on the command line are not overridden.
+	_, cmd = newCommands()
+	require.NoError(t, cmd.Flags().Set("optional-limit", "10"))
+	require.NoError(t, syncCommandScopedFlagsCmdFunc(cmd, nil))
+	require.Equal(t, "10", cmd.Flags().Lookup("optional-limit").Value.String())
+
+	_, cmd = newCommands()
+	t.Setenv("ZED_RELATIONSHIP_BULK_DELETE_OPTIONAL_LIMIT", "many")
+	require.ErrorContains(t, syncCommandScopedFlagsCmdFunc(cmd, nil), "invalid value for $ZED_RELATIONSHIP_BULK_DELETE_OPTIONAL_LIMIT")
+}
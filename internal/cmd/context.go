@@ -1,12 +1,20 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sync"
+	"time"
 
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/jzelinskie/cobrautil/v2"
 	"github.com/jzelinskie/stringz"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/authzed/zed/internal/client"
 	"github.com/authzed/zed/internal/console"
@@ -19,10 +27,17 @@ func registerContextCmd(rootCmd *cobra.Command) {
 
 	contextCmd.AddCommand(contextListCmd)
 	contextListCmd.Flags().Bool("reveal-tokens", false, "display secrets in results")
+	contextListCmd.Flags().Bool("check", false, "connect to each context and report whether it is reachable")
 
 	contextCmd.AddCommand(contextSetCmd)
+	contextSetCmd.Flags().Bool("from-env", false, "take the endpoint and API token from $ZED_ENDPOINT and $ZED_TOKEN (or --endpoint and --token), so only the name is provided")
 	contextCmd.AddCommand(contextRemoveCmd)
 	contextCmd.AddCommand(contextUseCmd)
+	registerContextUseFlags(contextUseCmd)
+}
+
+func registerContextUseFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("validate", false, "connect to the context and make a request with it before making it the current context")
 }
 
 var contextCmd = &cobra.Command{
@@ -40,10 +55,22 @@ var contextListCmd = &cobra.Command{
 	RunE:              contextListCmdFunc,
 }
 
+const contextSetCmdHelpLong = `Creates or overwrite a context.
+
+With --from-env, the endpoint and API token are taken from the $ZED_ENDPOINT and
+$ZED_TOKEN environment variables instead of being provided as arguments, so an
+ephemeral environment can save a context without any prompt:
+
+ZED_ENDPOINT=spicedb:50051 ZED_TOKEN=somesecret ZED_INSECURE=true zed context set --from-env local
+
+As with every flag, --insecure, --no-verify-ca and --certificate-path are read from
+$ZED_INSECURE, $ZED_NO_VERIFY_CA and $ZED_CERTIFICATE_PATH.`
+
 var contextSetCmd = &cobra.Command{
 	Use:               "set <name> <endpoint> <api-token>",
 	Short:             "Creates or overwrite a context",
-	Args:              cobra.ExactArgs(3),
+	Long:              contextSetCmdHelpLong,
+	Args:              cobra.RangeArgs(1, 3),
 	ValidArgsFunction: cobra.NoFileCompletions,
 	RunE:              contextSetCmdFunc,
 }
@@ -92,8 +119,18 @@ func contextListCmdFunc(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
+	check := cobrautil.MustGetBool(cmd, "check")
+	var statuses []string
+	if check {
+		names := make([]string, 0, len(secrets.Tokens))
+		for _, token := range secrets.Tokens {
+			names = append(names, token.Name)
+		}
+		statuses = contextConnectionStatuses(cmd, names, secretStore)
+	}
+
 	rows := make([][]string, 0, len(secrets.Tokens))
-	for _, token := range secrets.Tokens {
+	for i, token := range secrets.Tokens {
 		current := ""
 		if token.Name == cfg.CurrentToken {
 			current = "   ✓   "
@@ -114,23 +151,30 @@ func contextListCmdFunc(cmd *cobra.Command, _ []string) error {
 			certStr = "system"
 		}
 
-		rows = append(rows, []string{
+		row := []string{
 			current,
 			token.Name,
 			token.Endpoint,
 			secret,
 			certStr,
-		})
+		}
+		if check {
+			row = append(row, statuses[i])
+		}
+		rows = append(rows, row)
 	}
 
-	printers.PrintTable(os.Stdout, []string{"current", "name", "endpoint", "token", "tls cert"}, rows)
+	headers := []string{"current", "name", "endpoint", "token", "tls cert"}
+	if check {
+		headers = append(headers, "status")
+	}
+	printers.PrintTable(os.Stdout, headers, rows)
 
 	return nil
 }
 
 func contextSetCmdFunc(cmd *cobra.Command, args []string) error {
-	var name, endpoint, apiToken string
-	err := stringz.Unpack(args, &name, &endpoint, &apiToken)
+	name, endpoint, apiToken, err := contextSetArgs(cmd, args)
 	if err != nil {
 		return err
 	}
@@ -162,6 +206,29 @@ func contextSetCmdFunc(cmd *cobra.Command, args []string) error {
 	return storage.SetCurrentToken(name, cfgStore, secretStore)
 }
 
+// contextSetArgs returns the name, endpoint and API token of the context to set,
+// from the arguments or, with --from-env, from the --endpoint and --token flags,
+// which are themselves set from $ZED_ENDPOINT and $ZED_TOKEN.
+func contextSetArgs(cmd *cobra.Command, args []string) (name, endpoint, apiToken string, err error) {
+	if !cobrautil.MustGetBool(cmd, "from-env") {
+		if len(args) != 3 {
+			return "", "", "", fmt.Errorf("accepts 3 arg(s), received %d", len(args))
+		}
+		err = stringz.Unpack(args, &name, &endpoint, &apiToken)
+		return name, endpoint, apiToken, err
+	}
+
+	if len(args) != 1 {
+		return "", "", "", errors.New("with --from-env, only <name> must be provided")
+	}
+
+	apiToken = cobrautil.MustGetString(cmd, "token")
+	if apiToken == "" {
+		return "", "", "", errors.New("--from-env requires $ZED_TOKEN or --token to be set")
+	}
+	return args[0], cobrautil.MustGetString(cmd, "endpoint"), apiToken, nil
+}
+
 func contextRemoveCmdFunc(_ *cobra.Command, args []string) error {
 	// If the token is what's currently being used, remove it from the config.
 	cfgStore, secretStore := client.DefaultStorage()
@@ -182,7 +249,7 @@ func contextRemoveCmdFunc(_ *cobra.Command, args []string) error {
 	return storage.RemoveToken(args[0], secretStore)
 }
 
-func contextUseCmdFunc(_ *cobra.Command, args []string) error {
+func contextUseCmdFunc(cmd *cobra.Command, args []string) error {
 	cfgStore, secretStore := client.DefaultStorage()
 	switch len(args) {
 	case 0:
@@ -192,6 +259,21 @@ func contextUseCmdFunc(_ *cobra.Command, args []string) error {
 		}
 		console.Println(cfg.CurrentToken)
 	case 1:
+		if cobrautil.MustGetBool(cmd, "validate") {
+			spicedbClient, err := client.NewClientForContext(cmd, args[0], secretStore)
+			if err != nil {
+				return fmt.Errorf("context %q failed validation, not switching to it: %w", args[0], err)
+			}
+
+			err = validateContextConnection(cmd.Context(), spicedbClient)
+			if closeErr := spicedbClient.Close(); closeErr != nil {
+				log.Debug().Err(closeErr).Str("context", args[0]).Msg("failed to close connection")
+			}
+			if err != nil {
+				return fmt.Errorf("context %q failed validation, not switching to it: %w", args[0], err)
+			}
+		}
+
 		return storage.SetCurrentToken(args[0], cfgStore, secretStore)
 	default:
 		panic("cobra command did not enforce valid number of args")
@@ -199,3 +281,60 @@ func contextUseCmdFunc(_ *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// validateContextConnection makes a cheap request to check that the client
+// can connect and authenticate. A missing schema still proves both.
+func validateContextConnection(ctx context.Context, spicedbClient v1.SchemaServiceClient) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	request := &v1.ReadSchemaRequest{}
+	log.Trace().Interface("request", request).Msg("validating context")
+	if _, err := spicedbClient.ReadSchema(ctx, request); err != nil && status.Code(err) != codes.NotFound {
+		return err
+	}
+	return nil
+}
+
+// contextConnectionStatuses checks the connection to each of the named
+// contexts concurrently, so that listing many unreachable contexts takes as
+// long as a single check, and returns their statuses in the same order.
+func contextConnectionStatuses(cmd *cobra.Command, names []string, secretStore storage.SecretStore) []string {
+	statuses := make([]string, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			spicedbClient, err := client.NewClientForContext(cmd, name, secretStore)
+			if err != nil {
+				statuses[i] = "error: " + err.Error()
+				return
+			}
+			defer func() {
+				if err := spicedbClient.Close(); err != nil {
+					log.Debug().Err(err).Str("context", name).Msg("failed to close connection")
+				}
+			}()
+			statuses[i] = contextConnectionStatus(cmd.Context(), spicedbClient)
+		}()
+	}
+	wg.Wait()
+	return statuses
+}
+
+// contextConnectionStatus reports whether the client could reach the
+// permissions system, along with the gRPC status code of a failed request.
+// Errors other than the server being unavailable or timing out, such as an
+// invalid token, still prove the server is reachable.
+func contextConnectionStatus(ctx context.Context, spicedbClient v1.SchemaServiceClient) string {
+	err := validateContextConnection(ctx, spicedbClient)
+	switch code := status.Code(err); code {
+	case codes.OK:
+		return "reachable"
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return fmt.Sprintf("unreachable (%s)", code)
+	default:
+		return fmt.Sprintf("reachable (%s)", code)
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/authzed-go/v1"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/storage"
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+type fakeSchemaClient struct {
+	v1.SchemaServiceClient
+	err error
+}
+
+func (f fakeSchemaClient) ReadSchema(context.Context, *v1.ReadSchemaRequest, ...grpc.CallOption) (*v1.ReadSchemaResponse, error) {
+	return &v1.ReadSchemaResponse{}, f.err
+}
+
+func TestValidateContextConnection(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		err         error
+		expectedErr string
+	}{
+		{"schema read", nil, ""},
+		{"no schema", status.Error(codes.NotFound, "no schema has been defined"), ""},
+		{"unauthenticated", status.Error(codes.Unauthenticated, "invalid token"), "invalid token"},
+		{"unavailable", status.Error(codes.Unavailable, "connection refused"), "connection refused"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateContextConnection(context.Background(), fakeSchemaClient{err: tt.err})
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestContextConnectionStatus(t *testing.T) {
+	require.Equal(t, "reachable", contextConnectionStatus(context.Background(), fakeSchemaClient{}))
+	require.Equal(t, "reachable", contextConnectionStatus(context.Background(), fakeSchemaClient{err: status.Error(codes.NotFound, "no schema has been defined")}))
+	require.Equal(t, "unreachable (Unavailable)", contextConnectionStatus(context.Background(), fakeSchemaClient{err: status.Error(codes.Unavailable, "connection refused")}))
+	require.Equal(t, "reachable (Unauthenticated)", contextConnectionStatus(context.Background(), fakeSchemaClient{err: status.Error(codes.Unauthenticated, "invalid token")}))
+}
+
+func TestContextSetArgs(t *testing.T) {
+	for _, tt := range []struct {
+		name             string
+		args             []string
+		fromEnv          bool
+		endpoint         string
+		token            string
+		expectedEndpoint string
+		expectedToken    string
+		expectedErr      string
+	}{
+		{"arguments", []string{"dev", "localhost:50051", "secret"}, false, "ignored:50051", "ignored", "localhost:50051", "secret", ""},
+		{"missing arguments", []string{"dev"}, false, "", "", "", "", "accepts 3 arg(s), received 1"},
+		{"from env", []string{"dev"}, true, "spicedb:50051", "secret", "spicedb:50051", "secret", ""},
+		{"from env with default endpoint", []string{"dev"}, true, "", "secret", "", "secret", ""},
+		{"from env with arguments", []string{"dev", "localhost:50051", "secret"}, true, "", "secret", "", "", "only <name> must be provided"},
+		{"from env without token", []string{"dev"}, true, "spicedb:50051", "", "", "", "requires $ZED_TOKEN or --token"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.BoolFlag{FlagName: "from-env", FlagValue: tt.fromEnv},
+				zedtesting.StringFlag{FlagName: "endpoint", FlagValue: tt.endpoint},
+				zedtesting.StringFlag{FlagName: "token", FlagValue: tt.token})
+
+			name, endpoint, token, err := contextSetArgs(cmd, tt.args)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "dev", name)
+			require.Equal(t, tt.expectedEndpoint, endpoint)
+			require.Equal(t, tt.expectedToken, token)
+		})
+	}
+}
+
+func TestContextConnectionStatuses(t *testing.T) {
+	originalNewClientForContext := client.NewClientForContext
+	defer func() {
+		client.NewClientForContext = originalNewClientForContext
+	}()
+	client.NewClientForContext = func(_ *cobra.Command, contextName string, _ storage.SecretStore) (*authzed.Client, error) {
+		if contextName == "broken" {
+			return nil, errors.New("invalid endpoint")
+		}
+		// Nothing listens on port 1, so connecting is refused.
+		return authzed.NewClient("127.0.0.1:1", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	statuses := contextConnectionStatuses(cmd, []string{"down", "broken", "alsodown"}, nil)
+	require.Equal(t, []string{"unreachable (Unavailable)", "error: invalid endpoint", "unreachable (Unavailable)"}, statuses)
+}
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/authzed/authzed-go/pkg/requestmeta"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/semver"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/console"
+	"github.com/authzed/zed/internal/storage"
+)
+
+func registerDoctorCmd(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().Bool("json", false, "output the report as JSON")
+	doctorCmd.Flags().Duration("timeout", 10*time.Second, "maximum time to wait for the permissions system to respond")
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the configuration of and connection to the current permissions system",
+	Long: `Diagnose the configuration of and connection to the current permissions system.
+
+Checks the configuration, the TLS settings, connectivity, authentication and the version of
+the server, compared with the version of SpiceDB zed was built against, printing whether each
+passed. The report can be pasted into bug reports; it does
+not include the token. Exits with status 1 if any check failed.`,
+	Args: cobra.NoArgs,
+	RunE: doctorCmdFunc,
+}
+
+type doctorStatus string
+
+const (
+	doctorPass doctorStatus = "pass"
+	doctorWarn doctorStatus = "warn"
+	doctorFail doctorStatus = "fail"
+	doctorSkip doctorStatus = "skip"
+)
+
+// doctorCheck is the result of one of the checks run by doctor.
+type doctorCheck struct {
+	Name   string       `json:"name"`
+	Status doctorStatus `json:"status"`
+	Detail string       `json:"detail"`
+}
+
+type doctorReport struct {
+	ClientVersion string        `json:"client_version"`
+	Checks        []doctorCheck `json:"checks"`
+	Passed        bool          `json:"passed"`
+}
+
+func doctorCmdFunc(cmd *cobra.Command, _ []string) error {
+	report := doctorReport{ClientVersion: "(unknown)"}
+	var spicedbVersion string
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		report.ClientVersion = cobrautil.VersionWithFallbacks(bi)
+		for _, dep := range bi.Deps {
+			if dep.Path == spicedbModulePath {
+				spicedbVersion = dep.Version
+			}
+		}
+	}
+
+	configStore, secretStore := client.DefaultStorage()
+	token, err := client.GetCurrentTokenWithCLIOverride(cmd, configStore, secretStore)
+	if err != nil {
+		report.Checks = append(report.Checks,
+			doctorCheck{"configuration", doctorFail, err.Error()},
+			doctorCheck{"tls", doctorSkip, "no configuration"},
+			doctorCheck{"connectivity", doctorSkip, "no configuration"},
+			doctorCheck{"authentication", doctorSkip, "no configuration"},
+			doctorCheck{"server version", doctorSkip, "no configuration"},
+		)
+	} else {
+		report.Checks = append(report.Checks,
+			doctorCheck{"configuration", doctorPass, "endpoint " + token.Endpoint},
+			tlsDoctorCheck(token, cobrautil.MustGetString(cmd, "tls-server-name"), cobrautil.MustGetString(cmd, "tls-skip-verify-host")),
+		)
+
+		spicedbClient, err := client.NewClient(cmd)
+		if err != nil {
+			report.Checks = append(report.Checks,
+				doctorCheck{"connectivity", doctorFail, err.Error()},
+				doctorCheck{"authentication", doctorSkip, "not connected"},
+				doctorCheck{"server version", doctorSkip, "not connected"},
+			)
+		} else {
+			report.Checks = append(report.Checks, probeDoctorChecks(cmd.Context(), spicedbClient, cobrautil.MustGetDuration(cmd, "timeout"), spicedbVersion)...)
+			if closer, ok := spicedbClient.(io.Closer); ok {
+				if err := closer.Close(); err != nil {
+					log.Debug().Err(err).Msg("failed to close connection")
+				}
+			}
+		}
+	}
+
+	report.Passed = true
+	for _, check := range report.Checks {
+		if check.Status == doctorFail {
+			report.Passed = false
+		}
+	}
+
+	if cobrautil.MustGetBool(cmd, "json") {
+		reportJSON, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		console.Println(string(reportJSON))
+	} else {
+		console.Printf("zed %s\n", report.ClientVersion)
+		for _, check := range report.Checks {
+			console.Printf("[%s] %s: %s\n", check.Status, check.Name, check.Detail)
+		}
+	}
+
+	if !report.Passed {
+		return errDoctorFailed
+	}
+	return nil
+}
+
+var errDoctorFailed = fmt.Errorf("one or more doctor checks failed")
+
+// tlsDoctorCheck reports on the connection security configured for the token
+// and the --tls-server-name and --tls-skip-verify-host flags, as used by the
+// client.
+func tlsDoctorCheck(token storage.Token, serverName, skipVerifyHost string) doctorCheck {
+	switch {
+	case token.IsInsecure():
+		if serverName != "" {
+			return doctorCheck{"tls", doctorFail, "--tls-server-name cannot be used with a plaintext connection"}
+		}
+		if isLoopbackEndpoint(token.Endpoint) {
+			return doctorCheck{"tls", doctorPass, "plaintext connection to a local endpoint"}
+		}
+		return doctorCheck{"tls", doctorWarn, "plaintext connection to a remote endpoint; the token is sent unencrypted"}
+	case token.HasNoVerifyCA():
+		return doctorCheck{"tls", doctorWarn, "TLS without verifying the server's certificate"}
+	case client.SkipsHostVerification(token, skipVerifyHost):
+		return doctorCheck{"tls", doctorWarn, "TLS, verifying the server's certificate chain but not the host name it was issued for"}
+	}
+
+	detail := "TLS, verified with the system certificates"
+	if _, ok := token.Certificate(); ok {
+		detail = "TLS, verified with the configured certificate authority"
+	}
+	if serverName != "" {
+		detail += " for server name " + serverName
+	}
+	return doctorCheck{"tls", doctorPass, detail}
+}
+
+func isLoopbackEndpoint(endpoint string) bool {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host = endpoint
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// probeDoctorChecks reads the schema to check connectivity and
+// authentication, reading the server's version from the response headers and
+// comparing it with spicedbVersion, the version of SpiceDB zed was built
+// against, if known.
+func probeDoctorChecks(ctx context.Context, spicedbClient v1.SchemaServiceClient, timeout time.Duration, spicedbVersion string) []doctorCheck {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ctx = requestmeta.AddRequestHeaders(ctx, requestmeta.RequestServerVersion)
+	var headerMD metadata.MD
+	request := &v1.ReadSchemaRequest{}
+	log.Trace().Interface("request", request).Msg("probing permissions system")
+	_, err := spicedbClient.ReadSchema(ctx, request, grpc.Header(&headerMD))
+
+	connectivity := doctorCheck{"connectivity", doctorPass, "reached the permissions system"}
+	authentication := doctorCheck{"authentication", doctorPass, "token accepted"}
+	switch code := status.Code(err); code {
+	case codes.OK, codes.NotFound:
+	case codes.Unauthenticated:
+		authentication = doctorCheck{"authentication", doctorFail, "token rejected: " + status.Convert(err).Message()}
+	case codes.PermissionDenied:
+		authentication = doctorCheck{"authentication", doctorWarn, "token accepted, but not permitted to read the schema: " + status.Convert(err).Message()}
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return []doctorCheck{
+			{"connectivity", doctorFail, err.Error()},
+			{"authentication", doctorSkip, "not connected"},
+			{"server version", doctorSkip, "not connected"},
+		}
+	default:
+		authentication = doctorCheck{"authentication", doctorWarn, "unexpected error reading the schema: " + err.Error()}
+	}
+
+	version := doctorCheck{"server version", doctorWarn, "unknown; the server may not report its version"}
+	if serverVersion, ok := serverVersionFromHeaders(headerMD); ok {
+		version = serverVersionDoctorCheck(serverVersion, spicedbVersion)
+	}
+
+	return []doctorCheck{connectivity, authentication, version}
+}
+
+// spicedbModulePath is the module whose version in the build info is the
+// version of SpiceDB zed was built against.
+const spicedbModulePath = "github.com/authzed/spicedb"
+
+// serverVersionDoctorCheck warns when the minor version of the server differs
+// from that of the SpiceDB zed was built against, as either may then use
+// features the other does not support. Versions that are not semantic
+// versions, such as development builds, are not compared.
+func serverVersionDoctorCheck(serverVersion, spicedbVersion string) doctorCheck {
+	server := semver.MajorMinor(canonicalVersion(serverVersion))
+	built := semver.MajorMinor(canonicalVersion(spicedbVersion))
+	if server == "" || built == "" {
+		return doctorCheck{"server version", doctorPass, serverVersion}
+	}
+
+	switch semver.Compare(server, built) {
+	case -1:
+		return doctorCheck{"server version", doctorWarn, fmt.Sprintf("%s, older than SpiceDB %s that zed was built against; newer features may be unavailable", serverVersion, built)}
+	case 1:
+		return doctorCheck{"server version", doctorWarn, fmt.Sprintf("%s, newer than SpiceDB %s that zed was built against; consider upgrading zed", serverVersion, built)}
+	default:
+		return doctorCheck{"server version", doctorPass, serverVersion}
+	}
+}
+
+func canonicalVersion(version string) string {
+	if version != "" && !strings.HasPrefix(version, "v") {
+		return "v" + version
+	}
+	return version
+}
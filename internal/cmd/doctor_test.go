@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/zed/internal/storage"
+)
+
+func TestTLSDoctorCheck(t *testing.T) {
+	insecure := true
+	noVerifyCA := true
+	for _, tt := range []struct {
+		name           string
+		token          storage.Token
+		serverName     string
+		skipVerifyHost string
+		expected       doctorStatus
+	}{
+		{"verified TLS", storage.Token{Endpoint: "grpc.authzed.com:443"}, "", "", doctorPass},
+		{"verified TLS with server name", storage.Token{Endpoint: "10.0.0.1:443"}, "grpc.authzed.com", "", doctorPass},
+		{"skip verify host", storage.Token{Endpoint: "10.0.0.1:443"}, "", "10.0.0.1", doctorWarn},
+		{"skip verify other host", storage.Token{Endpoint: "grpc.authzed.com:443"}, "", "10.0.0.1", doctorPass},
+		{"no verify ca", storage.Token{Endpoint: "grpc.authzed.com:443", NoVerifyCA: &noVerifyCA}, "", "", doctorWarn},
+		{"plaintext to localhost", storage.Token{Endpoint: "localhost:50051", Insecure: &insecure}, "", "", doctorPass},
+		{"plaintext to loopback ip", storage.Token{Endpoint: "127.0.0.1:50051", Insecure: &insecure}, "", "", doctorPass},
+		{"plaintext to remote host", storage.Token{Endpoint: "spicedb.internal:50051", Insecure: &insecure}, "", "", doctorWarn},
+		{"plaintext with server name", storage.Token{Endpoint: "localhost:50051", Insecure: &insecure}, "spicedb.internal", "", doctorFail},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tlsDoctorCheck(tt.token, tt.serverName, tt.skipVerifyHost).Status)
+		})
+	}
+}
+
+func TestProbeDoctorChecks(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		err      error
+		expected []doctorStatus
+	}{
+		{"schema read", nil, []doctorStatus{doctorPass, doctorPass, doctorWarn}},
+		{"no schema", status.Error(codes.NotFound, "no schema has been defined"), []doctorStatus{doctorPass, doctorPass, doctorWarn}},
+		{"unauthenticated", status.Error(codes.Unauthenticated, "invalid token"), []doctorStatus{doctorPass, doctorFail, doctorWarn}},
+		{"permission denied", status.Error(codes.PermissionDenied, "cannot read schema"), []doctorStatus{doctorPass, doctorWarn, doctorWarn}},
+		{"unavailable", status.Error(codes.Unavailable, "connection refused"), []doctorStatus{doctorFail, doctorSkip, doctorSkip}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := probeDoctorChecks(context.Background(), fakeSchemaClient{err: tt.err}, time.Second, "v1.39.0")
+			statuses := make([]doctorStatus, 0, len(checks))
+			for _, check := range checks {
+				statuses = append(statuses, check.Status)
+			}
+			require.Equal(t, tt.expected, statuses)
+		})
+	}
+}
+
+func TestServerVersionDoctorCheck(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		serverVersion  string
+		spicedbVersion string
+		expected       doctorStatus
+	}{
+		{"same minor version", "v1.39.0", "v1.39.1-0.20250114225336-a80f596434e3", doctorPass},
+		{"without v prefix", "1.39.2", "v1.39.0", doctorPass},
+		{"older server", "v1.35.0", "v1.39.0", doctorWarn},
+		{"newer server", "v1.40.0", "v1.39.0", doctorWarn},
+		{"development build", "dev", "v1.39.0", doctorPass},
+		{"unknown build", "v1.35.0", "", doctorPass},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			check := serverVersionDoctorCheck(tt.serverVersion, tt.spicedbVersion)
+			require.Equal(t, tt.expected, check.Status)
+			require.Contains(t, check.Detail, tt.serverVersion)
+		})
+	}
+}
@@ -3,15 +3,20 @@ package cmd
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"strings"
+	"time"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/jzelinskie/cobrautil/v2"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/authzed/zed/internal/client"
 	"github.com/authzed/zed/internal/decode"
@@ -25,6 +30,7 @@ func registerImportCmd(rootCmd *cobra.Command) {
 	importCmd.Flags().Bool("schema", true, "import schema")
 	importCmd.Flags().Bool("relationships", true, "import relationships")
 	importCmd.Flags().String("schema-definition-prefix", "", "prefix to add to the schema's definition(s) before importing")
+	importCmd.Flags().Bool("use-bulk-import", false, "stream the relationships with the bulk import API, falling back to batched writes if the server does not support it; fails if any relationship already exists")
 }
 
 var importCmd = &cobra.Command{
@@ -49,6 +55,9 @@ var importCmd = &cobra.Command{
 	From a local file (no prefix):
 		zed import authzed-x7izWU8_2Gw3.yaml
 
+	From a local directory, merged into a single validation file:
+		zed import ./permissions/
+
 	Only schema:
 		zed import --relationships=false file:///Users/zed/Downloads/authzed-x7izWU8_2Gw3.yaml
 
@@ -95,7 +104,8 @@ func importCmdFunc(cmd *cobra.Command, args []string) error {
 	if cobrautil.MustGetBool(cmd, "relationships") {
 		batchSize := cobrautil.MustGetInt(cmd, "batch-size")
 		workers := cobrautil.MustGetInt(cmd, "workers")
-		if err := importRelationships(cmd.Context(), client, p.Relationships, prefix, batchSize, workers); err != nil {
+		useBulkImport := cobrautil.MustGetBool(cmd, "use-bulk-import")
+		if err := importRelationships(cmd.Context(), client, p.Relationships, prefix, batchSize, workers, useBulkImport); err != nil {
 			return err
 		}
 	}
@@ -123,7 +133,7 @@ func importSchema(ctx context.Context, client client.Client, schema string, defi
 	return nil
 }
 
-func importRelationships(ctx context.Context, client client.Client, relationships string, definitionPrefix string, batchSize int, workers int) error {
+func importRelationships(ctx context.Context, client client.Client, relationships string, definitionPrefix string, batchSize int, workers int, useBulkImport bool) error {
 	relationshipUpdates := make([]*v1.RelationshipUpdate, 0)
 	scanner := bufio.NewScanner(strings.NewReader(relationships))
 	for scanner.Scan() {
@@ -155,6 +165,14 @@ func importRelationships(ctx context.Context, client client.Client, relationship
 		return err
 	}
 
+	if useBulkImport {
+		err := bulkImportRelationships(ctx, client, relationshipUpdates, batchSize)
+		if status.Code(err) != codes.Unimplemented {
+			return err
+		}
+		log.Warn().Err(err).Msg("bulk import is not supported by the server, falling back to batched writes")
+	}
+
 	log.Info().
 		Int("batch_size", batchSize).
 		Int("workers", workers).
@@ -176,3 +194,48 @@ func importRelationships(ctx context.Context, client client.Client, relationship
 	})
 	return err
 }
+
+// bulkImportRelationships streams the relationships of the updates to the
+// bulk import API, in messages of batchSize relationships.
+func bulkImportRelationships(ctx context.Context, client client.Client, relationshipUpdates []*v1.RelationshipUpdate, batchSize int) error {
+	log.Info().
+		Int("batch_size", batchSize).
+		Int("count", len(relationshipUpdates)).
+		Msg("bulk importing relationships")
+
+	start := time.Now()
+	stream, err := client.BulkImportRelationships(ctx)
+	if err != nil {
+		return err
+	}
+
+	batch := make([]*v1.Relationship, 0, batchSize)
+	for i, update := range relationshipUpdates {
+		batch = append(batch, update.Relationship)
+		if len(batch) < batchSize && i < len(relationshipUpdates)-1 {
+			continue
+		}
+
+		if err := stream.Send(&v1.BulkImportRelationshipsRequest{Relationships: batch}); err != nil {
+			// The error that ended the stream is returned by CloseAndRecv.
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		batch = make([]*v1.Relationship, 0, batchSize)
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+
+	duration := time.Since(start)
+	log.Info().
+		Uint64("loaded", resp.NumLoaded).
+		Uint64("perSecond", perSec(resp.NumLoaded, duration)).
+		Stringer("duration", duration).
+		Msg("bulk imported relationships")
+	return nil
+}
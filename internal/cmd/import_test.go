@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/zed/internal/client"
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+type noBulkImportClient struct {
+	client.Client
+}
+
+func (noBulkImportClient) BulkImportRelationships(context.Context, ...grpc.CallOption) (v1.ExperimentalService_BulkImportRelationshipsClient, error) {
+	return nil, status.Error(codes.Unimplemented, "unknown method BulkImportRelationships")
+}
+
+func TestImportRelationshipsBulkImport(t *testing.T) {
+	relationships := `test/resource:1#reader@test/user:1
+// a comment
+test/resource:2#reader@test/user:2
+test/resource:3#reader@test/user:3`
+
+	for _, tt := range []struct {
+		name         string
+		bulkImporter bool
+	}{
+		{"bulk import", true},
+		{"fallback to writes", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			srv := zedtesting.NewTestServer(ctx, t)
+			go func() {
+				require.NoError(t, srv.Run(ctx))
+			}()
+			conn, err := srv.GRPCDialContext(ctx)
+			require.NoError(t, err)
+
+			c, err := zedtesting.ClientFromConn(conn)(nil)
+			require.NoError(t, err)
+			_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+			require.NoError(t, err)
+
+			importClient := c
+			if !tt.bulkImporter {
+				importClient = noBulkImportClient{c}
+			}
+			require.NoError(t, importRelationships(ctx, importClient, relationships, "", 2, 1, true))
+			assertRelationshipsRestored(ctx, t, c, []string{
+				"test/resource:1#reader@test/user:1",
+				"test/resource:2#reader@test/user:2",
+				"test/resource:3#reader@test/user:3",
+			})
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/spicedb/pkg/spiceerrors"
+	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/ccoveille/go-safecast"
 	"github.com/cenkalti/backoff/v4"
 	"github.com/mattn/go-isatty"
@@ -20,8 +22,8 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/commands"
 	"github.com/authzed/zed/internal/console"
-	"github.com/authzed/zed/pkg/backupformat"
 )
 
 type ConflictStrategy int
@@ -33,6 +35,9 @@ const (
 
 	defaultBackoff    = 50 * time.Millisecond
 	defaultMaxRetries = 10
+
+	// maxDisplayedFailedRels is the number of relationships of a failed batch shown when prompting.
+	maxDisplayedFailedRels = 10
 )
 
 var conflictStrategyMapping = map[string]ConflictStrategy{
@@ -52,17 +57,32 @@ var (
 		"retryable error",                          // CockroachDB, PostgreSQL
 		"try restarting transaction", "Error 1205", // MySQL
 	}
+	batchTooLargeErrorStrings = []string{
+		"larger than max",              // gRPC message size limits
+		"greater than maximum allowed", // SpiceDB request limits
+		"command is too large",         // CockroachDB
+		"max_allowed_packet",           // MySQL
+	}
 )
 
+// relationshipDecoder yields the relationships to restore, returning a nil
+// relationship once there are none left.
+type relationshipDecoder interface {
+	Next() (*v1.Relationship, error)
+}
+
 type restorer struct {
 	schema                string
-	decoder               *backupformat.Decoder
+	decoder               relationshipDecoder
 	client                client.Client
 	prefixFilter          string
 	batchSize             uint
 	batchesPerTransaction uint
 	conflictStrategy      ConflictStrategy
 	disableRetryErrors    bool
+	pauseOnError          bool
+	adaptiveBatching      bool
+	confirmDestructive    func(operation string) error
 	bar                   *progressbar.ProgressBar
 
 	// stats
@@ -74,12 +94,13 @@ type restorer struct {
 	duplicateRels    uint
 	duplicateBatches uint
 	totalRetries     uint
+	splitBatches     uint
 	requestTimeout   time.Duration
 }
 
-func newRestorer(schema string, decoder *backupformat.Decoder, client client.Client, prefixFilter string, batchSize uint,
+func newRestorer(schema string, decoder relationshipDecoder, client client.Client, prefixFilter string, batchSize uint,
 	batchesPerTransaction uint, conflictStrategy ConflictStrategy, disableRetryErrors bool,
-	requestTimeout time.Duration,
+	requestTimeout time.Duration, pauseOnError bool, adaptiveBatching bool, confirmDestructive func(operation string) error,
 ) *restorer {
 	return &restorer{
 		decoder:               decoder,
@@ -91,6 +112,9 @@ func newRestorer(schema string, decoder *backupformat.Decoder, client client.Cli
 		batchesPerTransaction: batchesPerTransaction,
 		conflictStrategy:      conflictStrategy,
 		disableRetryErrors:    disableRetryErrors,
+		pauseOnError:          pauseOnError,
+		adaptiveBatching:      adaptiveBatching,
+		confirmDestructive:    confirmDestructive,
 		bar:                   console.CreateProgressBar("restoring from backup"),
 	}
 }
@@ -203,6 +227,7 @@ func (r *restorer) restoreFromDecoder(ctx context.Context) error {
 		Uint("duplicate_relationships", r.duplicateRels).
 		Uint("relationships_filtered_out", r.filteredOutRels).
 		Uint("retried_errors", r.totalRetries).
+		Uint("split_batches", r.splitBatches).
 		Uint64("perSecond", perSec(uint64(r.writtenRels), totalTime)).
 		Stringer("duration", totalTime).
 		Msg("finished restore")
@@ -224,6 +249,7 @@ func (r *restorer) commitStream(ctx context.Context, bulkImportClient v1.Experim
 	// This lets us retry with TOUCH semantics in case of failure due to duplicates
 	retryable := isRetryableError(err)
 	conflict := isAlreadyExistsError(err)
+	tooLarge := isBatchTooLargeError(err)
 	canceled, cancelErr := isCanceledError(ctx.Err(), err)
 	unknown := !retryable && !conflict && !canceled && err != nil
 
@@ -233,6 +259,24 @@ func (r *restorer) commitStream(ctx context.Context, bulkImportClient v1.Experim
 	case canceled:
 		r.bar.Describe("backup restore aborted")
 		return cancelErr
+	case tooLarge && r.adaptiveBatching:
+		r.bar.Describe("splitting batches after size error")
+		for _, batch := range batchesToBeCommitted {
+			loaded, err := r.writeBatchSplitting(ctx, batch)
+			if err != nil {
+				return fmt.Errorf("failed to write split batch: %w", err)
+			}
+			numLoaded += loaded
+		}
+
+		r.writtenBatches += numBatches
+		r.writtenRels += numLoaded
+		r.bar.Describe("restoring relationships from backup")
+	case unknown && r.pauseOnError:
+		r.bar.Describe("paused after unrecoverable error")
+		if err := r.resolveFailedBatches(ctx, batchesToBeCommitted, err); err != nil {
+			return err
+		}
 	case unknown:
 		r.bar.Describe("failed with unrecoverable error")
 		return fmt.Errorf("error finalizing write of %d batches: %w", len(batchesToBeCommitted), err)
@@ -249,7 +293,7 @@ func (r *restorer) commitStream(ctx context.Context, bulkImportClient v1.Experim
 		r.duplicateRels += expectedLoaded
 		r.duplicateBatches += numBatches
 		r.totalRetries++
-		numLoaded, retries, err = r.writeBatchesWithRetry(ctx, batchesToBeCommitted)
+		numLoaded, retries, err = r.writeBatchesWithRetry(ctx, batchesToBeCommitted, v1.RelationshipUpdate_OPERATION_TOUCH)
 		if err != nil {
 			return fmt.Errorf("failed to write retried batch: %w", err)
 		}
@@ -257,13 +301,20 @@ func (r *restorer) commitStream(ctx context.Context, bulkImportClient v1.Experim
 		retries++ // account for the initial attempt
 		r.writtenBatches += numBatches
 		r.writtenRels += numLoaded
+	case conflict && r.conflictStrategy == Fail && r.pauseOnError:
+		// the operator decides what happens to the conflicting relationships, so they are
+		// only counted once resolved, as either skipped or written
+		r.bar.Describe("paused after conflict")
+		if err := r.resolveFailedBatches(ctx, batchesToBeCommitted, err); err != nil {
+			return err
+		}
 	case conflict && r.conflictStrategy == Fail:
 		r.bar.Describe("conflict detected, aborting restore")
 		return fmt.Errorf("duplicate relationships found")
 	case retryable:
 		r.bar.Describe("retrying after error")
 		r.totalRetries++
-		numLoaded, retries, err = r.writeBatchesWithRetry(ctx, batchesToBeCommitted)
+		numLoaded, retries, err = r.writeBatchesWithRetry(ctx, batchesToBeCommitted, v1.RelationshipUpdate_OPERATION_TOUCH)
 		if err != nil {
 			return fmt.Errorf("failed to write retried batch: %w", err)
 		}
@@ -312,9 +363,9 @@ func (r *restorer) commitStream(ctx context.Context, bulkImportClient v1.Experim
 	return nil
 }
 
-// writeBatchesWithRetry writes a set of batches using touch semantics and without transactional guarantees -
+// writeBatchesWithRetry writes a set of batches with the given operation and without transactional guarantees -
 // each batch will be committed independently. If a batch fails, it will be retried up to 10 times with a backoff.
-func (r *restorer) writeBatchesWithRetry(ctx context.Context, batches [][]*v1.Relationship) (uint, uint, error) {
+func (r *restorer) writeBatchesWithRetry(ctx context.Context, batches [][]*v1.Relationship, operation v1.RelationshipUpdate_Operation) (uint, uint, error) {
 	backoffInterval := backoff.NewExponentialBackOff()
 	backoffInterval.InitialInterval = defaultBackoff
 	backoffInterval.MaxInterval = 2 * time.Second
@@ -326,7 +377,7 @@ func (r *restorer) writeBatchesWithRetry(ctx context.Context, batches [][]*v1.Re
 		updates := lo.Map[*v1.Relationship, *v1.RelationshipUpdate](batch, func(item *v1.Relationship, _ int) *v1.RelationshipUpdate {
 			return &v1.RelationshipUpdate{
 				Relationship: item,
-				Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+				Operation:    operation,
 			}
 		})
 
@@ -360,6 +411,143 @@ func (r *restorer) writeBatchesWithRetry(ctx context.Context, batches [][]*v1.Re
 	return loadedRels, totalRetries, nil
 }
 
+// writeOperation returns the operation used to write relationships outside of bulk import. Under
+// the fail conflict strategy, relationships are created rather than touched, so that existing
+// relationships still fail the restore.
+func (r *restorer) writeOperation() v1.RelationshipUpdate_Operation {
+	if r.conflictStrategy == Fail {
+		return v1.RelationshipUpdate_OPERATION_CREATE
+	}
+	return v1.RelationshipUpdate_OPERATION_TOUCH
+}
+
+// writeBatchSplitting writes a batch in the same way as writeBatchesWithRetry. If the batch is
+// rejected for being too large, it is split in half and each half is written in turn, down to
+// single relationships.
+func (r *restorer) writeBatchSplitting(ctx context.Context, batch []*v1.Relationship) (uint, error) {
+	loaded, _, err := r.writeBatchesWithRetry(ctx, [][]*v1.Relationship{batch}, r.writeOperation())
+	if err == nil {
+		return loaded, nil
+	}
+	if !isBatchTooLargeError(err) || len(batch) == 1 {
+		return 0, err
+	}
+
+	r.splitBatches++
+	half := len(batch) / 2
+	log.Info().Int("relationships", len(batch)).Int("split_into", half).Err(err).Msg("splitting batch that was too large")
+
+	loadedFirst, err := r.writeBatchSplitting(ctx, batch[:half])
+	if err != nil {
+		return 0, err
+	}
+	loadedSecond, err := r.writeBatchSplitting(ctx, batch[half:])
+	if err != nil {
+		return 0, err
+	}
+	return loadedFirst + loadedSecond, nil
+}
+
+// restoreErrorPrompt defines an (overridable) function for asking the operator how to handle
+// a batch that failed to be committed. Without a terminal to prompt on, it fails rather than
+// waiting on an answer that will never come.
+var restoreErrorPrompt = func(prompt string) (string, error) {
+	if !commands.ConfirmInteractive() {
+		return "", errors.New("unable to prompt for a failed batch: stdin is not a terminal")
+	}
+
+	console.Errorf("%s", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("unable to read answer: %w", err)
+	}
+
+	return strings.ToLower(strings.TrimSpace(answer)), nil
+}
+
+// resolveFailedBatches displays the batches that failed to be committed and asks the operator
+// whether they should be skipped, retried, or the restore aborted. Retries use the operation of
+// the conflict strategy, so under the fail strategy the operator may instead choose to touch the
+// batches, overwriting existing relationships once confirmed.
+func (r *restorer) resolveFailedBatches(ctx context.Context, batches [][]*v1.Relationship, cause error) error {
+	var failedRels uint
+	for _, b := range batches {
+		failedRels += uint(len(b))
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		console.Errorf("\nerror committing %d batches (%d relationships): %s\n", len(batches), failedRels, cause)
+		displayed := 0
+		for _, b := range batches {
+			for _, rel := range b {
+				if displayed == maxDisplayedFailedRels {
+					break
+				}
+
+				relString, err := tuple.V1StringRelationship(rel)
+				if err != nil {
+					return err
+				}
+				console.Errorf("\t%s\n", relString)
+				displayed++
+			}
+		}
+		if remaining := failedRels - uint(displayed); remaining > 0 {
+			console.Errorf("\t... and %d more\n", remaining)
+		}
+
+		prompt := "(s)kip, (r)etry or (a)bort? "
+		if r.conflictStrategy == Fail {
+			prompt = "(s)kip, (r)etry, (t)ouch or (a)bort? "
+		}
+		answer, err := restoreErrorPrompt(prompt)
+		if err != nil {
+			return err
+		}
+
+		operation := r.writeOperation()
+		switch answer {
+		case "s", "skip":
+			r.skippedRels += failedRels
+			r.skippedBatches += uint(len(batches))
+			r.bar.Describe("skipped failed batch")
+			return nil
+		case "t", "touch":
+			if r.conflictStrategy != Fail {
+				console.Errorf("unrecognized option %q\n", answer)
+				continue
+			}
+			if err := r.confirmDestructive("overwrite the existing relationships of the failed batch"); err != nil {
+				console.Errorf("%s\n", err)
+				continue
+			}
+			operation = v1.RelationshipUpdate_OPERATION_TOUCH
+			fallthrough
+		case "r", "retry":
+			r.totalRetries++
+			numLoaded, _, err := r.writeBatchesWithRetry(ctx, batches, operation)
+			if err != nil {
+				cause = err
+				continue
+			}
+
+			r.writtenBatches += uint(len(batches))
+			r.writtenRels += numLoaded
+			r.bar.Describe("restoring relationships from backup")
+			return nil
+		case "a", "abort":
+			r.bar.Describe("backup restore aborted")
+			return fmt.Errorf("restore aborted after error: %w", cause)
+		default:
+			console.Errorf("unrecognized option %q\n", answer)
+		}
+	}
+}
+
 func isAlreadyExistsError(err error) bool {
 	if err == nil {
 		return false
@@ -372,6 +560,16 @@ func isAlreadyExistsError(err error) bool {
 	return isContainsErrorString(err, txConflictCodes...)
 }
 
+func isBatchTooLargeError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// ResourceExhausted is also returned for rate limits and quotas, which
+	// splitting would not help with, so only the size details are matched.
+	return isContainsErrorString(err, batchTooLargeErrorStrings...)
+}
+
 func isRetryableError(err error) bool {
 	if err == nil {
 		return false
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -155,7 +156,7 @@ func TestRestorer(t *testing.T) {
 				expectedSkippedRels += expectedConflicts * tt.batchSize
 			}
 
-			r := newRestorer(testSchema, d, c, tt.prefixFilter, tt.batchSize, tt.batchesPerTransaction, tt.conflictStrategy, tt.disableRetryErrors, 0*time.Second)
+			r := newRestorer(testSchema, d, c, tt.prefixFilter, tt.batchSize, tt.batchesPerTransaction, tt.conflictStrategy, tt.disableRetryErrors, 0*time.Second, false, false, nil)
 			err = r.restoreFromDecoder(context.Background())
 			if expectsError != nil || (expectedConflicts > 0 && tt.conflictStrategy == Fail) {
 				require.ErrorIs(err, expectsError)
@@ -184,6 +185,169 @@ func TestRestorer(t *testing.T) {
 	}
 }
 
+func TestRestorerPauseOnError(t *testing.T) {
+	for _, tt := range []struct {
+		name                string
+		answers             []string
+		commitErrors        []error
+		touchErrors         []error
+		confirmErr          error
+		expectedErr         string
+		expectedWrittenRels uint
+		expectedSkippedRels uint
+		expectedOperations  []v1.RelationshipUpdate_Operation
+	}{
+		{"skips failed batch", []string{"s"}, oneUnrecoverableError, nil, nil, "", 2, 1, nil},
+		{"retries failed batch", []string{"r"}, oneUnrecoverableError, nil, nil, "", 3, 0, []v1.RelationshipUpdate_Operation{v1.RelationshipUpdate_OPERATION_CREATE}},
+		{"prompts again when retry fails", []string{"retry", "skip"}, oneUnrecoverableError, oneUnrecoverableError, nil, "", 2, 1, []v1.RelationshipUpdate_Operation{v1.RelationshipUpdate_OPERATION_CREATE}},
+		{"aborts on request", []string{"a"}, oneUnrecoverableError, nil, nil, "restore aborted after error", 0, 0, nil},
+		{"prompts on conflict with fail strategy", []string{"s"}, oneConflictError, nil, nil, "", 2, 1, nil},
+		{"retries conflict without overwriting", []string{"r", "s"}, oneConflictError, oneConflictError, nil, "", 2, 1, []v1.RelationshipUpdate_Operation{v1.RelationshipUpdate_OPERATION_CREATE}},
+		{"touches failed batch once confirmed", []string{"t"}, oneConflictError, nil, nil, "", 3, 0, []v1.RelationshipUpdate_Operation{v1.RelationshipUpdate_OPERATION_TOUCH}},
+		{"prompts again when touch is not confirmed", []string{"touch", "s"}, oneConflictError, nil, errors.New("not confirmed"), "", 2, 1, nil},
+		{"reprompts on unknown answer", []string{"x", "a"}, oneUnrecoverableError, nil, nil, "restore aborted after error", 0, 0, nil},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			backupFileName := createTestBackup(t, testSchema, testRelationships)
+			d, closer, err := decoderFromArgs(backupFileName)
+			require.NoError(err)
+			t.Cleanup(func() {
+				require.NoError(closer.Close())
+			})
+
+			answers := tt.answers
+			originalPrompt := restoreErrorPrompt
+			restoreErrorPrompt = func(_ string) (string, error) {
+				require.NotEmpty(answers, "unexpected prompt")
+				answer := answers[0]
+				answers = answers[1:]
+				return answer, nil
+			}
+			defer func() {
+				restoreErrorPrompt = originalPrompt
+			}()
+
+			c := &mockClient{
+				t:                              t,
+				schema:                         testSchema,
+				expectedRels:                   testRelationships,
+				expectedBatches:                uint(len(testRelationships)),
+				requestedBatchSize:             1,
+				requestedBatchesPerTransaction: 1,
+				commitErrors:                   tt.commitErrors,
+				touchErrors:                    tt.touchErrors,
+			}
+
+			confirmDestructive := func(_ string) error {
+				return tt.confirmErr
+			}
+			r := newRestorer(testSchema, d, c, "", 1, 1, Fail, false, 0*time.Second, true, false, confirmDestructive)
+			err = r.restoreFromDecoder(context.Background())
+			require.Empty(answers, "not all answers were consumed")
+			if tt.expectedErr != "" {
+				require.ErrorContains(err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(err)
+			require.Equal(tt.expectedWrittenRels, r.writtenRels, "unexpected number of written relationships")
+			require.Equal(tt.expectedSkippedRels, r.skippedRels, "unexpected number of skipped relationships")
+			require.Zero(r.duplicateRels, "resolved relationships should not also be counted as duplicates")
+			require.Equal(tt.expectedOperations, c.operations, "unexpected write operations")
+		})
+	}
+}
+
+func TestRestorerAdaptiveBatching(t *testing.T) {
+	errTooLarge := status.Error(codes.ResourceExhausted, "grpc: trying to send message larger than max (5000000 vs. 4194304)")
+	rels := append([]string{"test/resource:4#reader@test/user:4"}, testRelationships...)
+
+	for _, tt := range []struct {
+		name                 string
+		adaptiveBatching     bool
+		conflictStrategy     ConflictStrategy
+		maxUpdates           int
+		expectedErr          string
+		expectedSplitBatches uint
+		expectedOperation    v1.RelationshipUpdate_Operation
+	}{
+		{"fails without adaptive batching", false, Fail, 1, "trying to send message larger than max", 0, 0},
+		{"splits down to single relationships", true, Fail, 1, "", 3, v1.RelationshipUpdate_OPERATION_CREATE},
+		{"splits only as needed", true, Fail, 2, "", 1, v1.RelationshipUpdate_OPERATION_CREATE},
+		{"touches with the touch strategy", true, Touch, 2, "", 1, v1.RelationshipUpdate_OPERATION_TOUCH},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			backupFileName := createTestBackup(t, testSchema, rels)
+			d, closer, err := decoderFromArgs(backupFileName)
+			require.NoError(err)
+			t.Cleanup(func() {
+				require.NoError(closer.Close())
+			})
+
+			c := &sizeLimitedClient{
+				mockClient: &mockClient{
+					t:                              t,
+					schema:                         testSchema,
+					expectedRels:                   rels,
+					expectedBatches:                1,
+					requestedBatchSize:             4,
+					requestedBatchesPerTransaction: 1,
+					commitErrors:                   []error{errTooLarge},
+				},
+				maxUpdates:  tt.maxUpdates,
+				errTooLarge: errTooLarge,
+			}
+
+			r := newRestorer(testSchema, d, c, "", 4, 1, tt.conflictStrategy, false, 0*time.Second, false, tt.adaptiveBatching, nil)
+			err = r.restoreFromDecoder(context.Background())
+			if tt.expectedErr != "" {
+				require.ErrorContains(err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(err)
+			require.Equal(uint(len(rels)), r.writtenRels)
+			require.Equal(rels, c.written)
+			require.Equal(tt.expectedSplitBatches, r.splitBatches)
+			for _, operation := range c.operations {
+				require.Equal(tt.expectedOperation, operation)
+			}
+		})
+	}
+}
+
+func TestIsBatchTooLargeError(t *testing.T) {
+	require.True(t, isBatchTooLargeError(status.Error(codes.ResourceExhausted, "grpc: trying to send message larger than max (5000000 vs. 4194304)")))
+	require.True(t, isBatchTooLargeError(status.Error(codes.InvalidArgument, "update count of 2000 is greater than maximum allowed of 1000")))
+	require.False(t, isBatchTooLargeError(status.Error(codes.ResourceExhausted, "rate limit exceeded")))
+	require.False(t, isBatchTooLargeError(nil))
+}
+
+// sizeLimitedClient rejects writes of more than maxUpdates relationships.
+type sizeLimitedClient struct {
+	*mockClient
+	maxUpdates  int
+	errTooLarge error
+	written     []string
+	operations  []v1.RelationshipUpdate_Operation
+}
+
+func (c *sizeLimitedClient) WriteRelationships(_ context.Context, in *v1.WriteRelationshipsRequest, _ ...grpc.CallOption) (*v1.WriteRelationshipsResponse, error) {
+	if len(in.Updates) > c.maxUpdates {
+		return nil, c.errTooLarge
+	}
+
+	for _, update := range in.Updates {
+		c.written = append(c.written, tuple.MustV1StringRelationship(update.Relationship))
+		c.operations = append(c.operations, update.Operation)
+	}
+	return &v1.WriteRelationshipsResponse{}, nil
+}
+
 type mockClient struct {
 	client.Client
 	v1.ExperimentalService_BulkImportRelationshipsClient
@@ -203,6 +367,7 @@ type mockClient struct {
 	sendErrors                     []error
 	commitErrors                   []error
 	touchErrors                    []error
+	operations                     []v1.RelationshipUpdate_Operation
 }
 
 func (m *mockClient) BulkImportRelationships(_ context.Context, _ ...grpc.CallOption) (v1.ExperimentalService_BulkImportRelationshipsClient, error) {
@@ -235,6 +400,9 @@ func (m *mockClient) Send(req *v1.BulkImportRelationshipsRequest) error {
 func (m *mockClient) WriteRelationships(_ context.Context, in *v1.WriteRelationshipsRequest, _ ...grpc.CallOption) (*v1.WriteRelationshipsResponse, error) {
 	m.touchedBatches++
 	m.touchedRels += uint(len(in.Updates))
+	for _, update := range in.Updates {
+		m.operations = append(m.operations, update.Operation)
+	}
 	if m.touchedBatches <= uint(len(m.touchErrors)) {
 		return nil, m.touchErrors[m.touchedBatches-1]
 	}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"os/user"
 	"strings"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
@@ -13,16 +15,23 @@ import (
 	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
 	"github.com/authzed/spicedb/pkg/schemadsl/generator"
 	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/typesystem"
+	"github.com/authzed/spicedb/pkg/validationfile"
 	"github.com/ccoveille/go-safecast"
 	"github.com/jzelinskie/cobrautil/v2"
 	"github.com/jzelinskie/stringz"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"github.com/authzed/zed/internal/client"
 	"github.com/authzed/zed/internal/commands"
 	"github.com/authzed/zed/internal/console"
+	"github.com/authzed/zed/internal/decode"
+	"github.com/authzed/zed/pkg/backupformat"
 )
 
 func registerAdditionalSchemaCmds(schemaCmd *cobra.Command) {
@@ -33,14 +42,24 @@ func registerAdditionalSchemaCmds(schemaCmd *cobra.Command) {
 	schemaCmd.AddCommand(schemaWriteCmd)
 	schemaWriteCmd.Flags().Bool("json", false, "output as JSON")
 	schemaWriteCmd.Flags().String("schema-definition-prefix", "", "prefix to add to the schema's definition(s) before writing")
+	schemaWriteCmd.Flags().Bool("append", false, "merge the definitions into the existing schema instead of replacing it")
+	schemaWriteCmd.Flags().Bool("replace-existing", false, "when appending, replace existing definitions with the same name instead of failing")
+	schemaWriteCmd.Flags().String("from-url", "", "fetch the schema from a URL (e.g. a gist or playground link) or a validation file, instead of a file or stdin")
+	schemaWriteCmd.Flags().Bool("dry-run", false, "compile and validate the schema without writing it")
+	schemaWriteCmd.Flags().String("backup-before", "", "save the current schema and its zedtoken to this backup file before writing, as a rollback point")
+	schemaWriteCmd.Flags().String("comment", "", "reason for the change, sent as request metadata and logged locally for auditing")
 
 	schemaCmd.AddCommand(schemaDiffCmd)
+
+	schemaCmd.AddCommand(schemaFilterCmd)
+	schemaFilterCmd.Flags().String("prefix", "", "keep only the definitions and caveats with this prefix")
+	_ = schemaFilterCmd.MarkFlagRequired("prefix")
 }
 
 var schemaWriteCmd = &cobra.Command{
 	Use:               "write <file?>",
 	Args:              cobra.MaximumNArgs(1),
-	Short:             "Write a schema file (.zed or stdin) to the current permissions system",
+	Short:             "Write a schema file (.zed, stdin or --from-url) to the current permissions system",
 	ValidArgsFunction: commands.FileExtensionCompletions("zed"),
 	RunE:              schemaWriteCmdFunc,
 }
@@ -60,6 +79,51 @@ var schemaDiffCmd = &cobra.Command{
 	RunE:  schemaDiffCmdFunc,
 }
 
+var schemaFilterCmd = &cobra.Command{
+	Use:   "filter <file?>",
+	Short: "Print the definitions and caveats of a schema that match a prefix",
+	Long: `Print the definitions and caveats of a schema that match a prefix, failing if the
+result does not type-check on its own.
+
+The schema is read from the file, if one is given, or else from the current permissions system.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: commands.FileExtensionCompletions("zed"),
+	RunE:              schemaFilterCmdFunc,
+}
+
+func schemaFilterCmdFunc(cmd *cobra.Command, args []string) error {
+	var schema string
+	if len(args) == 1 {
+		schemaBytes, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read schema file: %w", err)
+		}
+		schema = string(schemaBytes)
+	} else {
+		client, err := client.NewClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		schema, err = commands.ReadSchema(cmd.Context(), client)
+		if err != nil {
+			return err
+		}
+	}
+
+	if schema == "" {
+		return errors.New("no schema to filter")
+	}
+
+	filtered, err := filterSchemaDefs(schema, cobrautil.MustGetString(cmd, "prefix"))
+	if err != nil {
+		return err
+	}
+
+	console.Println(filtered)
+	return nil
+}
+
 func schemaDiffCmdFunc(_ *cobra.Command, args []string) error {
 	beforeBytes, err := os.ReadFile(args[0])
 	if err != nil {
@@ -179,7 +243,11 @@ func schemaWriteCmdFunc(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	if len(args) == 0 && term.IsTerminal(intFd) {
+	fromURL := cobrautil.MustGetString(cmd, "from-url")
+	if fromURL != "" && len(args) > 0 {
+		return errors.New("cannot provide both a schema file and --from-url")
+	}
+	if len(args) == 0 && fromURL == "" && term.IsTerminal(intFd) {
 		return fmt.Errorf("must provide file path or contents via stdin")
 	}
 
@@ -188,14 +256,20 @@ func schemaWriteCmdFunc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	var schemaBytes []byte
-	switch len(args) {
-	case 1:
+	switch {
+	case fromURL != "":
+		schemaBytes, err = schemaFromURL(fromURL)
+		if err != nil {
+			return err
+		}
+		log.Trace().Str("schema", string(schemaBytes)).Str("url", fromURL).Msg("read schema from URL")
+	case len(args) == 1:
 		schemaBytes, err = os.ReadFile(args[0])
 		if err != nil {
 			return fmt.Errorf("failed to read schema file: %w", err)
 		}
 		log.Trace().Str("schema", string(schemaBytes)).Str("file", args[0]).Msg("read schema from file")
-	case 0:
+	case len(args) == 0:
 		schemaBytes, err = io.ReadAll(os.Stdin)
 		if err != nil {
 			return fmt.Errorf("failed to read schema file: %w", err)
@@ -219,15 +293,58 @@ func schemaWriteCmdFunc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if cobrautil.MustGetBool(cmd, "append") {
+		existingSchemaText, err := commands.ReadSchema(cmd.Context(), client)
+		if err != nil {
+			return err
+		}
+
+		replaceExisting := cobrautil.MustGetBool(cmd, "replace-existing")
+		schemaText, err = mergeSchemas(existingSchemaText, schemaText, replaceExisting)
+		if err != nil {
+			return err
+		}
+
+		if replaceExisting && !cobrautil.MustGetBool(cmd, "dry-run") {
+			if err := commands.ConfirmDestructive(cmd, "replace the existing definitions"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if cobrautil.MustGetBool(cmd, "dry-run") {
+		if err := validateSchema(schemaText, "invalid schema"); err != nil {
+			return err
+		}
+		console.Println("schema is valid; not written due to --dry-run")
+		return nil
+	}
+
+	if backupFile := cobrautil.MustGetString(cmd, "backup-before"); backupFile != "" {
+		if err := backupSchema(cmd.Context(), client, backupFile); err != nil {
+			return err
+		}
+	}
+
+	ctx := cmd.Context()
+	comment := cobrautil.MustGetString(cmd, "comment")
+	if comment != "" {
+		ctx = withSchemaComment(ctx, comment)
+	}
+
 	request := &v1.WriteSchemaRequest{Schema: schemaText}
 	log.Trace().Interface("request", request).Msg("writing schema")
 
-	resp, err := client.WriteSchema(cmd.Context(), request)
+	resp, err := client.WriteSchema(ctx, request)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to write schema")
 	}
 	log.Trace().Interface("response", resp).Msg("wrote schema")
 
+	if comment != "" {
+		log.Info().Str("user", currentUsername()).Str("comment", comment).Str("revision", resp.GetWrittenAt().GetToken()).Msg("wrote schema")
+	}
+
 	if cobrautil.MustGetBool(cmd, "json") {
 		prettyProto, err := commands.PrettyProto(resp)
 		if err != nil {
@@ -241,6 +358,163 @@ func schemaWriteCmdFunc(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// schemaCommentMetadataKey is the request metadata key under which the
+// --comment of a schema write is sent, percent-encoded.
+const schemaCommentMetadataKey = "zed-schema-comment"
+
+// withSchemaComment attaches the comment to the outgoing request metadata,
+// so servers that record metadata can trace why the schema changed.
+func withSchemaComment(ctx context.Context, comment string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, schemaCommentMetadataKey, url.PathEscape(comment))
+}
+
+// currentUsername returns the name of the local user, for audit logging.
+func currentUsername() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// backupSchema saves the current schema, and the revision at which it was
+// read, to a backup file without any relationships. If no schema has been
+// written yet, the backup holds an empty schema.
+func backupSchema(ctx context.Context, c client.Client, filename string) (err error) {
+	if filename == "-" {
+		return errors.New("--backup-before must be a file")
+	}
+
+	resp, err := c.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+	if err != nil {
+		if status.Code(err) != codes.NotFound {
+			return fmt.Errorf("error reading schema to back up: %w", err)
+		}
+		log.Debug().Msg("no schema defined, backing up an empty schema")
+		resp = &v1.ReadSchemaResponse{}
+	}
+
+	token := resp.ReadAt
+	if token == nil {
+		token = &v1.ZedToken{}
+	}
+
+	f, err := createBackupFile(filename)
+	if err != nil {
+		return err
+	}
+	defer func(e *error) { *e = errors.Join(*e, f.Close()) }(&err)
+
+	encoder, err := backupformat.NewEncoder(f, resp.SchemaText, token)
+	if err != nil {
+		return fmt.Errorf("error creating schema backup: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("error writing schema backup: %w", err)
+	}
+
+	log.Info().Str("filename", filename).Str("zedtoken", token.Token).Msg("backed up the current schema")
+	return nil
+}
+
+// schemaFromURL fetches the schema from the URL with the same decoders as
+// validate, extracting the schema when the URL is of a full validation file.
+func schemaFromURL(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema URL: %w", err)
+	}
+
+	decoder, err := decode.DecoderForURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed validationfile.ValidationFile
+	if _, _, err := decoder(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to read schema from %s: %w", rawURL, err)
+	}
+	return []byte(parsed.Schema.Schema), nil
+}
+
+// validateSchema compiles the schema and validates the type system of each of
+// its definitions, catching the errors WriteSchema would otherwise only report
+// from the server, such as relations referencing undefined definitions. Errors
+// are prefixed with errPrefix.
+func validateSchema(schemaText, errPrefix string) error {
+	compiled, err := compiler.Compile(
+		compiler.InputSchema{Source: input.Source("schema"), SchemaString: schemaText},
+		compiler.AllowUnprefixedObjectType(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", errPrefix, err)
+	}
+
+	for _, def := range compiled.ObjectDefinitions {
+		ts, err := typesystem.NewNamespaceTypeSystem(def, typesystem.ResolverForSchema(*compiled))
+		if err != nil {
+			return fmt.Errorf("%s: %w", errPrefix, err)
+		}
+		if _, err := ts.Validate(context.Background()); err != nil {
+			return fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+	return nil
+}
+
+// mergeSchemas adds the definitions and caveats of the additional schema to
+// the existing schema. Definitions that already exist are replaced in place if
+// replaceExisting is true; otherwise they cause an error.
+func mergeSchemas(existingSchemaText, additionalSchemaText string, replaceExisting bool) (string, error) {
+	if existingSchemaText == "" {
+		return additionalSchemaText, nil
+	}
+
+	existing, err := compiler.Compile(
+		compiler.InputSchema{Source: input.Source("existing schema"), SchemaString: existingSchemaText},
+		compiler.AllowUnprefixedObjectType(),
+		compiler.SkipValidation(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error reading existing schema: %w", err)
+	}
+
+	additional, err := compiler.Compile(
+		compiler.InputSchema{Source: input.Source("schema"), SchemaString: additionalSchemaText},
+		compiler.AllowUnprefixedObjectType(),
+		compiler.SkipValidation(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error reading schema: %w", err)
+	}
+
+	merged := existing.OrderedDefinitions
+	indexByName := make(map[string]int, len(merged))
+	for i, def := range merged {
+		indexByName[def.GetName()] = i
+	}
+
+	var collisions []string
+	for _, def := range additional.OrderedDefinitions {
+		i, ok := indexByName[def.GetName()]
+		switch {
+		case !ok:
+			indexByName[def.GetName()] = len(merged)
+			merged = append(merged, def)
+		case replaceExisting:
+			merged[i] = def
+		default:
+			collisions = append(collisions, def.GetName())
+		}
+	}
+
+	if len(collisions) > 0 {
+		return "", fmt.Errorf("definitions already exist in the schema: %s; use --replace-existing to overwrite them", strings.Join(collisions, ", "))
+	}
+
+	generated, _, err := generator.GenerateSchema(merged)
+	return generated, err
+}
+
 // rewriteSchema rewrites the given existing schema to include the specified prefix on all definitions.
 func rewriteSchema(existingSchemaText string, definitionPrefix string) (string, error) {
 	if definitionPrefix == "" {
@@ -2,9 +2,20 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/zed/internal/console"
+	zedtesting "github.com/authzed/zed/internal/testing"
 )
 
 func TestDeterminePrefixForSchema(t *testing.T) {
@@ -115,3 +126,215 @@ caveat test/some_caveat(someCondition int) {
 		})
 	}
 }
+
+func TestValidateSchema(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		schema      string
+		expectedErr string
+	}{
+		{"valid", "definition user {}\ndefinition document {\n\trelation viewer: user\n\tpermission view = viewer\n}", ""},
+		{"syntax error", "definition user {", "invalid schema"},
+		{"undefined subject type", "definition document {\n\trelation viewer: user\n}", "user"},
+		{"undefined relation in permission", "definition user {}\ndefinition document {\n\tpermission view = editor\n}", "editor"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSchema(tt.schema, "invalid schema")
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestMergeSchemas(t *testing.T) {
+	tests := []struct {
+		name             string
+		existingSchema   string
+		additionalSchema string
+		replaceExisting  bool
+		expectedSchema   string
+		expectedErr      string
+	}{
+		{
+			"empty existing schema",
+			"",
+			"definition team/user {}",
+			false,
+			"definition team/user {}",
+			"",
+		},
+		{
+			"appends new definitions",
+			"definition other/user {}",
+			`definition team/user {}
+
+caveat team/is_admin(admin bool) { admin }`,
+			false,
+			`definition other/user {}
+
+definition team/user {}
+
+caveat team/is_admin(admin bool) {
+	admin
+}`,
+			"",
+		},
+		{
+			"collision without replace",
+			"definition team/user {}\n\ndefinition team/group {}",
+			"definition team/user {}\n\ndefinition team/group {}\n\ndefinition team/org {}",
+			false,
+			"",
+			"definitions already exist in the schema: team/user, team/group",
+		},
+		{
+			"collision with replace",
+			"definition team/user {}\n\ndefinition other/user {}",
+			"definition team/user {\n\trelation self: team/user\n}",
+			true,
+			`definition team/user {
+	relation self: team/user
+}
+
+definition other/user {}`,
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			merged, err := mergeSchemas(test.existingSchema, test.additionalSchema, test.replaceExisting)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectedSchema, merged)
+		})
+	}
+}
+
+func TestSchemaFromURL(t *testing.T) {
+	const schema = "definition user {}\n\ndefinition document {\n\trelation viewer: user\n}"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/schema.zed":
+			_, _ = w.Write([]byte(schema))
+		case "/validation.yaml":
+			_, _ = w.Write([]byte("schema: |-\n  definition user {}\n\n  definition document {\n  \trelation viewer: user\n  }\nrelationships: |-\n  document:1#viewer@user:1\n"))
+		default:
+			_, _ = w.Write([]byte("definition broken {"))
+		}
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		name        string
+		url         string
+		expectedErr string
+	}{
+		{"schema", srv.URL + "/schema.zed", ""},
+		{"validation file", srv.URL + "/validation.yaml", ""},
+		{"invalid schema", srv.URL + "/broken.zed", "failed to read schema from"},
+		{"unsupported scheme", "ftp://example.com/schema.zed", "ftp scheme not supported"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			schemaBytes, err := schemaFromURL(tt.url)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, schema, string(schemaBytes))
+		})
+	}
+}
+
+func TestSchemaFilterCmdFunc(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "schema.zed")
+	require.NoError(t, os.WriteFile(schemaPath, []byte(`definition tenant1/user {}
+
+definition tenant1/document {
+	relation viewer: tenant1/user
+}
+
+definition tenant2/user {}`), 0o600))
+
+	previous := console.Println
+	defer func() {
+		console.Println = previous
+	}()
+	var lines []string
+	console.Println = func(values ...any) {
+		lines = append(lines, fmt.Sprint(values...))
+	}
+
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t, zedtesting.StringFlag{FlagName: "prefix", FlagValue: "tenant1"})
+	require.NoError(t, schemaFilterCmdFunc(cmd, []string{schemaPath}))
+	require.Equal(t, []string{"definition tenant1/user {}\n\ndefinition tenant1/document {\n\trelation viewer: tenant1/user\n}"}, lines)
+
+	cmd = zedtesting.CreateTestCobraCommandWithFlagValue(t, zedtesting.StringFlag{FlagName: "prefix", FlagValue: "tenant3"})
+	require.ErrorContains(t, schemaFilterCmdFunc(cmd, []string{schemaPath}), "filtered all definitions from schema")
+}
+
+func TestBackupSchema(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	c, err := zedtesting.ClientFromConn(conn)(nil)
+	require.NoError(t, err)
+
+	// Without a schema, an empty one is backed up.
+	emptyBackup := filepath.Join(t.TempDir(), "empty.zedbackup")
+	require.NoError(t, backupSchema(ctx, c, emptyBackup))
+
+	d, closer, err := decoderFromArgs(emptyBackup)
+	require.NoError(t, err)
+	require.Empty(t, d.Schema())
+	require.NoError(t, d.Close())
+	require.NoError(t, closer.Close())
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	schemaBackup := filepath.Join(t.TempDir(), "schema.zedbackup")
+	require.NoError(t, backupSchema(ctx, c, schemaBackup))
+	require.ErrorContains(t, backupSchema(ctx, c, schemaBackup), "backup file already exists")
+
+	d, closer, err = decoderFromArgs(schemaBackup)
+	require.NoError(t, err)
+	defer func() {
+		_ = d.Close()
+		_ = closer.Close()
+	}()
+	require.Equal(t, testSchema, d.Schema())
+	require.NotEmpty(t, d.ZedToken().Token)
+
+	rel, err := d.Next()
+	require.NoError(t, err)
+	require.Nil(t, rel)
+}
+
+func TestWithSchemaComment(t *testing.T) {
+	ctx := withSchemaComment(context.Background(), "add viewer, for ticket #42 ✓")
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	require.True(t, ok)
+	values := md.Get(schemaCommentMetadataKey)
+	require.Len(t, values, 1)
+
+	comment, err := url.PathUnescape(values[0])
+	require.NoError(t, err)
+	require.Equal(t, "add viewer, for ticket #42 ✓", comment)
+}
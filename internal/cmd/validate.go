@@ -18,6 +18,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/jzelinskie/cobrautil/v2"
 	"github.com/muesli/termenv"
+	"gopkg.in/yaml.v3"
 
 	"github.com/authzed/zed/internal/commands"
 	"github.com/authzed/zed/internal/console"
@@ -47,6 +48,7 @@ var (
 
 func registerValidateCmd(cmd *cobra.Command) {
 	validateCmd.Flags().Bool("force-color", false, "force color code output even in non-tty environments")
+	validateCmd.Flags().String("write-expected", "", "write the computed expected relations to the given file as a validation-file `validation` block")
 	cmd.AddCommand(validateCmd)
 }
 
@@ -60,6 +62,9 @@ var validateCmd = &cobra.Command{
 	From a local file (no prefix):
 		zed validate authzed-x7izWU8_2Gw3.yaml
 
+	From a local directory, merged into a single validation file:
+		zed validate ./permissions/
+
 	From a gist:
 		zed validate https://gist.github.com/ecordell/8e3b613a677e3c844742cf24421c08b6
 
@@ -70,7 +75,10 @@ var validateCmd = &cobra.Command{
 		zed validate https://pastebin.com/8qU45rVK
 
 	From a devtools instance:
-		zed validate https://localhost:8443/download`,
+		zed validate https://localhost:8443/download
+
+	From stdin:
+		cat schema.zed | zed validate -`,
 	Args:              cobra.MinimumNArgs(1),
 	ValidArgsFunction: commands.FileExtensionCompletions("zed", "yaml", "zaml"),
 	PreRunE:           validatePreRunE,
@@ -101,6 +109,22 @@ func validateCmdFunc(cmd *cobra.Command, filenames []string) error {
 		successfullyValidatedFiles = 0
 	)
 
+	writeExpected := cobrautil.MustGetString(cmd, "write-expected")
+	if writeExpected != "" && totalFiles > 1 {
+		return errors.New("--write-expected can only be used when validating a single file")
+	}
+
+	// stdin can only be read once, so a second "-" would validate nothing.
+	stdinArgs := 0
+	for _, filename := range filenames {
+		if filename == "-" {
+			stdinArgs++
+		}
+	}
+	if stdinArgs > 1 {
+		return errors.New(`stdin ("-") can only be validated once`)
+	}
+
 	for _, filename := range filenames {
 		// If we're running over multiple files, print the filename for context/debugging purposes
 		if totalFiles > 1 {
@@ -164,10 +188,21 @@ func validateCmdFunc(cmd *cobra.Command, filenames []string) error {
 		successfullyValidatedFiles++
 
 		// Run expected relations for all parsed files
-		_, erDevErrs, rerr := development.RunValidation(devCtx, &parsed.ExpectedRelations)
+		membership, erDevErrs, rerr := development.RunValidation(devCtx, &parsed.ExpectedRelations)
 		if rerr != nil {
 			return rerr
 		}
+		// Write the computed expected relations before reporting any mismatches,
+		// so that the file can be used to update an outdated validation block.
+		if writeExpected != "" {
+			generated, err := development.GenerateValidation(membership)
+			if err != nil {
+				return fmt.Errorf("failed to generate expected relations: %w", err)
+			}
+			if err := writeExpectedRelations(writeExpected, generated); err != nil {
+				return err
+			}
+		}
 		if erDevErrs != nil {
 			outputDeveloperErrors(validateContents, erDevErrs)
 		}
@@ -203,6 +238,28 @@ func validateCmdFunc(cmd *cobra.Command, filenames []string) error {
 	return nil
 }
 
+// writeExpectedRelations wraps the expected relations generated by the
+// development package in a `validation` key, so the file can be pasted
+// directly into (or merged with) a validation file.
+func writeExpectedRelations(path string, generated string) error {
+	var expected map[string][]string
+	if err := yaml.Unmarshal([]byte(generated), &expected); err != nil {
+		return fmt.Errorf("failed to parse generated expected relations: %w", err)
+	}
+	if expected == nil {
+		expected = map[string][]string{}
+	}
+
+	contents, err := yaml.Marshal(map[string]any{"validation": expected})
+	if err != nil {
+		return fmt.Errorf("failed to marshal expected relations: %w", err)
+	}
+	if err := os.WriteFile(path, contents, 0o600); err != nil {
+		return fmt.Errorf("failed to write expected relations to %s: %w", path, err)
+	}
+	return nil
+}
+
 func ouputErrorWithSource(validateContents []byte, errWithSource spiceerrors.WithSourceError) {
 	console.Printf("%s%s\n", errorPrefix(), errorMessageStyle().Render(errWithSource.Error()))
 	outputForLine(validateContents, errWithSource.LineNumber, errWithSource.SourceCodeString, 0) // errWithSource.LineNumber is 1-indexed
@@ -252,7 +309,7 @@ func outputDeveloperError(devError *devinterface.DeveloperError, lines []string,
 	if devError.CheckResolvedDebugInformation != nil && devError.CheckResolvedDebugInformation.Check != nil {
 		console.Printf("\n  %s\n", traceStyle().Render("Explanation:"))
 		tp := printers.NewTreePrinter()
-		printers.DisplayCheckTrace(devError.CheckResolvedDebugInformation.Check, tp, true)
+		printers.DisplayCheckTrace(devError.CheckResolvedDebugInformation.Check, tp, printers.CheckTraceOptions{HasError: true})
 		tp.PrintIndented()
 	}
 
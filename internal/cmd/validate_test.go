@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/zed/internal/console"
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+func TestValidateWriteExpected(t *testing.T) {
+	dir := t.TempDir()
+	validationPath := filepath.Join(dir, "validation.yaml")
+	require.NoError(t, os.WriteFile(validationPath, []byte(`schema: |-
+  definition user {}
+
+  definition document {
+    relation editor: user
+    relation viewer: user
+    permission view = viewer + editor
+  }
+relationships: |-
+  document:1#viewer@user:alice
+  document:1#editor@user:bob
+validation:
+  document:1#view:
+  - "[user:alice] is <document:1#viewer>"
+  - "[user:bob] is <document:1#editor>"
+`), 0o600))
+
+	previousPrintf, previousPrint := console.Printf, console.Print
+	defer func() {
+		console.Printf, console.Print = previousPrintf, previousPrint
+	}()
+	console.Printf = func(string, ...any) {}
+	console.Print = func(...any) {}
+
+	expectedPath := filepath.Join(dir, "expected.yaml")
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.BoolFlag{FlagName: "force-color"},
+		zedtesting.StringFlag{FlagName: "write-expected", FlagValue: expectedPath},
+	)
+	require.NoError(t, validateCmdFunc(cmd, []string{validationPath}))
+
+	written, err := os.ReadFile(expectedPath)
+	require.NoError(t, err)
+	require.Equal(t, `validation:
+    document:1#view:
+        - '[user:alice] is <document:1#viewer>'
+        - '[user:bob] is <document:1#editor>'
+`, string(written))
+
+	err = validateCmdFunc(cmd, []string{validationPath, validationPath})
+	require.ErrorContains(t, err, "--write-expected can only be used when validating a single file")
+}
+
+func TestValidateStdinOnlyOnce(t *testing.T) {
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.BoolFlag{FlagName: "force-color"},
+		zedtesting.StringFlag{FlagName: "write-expected"},
+	)
+	err := validateCmdFunc(cmd, []string{"-", "-"})
+	require.ErrorContains(t, err, `stdin ("-") can only be validated once`)
+}
@@ -1,14 +1,23 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
 
 	"github.com/authzed/authzed-go/pkg/responsemeta"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/releases"
+	"github.com/google/go-github/v43/github"
 	"github.com/gookit/color"
 	"github.com/jzelinskie/cobrautil/v2"
 	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
@@ -48,16 +57,141 @@ func versionCmdFunc(cmd *cobra.Command, _ []string) error {
 		// version.
 		var headerMD metadata.MD
 		_, _ = client.ReadSchema(cmd.Context(), &v1.ReadSchemaRequest{}, grpc.Header(&headerMD))
-		version := headerMD.Get(string(responsemeta.ServerVersion))
 
 		blue := color.FgLightBlue.Render
 		fmt.Print(blue("service: "))
-		if len(version) == 1 {
-			console.Println(version[0])
+		if version, ok := serverVersionFromHeaders(headerMD); ok {
+			console.Println(version)
 		} else {
 			console.Println("(unknown)")
 		}
 	}
 
+	if cobrautil.MustGetBool(cmd, "check-update") && !cobrautil.MustGetBool(cmd, "skip-version-check") {
+		return checkForUpdate(cmd.Context())
+	}
+
+	return nil
+}
+
+// serverVersionFromHeaders returns the version of SpiceDB reported in the
+// response headers of a request, if the server reported one.
+func serverVersionFromHeaders(headerMD metadata.MD) (string, bool) {
+	version := headerMD.Get(string(responsemeta.ServerVersion))
+	if len(version) != 1 {
+		return "", false
+	}
+	return version[0], true
+}
+
+// errUpdateAvailable is returned by `version --check-update` when a newer
+// release of zed exists; zed exits with updateAvailableExitCode for it.
+var errUpdateAvailable = errors.New("a newer version of zed is available")
+
+const (
+	updateAvailableExitCode = 2
+	updateCheckCacheTTL     = time.Hour
+	updateCheckCacheFile    = "latest-release.json"
+)
+
+// currentZedVersion defines an (overridable) means of looking up the version
+// of this build of zed.
+var currentZedVersion = func() (string, error) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", nil
+	}
+	return cobrautil.VersionWithFallbacks(bi), nil
+}
+
+// getLatestZedRelease defines an (overridable) means of looking up the latest
+// released version of zed.
+var getLatestZedRelease = func(ctx context.Context) (*releases.Release, error) {
+	release, _, err := github.NewClient(nil).Repositories.GetLatestRelease(ctx, "authzed", "zed")
+	if err != nil {
+		return nil, err
+	}
+
+	return &releases.Release{
+		Version:     release.GetTagName(),
+		PublishedAt: release.GetPublishedAt().UTC(),
+		ViewURL:     release.GetHTMLURL(),
+	}, nil
+}
+
+// updateCheckCacheDir defines an (overridable) directory in which the latest
+// release is cached between update checks.
+var updateCheckCacheDir = func() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "zed"), nil
+}
+
+type cachedRelease struct {
+	Release   releases.Release `json:"release"`
+	CheckedAt time.Time        `json:"checked_at"`
+}
+
+func checkForUpdate(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	state, currentVersion, release, err := releases.CheckIsLatestVersion(ctx, currentZedVersion, latestZedReleaseWithCache)
+	if err != nil {
+		// The update check is informational, so being offline or rate limited
+		// does not fail the command.
+		log.Warn().Err(err).Msg("unable to check for a newer version of zed")
+		return nil
+	}
+
+	switch state {
+	case releases.UpdateAvailable:
+		console.Printf("zed %s is available (this is %s). See: %s\n", release.Version, currentVersion, release.ViewURL)
+		return errUpdateAvailable
+	case releases.UpToDate:
+		console.Printf("zed %s is the latest version\n", currentVersion)
+	case releases.UnreleasedVersion:
+		console.Printf("zed %q is not a released version; skipping update check\n", currentVersion)
+	default:
+		console.Printf("unable to determine whether a newer version of zed is available\n")
+	}
 	return nil
 }
+
+// latestZedReleaseWithCache returns the latest release of zed, looking it up
+// at most once per updateCheckCacheTTL. Caching is best-effort.
+func latestZedReleaseWithCache(ctx context.Context) (*releases.Release, error) {
+	cacheDir, err := updateCheckCacheDir()
+	if err != nil {
+		log.Debug().Err(err).Msg("unable to determine cache directory for update check")
+		return getLatestZedRelease(ctx)
+	}
+
+	cachePath := filepath.Join(cacheDir, updateCheckCacheFile)
+	if cacheBytes, err := os.ReadFile(cachePath); err == nil {
+		var cached cachedRelease
+		if err := json.Unmarshal(cacheBytes, &cached); err == nil && time.Since(cached.CheckedAt) < updateCheckCacheTTL {
+			return &cached.Release, nil
+		}
+	}
+
+	release, err := getLatestZedRelease(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheBytes, err := json.Marshal(cachedRelease{Release: *release, CheckedAt: time.Now()})
+	if err == nil {
+		err = os.MkdirAll(cacheDir, 0o700)
+	}
+	if err == nil {
+		err = os.WriteFile(cachePath, cacheBytes, 0o600)
+	}
+	if err != nil {
+		log.Debug().Err(err).Msg("unable to cache latest zed release")
+	}
+
+	return release, nil
+}
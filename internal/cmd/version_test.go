@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/authzed/spicedb/pkg/releases"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestZedReleaseWithCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	originalCacheDir := updateCheckCacheDir
+	originalGetLatest := getLatestZedRelease
+	defer func() {
+		updateCheckCacheDir = originalCacheDir
+		getLatestZedRelease = originalGetLatest
+	}()
+	updateCheckCacheDir = func() (string, error) { return cacheDir, nil }
+
+	var lookups int
+	latest := &releases.Release{Version: "v0.30.0", ViewURL: "https://github.com/authzed/zed/releases/tag/v0.30.0"}
+	getLatestZedRelease = func(context.Context) (*releases.Release, error) {
+		lookups++
+		return latest, nil
+	}
+
+	// The first lookup populates the cache, which serves the second.
+	for range 2 {
+		release, err := latestZedReleaseWithCache(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "v0.30.0", release.Version)
+		require.Equal(t, latest.ViewURL, release.ViewURL)
+	}
+	require.Equal(t, 1, lookups)
+
+	// An expired cache entry is looked up again.
+	cacheBytes, err := json.Marshal(cachedRelease{Release: releases.Release{Version: "v0.29.0"}, CheckedAt: time.Now().Add(-2 * updateCheckCacheTTL)})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, updateCheckCacheFile), cacheBytes, 0o600))
+
+	release, err := latestZedReleaseWithCache(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "v0.30.0", release.Version)
+	require.Equal(t, 2, lookups)
+
+	// Lookup failures are returned and not cached.
+	require.NoError(t, os.Remove(filepath.Join(cacheDir, updateCheckCacheFile)))
+	getLatestZedRelease = func(context.Context) (*releases.Release, error) {
+		return nil, errors.New("rate limited")
+	}
+	_, err = latestZedReleaseWithCache(context.Background())
+	require.ErrorContains(t, err, "rate limited")
+	require.NoFileExists(t, filepath.Join(cacheDir, updateCheckCacheFile))
+}
+
+func TestCheckForUpdateLookupFailure(t *testing.T) {
+	originalCacheDir := updateCheckCacheDir
+	originalGetLatest := getLatestZedRelease
+	originalCurrent := currentZedVersion
+	defer func() {
+		updateCheckCacheDir = originalCacheDir
+		getLatestZedRelease = originalGetLatest
+		currentZedVersion = originalCurrent
+	}()
+	cacheDir := t.TempDir()
+	updateCheckCacheDir = func() (string, error) { return cacheDir, nil }
+	currentZedVersion = func() (string, error) { return "v0.29.0", nil }
+
+	// A failed lookup, such as when offline, does not fail the command.
+	getLatestZedRelease = func(context.Context) (*releases.Release, error) {
+		return nil, errors.New("dial tcp: lookup api.github.com: no such host")
+	}
+	require.NoError(t, checkForUpdate(context.Background()))
+
+	getLatestZedRelease = func(context.Context) (*releases.Release, error) {
+		return &releases.Release{Version: "v0.30.0"}, nil
+	}
+	require.ErrorIs(t, checkForUpdate(context.Background()), errUpdateAvailable)
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/tuple"
+
+	"github.com/authzed/zed/internal/client"
+)
+
+// validateCheckCaveatContext returns an error if the check's caveat context has
+// fields that are not parameters of any caveat the check can evaluate, or if
+// the schema cannot be read to find out.
+//
+// SpiceDB evaluates every caveat on the path of a check, whether on the
+// resource's or the subject's relationships, with the same context, so the
+// only mistake that can be caught is a field no such caveat reads.
+//
+// The schema can only be read at the latest revision, so checks at an exact
+// snapshot cannot be validated.
+func validateCheckCaveatContext(ctx context.Context, c client.Client, request *v1.CheckPermissionRequest) error {
+	if len(request.GetContext().GetFields()) == 0 {
+		return nil
+	}
+	if request.GetConsistency().GetAtExactSnapshot() != nil {
+		return errors.New("--validate-caveat-context cannot be combined with --consistency-at-exactly, as the schema can only be read at the latest revision")
+	}
+
+	schemaText, err := ReadSchema(ctx, c)
+	if err != nil {
+		return fmt.Errorf("unable to read the schema to validate the caveat context: %w", err)
+	}
+
+	parameters, err := reachableCaveatParameters(schemaText, request.Resource.ObjectType, request.Permission)
+	if err != nil {
+		return err
+	}
+
+	var unused []string
+	for field := range request.Context.Fields {
+		if _, ok := parameters[field]; !ok {
+			unused = append(unused, field)
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+
+	slices.Sort(unused)
+	if len(parameters) == 0 {
+		return fmt.Errorf("caveat context was given, but no caveat is on the path of %s#%s", request.Resource.ObjectType, request.Permission)
+	}
+	return fmt.Errorf("caveat context fields %s are not parameters of any caveat on the path of %s#%s", strings.Join(unused, ", "), request.Resource.ObjectType, request.Permission)
+}
+
+// reachableCaveatParameters returns the parameters of every caveat that can be
+// evaluated when checking the permission or relation on the resource type.
+func reachableCaveatParameters(schemaText, resourceType, permission string) (map[string]struct{}, error) {
+	compiled, err := compiler.Compile(
+		compiler.InputSchema{Source: input.Source("schema"), SchemaString: schemaText},
+		compiler.AllowUnprefixedObjectType(),
+		compiler.SkipValidation(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema: %w", err)
+	}
+
+	w := caveatWalker{
+		definitions: make(map[string]*core.NamespaceDefinition, len(compiled.ObjectDefinitions)),
+		caveats:     make(map[string]*core.CaveatDefinition, len(compiled.CaveatDefinitions)),
+		visited:     make(map[string]struct{}),
+		parameters:  make(map[string]struct{}),
+	}
+	for _, def := range compiled.ObjectDefinitions {
+		w.definitions[def.Name] = def
+	}
+	for _, caveat := range compiled.CaveatDefinitions {
+		w.caveats[caveat.Name] = caveat
+	}
+
+	if w.relation(resourceType, permission) == nil {
+		return nil, fmt.Errorf("%s#%s is not defined in the schema", resourceType, permission)
+	}
+
+	w.visitRelation(resourceType, permission)
+	return w.parameters, nil
+}
+
+// caveatWalker walks the relations a check can reach, collecting the
+// parameters of the caveats on them.
+type caveatWalker struct {
+	definitions map[string]*core.NamespaceDefinition
+	caveats     map[string]*core.CaveatDefinition
+	visited     map[string]struct{}
+	parameters  map[string]struct{}
+}
+
+func (w caveatWalker) relation(definitionName, relationName string) *core.Relation {
+	for _, rel := range w.definitions[definitionName].GetRelation() {
+		if rel.Name == relationName {
+			return rel
+		}
+	}
+	return nil
+}
+
+func (w caveatWalker) visitRelation(definitionName, relationName string) {
+	key := definitionName + "#" + relationName
+	if _, ok := w.visited[key]; ok {
+		return
+	}
+	w.visited[key] = struct{}{}
+
+	rel := w.relation(definitionName, relationName)
+	for _, allowed := range rel.GetTypeInformation().GetAllowedDirectRelations() {
+		if caveatName := allowed.GetRequiredCaveat().GetCaveatName(); caveatName != "" {
+			for parameter := range w.caveats[caveatName].GetParameterTypes() {
+				w.parameters[parameter] = struct{}{}
+			}
+		}
+
+		if subjectRelation := allowed.GetRelation(); subjectRelation != "" && subjectRelation != tuple.Ellipsis {
+			w.visitRelation(allowed.Namespace, subjectRelation)
+		}
+	}
+
+	w.visitRewrite(definitionName, rel.GetUsersetRewrite())
+}
+
+func (w caveatWalker) visitRewrite(definitionName string, rewrite *core.UsersetRewrite) {
+	for _, operation := range []*core.SetOperation{rewrite.GetUnion(), rewrite.GetIntersection(), rewrite.GetExclusion()} {
+		for _, child := range operation.GetChild() {
+			switch {
+			case child.GetComputedUserset() != nil:
+				w.visitRelation(definitionName, child.GetComputedUserset().Relation)
+			case child.GetTupleToUserset() != nil:
+				ttu := child.GetTupleToUserset()
+				w.visitArrow(definitionName, ttu.GetTupleset().GetRelation(), ttu.GetComputedUserset().GetRelation())
+			case child.GetFunctionedTupleToUserset() != nil:
+				ttu := child.GetFunctionedTupleToUserset()
+				w.visitArrow(definitionName, ttu.GetTupleset().GetRelation(), ttu.GetComputedUserset().GetRelation())
+			case child.GetUsersetRewrite() != nil:
+				w.visitRewrite(definitionName, child.GetUsersetRewrite())
+			}
+		}
+	}
+}
+
+func (w caveatWalker) visitArrow(definitionName, tuplesetRelation, computedRelation string) {
+	w.visitRelation(definitionName, tuplesetRelation)
+	for _, allowed := range w.relation(definitionName, tuplesetRelation).GetTypeInformation().GetAllowedDirectRelations() {
+		if w.relation(allowed.Namespace, computedRelation) != nil {
+			w.visitRelation(allowed.Namespace, computedRelation)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const caveatContextTestSchema = `caveat on_weekday(day string) {
+	day != "saturday" && day != "sunday"
+}
+
+caveat from_ip(ip ipaddress, allowed list<ipaddress>) {
+	ip in allowed
+}
+
+caveat unused(flag bool) {
+	flag
+}
+
+definition user {}
+
+definition group {
+	relation member: user | user with from_ip
+}
+
+definition folder {
+	relation viewer: group#member
+}
+
+definition document {
+	relation parent: folder
+	relation editor: user with on_weekday
+	relation owner: user
+
+	permission edit = editor + owner
+	permission view = edit + parent->viewer
+	permission own = owner
+}`
+
+func TestReachableCaveatParameters(t *testing.T) {
+	for _, tt := range []struct {
+		permission  string
+		expected    []string
+		expectedErr string
+	}{
+		{permission: "own"},
+		{permission: "editor", expected: []string{"day"}},
+		{permission: "edit", expected: []string{"day"}},
+		{permission: "view", expected: []string{"allowed", "day", "ip"}},
+		{permission: "missing", expectedErr: "document#missing is not defined in the schema"},
+	} {
+		t.Run(tt.permission, func(t *testing.T) {
+			parameters, err := reachableCaveatParameters(caveatContextTestSchema, "document", tt.permission)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, slices.Sorted(maps.Keys(parameters)))
+		})
+	}
+}
+
+type schemaOnlyClient struct {
+	*mockClient
+	schema string
+	err    error
+}
+
+func (c *schemaOnlyClient) ReadSchema(_ context.Context, _ *v1.ReadSchemaRequest, _ ...grpc.CallOption) (*v1.ReadSchemaResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &v1.ReadSchemaResponse{SchemaText: c.schema}, nil
+}
+
+func TestValidateCheckCaveatContext(t *testing.T) {
+	c := &schemaOnlyClient{mockClient: &mockClient{t: t}, schema: caveatContextTestSchema}
+	for _, tt := range []struct {
+		name          string
+		permission    string
+		caveatContext map[string]any
+		expectedErr   string
+	}{
+		{name: "no context", permission: "own"},
+		{name: "used fields", permission: "view", caveatContext: map[string]any{"day": "monday", "ip": "10.0.0.1"}},
+		{name: "unused fields", permission: "edit", caveatContext: map[string]any{"day": "monday", "ip": "10.0.0.1", "flag": true}, expectedErr: "caveat context fields flag, ip are not parameters of any caveat on the path of document#edit"},
+		{name: "no caveats", permission: "own", caveatContext: map[string]any{"day": "monday"}, expectedErr: "no caveat is on the path of document#own"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &v1.CheckPermissionRequest{
+				Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: "1"},
+				Permission: tt.permission,
+			}
+			if tt.caveatContext != nil {
+				caveatContext, err := structpb.NewStruct(tt.caveatContext)
+				require.NoError(t, err)
+				request.Context = caveatContext
+			}
+
+			err := validateCheckCaveatContext(context.Background(), c, request)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	caveatContext, err := structpb.NewStruct(map[string]any{"day": "monday"})
+	require.NoError(t, err)
+	request := &v1.CheckPermissionRequest{
+		Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: "1"},
+		Permission: "view",
+		Context:    caveatContext,
+	}
+
+	failing := &schemaOnlyClient{mockClient: &mockClient{t: t}, err: status.Error(codes.PermissionDenied, "cannot read schema")}
+	require.ErrorContains(t, validateCheckCaveatContext(context.Background(), failing, request), "unable to read the schema to validate the caveat context")
+
+	request.Consistency = &v1.Consistency{Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: &v1.ZedToken{Token: "token"}}}
+	require.ErrorContains(t, validateCheckCaveatContext(context.Background(), c, request), "cannot be combined with --consistency-at-exactly")
+}
@@ -6,6 +6,7 @@ import (
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
 	"github.com/authzed/zed/internal/client"
@@ -30,12 +31,6 @@ func FileExtensionCompletions(extension ...string) func(cmd *cobra.Command, args
 
 func GetArgs(fields ...CompletionArgumentType) func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		// Read the current schema, if any.
-		schema, err := readSchema(cmd)
-		if err != nil {
-			return nil, cobra.ShellCompDirectiveError
-		}
-
 		// Find the specified resource type, if any.
 		var resourceType string
 	loop:
@@ -62,6 +57,22 @@ func GetArgs(fields ...CompletionArgumentType) func(cmd *cobra.Command, args []s
 			return comps, cobra.ShellCompDirectiveNoFileComp
 		}
 
+		// Complete the relations and permissions of the resource type from the
+		// reflected schema, which does not require reading the whole schema.
+		if len(args) < len(fields) && fields[len(args)] == Permission && resourceType != "" {
+			names, err := reflectRelationNames(cmd, resourceType)
+			if err == nil {
+				return names, cobra.ShellCompDirectiveNoFileComp
+			}
+			log.Debug().Err(err).Msg("failed to reflect schema, falling back to reading it")
+		}
+
+		// Read the current schema, if any.
+		schema, err := readSchema(cmd)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
 		// Handle # on subject types. If the toComplete contains a valid subject,
 		// then we should return the relation names. Note that we cannot do this
 		// on the # character because shell autocompletion won't send it to us.
@@ -132,6 +143,40 @@ func GetArgs(fields ...CompletionArgumentType) func(cmd *cobra.Command, args []s
 	}
 }
 
+// reflectRelationNames returns the names of the relations and permissions
+// defined on the resource type, using schema reflection.
+func reflectRelationNames(cmd *cobra.Command, resourceType string) ([]string, error) {
+	client, err := client.NewClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &v1.ExperimentalReflectSchemaRequest{
+		Consistency:     &v1.Consistency{Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true}},
+		OptionalFilters: []*v1.ExpSchemaFilter{{OptionalDefinitionNameFilter: resourceType}},
+	}
+
+	resp, err := client.ExperimentalReflectSchema(cmd.Context(), request)
+	if err != nil {
+		return nil, err
+	}
+
+	// The filter matches definition names by prefix.
+	names := make([]string, 0)
+	for _, def := range resp.Definitions {
+		if def.Name != resourceType {
+			continue
+		}
+		for _, relation := range def.Relations {
+			names = append(names, relation.Name)
+		}
+		for _, permission := range def.Permissions {
+			names = append(names, permission.Name)
+		}
+	}
+	return names, nil
+}
+
 func readSchema(cmd *cobra.Command) (*compiler.CompiledSchema, error) {
 	// TODO: we should find a way to cache this
 	client, err := client.NewClient(cmd)
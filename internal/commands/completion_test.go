@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/zed/internal/client"
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+func TestGetArgsPermissionCompletions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+	client.NewClient = zedtesting.ClientFromConn(conn)
+
+	c, err := client.NewClient(nil)
+	require.NoError(t, err)
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema + `
+
+definition test/resourcegroup {
+	relation member: test/user
+}`})
+	require.NoError(t, err)
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(ctx)
+
+	for _, tt := range []struct {
+		name     string
+		complete func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+		args     []string
+		expected []string
+	}{
+		{"check", GetArgs(ResourceID, Permission, SubjectID), []string{"test/resource:1"}, []string{"reader", "writer", "read"}},
+		{"lookup", GetArgs(ResourceType, Permission, SubjectID), []string{"test/resource"}, []string{"reader", "writer", "read"}},
+		{"type sharing a prefix", GetArgs(ResourceType, Permission, SubjectID), []string{"test/resourcegroup"}, []string{"member"}},
+		{"unknown type", GetArgs(ResourceType, Permission, SubjectID), []string{"test/unknown"}, []string{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			completions, directive := tt.complete(cmd, tt.args, "")
+			require.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+			require.Equal(t, tt.expected, completions)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const consistencyTokenFileFlagUsage = "file holding a zedtoken shared across invocations: commands evaluate at least as fresh as it, unless another consistency flag is set, and record the zedtoken of their writes and checks in it"
+
+func registerConsistencyTokenFileFlag(flags *pflag.FlagSet) {
+	flags.String("consistency-token-file", "", consistencyTokenFileFlagUsage)
+}
+
+// readConsistencyTokenFile returns the zedtoken held in the file of
+// --consistency-token-file, or an empty string if the flag is not set or the
+// file does not exist yet.
+func readConsistencyTokenFile(cmd *cobra.Command) (string, error) {
+	filename := cobrautil.MustGetString(cmd, "consistency-token-file")
+	if filename == "" {
+		return "", nil
+	}
+
+	contents, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read consistency token file: %w", err)
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
+
+// recordConsistencyToken writes the zedtoken to the file of
+// --consistency-token-file, if it is set.
+//
+// Zedtokens are opaque, so they cannot be compared: the caller must only
+// record zedtokens known to be at least as fresh as the one in the file, such
+// as those of writes or of reads evaluated with the file's zedtoken.
+func recordConsistencyToken(cmd *cobra.Command, token *v1.ZedToken) error {
+	filename := cobrautil.MustGetString(cmd, "consistency-token-file")
+	if filename == "" || token.GetToken() == "" {
+		return nil
+	}
+
+	log.Trace().Str("token", token.Token).Str("file", filename).Msg("recording consistency token")
+	if err := writeFileAtomically(filename, []byte(token.Token+"\n")); err != nil {
+		return fmt.Errorf("failed to write consistency token file: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomically writes the contents to a temporary file next to the
+// file, and renames it over the file, so that invocations running
+// concurrently never read a partially written file.
+func writeFileAtomically(filename string, contents []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Once renamed, the temporary file no longer exists and this fails.
+		_ = os.Remove(f.Name())
+	}()
+
+	if _, err := f.Write(contents); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// recordReadConsistencyToken records the zedtoken of a read evaluated with
+// the consistency, if it was not explicitly requested at an older revision.
+func recordReadConsistencyToken(cmd *cobra.Command, consistency *v1.Consistency, token *v1.ZedToken) error {
+	if consistency.GetAtExactSnapshot() != nil || consistency.GetMinimizeLatency() {
+		return nil
+	}
+	if consistency.GetAtLeastAsFresh() != nil {
+		// An explicit --consistency-at-least may be older than the file.
+		fileToken, err := readConsistencyTokenFile(cmd)
+		if err != nil {
+			return err
+		}
+		if fileToken != consistency.GetAtLeastAsFresh().Token {
+			return nil
+		}
+	}
+	return recordConsistencyToken(cmd, token)
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+func TestConsistencyTokenFile(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "zedtoken")
+	consistencyCmd := func(atLeast, atExactly string) *v1.Consistency {
+		cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+			zedtesting.StringFlag{FlagName: "consistency-at-least", FlagValue: atLeast},
+			zedtesting.StringFlag{FlagName: "consistency-at-exactly", FlagValue: atExactly},
+			zedtesting.BoolFlag{FlagName: "consistency-full"},
+			zedtesting.BoolFlag{FlagName: "consistency-min-latency"},
+			zedtesting.StringFlag{FlagName: "consistency-token-file", FlagValue: tokenFile},
+			zedtesting.StringFlag{FlagName: "revision"})
+		consistency, err := consistencyFromCmd(cmd)
+		require.NoError(t, err)
+		return consistency
+	}
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file", FlagValue: tokenFile})
+
+	// Until a zedtoken is recorded, the default consistency is used.
+	require.True(t, consistencyCmd("", "").GetMinimizeLatency())
+
+	require.NoError(t, recordConsistencyToken(cmd, &v1.ZedToken{Token: "written"}))
+	contents, err := os.ReadFile(tokenFile)
+	require.NoError(t, err)
+	require.Equal(t, "written\n", string(contents))
+
+	// The file is replaced, rather than written in place, and no temporary
+	// files are left behind.
+	entries, err := os.ReadDir(filepath.Dir(tokenFile))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	fromFile := consistencyCmd("", "")
+	require.Equal(t, "written", fromFile.GetAtLeastAsFresh().GetToken())
+
+	// Explicit consistency flags take precedence over the file.
+	require.Equal(t, "other", consistencyCmd("other", "").GetAtLeastAsFresh().GetToken())
+	require.Equal(t, "other", consistencyCmd("", "other").GetAtExactSnapshot().GetToken())
+
+	for _, tt := range []struct {
+		name        string
+		consistency *v1.Consistency
+		expected    string
+	}{
+		{"at exactly is not recorded", consistencyCmd("", "other"), "written"},
+		{"explicit at least is not recorded", consistencyCmd("other", ""), "written"},
+		{"from file is recorded", fromFile, "checked"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, recordConsistencyToken(cmd, &v1.ZedToken{Token: "written"}))
+			require.NoError(t, recordReadConsistencyToken(cmd, tt.consistency, &v1.ZedToken{Token: "checked"}))
+
+			token, err := readConsistencyTokenFile(cmd)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, token)
+		})
+	}
+}
@@ -1,13 +1,20 @@
 package commands
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/dustin/go-humanize/english"
 
 	"github.com/authzed/authzed-go/pkg/requestmeta"
 	"github.com/authzed/authzed-go/pkg/responsemeta"
@@ -24,6 +31,7 @@ import (
 
 	"github.com/authzed/zed/internal/client"
 	"github.com/authzed/zed/internal/console"
+	"github.com/authzed/zed/internal/grpcutil"
 	"github.com/authzed/zed/internal/printers"
 )
 
@@ -34,6 +42,7 @@ func registerConsistencyFlags(flags *pflag.FlagSet) {
 	flags.String("consistency-at-least", "", "evaluate at least as consistent as the provided zedtoken")
 	flags.Bool("consistency-min-latency", false, "evaluate at the zedtoken preferred by the database")
 	flags.Bool("consistency-full", false, "evaluate at the newest zedtoken in the database")
+	registerConsistencyTokenFileFlag(flags)
 }
 
 func consistencyFromCmd(cmd *cobra.Command) (c *v1.Consistency, err error) {
@@ -62,6 +71,23 @@ func consistencyFromCmd(cmd *cobra.Command) (c *v1.Consistency, err error) {
 		c = &v1.Consistency{Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: &v1.ZedToken{Token: exact}}}
 	}
 
+	if cobrautil.MustGetBool(cmd, "consistency-min-latency") {
+		if c != nil {
+			return nil, ErrMultipleConsistencies
+		}
+		c = &v1.Consistency{Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true}}
+	}
+
+	if c == nil {
+		token, err := readConsistencyTokenFile(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			c = &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: &v1.ZedToken{Token: token}}}
+		}
+	}
+
 	if c == nil {
 		c = &v1.Consistency{Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true}}
 	}
@@ -73,46 +99,97 @@ func RegisterPermissionCmd(rootCmd *cobra.Command) *cobra.Command {
 
 	permissionCmd.AddCommand(checkCmd)
 	checkCmd.Flags().Bool("json", false, "output as JSON")
+	registerCompactFlag(checkCmd)
 	checkCmd.Flags().String("revision", "", "optional revision at which to check")
 	_ = checkCmd.Flags().MarkHidden("revision")
 	checkCmd.Flags().Bool("explain", false, "requests debug information from SpiceDB and prints out a trace of the requests")
+	checkCmd.Flags().Int("explain-depth", 0, "maximum depth of the trace printed by --explain; 0 prints the full trace")
+	checkCmd.Flags().Bool("explain-legend", false, "print a key to the symbols and colors of the trace printed by --explain")
+	checkCmd.Flags().Bool("hide-cached", false, "leave the subproblems resolved from a cache out of the trace printed by --explain")
 	checkCmd.Flags().Bool("schema", false, "requests debug information from SpiceDB and prints out the schema used")
+	checkCmd.Flags().Bool("all-caveats", false, "requests debug information from SpiceDB and prints out every caveat evaluated")
 	checkCmd.Flags().Bool("error-on-no-permission", false, "if true, zed will return exit code 1 if subject does not have unconditional permission")
-	checkCmd.Flags().String("caveat-context", "", "the caveat context to send along with the check, in JSON form")
+	checkCmd.Flags().String("caveat-context", "", "the caveat context to send along with the check, in JSON form; SpiceDB evaluates every caveat on the path of the check, on both resource and subject relationships, with this one context")
+	registerCaveatContextFileFlags(checkCmd.Flags())
+	checkCmd.Flags().Bool("validate-caveat-context", false, "read the schema first and fail if the caveat context has fields that no caveat on the path of the check reads")
+	registerOutputTemplateFlag(checkCmd, "CheckPermissionResponse (e.g. {{.Permissionship}})")
+	checkCmd.Flags().String("assert", "", "if set, zed will return exit code 1 if the permissionship does not match. Possible values: true, false, caveated")
+	checkCmd.Flags().Int("count", 1, "number of times to issue the check; if greater than one, latency statistics are printed")
+	checkCmd.Flags().Int("concurrency", 1, "number of checks issued concurrently when --count is greater than one (0 uses GOMAXPROCS)")
+	checkCmd.Flags().String("repeat-until", "", "re-issue the check until the permissionship matches, returning exit code 1 if it does not within --repeat-timeout. Possible values: true, false, caveated")
+	checkCmd.Flags().Duration("repeat-interval", time.Second, "time to wait between checks issued by --repeat-until")
+	checkCmd.Flags().Duration("repeat-timeout", 30*time.Second, "maximum time to re-issue the check for with --repeat-until")
+	registerSubjectFromTokenFlags(checkCmd)
 	registerConsistencyFlags(checkCmd.Flags())
 
+	permissionCmd.AddCommand(whyNotCmd)
+	whyNotCmd.Flags().String("revision", "", "optional revision at which to check")
+	_ = whyNotCmd.Flags().MarkHidden("revision")
+	whyNotCmd.Flags().String("caveat-context", "", "the caveat context to send along with the check, in JSON form")
+	registerCaveatContextFileFlags(whyNotCmd.Flags())
+	registerConsistencyFlags(whyNotCmd.Flags())
+
 	permissionCmd.AddCommand(checkBulkCmd)
 	checkBulkCmd.Flags().String("revision", "", "optional revision at which to check")
 	checkBulkCmd.Flags().Bool("json", false, "output as JSON")
+	registerCompactFlag(checkBulkCmd)
 	checkBulkCmd.Flags().Bool("explain", false, "requests debug information from SpiceDB and prints out a trace of the requests")
+	checkBulkCmd.Flags().Int("explain-depth", 0, "maximum depth of the trace printed by --explain; 0 prints the full trace")
+	checkBulkCmd.Flags().Bool("explain-legend", false, "print a key to the symbols and colors of the trace printed by --explain")
+	checkBulkCmd.Flags().Bool("hide-cached", false, "leave the subproblems resolved from a cache out of the trace printed by --explain")
 	checkBulkCmd.Flags().Bool("schema", false, "requests debug information from SpiceDB and prints out the schema used")
+	checkBulkCmd.Flags().Bool("all-caveats", false, "requests debug information from SpiceDB and prints out every caveat evaluated")
 	registerConsistencyFlags(checkBulkCmd.Flags())
 
+	registerCheckRelationshipsCmd(permissionCmd)
+
 	permissionCmd.AddCommand(expandCmd)
 	expandCmd.Flags().Bool("json", false, "output as JSON")
+	registerCompactFlag(expandCmd)
 	expandCmd.Flags().String("revision", "", "optional revision at which to check")
+	expandCmd.Flags().Bool("show-zedtoken", false, "print the zedtoken at which the permission was expanded")
+	expandCmd.Flags().Bool("recursive", false, "further expand the subjects that are usersets (e.g. group:eng#member), materializing the full tree")
+	expandCmd.Flags().Uint("max-depth", 10, "with --recursive, the maximum number of nested expansions of usersets")
 	registerConsistencyFlags(expandCmd.Flags())
 
 	// NOTE: `lookup` is an alias of `lookup-resources` (below)
 	// and must have the same list of flags in order for it to work.
 	permissionCmd.AddCommand(lookupCmd)
 	lookupCmd.Flags().Bool("json", false, "output as JSON")
+	registerCompactFlag(lookupCmd)
 	lookupCmd.Flags().String("revision", "", "optional revision at which to check")
 	lookupCmd.Flags().String("caveat-context", "", "the caveat context to send along with the lookup, in JSON form")
+	registerCaveatContextFileFlags(lookupCmd.Flags())
 	lookupCmd.Flags().Uint32("page-limit", 0, "limit of relations returned per page")
+	lookupCmd.Flags().String("cursor", "", "cursor printed by a previous, interrupted lookup with the same arguments and flags, from which to resume it")
+	lookupCmd.Flags().String("resource-id-prefix", "", "only print resources whose IDs start with this prefix; filtered client-side, so it does not reduce the work done by the server")
+	registerOutputTemplateFlag(lookupCmd, "LookupResourcesResponse (e.g. {{.ResourceObjectId}})")
+	registerAsRelationshipsFlag(lookupCmd)
 	registerConsistencyFlags(lookupCmd.Flags())
 
 	permissionCmd.AddCommand(lookupResourcesCmd)
 	lookupResourcesCmd.Flags().Bool("json", false, "output as JSON")
+	registerCompactFlag(lookupResourcesCmd)
 	lookupResourcesCmd.Flags().String("revision", "", "optional revision at which to check")
 	lookupResourcesCmd.Flags().String("caveat-context", "", "the caveat context to send along with the lookup, in JSON form")
+	registerCaveatContextFileFlags(lookupResourcesCmd.Flags())
 	lookupResourcesCmd.Flags().Uint32("page-limit", 0, "limit of relations returned per page")
+	lookupResourcesCmd.Flags().String("cursor", "", "cursor printed by a previous, interrupted lookup with the same arguments and flags, from which to resume it")
+	lookupResourcesCmd.Flags().String("resource-id-prefix", "", "only print resources whose IDs start with this prefix; filtered client-side, so it does not reduce the work done by the server")
+	registerOutputTemplateFlag(lookupResourcesCmd, "LookupResourcesResponse (e.g. {{.ResourceObjectId}})")
+	registerAsRelationshipsFlag(lookupResourcesCmd)
 	registerConsistencyFlags(lookupResourcesCmd.Flags())
 
 	permissionCmd.AddCommand(lookupSubjectsCmd)
 	lookupSubjectsCmd.Flags().Bool("json", false, "output as JSON")
+	registerCompactFlag(lookupSubjectsCmd)
 	lookupSubjectsCmd.Flags().String("revision", "", "optional revision at which to check")
 	lookupSubjectsCmd.Flags().String("caveat-context", "", "the caveat context to send along with the lookup, in JSON form")
+	registerCaveatContextFileFlags(lookupSubjectsCmd.Flags())
+	registerOutputTemplateFlag(lookupSubjectsCmd, "LookupSubjectsResponse (e.g. {{.Subject.SubjectObjectId}})")
+	registerAsRelationshipsFlag(lookupSubjectsCmd)
+	lookupSubjectsCmd.Flags().Bool("only-excluded", false, "only print the subjects excluded from wildcard results (e.g. user:1 for user:* - {user:1}), as JSON with --json")
+	lookupSubjectsCmd.MarkFlagsMutuallyExclusive("only-excluded", "output-template", "as-relationships")
 	registerConsistencyFlags(lookupSubjectsCmd.Flags())
 
 	return permissionCmd
@@ -131,10 +208,20 @@ var checkBulkCmd = &cobra.Command{
 	RunE:  checkBulkCmdFunc,
 }
 
+var whyNotCmd = &cobra.Command{
+	Use:               "why-not <resource:id> <permission> <subject:id>",
+	Short:             "Explain which paths to a permission a subject is missing",
+	Long:              "Checks the permission with debug information and, if the subject does not unconditionally have it, lists each path through the schema that failed and why, such as relations without a relationship to the subject or caveats missing context.",
+	Args:              cobra.ExactArgs(3),
+	ValidArgsFunction: GetArgs(ResourceID, Permission, SubjectID),
+	RunE:              whyNotCmdFunc,
+}
+
 var checkCmd = &cobra.Command{
 	Use:               "check <resource:id> <permission> <subject:id>",
 	Short:             "Check that a permission exists for a subject",
-	Args:              cobra.ExactArgs(3),
+	Long:              "Check that a permission exists for a subject.\n\nThe subject can instead be derived from a claim of a JWT with --subject-from-token, in which case only <resource:id> <permission> are provided.",
+	Args:              cobra.RangeArgs(2, 3),
 	ValidArgsFunction: GetArgs(ResourceID, Permission, SubjectID),
 	RunE:              checkCmdFunc,
 }
@@ -173,36 +260,77 @@ var lookupSubjectsCmd = &cobra.Command{
 	RunE:              lookupSubjectsCmdFunc,
 }
 
-func checkCmdFunc(cmd *cobra.Command, args []string) error {
-	var objectNS, objectID string
-	err := stringz.SplitExact(args[0], ":", &objectNS, &objectID)
+func whyNotCmdFunc(cmd *cobra.Command, args []string) error {
+	request, err := checkPermissionRequestFromArgs(cmd, args)
 	if err != nil {
 		return err
 	}
+	request.WithTracing = true
 
-	relation := args[1]
-
-	subjectNS, subjectID, subjectRel, err := ParseSubject(args[2])
+	client, err := client.NewClient(cmd)
 	if err != nil {
 		return err
 	}
 
-	caveatContext, err := GetCaveatContext(cmd)
+	log.Trace().Interface("request", request).Send()
+
+	ctx := requestmeta.AddRequestHeaders(cmd.Context(), requestmeta.RequestDebugInformation)
+	var trailerMD metadata.MD
+	resp, err := client.CheckPermission(ctx, request, grpc.Trailer(&trailerMD))
 	if err != nil {
 		return err
 	}
 
-	consistency, err := consistencyFromCmd(cmd)
-	if err != nil {
+	if err := printPermissionship(resp); err != nil {
 		return err
 	}
 
-	client, err := client.NewClient(cmd)
+	if resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+		return nil
+	}
+
+	debugInfo, err := debugInformationFromResponse(resp.DebugTrace, trailerMD)
 	if err != nil {
 		return err
 	}
 
-	request := &v1.CheckPermissionRequest{
+	if debugInfo.GetCheck() == nil {
+		return errors.New("no trace was returned for the check")
+	}
+
+	for _, reason := range printers.WhyNot(debugInfo.Check) {
+		console.Printf("- %s\n", reason)
+	}
+	return nil
+}
+
+// checkPermissionRequestFromArgs builds the CheckPermissionRequest for the
+// <resource:id> <permission> <subject:id> arguments and flags.
+func checkPermissionRequestFromArgs(cmd *cobra.Command, args []string) (*v1.CheckPermissionRequest, error) {
+	var objectNS, objectID string
+	err := stringz.SplitExact(args[0], ":", &objectNS, &objectID)
+	if err != nil {
+		return nil, err
+	}
+
+	relation := args[1]
+
+	subjectNS, subjectID, subjectRel, err := ParseSubject(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	caveatContext, err := GetCaveatContext(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	consistency, err := consistencyFromCmd(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.CheckPermissionRequest{
 		Resource: &v1.ObjectReference{
 			ObjectType: objectNS,
 			ObjectId:   objectID,
@@ -217,18 +345,89 @@ func checkCmdFunc(cmd *cobra.Command, args []string) error {
 		},
 		Context:     caveatContext,
 		Consistency: consistency,
+	}, nil
+}
+
+func checkCmdFunc(cmd *cobra.Command, args []string) error {
+	args, err := argsWithSubjectFromToken(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	request, err := checkPermissionRequestFromArgs(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	expected, err := assertedPermissionship(cmd)
+	if err != nil {
+		return err
+	}
+
+	repeatUntil, err := permissionshipFromFlag(cmd, "repeat-until")
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := outputTemplateFromCmd(cmd)
+	if err != nil {
+		return err
+	}
+
+	// --count prints the result once along with latency statistics, so it
+	// cannot honor the flags changing how, or how often, a check is printed.
+	count := cobrautil.MustGetInt(cmd, "count")
+	if count > 1 {
+		switch {
+		case debugInformationRequested(cmd):
+			return errors.New("--count cannot be combined with --explain, --schema or --all-caveats")
+		case repeatUntil != v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED:
+			return errors.New("--count cannot be combined with --repeat-until")
+		case cobrautil.MustGetBool(cmd, "json") || tmpl != nil:
+			return errors.New("--count cannot be combined with --json or --output-template")
+		}
+	}
+
+	client, err := client.NewClient(cmd)
+	if err != nil {
+		return err
 	}
+
 	log.Trace().Interface("request", request).Send()
 
 	ctx := cmd.Context()
-	if cobrautil.MustGetBool(cmd, "explain") || cobrautil.MustGetBool(cmd, "schema") {
+	if cobrautil.MustGetBool(cmd, "validate-caveat-context") {
+		if err := validateCheckCaveatContext(ctx, client, request); err != nil {
+			return err
+		}
+	}
+
+	if count > 1 {
+		resp, err := benchmarkCheck(ctx, client, request, count, cobrautil.MustGetInt(cmd, "concurrency"))
+		if err != nil {
+			return err
+		}
+		return checkResultAssertions(cmd, expected, resp)
+	}
+
+	repeat := repeatUntil != v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED
+	if repeat && debugInformationRequested(cmd) {
+		return errors.New("--repeat-until cannot be combined with --explain, --schema or --all-caveats")
+	}
+
+	if debugInformationRequested(cmd) {
 		log.Info().Msg("debugging requested on check")
 		ctx = requestmeta.AddRequestHeaders(ctx, requestmeta.RequestDebugInformation)
 		request.WithTracing = true
 	}
 
 	var trailerMD metadata.MD
-	resp, err := client.CheckPermission(ctx, request, grpc.Trailer(&trailerMD))
+	var resp *v1.CheckPermissionResponse
+	if repeat {
+		resp, err = repeatCheckUntil(ctx, client, request, repeatUntil, cobrautil.MustGetDuration(cmd, "repeat-interval"), cobrautil.MustGetDuration(cmd, "repeat-timeout"))
+	} else {
+		resp, err = client.CheckPermission(ctx, request, grpc.Trailer(&trailerMD))
+	}
 	if err != nil {
 		var debugInfo *v1.DebugInformation
 
@@ -250,16 +449,136 @@ func checkCmdFunc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if err := recordReadConsistencyToken(cmd, request.Consistency, resp.CheckedAt); err != nil {
+		return err
+	}
+
 	if cobrautil.MustGetBool(cmd, "json") {
-		prettyProto, err := PrettyProto(resp)
+		encoded, err := jsonProtoFromCmd(cmd, resp)
 		if err != nil {
 			return err
 		}
 
-		console.Println(string(prettyProto))
+		console.Println(string(encoded))
+		return checkPermissionshipAssertion(expected, resp)
+	}
+
+	if tmpl != nil {
+		if err := printWithTemplate(tmpl, resp); err != nil {
+			return err
+		}
+	} else if err := printPermissionship(resp); err != nil {
+		return err
+	}
+
+	err = displayDebugInformationIfRequested(cmd, resp.DebugTrace, trailerMD, false)
+	if err != nil {
+		return err
+	}
+
+	return checkResultAssertions(cmd, expected, resp)
+}
+
+// checkResultAssertions applies --assert and --error-on-no-permission to the
+// result of a check.
+func checkResultAssertions(cmd *cobra.Command, expected v1.CheckPermissionResponse_Permissionship, resp *v1.CheckPermissionResponse) error {
+	if err := checkPermissionshipAssertion(expected, resp); err != nil {
+		return err
+	}
+
+	if cobrautil.MustGetBool(cmd, "error-on-no-permission") {
+		if resp.Permissionship != v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+			os.Exit(1)
+		}
+	}
+
+	return nil
+}
+
+var permissionshipNames = map[string]v1.CheckPermissionResponse_Permissionship{
+	"true":     v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION,
+	"false":    v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION,
+	"caveated": v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION,
+}
+
+// assertedPermissionship returns the permissionship provided via --assert,
+// or UNSPECIFIED if no assertion was requested.
+func assertedPermissionship(cmd *cobra.Command) (v1.CheckPermissionResponse_Permissionship, error) {
+	return permissionshipFromFlag(cmd, "assert")
+}
+
+// permissionshipFromFlag returns the permissionship named by the flag, or
+// UNSPECIFIED if the flag is empty.
+func permissionshipFromFlag(cmd *cobra.Command, flagName string) (v1.CheckPermissionResponse_Permissionship, error) {
+	value := strings.TrimSpace(strings.ToLower(cobrautil.MustGetString(cmd, flagName)))
+	if value == "" {
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED, nil
+	}
+
+	permissionship, ok := permissionshipNames[value]
+	if !ok {
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED, fmt.Errorf("unexpected flag '%s' value '%s': should be one of true, false, caveated", flagName, value)
+	}
+	return permissionship, nil
+}
+
+// repeatCheckUntil re-issues the check every interval until its
+// permissionship is the expected one, failing if that does not happen within
+// the timeout.
+func repeatCheckUntil(ctx context.Context, c client.Client, request *v1.CheckPermissionRequest, expected v1.CheckPermissionResponse_Permissionship, interval, timeout time.Duration) (*v1.CheckPermissionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED
+	timedOut := func(attempts int) error {
+		return fmt.Errorf("permissionship was not %s within %s: it was %s after %s", permissionshipName(expected), timeout, permissionshipName(last), english.Plural(attempts, "check", ""))
+	}
+
+	for attempts := 1; ; attempts++ {
+		resp, err := c.CheckPermission(ctx, request)
+		if err != nil {
+			if ctx.Err() != nil && last != v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED {
+				return nil, timedOut(attempts - 1)
+			}
+			return nil, err
+		}
+
+		if resp.Permissionship == expected {
+			log.Debug().Int("attempts", attempts).Msg("check reached the expected permissionship")
+			return resp, nil
+		}
+		last = resp.Permissionship
+		log.Debug().Int("attempts", attempts).Str("permissionship", permissionshipName(last)).Msg("check has not reached the expected permissionship, retrying")
+
+		select {
+		case <-ctx.Done():
+			return nil, timedOut(attempts)
+		case <-ticker.C:
+		}
+	}
+}
+
+func checkPermissionshipAssertion(expected v1.CheckPermissionResponse_Permissionship, resp *v1.CheckPermissionResponse) error {
+	if expected == v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED || expected == resp.Permissionship {
 		return nil
 	}
 
+	return fmt.Errorf("assertion failed: expected %s, got %s", permissionshipName(expected), permissionshipName(resp.Permissionship))
+}
+
+func permissionshipName(permissionship v1.CheckPermissionResponse_Permissionship) string {
+	for name, p := range permissionshipNames {
+		if p == permissionship {
+			return name
+		}
+	}
+	return permissionship.String()
+}
+
+func printPermissionship(resp *v1.CheckPermissionResponse) error {
 	switch resp.Permissionship {
 	case v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION:
 		log.Warn().Strs("fields", resp.PartialCaveatInfo.MissingRequiredContext).Msg("missing fields in caveat context")
@@ -275,18 +594,60 @@ func checkCmdFunc(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("unknown permission response: %v", resp.Permissionship)
 	}
 
-	err = displayDebugInformationIfRequested(cmd, resp.DebugTrace, trailerMD, false)
+	return nil
+}
+
+// benchmarkCheck issues the same check count times across the given number of
+// workers, printing the result of the check once followed by throughput and
+// latency percentiles. It returns the response of the first check to complete.
+func benchmarkCheck(ctx context.Context, c client.Client, request *v1.CheckPermissionRequest, count, concurrency int) (*v1.CheckPermissionResponse, error) {
+	var (
+		lock      sync.Mutex
+		firstResp *v1.CheckPermissionResponse
+	)
+	durations := make([]time.Duration, count)
+
+	start := time.Now()
+	err := grpcutil.ConcurrentBatch(ctx, count, 1, concurrency, func(ctx context.Context, no int, _ int, _ int) error {
+		checkStart := time.Now()
+		resp, err := c.CheckPermission(ctx, request)
+		if err != nil {
+			return err
+		}
+		durations[no] = time.Since(checkStart)
+
+		lock.Lock()
+		defer lock.Unlock()
+		if firstResp == nil {
+			firstResp = resp
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	totalTime := time.Since(start)
 
-	if cobrautil.MustGetBool(cmd, "error-on-no-permission") {
-		if resp.Permissionship != v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
-			os.Exit(1)
-		}
+	if err := printPermissionship(firstResp); err != nil {
+		return nil, err
 	}
 
-	return nil
+	slices.Sort(durations)
+	console.Printf("%d checks in %s (%.2f checks/s)\n", count, totalTime, float64(count)/totalTime.Seconds())
+	console.Printf("p50: %s\n", percentile(durations, 0.50))
+	console.Printf("p95: %s\n", percentile(durations, 0.95))
+	console.Printf("p99: %s\n", percentile(durations, 0.99))
+	return firstResp, nil
+}
+
+// percentile returns the nearest-rank percentile of the given sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
 }
 
 func checkBulkCmdFunc(cmd *cobra.Command, args []string) error {
@@ -334,7 +695,7 @@ func checkBulkCmdFunc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if cobrautil.MustGetBool(cmd, "explain") || cobrautil.MustGetBool(cmd, "schema") {
+	if debugInformationRequested(cmd) {
 		bulk.WithTracing = true
 	}
 
@@ -343,13 +704,17 @@ func checkBulkCmdFunc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if err := recordReadConsistencyToken(cmd, consistency, resp.CheckedAt); err != nil {
+		return err
+	}
+
 	if cobrautil.MustGetBool(cmd, "json") {
-		prettyProto, err := PrettyProto(resp)
+		encoded, err := jsonProtoFromCmd(cmd, resp)
 		if err != nil {
 			return err
 		}
 
-		console.Println(string(prettyProto))
+		console.Println(string(encoded))
 		return nil
 	}
 
@@ -417,13 +782,28 @@ func expandCmdFunc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if cobrautil.MustGetBool(cmd, "recursive") {
+		// Expand the usersets at the same revision, so the tree is consistent.
+		expander := &recursiveExpander{
+			client:      client,
+			consistency: consistency,
+			path:        map[string]struct{}{expandKey(request.Resource, relation): {}},
+		}
+		if resp.ExpandedAt != nil {
+			expander.consistency = &v1.Consistency{Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: resp.ExpandedAt}}
+		}
+		if err := expander.expand(cmd.Context(), resp.TreeRoot, cobrautil.MustGetUint(cmd, "max-depth")); err != nil {
+			return err
+		}
+	}
+
 	if cobrautil.MustGetBool(cmd, "json") {
-		prettyProto, err := PrettyProto(resp)
+		encoded, err := jsonProtoFromCmd(cmd, resp)
 		if err != nil {
 			return err
 		}
 
-		console.Println(string(prettyProto))
+		console.Println(string(encoded))
 		return nil
 	}
 
@@ -431,12 +811,110 @@ func expandCmdFunc(cmd *cobra.Command, args []string) error {
 	printers.TreeNodeTree(tp, resp.TreeRoot)
 	tp.Print()
 
+	if cobrautil.MustGetBool(cmd, "show-zedtoken") && resp.ExpandedAt != nil {
+		console.Printf("expanded at: %s\n", resp.ExpandedAt.Token)
+	}
+
 	return nil
 }
 
+// recursiveExpander materializes a permission tree by expanding the usersets
+// among the subjects of its leaves.
+type recursiveExpander struct {
+	client      client.Client
+	consistency *v1.Consistency
+
+	// path holds the usersets being expanded by the ancestors of the current
+	// node, to detect cycles.
+	path map[string]struct{}
+}
+
+func expandKey(object *v1.ObjectReference, relation string) string {
+	return object.ObjectType + ":" + object.ObjectId + "#" + relation
+}
+
+// expand replaces each leaf of the tree holding usersets with the union of
+// its other subjects and the expansion of each userset, up to maxDepth nested
+// expansions. Usersets that would form a cycle are left unexpanded.
+func (e *recursiveExpander) expand(ctx context.Context, node *v1.PermissionRelationshipTree, maxDepth uint) error {
+	if node.ExpandedObject != nil {
+		key := expandKey(node.ExpandedObject, node.ExpandedRelation)
+		if _, ok := e.path[key]; !ok {
+			e.path[key] = struct{}{}
+			defer delete(e.path, key)
+		}
+	}
+
+	switch typed := node.TreeType.(type) {
+	case *v1.PermissionRelationshipTree_Intermediate:
+		for _, child := range typed.Intermediate.Children {
+			if err := e.expand(ctx, child, maxDepth); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *v1.PermissionRelationshipTree_Leaf:
+		if maxDepth == 0 {
+			return nil
+		}
+
+		var subjects []*v1.SubjectReference
+		var expanded []*v1.PermissionRelationshipTree
+		for _, subject := range typed.Leaf.Subjects {
+			if subject.OptionalRelation == "" {
+				subjects = append(subjects, subject)
+				continue
+			}
+
+			key := expandKey(subject.Object, subject.OptionalRelation)
+			if _, ok := e.path[key]; ok {
+				log.Debug().Str("userset", key).Msg("not expanding userset forming a cycle")
+				subjects = append(subjects, subject)
+				continue
+			}
+
+			request := &v1.ExpandPermissionTreeRequest{
+				Resource:    subject.Object,
+				Permission:  subject.OptionalRelation,
+				Consistency: e.consistency,
+			}
+			log.Trace().Interface("request", request).Send()
+
+			resp, err := e.client.ExpandPermissionTree(ctx, request)
+			if err != nil {
+				return fmt.Errorf("failed to expand %s: %w", key, err)
+			}
+			if err := e.expand(ctx, resp.TreeRoot, maxDepth-1); err != nil {
+				return err
+			}
+			expanded = append(expanded, resp.TreeRoot)
+		}
+
+		if len(expanded) == 0 {
+			return nil
+		}
+
+		children := expanded
+		if len(subjects) > 0 {
+			children = append([]*v1.PermissionRelationshipTree{{
+				TreeType: &v1.PermissionRelationshipTree_Leaf{Leaf: &v1.DirectSubjectSet{Subjects: subjects}},
+			}}, expanded...)
+		}
+		node.TreeType = &v1.PermissionRelationshipTree_Intermediate{Intermediate: &v1.AlgebraicSubjectSet{
+			Operation: v1.AlgebraicSubjectSet_OPERATION_UNION,
+			Children:  children,
+		}}
+		return nil
+
+	default:
+		return nil
+	}
+}
+
 var newLookupResourcesPageCallbackForTests func(readByPage uint)
 
-func lookupResourcesCmdFunc(cmd *cobra.Command, args []string) error {
+func lookupResourcesCmdFunc(cmd *cobra.Command, args []string) (err error) {
 	objectNS := args[0]
 	relation := args[1]
 	subjectNS, subjectID, subjectRel, err := ParseSubject(args[2])
@@ -445,11 +923,18 @@ func lookupResourcesCmdFunc(cmd *cobra.Command, args []string) error {
 	}
 
 	pageLimit := cobrautil.MustGetUint32(cmd, "page-limit")
+	asRelationships := cobrautil.MustGetBool(cmd, "as-relationships")
+	resourceIDPrefix := cobrautil.MustGetString(cmd, "resource-id-prefix")
 	caveatContext, err := GetCaveatContext(cmd)
 	if err != nil {
 		return err
 	}
 
+	tmpl, err := outputTemplateFromCmd(cmd)
+	if err != nil {
+		return err
+	}
+
 	consistency, err := consistencyFromCmd(cmd)
 	if err != nil {
 		return err
@@ -461,7 +946,25 @@ func lookupResourcesCmdFunc(cmd *cobra.Command, args []string) error {
 	}
 
 	var cursor *v1.Cursor
+	if token := cobrautil.MustGetString(cmd, "cursor"); token != "" {
+		cursor = &v1.Cursor{Token: token}
+	}
+
+	// Print the last cursor received, so that an interrupted or paginated
+	// enumeration can be resumed from where it left off.
+	reportCursor := pageLimit > 0 || cursor != nil
+	defer func() {
+		switch {
+		case cursor == nil:
+		case err != nil:
+			console.Errorf("lookup interrupted, resume it with: --cursor %s\n", cursor.Token)
+		case reportCursor:
+			console.Errorf("last cursor: %s\n", cursor.Token)
+		}
+	}()
+
 	var totalCount uint
+	var lookedUpAt *v1.ZedToken
 	for {
 		request := &v1.LookupResourcesRequest{
 			ResourceObjectType: objectNS,
@@ -496,19 +999,38 @@ func lookupResourcesCmdFunc(cmd *cobra.Command, args []string) error {
 			case err != nil:
 				return err
 			default:
+				// The page size is counted before filtering, as it determines
+				// whether another page must be requested.
 				count++
+				cursor = resp.AfterResultCursor
+				lookedUpAt = resp.LookedUpAt
+				if !strings.HasPrefix(resp.ResourceObjectId, resourceIDPrefix) {
+					continue
+				}
+
 				totalCount++
 				if cobrautil.MustGetBool(cmd, "json") {
-					prettyProto, err := PrettyProto(resp)
+					encoded, err := jsonProtoFromCmd(cmd, resp)
 					if err != nil {
 						return err
 					}
 
-					console.Println(string(prettyProto))
+					console.Println(string(encoded))
 				}
 
-				console.Println(prettyLookupPermissionship(resp.ResourceObjectId, resp.Permissionship, resp.PartialCaveatInfo))
-				cursor = resp.AfterResultCursor
+				switch {
+				case tmpl != nil:
+					if err := printWithTemplate(tmpl, resp); err != nil {
+						return err
+					}
+				case asRelationships:
+					resource := &v1.ObjectReference{ObjectType: objectNS, ObjectId: resp.ResourceObjectId}
+					if line, ok := lookupResultRelationshipLine(resource, relation, request.Subject, resp.Permissionship); ok {
+						console.Println(line)
+					}
+				default:
+					console.Println(prettyLookupPermissionship(resp.ResourceObjectId, resp.Permissionship, resp.PartialCaveatInfo))
+				}
 			}
 		}
 
@@ -521,7 +1043,7 @@ func lookupResourcesCmdFunc(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	return nil
+	return recordReadConsistencyToken(cmd, consistency, lookedUpAt)
 }
 
 func lookupSubjectsCmdFunc(cmd *cobra.Command, args []string) error {
@@ -534,12 +1056,19 @@ func lookupSubjectsCmdFunc(cmd *cobra.Command, args []string) error {
 	permission := args[1]
 
 	subjectType, subjectRelation := ParseType(args[2])
+	asRelationships := cobrautil.MustGetBool(cmd, "as-relationships")
+	onlyExcluded := cobrautil.MustGetBool(cmd, "only-excluded")
 
 	caveatContext, err := GetCaveatContext(cmd)
 	if err != nil {
 		return err
 	}
 
+	tmpl, err := outputTemplateFromCmd(cmd)
+	if err != nil {
+		return err
+	}
+
 	consistency, err := consistencyFromCmd(cmd)
 	if err != nil {
 		return err
@@ -567,22 +1096,54 @@ func lookupSubjectsCmdFunc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	var lookedUpAt *v1.ZedToken
 	for {
 		resp, err := respStream.Recv()
 		switch {
 		case errors.Is(err, io.EOF):
-			return nil
+			return recordReadConsistencyToken(cmd, consistency, lookedUpAt)
 		case err != nil:
 			return err
 		default:
+			lookedUpAt = resp.LookedUpAt
+			if onlyExcluded {
+				if err := printExcludedSubjects(cmd, subjectType, resp.ExcludedSubjects); err != nil {
+					return err
+				}
+				continue
+			}
+
 			if cobrautil.MustGetBool(cmd, "json") {
-				prettyProto, err := PrettyProto(resp)
+				encoded, err := jsonProtoFromCmd(cmd, resp)
 				if err != nil {
 					return err
 				}
 
-				console.Println(string(prettyProto))
+				console.Println(string(encoded))
+			}
+
+			if tmpl != nil {
+				if err := printWithTemplate(tmpl, resp); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if asRelationships {
+				subject := &v1.SubjectReference{
+					Object:           &v1.ObjectReference{ObjectType: subjectType, ObjectId: resp.Subject.SubjectObjectId},
+					OptionalRelation: subjectRelation,
+				}
+				if len(resp.ExcludedSubjects) > 0 {
+					log.Warn().Str("subject", tuple.V1StringSubjectRef(subject)).Msg("skipping wildcard subject with exclusions, which cannot be written as a relationship")
+					continue
+				}
+				if line, ok := lookupResultRelationshipLine(request.Resource, permission, subject, resp.Subject.Permissionship); ok {
+					console.Println(line)
+				}
+				continue
 			}
+
 			console.Printf("%s:%s%s\n",
 				subjectType,
 				prettyLookupPermissionship(resp.Subject.SubjectObjectId, resp.Subject.Permissionship, resp.Subject.PartialCaveatInfo),
@@ -592,6 +1153,39 @@ func lookupSubjectsCmdFunc(cmd *cobra.Command, args []string) error {
 	}
 }
 
+// lookupResultRelationshipLine formats a lookup result as a
+// 'resource:id permission subject:id' line, the format in which the
+// relationship write commands read relationships from stdin. Caveated
+// results are skipped, as the caveat they depend on is unknown.
+func lookupResultRelationshipLine(resource *v1.ObjectReference, permission string, subject *v1.SubjectReference, permissionship v1.LookupPermissionship) (string, bool) {
+	if permissionship == v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_CONDITIONAL_PERMISSION {
+		log.Warn().
+			Str("resource", tuple.V1StringObjectRef(resource)).
+			Str("subject", tuple.V1StringSubjectRef(subject)).
+			Msg("skipping caveated result, which cannot be written as a relationship")
+		return "", false
+	}
+	return fmt.Sprintf("%s %s %s", tuple.V1StringObjectRef(resource), permission, tuple.V1StringSubjectRef(subject)), true
+}
+
+// printExcludedSubjects prints each subject excluded from a wildcard result, as
+// JSON with --json, or with its type and caveat information otherwise.
+func printExcludedSubjects(cmd *cobra.Command, subjectType string, excluded []*v1.ResolvedSubject) error {
+	for _, subj := range excluded {
+		if cobrautil.MustGetBool(cmd, "json") {
+			encoded, err := jsonProtoFromCmd(cmd, subj)
+			if err != nil {
+				return err
+			}
+			console.Println(string(encoded))
+			continue
+		}
+
+		console.Printf("%s:%s\n", subjectType, prettyLookupPermissionship(subj.SubjectObjectId, subj.Permissionship, subj.PartialCaveatInfo))
+	}
+	return nil
+}
+
 func excludedSubjectsString(excluded []*v1.ResolvedSubject) string {
 	if len(excluded) == 0 {
 		return ""
@@ -619,27 +1213,46 @@ func prettyLookupPermissionship(objectID string, p v1.LookupPermissionship, info
 	return b.String()
 }
 
-func displayDebugInformationIfRequested(cmd *cobra.Command, debug *v1.DebugInformation, trailerMD metadata.MD, hasError bool) error {
-	if cobrautil.MustGetBool(cmd, "explain") || cobrautil.MustGetBool(cmd, "schema") {
-		debugInfo := &v1.DebugInformation{}
-		// DebugInformation comes in trailer < 1.30, and in response payload >= 1.30
-		if debug == nil {
-			found, err := responsemeta.GetResponseTrailerMetadataOrNil(trailerMD, responsemeta.DebugInformation)
-			if err != nil {
-				return err
-			}
+// debugInformationRequested returns whether any of the flags that display
+// debug information were provided.
+func debugInformationRequested(cmd *cobra.Command) bool {
+	return cobrautil.MustGetBool(cmd, "explain") || cobrautil.MustGetBool(cmd, "schema") || cobrautil.MustGetBool(cmd, "all-caveats")
+}
 
-			if found == nil {
-				log.Warn().Msg("No debugging information returned for the check")
-				return nil
-			}
+// debugInformationFromResponse returns the debug information for a check,
+// which comes in the trailer for SpiceDB < 1.30 and in the response payload
+// for >= 1.30. It returns nil if none was returned.
+func debugInformationFromResponse(debug *v1.DebugInformation, trailerMD metadata.MD) (*v1.DebugInformation, error) {
+	if debug != nil {
+		return debug, nil
+	}
 
-			err = protojson.Unmarshal([]byte(*found), debugInfo)
-			if err != nil {
-				return err
-			}
-		} else {
-			debugInfo = debug
+	found, err := responsemeta.GetResponseTrailerMetadataOrNil(trailerMD, responsemeta.DebugInformation)
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, nil
+	}
+
+	debugInfo := &v1.DebugInformation{}
+	if err := protojson.Unmarshal([]byte(*found), debugInfo); err != nil {
+		return nil, err
+	}
+	return debugInfo, nil
+}
+
+func displayDebugInformationIfRequested(cmd *cobra.Command, debug *v1.DebugInformation, trailerMD metadata.MD, hasError bool) error {
+	if debugInformationRequested(cmd) {
+		debugInfo, err := debugInformationFromResponse(debug, trailerMD)
+		if err != nil {
+			return err
+		}
+
+		if debugInfo == nil {
+			log.Warn().Msg("No debugging information returned for the check")
+			return nil
 		}
 
 		if debugInfo.Check == nil {
@@ -649,8 +1262,22 @@ func displayDebugInformationIfRequested(cmd *cobra.Command, debug *v1.DebugInfor
 
 		if cobrautil.MustGetBool(cmd, "explain") {
 			tp := printers.NewTreePrinter()
-			printers.DisplayCheckTrace(debugInfo.Check, tp, hasError)
+			printers.DisplayCheckTrace(debugInfo.Check, tp, printers.CheckTraceOptions{
+				HasError:   hasError,
+				MaxDepth:   cobrautil.MustGetInt(cmd, "explain-depth"),
+				HideCached: cobrautil.MustGetBool(cmd, "hide-cached"),
+			})
 			tp.Print()
+
+			if cobrautil.MustGetBool(cmd, "explain-legend") {
+				console.Println()
+				console.Println(printers.CheckTraceLegend())
+			}
+		}
+
+		if cobrautil.MustGetBool(cmd, "all-caveats") {
+			console.Println()
+			displayCaveatEvaluations(printers.CaveatEvaluations(debugInfo.Check))
 		}
 
 		if cobrautil.MustGetBool(cmd, "schema") {
@@ -660,3 +1287,21 @@ func displayDebugInformationIfRequested(cmd *cobra.Command, debug *v1.DebugInfor
 	}
 	return nil
 }
+
+func displayCaveatEvaluations(evaluations []*v1.CaveatEvalInfo) {
+	if len(evaluations) == 0 {
+		console.Println("no caveats were evaluated")
+		return
+	}
+
+	rows := make([][]string, 0, len(evaluations))
+	for _, evaluation := range evaluations {
+		result := strings.ToLower(strings.TrimPrefix(evaluation.Result.String(), "RESULT_"))
+		missing := strings.Join(evaluation.GetPartialCaveatInfo().GetMissingRequiredContext(), ", ")
+		rows = append(rows, []string{evaluation.CaveatName, evaluation.Expression, result, missing})
+	}
+
+	var buf bytes.Buffer
+	printers.PrintTable(&buf, []string{"caveat", "expression", "result", "missing context"}, rows)
+	console.Print(buf.String())
+}
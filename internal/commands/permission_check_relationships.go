@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/console"
+)
+
+func registerCheckRelationshipsCmd(permissionCmd *cobra.Command) {
+	permissionCmd.AddCommand(checkRelationshipsCmd)
+	checkRelationshipsCmd.Flags().IntP("batch-size", "b", 100, "number of relationships checked per bulk check request")
+	checkRelationshipsCmd.Flags().Bool("verbose", false, "print the result of every check, instead of only those that failed")
+	registerConsistencyFlags(checkRelationshipsCmd.Flags())
+}
+
+const checkRelationshipsCmdHelpLong = `Checks that the relationships in a file or stdin, one per line, resolve.
+
+Each line is a relationship, either as text (e.g. "document:1 reader user:2" or
+"document:1#reader@user:2") or as a JSON-encoded Relationship, such as the output
+of "zed backup parse-relationships". Each relationship is checked as its relation
+on the resource for its subject, with the context of its caveat, if any. A check
+passes if the subject has the relation unconditionally.
+
+Useful to verify that restored relationships resolve as expected. Returns exit
+code 1 if any check did not pass.`
+
+var checkRelationshipsCmd = &cobra.Command{
+	Use:   "check-relationships <file?>",
+	Short: "Check that each relationship in a file resolves as a permission check",
+	Long:  checkRelationshipsCmdHelpLong,
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  checkRelationshipsCmdFunc,
+}
+
+var errCheckRelationshipsFailed = errors.New("one or more relationship checks did not pass")
+
+func checkRelationshipsCmdFunc(cmd *cobra.Command, args []string) error {
+	var input io.Reader = os.Stdin
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open relationships file: %w", err)
+		}
+		defer f.Close()
+		input = f
+	} else if !isArgsViaFile(os.Stdin) {
+		return errors.New("must provide a relationships file path or contents via stdin")
+	}
+
+	batchSize := cobrautil.MustGetInt(cmd, "batch-size")
+	verbose := cobrautil.MustGetBool(cmd, "verbose")
+
+	consistency, err := consistencyFromCmd(cmd)
+	if err != nil {
+		return err
+	}
+
+	spicedbClient, err := client.NewClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	var passed, failed int
+	checkBatch := func(batch []*v1.CheckBulkPermissionsRequestItem) error {
+		results, err := checkRelationshipItems(cmd.Context(), spicedbClient, consistency, batch)
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			if result == "true" {
+				passed++
+			} else {
+				failed++
+			}
+			if verbose || result != "true" {
+				console.Printf("%s => %s\n", checkItemString(batch[i]), result)
+			}
+		}
+		return nil
+	}
+
+	err = forEachRelationshipBatch(input, batchSize, func(rels []*v1.Relationship) error {
+		batch := make([]*v1.CheckBulkPermissionsRequestItem, 0, len(rels))
+		for _, rel := range rels {
+			item := &v1.CheckBulkPermissionsRequestItem{
+				Resource:   rel.Resource,
+				Permission: rel.Relation,
+				Subject:    rel.Subject,
+			}
+			if rel.OptionalCaveat != nil {
+				item.Context = rel.OptionalCaveat.Context
+			}
+			batch = append(batch, item)
+		}
+		return checkBatch(batch)
+	})
+	if err != nil {
+		return err
+	}
+
+	console.Printf("%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return errCheckRelationshipsFailed
+	}
+	return nil
+}
+
+// checkRelationshipItems bulk checks the items and returns the result of each,
+// in order: the permissionship name, or the error of the check.
+func checkRelationshipItems(ctx context.Context, spicedbClient client.Client, consistency *v1.Consistency, items []*v1.CheckBulkPermissionsRequestItem) ([]string, error) {
+	request := &v1.CheckBulkPermissionsRequest{
+		Consistency: consistency,
+		Items:       items,
+	}
+	log.Trace().Int("items", len(items)).Msg("checking relationships")
+
+	resp, err := spicedbClient.CheckBulkPermissions(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Pairs) != len(items) {
+		return nil, fmt.Errorf("expected %d bulk check results, got %d", len(items), len(resp.Pairs))
+	}
+
+	results := make([]string, 0, len(resp.Pairs))
+	for _, pair := range resp.Pairs {
+		switch response := pair.Response.(type) {
+		case *v1.CheckBulkPermissionsPair_Item:
+			results = append(results, permissionshipName(response.Item.Permissionship))
+		case *v1.CheckBulkPermissionsPair_Error:
+			results = append(results, "error: "+response.Error.GetMessage())
+		}
+	}
+	return results, nil
+}
+
+func checkItemString(item *v1.CheckBulkPermissionsRequestItem) string {
+	return tuple.MustV1StringRelationship(&v1.Relationship{
+		Resource: item.Resource,
+		Relation: item.Permission,
+		Subject:  item.Subject,
+	})
+}
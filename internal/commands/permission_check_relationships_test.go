@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/console"
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+func TestCheckRelationshipsCmdFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+	client.NewClient = zedtesting.ClientFromConn(conn)
+
+	c, err := zedtesting.ClientFromConn(conn)(nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{
+		{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: tuple.MustParseV1Rel("test/resource:1#reader@test/user:1")},
+		{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: tuple.MustParseV1Rel("test/resource:2#writer@test/user:2")},
+	}})
+	require.NoError(t, err)
+
+	previous := console.Printf
+	defer func() {
+		console.Printf = previous
+	}()
+	var lines []string
+	console.Printf = func(format string, a ...any) {
+		lines = append(lines, fmt.Sprintf(format, a...))
+	}
+
+	relsFile := filepath.Join(t.TempDir(), "relationships.txt")
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.IntFlag{FlagName: "batch-size", FlagValue: 2},
+		zedtesting.BoolFlag{FlagName: "verbose"},
+		zedtesting.BoolFlag{FlagName: "consistency-full", FlagValue: true},
+		zedtesting.StringFlag{FlagName: "consistency-at-least"},
+		zedtesting.BoolFlag{FlagName: "consistency-min-latency"},
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
+		zedtesting.StringFlag{FlagName: "consistency-at-exactly"},
+		zedtesting.StringFlag{FlagName: "revision"})
+
+	// Every relationship resolves.
+	require.NoError(t, os.WriteFile(relsFile, []byte("test/resource:1 reader test/user:1\n\ntest/resource:2#writer@test/user:2\n"), 0o600))
+	require.NoError(t, checkRelationshipsCmdFunc(cmd, []string{relsFile}))
+	require.Equal(t, []string{"2 passed, 0 failed\n"}, lines)
+
+	// Only the relationships that do not resolve are printed.
+	lines = nil
+	require.NoError(t, os.WriteFile(relsFile, []byte("test/resource:1 reader test/user:1\ntest/resource:2 writer test/user:1\ntest/resource:3 reader test/user:1\n"), 0o600))
+	err = checkRelationshipsCmdFunc(cmd, []string{relsFile})
+	require.ErrorIs(t, err, errCheckRelationshipsFailed)
+	require.Equal(t, []string{
+		"test/resource:2#writer@test/user:1 => false\n",
+		"test/resource:3#reader@test/user:1 => false\n",
+		"1 passed, 2 failed\n",
+	}, lines)
+}
@@ -3,7 +3,9 @@ package commands
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/authzed/spicedb/pkg/tuple"
 
@@ -16,6 +18,8 @@ import (
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/prototext"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/spicedb/pkg/spiceerrors"
@@ -70,8 +74,19 @@ func TestCheckErrorWithDebugInformation(t *testing.T) {
 	_ = cmd.Flags().MarkHidden("revision")
 	cmd.Flags().Bool("explain", false, "requests debug information from SpiceDB and prints out a trace of the requests")
 	cmd.Flags().Bool("schema", false, "requests debug information from SpiceDB and prints out the schema used")
+	cmd.Flags().Bool("all-caveats", false, "requests debug information from SpiceDB and prints out every caveat evaluated")
 	cmd.Flags().Bool("error-on-no-permission", false, "if true, zed will return exit code 1 if subject does not have unconditional permission")
 	cmd.Flags().String("caveat-context", "", "the caveat context to send along with the check, in JSON form")
+	registerCaveatContextFileFlags(cmd.Flags())
+	cmd.Flags().Bool("validate-caveat-context", false, "read the schema first and fail if the caveat context has unused fields")
+	cmd.Flags().String("assert", "", "if set, zed will return exit code 1 if the permissionship does not match")
+	cmd.Flags().String("output-template", "", "Go text/template used to render each result")
+	cmd.Flags().Int("count", 1, "number of times to issue the check")
+	cmd.Flags().Int("concurrency", 1, "number of checks issued concurrently")
+	cmd.Flags().String("repeat-until", "", "re-issue the check until the permissionship matches")
+	cmd.Flags().Duration("repeat-interval", time.Second, "time to wait between checks")
+	cmd.Flags().Duration("repeat-timeout", 30*time.Second, "maximum time to re-issue the check for")
+	registerSubjectFromTokenFlags(cmd)
 	registerConsistencyFlags(cmd.Flags())
 
 	err := checkCmdFunc(cmd, []string{"object:1", "perm", "object:2"})
@@ -95,8 +110,19 @@ func TestCheckErrorWithInvalidDebugInformation(t *testing.T) {
 	_ = cmd.Flags().MarkHidden("revision")
 	cmd.Flags().Bool("explain", false, "requests debug information from SpiceDB and prints out a trace of the requests")
 	cmd.Flags().Bool("schema", false, "requests debug information from SpiceDB and prints out the schema used")
+	cmd.Flags().Bool("all-caveats", false, "requests debug information from SpiceDB and prints out every caveat evaluated")
 	cmd.Flags().Bool("error-on-no-permission", false, "if true, zed will return exit code 1 if subject does not have unconditional permission")
 	cmd.Flags().String("caveat-context", "", "the caveat context to send along with the check, in JSON form")
+	registerCaveatContextFileFlags(cmd.Flags())
+	cmd.Flags().Bool("validate-caveat-context", false, "read the schema first and fail if the caveat context has unused fields")
+	cmd.Flags().String("assert", "", "if set, zed will return exit code 1 if the permissionship does not match")
+	cmd.Flags().String("output-template", "", "Go text/template used to render each result")
+	cmd.Flags().Int("count", 1, "number of times to issue the check")
+	cmd.Flags().Int("concurrency", 1, "number of checks issued concurrently")
+	cmd.Flags().String("repeat-until", "", "re-issue the check until the permissionship matches")
+	cmd.Flags().Duration("repeat-interval", time.Second, "time to wait between checks")
+	cmd.Flags().Duration("repeat-timeout", 30*time.Second, "maximum time to re-issue the check for")
+	registerSubjectFromTokenFlags(cmd)
 	registerConsistencyFlags(cmd.Flags())
 
 	err := checkCmdFunc(cmd, []string{"object:1", "perm", "object:2"})
@@ -104,6 +130,135 @@ func TestCheckErrorWithInvalidDebugInformation(t *testing.T) {
 	require.ErrorContains(t, err, "unknown field: invalid")
 }
 
+func TestCheckPermissionshipAssertion(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		assert         string
+		permissionship v1.CheckPermissionResponse_Permissionship
+		err            string
+	}{
+		{name: "no assertion", permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION},
+		{name: "true matches", assert: "true", permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION},
+		{name: "false matches", assert: "false", permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION},
+		{name: "caveated matches", assert: "Caveated", permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION},
+		{name: "mismatch", assert: "true", permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION, err: "expected true, got caveated"},
+		{name: "invalid value", assert: "maybe", err: "unexpected flag 'assert' value 'maybe'"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t, zedtesting.StringFlag{FlagName: "assert", FlagValue: tt.assert})
+			expected, err := assertedPermissionship(cmd)
+			if err == nil {
+				err = checkPermissionshipAssertion(expected, &v1.CheckPermissionResponse{Permissionship: tt.permissionship})
+			}
+
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+type sequenceCheckClient struct {
+	*mockCheckClient
+	permissionships []v1.CheckPermissionResponse_Permissionship
+	calls           int
+}
+
+func (m *sequenceCheckClient) CheckPermission(_ context.Context, _ *v1.CheckPermissionRequest, _ ...grpc.CallOption) (*v1.CheckPermissionResponse, error) {
+	permissionship := m.permissionships[min(m.calls, len(m.permissionships)-1)]
+	m.calls++
+	return &v1.CheckPermissionResponse{Permissionship: permissionship}, nil
+}
+
+func TestRepeatCheckUntil(t *testing.T) {
+	noPermission := v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+	hasPermission := v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+
+	c := &sequenceCheckClient{permissionships: []v1.CheckPermissionResponse_Permissionship{noPermission, noPermission, hasPermission}}
+	resp, err := repeatCheckUntil(context.Background(), c, &v1.CheckPermissionRequest{}, hasPermission, time.Millisecond, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, hasPermission, resp.Permissionship)
+	require.Equal(t, 3, c.calls)
+
+	c = &sequenceCheckClient{permissionships: []v1.CheckPermissionResponse_Permissionship{noPermission}}
+	_, err = repeatCheckUntil(context.Background(), c, &v1.CheckPermissionRequest{}, hasPermission, 5*time.Millisecond, 20*time.Millisecond)
+	require.ErrorContains(t, err, "permissionship was not true within 20ms: it was false after")
+	require.Greater(t, c.calls, 1)
+}
+
+func TestBenchmarkCheckAssertion(t *testing.T) {
+	noPermission := v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+	c := &sequenceCheckClient{permissionships: []v1.CheckPermissionResponse_Permissionship{noPermission}}
+	resp, err := benchmarkCheck(context.Background(), c, &v1.CheckPermissionRequest{}, 3, 1)
+	require.NoError(t, err)
+	require.Equal(t, 3, c.calls)
+
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "assert", FlagValue: "true"},
+		zedtesting.BoolFlag{FlagName: "error-on-no-permission"})
+	expected, err := assertedPermissionship(cmd)
+	require.NoError(t, err)
+	require.ErrorContains(t, checkResultAssertions(cmd, expected, resp), "assertion failed: expected true, got false")
+}
+
+func TestCheckCountRejectsOutputFlags(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		json        bool
+		template    string
+		repeatUntil string
+		expectedErr string
+	}{
+		{"json", true, "", "", "--count cannot be combined with --json or --output-template"},
+		{"output template", false, "{{.Permissionship}}", "", "--count cannot be combined with --json or --output-template"},
+		{"repeat until", false, "", "true", "--count cannot be combined with --repeat-until"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			originalClient := client.NewClient
+			client.NewClient = func(*cobra.Command) (client.Client, error) {
+				require.Fail(t, "unexpected client creation")
+				return nil, nil
+			}
+			defer func() {
+				client.NewClient = originalClient
+			}()
+
+			cmd := &cobra.Command{}
+			cmd.Flags().String("revision", "", "")
+			cmd.Flags().Bool("json", tt.json, "")
+			cmd.Flags().Bool("explain", false, "")
+			cmd.Flags().Bool("schema", false, "")
+			cmd.Flags().Bool("all-caveats", false, "")
+			cmd.Flags().Bool("error-on-no-permission", false, "")
+			cmd.Flags().String("caveat-context", "", "")
+			registerCaveatContextFileFlags(cmd.Flags())
+			cmd.Flags().String("assert", "", "")
+			cmd.Flags().String("output-template", tt.template, "")
+			cmd.Flags().Int("count", 10, "")
+			cmd.Flags().String("repeat-until", tt.repeatUntil, "")
+			registerSubjectFromTokenFlags(cmd)
+			registerConsistencyFlags(cmd.Flags())
+
+			require.ErrorContains(t, checkCmdFunc(cmd, []string{"object:1", "perm", "object:2"}), tt.expectedErr)
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	durations := make([]time.Duration, 0, 100)
+	for i := 1; i <= 100; i++ {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	require.Equal(t, 50*time.Millisecond, percentile(durations, 0.50))
+	require.Equal(t, 95*time.Millisecond, percentile(durations, 0.95))
+	require.Equal(t, 99*time.Millisecond, percentile(durations, 0.99))
+	require.Equal(t, time.Millisecond, percentile(durations[:1], 0.99))
+	require.Equal(t, time.Duration(0), percentile(nil, 0.5))
+}
+
 func TestLookupResourcesCommand(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -181,6 +336,447 @@ func TestLookupResourcesCommand(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 10, count)
 	require.EqualValues(t, []uint{3, 3, 3, 1}, receivedPageSizes)
+
+	// filter by resource ID prefix, which must not affect pagination
+	count = 0
+	receivedPageSizes = nil
+	cmd = testLookupResourcesCommand(t, 3)
+	require.NoError(t, cmd.Flags().Set("resource-id-prefix", "1"))
+	err = lookupResourcesCmdFunc(cmd, []string{"test/resource", "read", "test/user:1"})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.EqualValues(t, []uint{3, 3, 3, 1}, receivedPageSizes)
+}
+
+func TestLookupResourcesCommandResumesFromCursor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	client.NewClient = zedtesting.ClientFromConn(conn)
+
+	c, err := zedtesting.ClientFromConn(conn)(nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	var updates []*v1.RelationshipUpdate
+	for i := 0; i < 10; i++ {
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.MustParseV1Rel(fmt.Sprintf("test/resource:%d#reader@test/user:1", i)),
+		})
+	}
+
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+	require.NoError(t, err)
+
+	previousPrintln := console.Println
+	previousErrorf := console.Errorf
+	defer func() {
+		console.Println = previousPrintln
+		console.Errorf = previousErrorf
+	}()
+	var resources []string
+	console.Println = func(values ...any) {
+		resources = append(resources, fmt.Sprint(values...))
+	}
+	var errLines []string
+	console.Errorf = func(format string, a ...any) {
+		errLines = append(errLines, fmt.Sprintf(format, a...))
+	}
+
+	// Interrupt the lookup once its first page has been printed.
+	lookupCtx, interrupt := context.WithCancel(ctx)
+	newLookupResourcesPageCallbackForTests = func(uint) {
+		interrupt()
+	}
+	defer func() {
+		newLookupResourcesPageCallbackForTests = nil
+	}()
+
+	cmd := testLookupResourcesCommand(t, 3)
+	cmd.SetContext(lookupCtx)
+	err = lookupResourcesCmdFunc(cmd, []string{"test/resource", "read", "test/user:1"})
+	require.Equal(t, codes.Canceled, status.Code(err))
+	require.Len(t, resources, 3)
+	require.Len(t, errLines, 1)
+
+	cursor, ok := strings.CutPrefix(strings.TrimSpace(errLines[0]), "lookup interrupted, resume it with: --cursor ")
+	require.True(t, ok, errLines[0])
+
+	// Resuming from the cursor, with the same page limit, lists the
+	// remaining resources.
+	newLookupResourcesPageCallbackForTests = nil
+	errLines = nil
+	cmd = testLookupResourcesCommand(t, 3)
+	require.NoError(t, cmd.Flags().Set("cursor", cursor))
+	err = lookupResourcesCmdFunc(cmd, []string{"test/resource", "read", "test/user:1"})
+	require.NoError(t, err)
+	require.Len(t, resources, 10)
+	require.ElementsMatch(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, resources)
+	require.Len(t, errLines, 1)
+	require.True(t, strings.HasPrefix(errLines[0], "last cursor: "), errLines[0])
+}
+
+func TestLookupResultRelationshipLine(t *testing.T) {
+	resource := &v1.ObjectReference{ObjectType: "document", ObjectId: "1"}
+	user := &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "tom"}}
+	members := &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "group", ObjectId: "eng"}, OptionalRelation: "member"}
+
+	line, ok := lookupResultRelationshipLine(resource, "view", user, v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION)
+	require.True(t, ok)
+	require.Equal(t, "document:1 view user:tom", line)
+
+	line, ok = lookupResultRelationshipLine(resource, "view", members, v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION)
+	require.True(t, ok)
+	require.Equal(t, "document:1 view group:eng#member", line)
+
+	// The printed line is accepted by the relationship write parser.
+	res, rel, subj, err := parseRelationshipLine(line)
+	require.NoError(t, err)
+	require.Equal(t, []string{"document:1", "view", "group:eng#member"}, []string{res, rel, subj})
+
+	_, ok = lookupResultRelationshipLine(resource, "view", user, v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_CONDITIONAL_PERMISSION)
+	require.False(t, ok)
+}
+
+func TestPrintExcludedSubjects(t *testing.T) {
+	previous := console.Printf
+	defer func() {
+		console.Printf = previous
+	}()
+	var lines []string
+	console.Printf = func(format string, a ...any) {
+		lines = append(lines, fmt.Sprintf(format, a...))
+	}
+
+	excluded := []*v1.ResolvedSubject{
+		{SubjectObjectId: "1", Permissionship: v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION},
+		{
+			SubjectObjectId:   "2",
+			Permissionship:    v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_CONDITIONAL_PERMISSION,
+			PartialCaveatInfo: &v1.PartialCaveatInfo{MissingRequiredContext: []string{"ip"}},
+		},
+	}
+
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.BoolFlag{FlagName: "json"},
+		zedtesting.BoolFlag{FlagName: "compact"})
+	require.NoError(t, printExcludedSubjects(cmd, "user", excluded))
+	require.Equal(t, []string{"user:1\n", "user:2 (caveated, missing context: ip)\n"}, lines)
+
+	lines = nil
+	cmd = zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.BoolFlag{FlagName: "json", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "compact", FlagValue: true})
+	require.NoError(t, printExcludedSubjects(cmd, "user", excluded))
+	require.Equal(t, []string{
+		`{"subjectObjectId":"1","permissionship":"LOOKUP_PERMISSIONSHIP_HAS_PERMISSION"}` + "\n",
+		`{"subjectObjectId":"2","permissionship":"LOOKUP_PERMISSIONSHIP_CONDITIONAL_PERMISSION","partialCaveatInfo":{"missingRequiredContext":["ip"]}}` + "\n",
+	}, lines)
+}
+
+type expandRecordingClient struct {
+	client.Client
+	requests []*v1.ExpandPermissionTreeRequest
+}
+
+func (c *expandRecordingClient) ExpandPermissionTree(ctx context.Context, in *v1.ExpandPermissionTreeRequest, opts ...grpc.CallOption) (*v1.ExpandPermissionTreeResponse, error) {
+	c.requests = append(c.requests, in)
+	return c.Client.ExpandPermissionTree(ctx, in, opts...)
+}
+
+func TestExpandCmdFuncConsistency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	c, err := zedtesting.ClientFromConn(conn)(nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	resp, err := c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.MustParseV1Rel("test/resource:1#reader@test/user:1"),
+		}},
+	})
+	require.NoError(t, err)
+	writtenAt := resp.WrittenAt.Token
+
+	recording := &expandRecordingClient{Client: c}
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+	client.NewClient = func(*cobra.Command) (client.Client, error) {
+		return recording, nil
+	}
+
+	previousPrintln := console.Println
+	previousPrintf := console.Printf
+	defer func() {
+		console.Println = previousPrintln
+		console.Printf = previousPrintf
+	}()
+	var output strings.Builder
+	console.Println = func(values ...any) {
+		output.WriteString(fmt.Sprintln(values...))
+	}
+	console.Printf = func(format string, a ...any) {
+		output.WriteString(fmt.Sprintf(format, a...))
+	}
+
+	for _, tt := range []struct {
+		name        string
+		atLeast     string
+		atExactly   string
+		full        bool
+		minLatency  bool
+		expected    string
+		expectedErr error
+	}{
+		{name: "default", expected: `minimize_latency:true`},
+		{name: "min latency", minLatency: true, expected: `minimize_latency:true`},
+		{name: "full", full: true, expected: `fully_consistent:true`},
+		{name: "at least", atLeast: writtenAt, expected: fmt.Sprintf(`at_least_as_fresh:{token:%q}`, writtenAt)},
+		{name: "at exactly", atExactly: writtenAt, expected: fmt.Sprintf(`at_exact_snapshot:{token:%q}`, writtenAt)},
+		{name: "multiple", atExactly: writtenAt, minLatency: true, expectedErr: ErrMultipleConsistencies},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			recording.requests = nil
+			output.Reset()
+
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.StringFlag{FlagName: "consistency-at-least", FlagValue: tt.atLeast},
+				zedtesting.StringFlag{FlagName: "consistency-at-exactly", FlagValue: tt.atExactly},
+				zedtesting.BoolFlag{FlagName: "consistency-full", FlagValue: tt.full},
+				zedtesting.BoolFlag{FlagName: "consistency-min-latency", FlagValue: tt.minLatency},
+				zedtesting.StringFlag{FlagName: "consistency-token-file"},
+				zedtesting.StringFlag{FlagName: "revision"},
+				zedtesting.BoolFlag{FlagName: "json"},
+				zedtesting.BoolFlag{FlagName: "show-zedtoken", FlagValue: true},
+				zedtesting.BoolFlag{FlagName: "recursive"},
+				zedtesting.UintFlag{FlagName: "max-depth", FlagValue: 10})
+			err := expandCmdFunc(cmd, []string{"read", "test/resource:1"})
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, recording.requests, 1)
+			require.Equal(t, tt.expected, strings.ReplaceAll(prototext.MarshalOptions{}.Format(recording.requests[0].Consistency), " ", ""))
+			require.Contains(t, output.String(), "user:1")
+			require.Regexp(t, `expanded at: \S+`, output.String())
+		})
+	}
+}
+
+func TestExpandCmdFuncRecursive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	c, err := zedtesting.ClientFromConn(conn)(nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: `definition test/user {}
+
+definition test/group {
+	relation member: test/user | test/group#member
+}
+
+definition test/resource {
+	relation reader: test/user | test/group#member
+}`})
+	require.NoError(t, err)
+
+	var updates []*v1.RelationshipUpdate
+	for _, rel := range []string{
+		"test/resource:1#reader@test/user:1",
+		"test/resource:1#reader@test/group:eng#member",
+		"test/group:eng#member@test/user:2",
+		"test/group:eng#member@test/group:all#member",
+		"test/group:all#member@test/user:3",
+		"test/group:all#member@test/group:eng#member",
+	} {
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.MustParseV1Rel(rel),
+		})
+	}
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+	require.NoError(t, err)
+
+	recording := &expandRecordingClient{Client: c}
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+	client.NewClient = func(*cobra.Command) (client.Client, error) {
+		return recording, nil
+	}
+
+	previousPrintln := console.Println
+	defer func() {
+		console.Println = previousPrintln
+	}()
+	var output strings.Builder
+	console.Println = func(values ...any) {
+		output.WriteString(fmt.Sprintln(values...))
+	}
+
+	for _, tt := range []struct {
+		name             string
+		recursive        bool
+		maxDepth         uint
+		expectedRequests int
+		expected         []string
+		notExpected      []string
+	}{
+		{"not recursive", false, 10, 1, []string{"user:1", "group:eng->member"}, []string{"user:2", "user:3"}},
+		{"recursive", true, 10, 3, []string{"user:1", "user:2", "user:3"}, nil},
+		{"max depth", true, 1, 2, []string{"user:1", "user:2", "group:all->member"}, []string{"user:3"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			recording.requests = nil
+			output.Reset()
+
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.StringFlag{FlagName: "consistency-at-least"},
+				zedtesting.StringFlag{FlagName: "consistency-at-exactly"},
+				zedtesting.BoolFlag{FlagName: "consistency-full", FlagValue: true},
+				zedtesting.BoolFlag{FlagName: "consistency-min-latency"},
+				zedtesting.StringFlag{FlagName: "consistency-token-file"},
+				zedtesting.StringFlag{FlagName: "revision"},
+				zedtesting.BoolFlag{FlagName: "json"},
+				zedtesting.BoolFlag{FlagName: "show-zedtoken"},
+				zedtesting.BoolFlag{FlagName: "recursive", FlagValue: tt.recursive},
+				zedtesting.UintFlag{FlagName: "max-depth", FlagValue: tt.maxDepth})
+			require.NoError(t, expandCmdFunc(cmd, []string{"reader", "test/resource:1"}))
+
+			// The cycle back to group:eng is not expanded again.
+			require.Len(t, recording.requests, tt.expectedRequests)
+			for _, expected := range tt.expected {
+				require.Contains(t, output.String(), expected)
+			}
+			for _, notExpected := range tt.notExpected {
+				require.NotContains(t, output.String(), notExpected)
+			}
+		})
+	}
+
+	// With --compact, the materialized tree is output as a single line of JSON.
+	output.Reset()
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-at-least"},
+		zedtesting.StringFlag{FlagName: "consistency-at-exactly"},
+		zedtesting.BoolFlag{FlagName: "consistency-full", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "consistency-min-latency"},
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
+		zedtesting.StringFlag{FlagName: "revision"},
+		zedtesting.BoolFlag{FlagName: "json", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "compact", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "show-zedtoken"},
+		zedtesting.BoolFlag{FlagName: "recursive", FlagValue: true},
+		zedtesting.UintFlag{FlagName: "max-depth", FlagValue: 10})
+	require.NoError(t, expandCmdFunc(cmd, []string{"reader", "test/resource:1"}))
+	require.Equal(t, 1, strings.Count(output.String(), "\n"))
+	require.Contains(t, output.String(), `"objectId":"3"`)
+}
+
+func TestWhyNotCmdFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+	client.NewClient = zedtesting.ClientFromConn(conn)
+
+	c, err := client.NewClient(nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.MustParseV1Rel("test/resource:1#reader@test/user:1"),
+		}},
+	})
+	require.NoError(t, err)
+
+	previousPrintln := console.Println
+	previousPrintf := console.Printf
+	defer func() {
+		console.Println = previousPrintln
+		console.Printf = previousPrintf
+	}()
+	var lines []string
+	console.Println = func(values ...any) {
+		lines = append(lines, fmt.Sprint(values...))
+	}
+	console.Printf = func(format string, a ...any) {
+		lines = append(lines, strings.TrimSpace(fmt.Sprintf(format, a...)))
+	}
+
+	whyNotCommand := func() *cobra.Command {
+		return zedtesting.CreateTestCobraCommandWithFlagValue(t,
+			zedtesting.BoolFlag{FlagName: "consistency-full", FlagValue: true},
+			zedtesting.StringFlag{FlagName: "consistency-at-least"},
+			zedtesting.BoolFlag{FlagName: "consistency-min-latency"},
+			zedtesting.StringFlag{FlagName: "consistency-token-file"},
+			zedtesting.StringFlag{FlagName: "consistency-at-exactly"},
+			zedtesting.StringFlag{FlagName: "revision"},
+			zedtesting.StringFlag{FlagName: "caveat-context"},
+			zedtesting.StringFlag{FlagName: "caveat-context-file"},
+			zedtesting.BoolFlag{FlagName: "caveat-context-merge"})
+	}
+
+	require.NoError(t, whyNotCmdFunc(whyNotCommand(), []string{"test/resource:1", "read", "test/user:1"}))
+	require.Equal(t, []string{"true"}, lines)
+
+	lines = nil
+	require.NoError(t, whyNotCmdFunc(whyNotCommand(), []string{"test/resource:1", "read", "test/user:2"}))
+	require.Equal(t, []string{
+		"false",
+		"- test/resource:1#read → test/resource:1#reader: no relationship to the subject",
+		"- test/resource:1#read → test/resource:1#writer: no relationship to the subject",
+	}, lines)
 }
 
 func testLookupResourcesCommand(t *testing.T, limit uint32) *cobra.Command {
@@ -188,9 +784,17 @@ func testLookupResourcesCommand(t *testing.T, limit uint32) *cobra.Command {
 		zedtesting.BoolFlag{FlagName: "consistency-full", FlagValue: true},
 		zedtesting.StringFlag{FlagName: "consistency-at-least"},
 		zedtesting.BoolFlag{FlagName: "consistency-min-latency", FlagValue: false},
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
 		zedtesting.StringFlag{FlagName: "consistency-at-exactly"},
 		zedtesting.StringFlag{FlagName: "revision"},
 		zedtesting.StringFlag{FlagName: "caveat-context"},
+		zedtesting.StringFlag{FlagName: "caveat-context-file"},
+		zedtesting.BoolFlag{FlagName: "caveat-context-merge"},
 		zedtesting.UintFlag32{FlagName: "page-limit", FlagValue: limit},
-		zedtesting.BoolFlag{FlagName: "json"})
+		zedtesting.StringFlag{FlagName: "cursor"},
+		zedtesting.StringFlag{FlagName: "resource-id-prefix"},
+		zedtesting.BoolFlag{FlagName: "json"},
+		zedtesting.BoolFlag{FlagName: "compact"},
+		zedtesting.StringFlag{FlagName: "output-template"},
+		zedtesting.BoolFlag{FlagName: "as-relationships"})
 }
@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func registerSubjectFromTokenFlags(cmd *cobra.Command) {
+	cmd.Flags().String("subject-from-token", "", "derive the subject from a claim of this JWT, instead of passing <subject:id>")
+	cmd.Flags().String("subject-claim", "sub", "with --subject-from-token, the claim holding the subject ID; nested claims are separated with dots")
+	cmd.Flags().String("subject-type", "user", "with --subject-from-token, the object type of the subject")
+	cmd.Flags().String("jwks-url", "", "with --subject-from-token, verify the JWT signature with the keys at this JWKS URL; otherwise the JWT is not verified")
+}
+
+// argsWithSubjectFromToken returns the check arguments with the subject
+// derived from --subject-from-token appended, or the arguments unchanged if
+// the flag is not set.
+func argsWithSubjectFromToken(cmd *cobra.Command, args []string) ([]string, error) {
+	token := cobrautil.MustGetString(cmd, "subject-from-token")
+	if token == "" {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("accepts 3 arg(s), received %d", len(args))
+		}
+		return args, nil
+	}
+	if len(args) != 2 {
+		return nil, errors.New("with --subject-from-token, only <resource:id> <permission> must be provided")
+	}
+
+	claims, err := jwtClaims(cmd.Context(), token, cobrautil.MustGetString(cmd, "jwks-url"))
+	if err != nil {
+		return nil, err
+	}
+
+	subjectID, err := claimString(claims, cobrautil.MustGetString(cmd, "subject-claim"))
+	if err != nil {
+		return nil, err
+	}
+
+	subject := cobrautil.MustGetString(cmd, "subject-type") + ":" + subjectID
+	log.Debug().Str("subject", subject).Msg("derived subject from token")
+	return append(args, subject), nil
+}
+
+// jwtSignatureAlgorithms are the algorithms a JWT verified against a JWKS may
+// be signed with. Symmetric algorithms, whose keys cannot be published in a
+// JWKS, and unsigned JWTs are rejected.
+var jwtSignatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// unverifiedJWTSignatureAlgorithms are the algorithms accepted when the JWT is
+// not verified.
+var unverifiedJWTSignatureAlgorithms = append([]jose.SignatureAlgorithm{jose.HS256, jose.HS384, jose.HS512}, jwtSignatureAlgorithms...)
+
+// jwtClockSkew is the leeway given to the exp, nbf and iat claims of a
+// verified JWT.
+const jwtClockSkew = time.Minute
+
+// jwtClaims decodes the claims of the JWT, verifying its signature and time
+// claims against the keys at jwksURL if it is set.
+func jwtClaims(ctx context.Context, token, jwksURL string) (map[string]any, error) {
+	algorithms := jwtSignatureAlgorithms
+	if jwksURL == "" {
+		algorithms = unverifiedJWTSignatureAlgorithms
+	}
+
+	parsed, err := jwt.ParseSigned(strings.TrimSpace(token), algorithms)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT: %w", err)
+	}
+
+	var claims map[string]any
+	if jwksURL == "" {
+		if err := parsed.UnsafeClaimsWithoutVerification(&claims); err != nil {
+			return nil, fmt.Errorf("invalid JWT claims: %w", err)
+		}
+		return claims, nil
+	}
+
+	keys, err := fetchJWKS(ctx, jwksURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key matching the header's key ID verifies the JWT, or any key if it
+	// has none. A key restricted to another algorithm is never used.
+	header := parsed.Headers[0]
+	candidates := keys.Keys
+	if header.KeyID != "" {
+		candidates = keys.Key(header.KeyID)
+	}
+
+	var registered jwt.Claims
+	verified := false
+	for _, key := range candidates {
+		if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+			continue
+		}
+		if err := parsed.Claims(key, &claims, &registered); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("JWT signature could not be verified with the JWKS")
+	}
+
+	if err := registered.ValidateWithLeeway(jwt.Expected{Time: time.Now()}, jwtClockSkew); err != nil {
+		switch {
+		case errors.Is(err, jwt.ErrExpired):
+			return nil, errors.New("JWT has expired")
+		case errors.Is(err, jwt.ErrNotValidYet):
+			return nil, errors.New("JWT is not valid yet")
+		case errors.Is(err, jwt.ErrIssuedInTheFuture):
+			return nil, errors.New("JWT was issued in the future")
+		default:
+			return nil, fmt.Errorf("invalid JWT claims: %w", err)
+		}
+	}
+	return claims, nil
+}
+
+// claimString returns the claim at the dot-separated path as a string.
+func claimString(claims map[string]any, path string) (string, error) {
+	var value any = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("claim %s not found in JWT", path)
+		}
+		if value, ok = object[name]; !ok {
+			return "", fmt.Errorf("claim %s not found in JWT", path)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return "", fmt.Errorf("claim %s is empty", path)
+		}
+		return v, nil
+	case float64:
+		return big.NewFloat(v).Text('f', -1), nil
+	default:
+		return "", fmt.Errorf("claim %s is not a string or a number", path)
+	}
+}
+
+func fetchJWKS(ctx context.Context, jwksURL string) (*jose.JSONWebKeySet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS URL: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	return &jwks, nil
+}
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+func signedTestJWT(t *testing.T, alg, kid string, claims map[string]any, sign func(digest []byte) []byte) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(digest[:]))
+}
+
+func TestArgsWithSubjectFromToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signRSA := func(digest []byte) []byte {
+		signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest)
+		require.NoError(t, err)
+		return signature
+	}
+	signEC := func(digest []byte) []byte {
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest)
+		require.NoError(t, err)
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA",
+			"kid": "rsa",
+			"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{
+			"kty": "EC",
+			"kid": "ec",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+			"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+		},
+	}})
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(jwks)
+	}))
+	defer srv.Close()
+
+	claims := map[string]any{"sub": "alice", "org": map[string]any{"member": float64(42)}}
+	rsaToken := signedTestJWT(t, "RS256", "rsa", claims, signRSA)
+	ecToken := signedTestJWT(t, "ES256", "ec", claims, signEC)
+	expiredToken := signedTestJWT(t, "RS256", "rsa", map[string]any{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()}, signRSA)
+	wrongKeyToken := signedTestJWT(t, "RS256", "ec", claims, signRSA)
+	notYetValidToken := signedTestJWT(t, "RS256", "rsa", map[string]any{"sub": "alice", "nbf": time.Now().Add(time.Hour).Unix()}, signRSA)
+	issuedInTheFutureToken := signedTestJWT(t, "RS256", "rsa", map[string]any{"sub": "alice", "iat": time.Now().Add(time.Hour).Unix()}, signRSA)
+	skewedToken := signedTestJWT(t, "RS256", "rsa", map[string]any{"sub": "alice", "nbf": time.Now().Add(10 * time.Second).Unix()}, signRSA)
+	unsignedToken := signedTestJWT(t, "none", "rsa", claims, func([]byte) []byte { return nil })
+	symmetricToken := signedTestJWT(t, "HS256", "rsa", claims, signRSA)
+	shortSignatureToken := signedTestJWT(t, "ES256", "ec", claims, func(digest []byte) []byte { return signEC(digest)[1:] })
+
+	for _, tt := range []struct {
+		name            string
+		args            []string
+		token           string
+		claim           string
+		jwksURL         string
+		expectedSubject string
+		expectedErr     string
+	}{
+		{"no token", []string{"document:1", "view", "user:bob"}, "", "sub", "", "user:bob", ""},
+		{"no token missing subject", []string{"document:1", "view"}, "", "sub", "", "", "accepts 3 arg(s), received 2"},
+		{"token with subject", []string{"document:1", "view", "user:bob"}, rsaToken, "sub", "", "", "only <resource:id> <permission> must be provided"},
+		{"unverified", []string{"document:1", "view"}, rsaToken, "sub", "", "user:alice", ""},
+		{"nested numeric claim", []string{"document:1", "view"}, rsaToken, "org.member", "", "user:42", ""},
+		{"missing claim", []string{"document:1", "view"}, rsaToken, "email", "", "", "claim email not found in JWT"},
+		{"invalid token", []string{"document:1", "view"}, "not-a-jwt", "sub", "", "", "invalid JWT"},
+		{"verified RSA", []string{"document:1", "view"}, rsaToken, "sub", srv.URL, "user:alice", ""},
+		{"verified ECDSA", []string{"document:1", "view"}, ecToken, "sub", srv.URL, "user:alice", ""},
+		{"expired", []string{"document:1", "view"}, expiredToken, "sub", srv.URL, "", "JWT has expired"},
+		{"wrong key", []string{"document:1", "view"}, wrongKeyToken, "sub", srv.URL, "", "could not be verified"},
+		{"not valid yet", []string{"document:1", "view"}, notYetValidToken, "sub", srv.URL, "", "JWT is not valid yet"},
+		{"issued in the future", []string{"document:1", "view"}, issuedInTheFutureToken, "sub", srv.URL, "", "JWT was issued in the future"},
+		{"within clock skew", []string{"document:1", "view"}, skewedToken, "sub", srv.URL, "user:alice", ""},
+		{"unsigned", []string{"document:1", "view"}, unsignedToken, "sub", srv.URL, "", "invalid JWT"},
+		{"symmetric algorithm", []string{"document:1", "view"}, symmetricToken, "sub", srv.URL, "", "invalid JWT"},
+		{"short ECDSA signature", []string{"document:1", "view"}, shortSignatureToken, "sub", srv.URL, "", "could not be verified"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.StringFlag{FlagName: "subject-from-token", FlagValue: tt.token},
+				zedtesting.StringFlag{FlagName: "subject-claim", FlagValue: tt.claim},
+				zedtesting.StringFlag{FlagName: "subject-type", FlagValue: "user"},
+				zedtesting.StringFlag{FlagName: "jwks-url", FlagValue: tt.jwksURL})
+
+			args, err := argsWithSubjectFromToken(cmd, tt.args)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, []string{"document:1", "view", tt.expectedSubject}, args)
+		})
+	}
+}
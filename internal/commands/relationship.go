@@ -3,23 +3,33 @@ package commands
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 
 	"github.com/authzed/zed/internal/client"
 	"github.com/authzed/zed/internal/console"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
 	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/dustin/go-humanize/english"
 	"github.com/jzelinskie/cobrautil/v2"
 	"github.com/jzelinskie/stringz"
 	"github.com/rs/zerolog/log"
+	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
@@ -30,15 +40,30 @@ func RegisterRelationshipCmd(rootCmd *cobra.Command) *cobra.Command {
 	createCmd.Flags().Bool("json", false, "output as JSON")
 	createCmd.Flags().String("caveat", "", `the caveat for the relationship, with format: 'caveat_name:{"some":"context"}'`)
 	createCmd.Flags().IntP("batch-size", "b", 100, "batch size when writing streams of relationships from stdin")
+	createCmd.Flags().String("from-lookup", "", "read the output of a lookup command run with --as-relationships from stdin, writing each result with this relation in place of the permission")
+	createCmd.Flags().Bool("summary", false, "after writing, output the number of relationships and batches written: to stderr, or as a final JSON object with --json")
+	registerDedupeFlags(createCmd)
+	createCmd.Flags().Bool("continue-on-error", false, "when writing streams of relationships from stdin, log the batches that fail to be written and continue with the next ones, exiting with an error at the end")
+	registerConsistencyTokenFileFlag(createCmd.Flags())
 
 	relationshipCmd.AddCommand(touchCmd)
 	touchCmd.Flags().Bool("json", false, "output as JSON")
 	touchCmd.Flags().String("caveat", "", `the caveat for the relationship, with format: 'caveat_name:{"some":"context"}'`)
 	touchCmd.Flags().IntP("batch-size", "b", 100, "batch size when writing streams of relationships from stdin")
+	touchCmd.Flags().String("from-lookup", "", "read the output of a lookup command run with --as-relationships from stdin, writing each result with this relation in place of the permission")
+	touchCmd.Flags().Bool("summary", false, "after writing, output the number of relationships and batches written: to stderr, or as a final JSON object with --json")
+	registerDedupeFlags(touchCmd)
+	touchCmd.Flags().Bool("continue-on-error", false, "when writing streams of relationships from stdin, log the batches that fail to be written and continue with the next ones, exiting with an error at the end")
+	registerConsistencyTokenFileFlag(touchCmd.Flags())
 
 	relationshipCmd.AddCommand(deleteCmd)
 	deleteCmd.Flags().Bool("json", false, "output as JSON")
 	deleteCmd.Flags().IntP("batch-size", "b", 100, "batch size when deleting streams of relationships from stdin")
+	deleteCmd.Flags().String("from-lookup", "", "read the output of a lookup command run with --as-relationships from stdin, writing each result with this relation in place of the permission")
+	deleteCmd.Flags().Bool("summary", false, "after writing, output the number of relationships and batches written: to stderr, or as a final JSON object with --json")
+	registerDedupeFlags(deleteCmd)
+	deleteCmd.Flags().Bool("continue-on-error", false, "when writing streams of relationships from stdin, log the batches that fail to be written and continue with the next ones, exiting with an error at the end")
+	registerConsistencyTokenFileFlag(deleteCmd.Flags())
 
 	relationshipCmd.AddCommand(readCmd)
 	readCmd.Flags().Bool("json", false, "output as JSON")
@@ -46,14 +71,44 @@ func RegisterRelationshipCmd(rootCmd *cobra.Command) *cobra.Command {
 	_ = readCmd.Flags().MarkHidden("revision")
 	readCmd.Flags().String("subject-filter", "", "optional subject filter")
 	readCmd.Flags().Uint32("page-limit", 100, "limit of relations returned per page")
+	registerOutputTemplateFlag(readCmd, "Relationship (e.g. {{.Resource.ObjectType}}:{{.Resource.ObjectId}})")
+	readCmd.Flags().Bool("show-caveat-context-only", false, "only output caveated relationships, as the relationship followed by the caveat name and context")
+	readCmd.MarkFlagsMutuallyExclusive("show-caveat-context-only", "json")
+	readCmd.MarkFlagsMutuallyExclusive("show-caveat-context-only", "output-template")
+	readCmd.Flags().Bool("json-relationship", false, "output each relationship as a single line of JSON, without the wrapping response")
+	readCmd.MarkFlagsMutuallyExclusive("json-relationship", "json", "output-template", "show-caveat-context-only")
+	readCmd.Flags().Bool("sort", false, "buffer the relationships and output them sorted by their string form, rather than as they are streamed")
+	readCmd.Flags().Uint("sort-buffer-size", 100_000, "maximum number of relationships buffered by --sort")
+	registerCompactFlag(readCmd)
+	readCmd.Flags().Bool("include-revision", false, "after the relationships, output the revision they were read at: as a final JSON line with --json or --json-relationship, or to stderr otherwise")
+	readCmd.Flags().Uint("resolve", 0, "beneath each relationship whose subject has a relation (e.g. group:eng#member), print the subjects of that relation, resolving nested subject relations up to this depth")
+	readCmd.Flags().Uint32("resolve-limit", 100, "maximum number of subjects printed for each subject relation resolved with --resolve")
+	readCmd.Flags().Bool("disable-retries", false, "fail when the read is interrupted by a retryable error, instead of resuming it from the last relationship read")
+	readCmd.Flags().Uint("max-retries", 10, "maximum number of times an interrupted read is resumed")
+	readCmd.Flags().Bool("deduplicate", false, "skip relationships already output, such as those of a page replayed when a read is resumed")
+	readCmd.Flags().Bool("paginate-summary", false, "after the relationships, print to stderr the number of pages and relationships read, and the cursor of the last relationship to resume from")
+	readCmd.Flags().Uint("deduplicate-max-size", 1_000_000, "maximum number of relationships remembered by --deduplicate; past it, a warning is logged and further relationships are not deduplicated")
 	registerConsistencyFlags(readCmd.Flags())
 
+	relationshipCmd.AddCommand(existsCmd)
+	existsCmd.Flags().Bool("quiet", false, "do not print whether the relationship exists")
+	registerConsistencyFlags(existsCmd.Flags())
+
 	relationshipCmd.AddCommand(bulkDeleteCmd)
 	bulkDeleteCmd.Flags().Bool("force", false, "force deletion of all elements in batches defined by <optional-limit>")
 	bulkDeleteCmd.Flags().String("subject-filter", "", "optional subject filter")
 	bulkDeleteCmd.Flags().Uint32("optional-limit", 1000, "the max amount of elements to delete. If you want to delete all in batches of size <optional-limit>, set --force to true")
 	bulkDeleteCmd.Flags().Bool("estimate-count", true, "estimate the count of relationships to be deleted")
 	_ = bulkDeleteCmd.Flags().MarkDeprecated("estimate-count", "no longer used, make use of --optional-limit instead")
+	bulkDeleteCmd.Flags().Bool("all-resource-types", false, "delete the relationships of every resource type in the schema, instead of those matching a pattern")
+	bulkDeleteCmd.Flags().Int("parallelism", 4, "with --all-resource-types, the number of resource types deleted concurrently")
+	bulkDeleteCmd.Flags().Bool("progress-accurate", false, "count the matching relationships before deleting them, so the progress shows the number actually deleted")
+	registerConsistencyTokenFileFlag(bulkDeleteCmd.Flags())
+
+	registerRelationshipDiffCmd(relationshipCmd)
+	registerRelationshipListExpiredCmd(relationshipCmd)
+	registerRelationshipApplyCmd(relationshipCmd)
+	registerRelationshipImportCmd(relationshipCmd)
 	return relationshipCmd
 }
 
@@ -91,6 +146,16 @@ const readCmdHelpLong = `Enumerates relationships matching the provided pattern.
 To filter returned relationships using a resource ID prefix, append a '%' to the resource ID:
 
 zed relationship read some-type:some-prefix-%
+
+Likewise, to filter using a subject ID prefix, append a '%' to the subject ID. As SpiceDB
+cannot filter on a subject ID prefix, relationships of the subject type are read and those
+without the prefix are skipped by zed:
+
+zed relationship read some-type some-relation subject-type:some-prefix-%
+
+Relationships are printed as they are streamed, in the order returned by SpiceDB. To compare
+the output of two reads, --sort buffers every matching relationship (up to --sort-buffer-size)
+and prints them sorted by their string form once all have been read.
 `
 
 var readCmd = &cobra.Command{
@@ -102,10 +167,40 @@ var readCmd = &cobra.Command{
 	RunE:              readRelationships,
 }
 
+var existsCmd = &cobra.Command{
+	Use:               "exists <resource:id> <relation> <subject:id#optional_subject_relation>",
+	Short:             "Checks whether a relationship exists, exiting with status 1 if it does not",
+	Args:              cobra.ExactArgs(3),
+	ValidArgsFunction: GetArgs(ResourceID, Permission, SubjectTypeWithOptionalRelation),
+	RunE:              existsCmdFunc,
+}
+
+const bulkDeleteCmdHelpLong = `Deletes relationships matching the provided pattern en masse.
+
+To delete relationships by a resource ID prefix, append a '%' to the resource ID:
+
+zed relationship bulk-delete some-type:some-prefix-%
+
+Without --force, the deletion fails if more than --optional-limit relationships match; with
+--force, they are deleted --optional-limit at a time until none are left.
+
+To delete relationships by a subject ID prefix, append a '%' to the subject ID:
+
+zed relationship bulk-delete some-type some-relation subject-type:some-prefix-%
+
+As SpiceDB cannot delete by a subject ID prefix, the relationships of the subject type are
+read and those with the prefix are then deleted in batches of --optional-limit.
+
+To delete the relationships of every resource type in the schema, e.g. to reset a test
+environment, use --all-resource-types without a pattern. Resource types are deleted
+concurrently, up to --parallelism at a time, each honoring --optional-limit and --force.
+`
+
 var bulkDeleteCmd = &cobra.Command{
 	Use:               "bulk-delete <resource_type:optional_resource_id> <optional_relation> <optional_subject_type:optional_subject_id#optional_subject_relation>",
 	Short:             "Deletes relationships matching the provided pattern en masse",
-	Args:              cobra.RangeArgs(1, 3),
+	Long:              bulkDeleteCmdHelpLong,
+	Args:              cobra.RangeArgs(0, 3),
 	ValidArgsFunction: GetArgs(ResourceID, Permission, SubjectTypeWithOptionalRelation),
 	RunE:              bulkDeleteRelationships,
 }
@@ -120,30 +215,123 @@ func StdinOrExactArgs(n int) cobra.PositionalArgs {
 	}
 }
 
+func StdinOrMinimumArgs(n int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if ok := isArgsViaFile(os.Stdin) && len(args) == 0; ok {
+			return nil
+		}
+
+		return cobra.MinimumNArgs(n)(cmd, args)
+	}
+}
+
 func isArgsViaFile(file *os.File) bool {
 	return !isFileTerminal(file)
 }
 
 func bulkDeleteRelationships(cmd *cobra.Command, args []string) error {
+	allResourceTypes := cobrautil.MustGetBool(cmd, "all-resource-types")
+	switch {
+	case allResourceTypes && len(args) > 0:
+		return errors.New("cannot provide a pattern with --all-resource-types")
+	case !allResourceTypes && len(args) == 0:
+		return errors.New("requires at least 1 arg(s), only received 0")
+	}
+
+	allowPartialDeletions := cobrautil.MustGetBool(cmd, "force")
+	switch {
+	case allResourceTypes:
+		if err := ConfirmDestructive(cmd, "delete the relationships of every resource type"); err != nil {
+			return err
+		}
+	case allowPartialDeletions:
+		if err := ConfirmDestructive(cmd, "delete all the relationships matching "+strings.Join(args, " ")); err != nil {
+			return err
+		}
+	}
+
 	spicedbClient, err := client.NewClient(cmd)
 	if err != nil {
 		return err
 	}
 
-	filter, err := buildRelationshipsFilter(cmd, args)
-	if err != nil {
+	deletedAt, err := bulkDeleteMatchingRelationships(cmd, spicedbClient, args, allResourceTypes, allowPartialDeletions)
+	if err != nil || deletedAt == nil {
 		return err
 	}
 
+	console.Println(deletedAt.GetToken())
+	return recordConsistencyToken(cmd, deletedAt)
+}
+
+// bulkDeleteMatchingRelationships deletes the relationships matching the
+// arguments, or of every resource type, and returns the revision following
+// the deletions, or nil if there was nothing to delete.
+func bulkDeleteMatchingRelationships(cmd *cobra.Command, spicedbClient client.Client, args []string, allResourceTypes bool, allowPartialDeletions bool) (*v1.ZedToken, error) {
+	optionalLimit := cobrautil.MustGetUint32(cmd, "optional-limit")
+	progressAccurate := cobrautil.MustGetBool(cmd, "progress-accurate")
+	if allResourceTypes {
+		return bulkDeleteAllResourceTypes(cmd.Context(), spicedbClient, optionalLimit, allowPartialDeletions, progressAccurate, cobrautil.MustGetInt(cmd, "parallelism"))
+	}
+
+	filter, subjectIDPrefix, err := buildRelationshipsFilter(cmd, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if subjectIDPrefix != "" {
+		return bulkDeleteRelationshipsWithSubjectIDPrefix(cmd.Context(), spicedbClient, filter, subjectIDPrefix, optionalLimit, allowPartialDeletions)
+	}
+
 	bar := console.CreateProgressBar("deleting relationships")
 	defer func() {
 		_ = bar.Finish()
 	}()
 
-	allowPartialDeletions := cobrautil.MustGetBool(cmd, "force")
-	optionalLimit := cobrautil.MustGetUint32(cmd, "optional-limit")
+	deletedAt, _, err := deleteRelationshipsMatchingFilter(cmd.Context(), spicedbClient, filter, optionalLimit, allowPartialDeletions, progressAccurate, bar)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = bar.Finish()
+	return deletedAt, nil
+}
+
+// deleteRelationshipsMatchingFilter deletes the relationships matching the
+// filter in batches of optionalLimit, until none are left, advancing the bar
+// as it goes. It returns the revision of the final deletion and the number of
+// relationships deleted.
+//
+// DeleteRelationships does not report how many relationships it deleted: a
+// partial deletion deletes exactly optionalLimit, but the final one deletes
+// whatever remains. If countFirst is set, the matching relationships are
+// counted beforehand and the final deletion removes the remainder; otherwise
+// the final deletion is counted afterwards, by reading the relationships it
+// removed at the revision it started from. Either way, relationships written
+// or deleted concurrently can make the count approximate.
+func deleteRelationshipsMatchingFilter(ctx context.Context, spicedbClient client.Client, filter *v1.RelationshipFilter, optionalLimit uint32, allowPartialDeletions bool, countFirst bool, bar *progressbar.ProgressBar) (*v1.ZedToken, int, error) {
+	expected := -1
+	if countFirst {
+		expected = 0
+		if err := readAllRelationships(ctx, spicedbClient, filter, optionalLimit, func(*v1.Relationship) error {
+			expected++
+			return nil
+		}); err != nil {
+			return nil, 0, fmt.Errorf("failed to count relationships: %w", err)
+		}
+		log.Debug().Str("resource type", filter.ResourceType).Int("count", expected).Msg("counted relationships to delete")
+	}
+
+	// The revision before the pending deletion, to count the final one at.
+	var before *v1.ZedToken
+	if !countFirst {
+		var err error
+		if before, err = matchingRelationshipsRevision(ctx, spicedbClient, filter); err != nil {
+			return nil, 0, fmt.Errorf("failed to read relationships: %w", err)
+		}
+	}
 
-	var resp *v1.DeleteRelationshipsResponse
+	var deleted int
 	for {
 		delRequest := &v1.DeleteRelationshipsRequest{
 			RelationshipFilter:            filter,
@@ -152,7 +340,7 @@ func bulkDeleteRelationships(cmd *cobra.Command, args []string) error {
 		}
 		log.Trace().Interface("request", delRequest).Msg("deleting relationships")
 
-		resp, err = spicedbClient.DeleteRelationships(cmd.Context(), delRequest)
+		resp, err := spicedbClient.DeleteRelationships(ctx, delRequest)
 		if errorInfo, ok := grpcErrorInfoFrom(err); ok {
 			if errorInfo.GetReason() == v1.ErrorReason_ERROR_REASON_TOO_MANY_RELATIONSHIPS_FOR_TRANSACTIONAL_DELETE.String() {
 				resourceType := "relationships"
@@ -160,27 +348,233 @@ func bulkDeleteRelationships(cmd *cobra.Command, args []string) error {
 					resourceType = returnedResourceType
 				}
 
-				return fmt.Errorf("could not delete %s, as more than %s relationships were found. Consider increasing --optional-limit or deleting all relationships using --force",
+				return nil, 0, fmt.Errorf("could not delete %s, as more than %s relationships were found. Consider increasing --optional-limit or deleting all relationships using --force",
 					resourceType,
 					errorInfo.GetMetadata()["limit"])
 			}
 		}
 		if err != nil {
-			return err
+			return nil, 0, err
 		}
 
-		if resp.DeletionProgress == v1.DeleteRelationshipsResponse_DELETION_PROGRESS_COMPLETE {
-			break
+		batch := int(optionalLimit)
+		complete := resp.DeletionProgress == v1.DeleteRelationshipsResponse_DELETION_PROGRESS_COMPLETE
+		switch {
+		case complete && countFirst:
+			// Relationships written since they were counted are not
+			// included, and those deleted concurrently are not subtracted.
+			batch = max(expected-deleted, 0)
+		case complete:
+			if batch, err = countRelationshipsAt(ctx, spicedbClient, filter, before); err != nil {
+				return nil, 0, fmt.Errorf("failed to count deleted relationships: %w", err)
+			}
+		default:
+			before = resp.DeletedAt
 		}
 
-		if err := bar.Add(int(optionalLimit)); err != nil {
+		deleted += batch
+		if err := bar.Add(batch); err != nil {
+			return nil, 0, err
+		}
+
+		if complete {
+			return resp.DeletedAt, deleted, nil
+		}
+	}
+}
+
+// bulkDeleteAllResourceTypes deletes the relationships of every resource type
+// defined in the schema, running up to parallelism deletions concurrently, and
+// returns the revision of the deletion to complete last, or nil if nothing was
+// deleted.
+func bulkDeleteAllResourceTypes(ctx context.Context, spicedbClient client.Client, optionalLimit uint32, allowPartialDeletions bool, countFirst bool, parallelism int) (*v1.ZedToken, error) {
+	if parallelism < 1 {
+		return nil, errors.New("parallelism must be at least 1")
+	}
+
+	schemaText, err := ReadSchema(ctx, spicedbClient)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled, err := compiler.Compile(
+		compiler.InputSchema{Source: "schema", SchemaString: schemaText},
+		compiler.AllowUnprefixedObjectType(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema: %w", err)
+	}
+
+	bar := console.CreateProgressBar("deleting relationships")
+	defer func() {
+		_ = bar.Finish()
+	}()
+
+	var (
+		lock      sync.Mutex
+		deleted   int
+		deletedAt *v1.ZedToken
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(parallelism)
+	for _, def := range compiled.ObjectDefinitions {
+		resourceType := def.Name
+		g.Go(func() error {
+			typeDeletedAt, count, err := deleteRelationshipsMatchingFilter(gctx, spicedbClient, &v1.RelationshipFilter{ResourceType: resourceType}, optionalLimit, allowPartialDeletions, countFirst, bar)
+			if err != nil {
+				return fmt.Errorf("failed to delete the relationships of %s: %w", resourceType, err)
+			}
+			log.Info().Str("resource type", resourceType).Int("relationships", count).Msg("deleted relationships")
+
+			lock.Lock()
+			defer lock.Unlock()
+			deleted += count
+			deletedAt = typeDeletedAt
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	_ = bar.Finish()
+	if len(compiled.ObjectDefinitions) == 0 {
+		console.Errorf("no resource types defined in the schema\n")
+		return nil, nil
+	}
+
+	log.Info().Int("resource types", len(compiled.ObjectDefinitions)).Int("relationships", deleted).Msg("deleted the relationships of every resource type")
+	if deleted == 0 {
+		console.Errorf("no relationships found\n")
+		return nil, nil
+	}
+
+	// Zedtokens cannot be compared, so the deletion to complete last stands
+	// in for the latest.
+	return deletedAt, nil
+}
+
+// matchingRelationshipsRevision returns the revision at which a relationship
+// matching the filter was read, or nil if none match.
+func matchingRelationshipsRevision(ctx context.Context, spicedbClient client.Client, filter *v1.RelationshipFilter) (*v1.ZedToken, error) {
+	stream, err := spicedbClient.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true},
+		},
+		RelationshipFilter: filter,
+		OptionalLimit:      1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.ReadAt, nil
+}
+
+// countRelationshipsAt counts the relationships matching the filter at the
+// revision, which counts none if it is nil.
+func countRelationshipsAt(ctx context.Context, spicedbClient client.Client, filter *v1.RelationshipFilter, at *v1.ZedToken) (int, error) {
+	if at == nil {
+		return 0, nil
+	}
+
+	stream, err := spicedbClient.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: at},
+		},
+		RelationshipFilter: filter,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for {
+		_, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+}
+
+// bulkDeleteRelationshipsWithSubjectIDPrefix deletes the relationships matching
+// the filter whose subject ID has the prefix. DeleteRelationships cannot filter
+// on a subject ID prefix, so the matching relationships are deleted in batches
+// of optionalLimit as they are read. Without partial deletions they must all
+// be deleted at once, so up to optionalLimit of them are read first. It
+// returns the revision of the final deletion, or nil if no relationship had
+// the prefix.
+func bulkDeleteRelationshipsWithSubjectIDPrefix(ctx context.Context, spicedbClient client.Client, filter *v1.RelationshipFilter, subjectIDPrefix string, optionalLimit uint32, allowPartialDeletions bool) (*v1.ZedToken, error) {
+	bar := console.CreateProgressBar("deleting relationships")
+	defer func() {
+		_ = bar.Finish()
+	}()
+
+	var (
+		batch     []*v1.RelationshipUpdate
+		writtenAt *v1.ZedToken
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		request := &v1.WriteRelationshipsRequest{Updates: batch}
+		log.Trace().Interface("request", request).Msg("deleting relationships")
+		resp, err := spicedbClient.WriteRelationships(ctx, request)
+		if err != nil {
 			return err
 		}
+		writtenAt = resp.WrittenAt
+
+		if err := bar.Add(len(batch)); err != nil {
+			return err
+		}
+		batch = nil
+		return nil
+	}
+
+	err := readAllRelationships(ctx, spicedbClient, filter, optionalLimit, func(rel *v1.Relationship) error {
+		if !hasSubjectIDPrefix(rel, subjectIDPrefix) {
+			return nil
+		}
+		batch = append(batch, &v1.RelationshipUpdate{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: rel})
+
+		switch {
+		case optionalLimit == 0 || len(batch) < int(optionalLimit):
+			return nil
+		case allowPartialDeletions:
+			return flush()
+		case len(batch) > int(optionalLimit):
+			return fmt.Errorf("could not delete %s, as more than %d relationships were found. Consider increasing --optional-limit or deleting all relationships using --force",
+				filter.ResourceType, optionalLimit)
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
 	}
 
 	_ = bar.Finish()
-	console.Println(resp.DeletedAt.GetToken())
-	return nil
+	if writtenAt == nil {
+		console.Errorf("no relationships with subject ID prefix %q found\n", subjectIDPrefix)
+	}
+	return writtenAt, nil
 }
 
 func grpcErrorInfoFrom(err error) (*errdetails.ErrorInfo, bool) {
@@ -199,14 +593,18 @@ func grpcErrorInfoFrom(err error) (*errdetails.ErrorInfo, bool) {
 	return nil, false
 }
 
-func buildRelationshipsFilter(cmd *cobra.Command, args []string) (*v1.RelationshipFilter, error) {
-	filter := &v1.RelationshipFilter{ResourceType: args[0]}
+// buildRelationshipsFilter builds the filter for the relationships matching the
+// arguments. RelationshipFilter has no field for a subject ID prefix, so a
+// subject ID ending in '%' is returned separately to be matched client-side,
+// with the filter narrowed to the subject type.
+func buildRelationshipsFilter(cmd *cobra.Command, args []string) (filter *v1.RelationshipFilter, subjectIDPrefix string, err error) {
+	filter = &v1.RelationshipFilter{ResourceType: args[0]}
 
 	if strings.Contains(args[0], ":") {
 		var resourceID string
 		err := stringz.SplitExact(args[0], ":", &filter.ResourceType, &resourceID)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		if strings.HasSuffix(resourceID, "%") {
@@ -223,7 +621,7 @@ func buildRelationshipsFilter(cmd *cobra.Command, args []string) (*v1.Relationsh
 	subjectFilter := cobrautil.MustGetString(cmd, "subject-filter")
 	if len(args) == 3 {
 		if subjectFilter != "" {
-			return nil, errors.New("cannot specify subject filter both positionally and via --subject-filter")
+			return nil, "", errors.New("cannot specify subject filter both positionally and via --subject-filter")
 		}
 		subjectFilter = args[2]
 	}
@@ -232,7 +630,12 @@ func buildRelationshipsFilter(cmd *cobra.Command, args []string) (*v1.Relationsh
 		if strings.Contains(subjectFilter, ":") {
 			subjectNS, subjectID, subjectRel, err := ParseSubject(subjectFilter)
 			if err != nil {
-				return nil, err
+				return nil, "", err
+			}
+
+			if strings.HasSuffix(subjectID, "%") {
+				subjectIDPrefix = strings.TrimSuffix(subjectID, "%")
+				subjectID = ""
 			}
 
 			filter.OptionalSubjectFilter = &v1.SubjectFilter{
@@ -249,7 +652,13 @@ func buildRelationshipsFilter(cmd *cobra.Command, args []string) (*v1.Relationsh
 		}
 	}
 
-	return filter, nil
+	return filter, subjectIDPrefix, nil
+}
+
+// hasSubjectIDPrefix returns whether the relationship's subject ID starts
+// with the prefix, which is always the case for an empty prefix.
+func hasSubjectIDPrefix(rel *v1.Relationship, prefix string) bool {
+	return strings.HasPrefix(rel.GetSubject().GetObject().GetObjectId(), prefix)
 }
 
 func readRelationships(cmd *cobra.Command, args []string) error {
@@ -258,21 +667,102 @@ func readRelationships(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	filter, err := buildRelationshipsFilter(cmd, args)
+	filter, subjectIDPrefix, err := buildRelationshipsFilter(cmd, args)
 	if err != nil {
 		return err
 	}
 
-	request := &v1.ReadRelationshipsRequest{RelationshipFilter: filter}
+	tmpl, err := outputTemplateFromCmd(cmd)
+	if err != nil {
+		return err
+	}
 
-	limit := cobrautil.MustGetUint32(cmd, "page-limit")
-	request.OptionalLimit = limit
-	request.Consistency, err = consistencyFromCmd(cmd)
+	consistency, err := consistencyFromCmd(cmd)
+	if err != nil {
+		return err
+	}
+
+	resolver, err := subjectResolverFromCmd(cmd, spicedbClient, consistency)
 	if err != nil {
 		return err
 	}
 
+	caveatContextOnly := cobrautil.MustGetBool(cmd, "show-caveat-context-only")
+	emit := func(msg *v1.ReadRelationshipsResponse) error {
+		if caveatContextOnly {
+			if msg.Relationship.OptionalCaveat == nil {
+				return nil
+			}
+
+			caveatString, err := relationshipCaveatContextString(msg.Relationship)
+			if err != nil {
+				return err
+			}
+			console.Println(caveatString)
+			return nil
+		}
+
+		if tmpl != nil {
+			return printWithTemplate(tmpl, msg.Relationship)
+		}
+
+		if err := printRelationship(cmd, msg); err != nil {
+			return err
+		}
+
+		if resolver != nil {
+			return resolver.printResolved(cmd.Context(), msg.Relationship.Subject)
+		}
+		return nil
+	}
+
+	// Sorting requires every relationship to be read before any is printed,
+	// so the number buffered is bounded.
+	sortOutput := cobrautil.MustGetBool(cmd, "sort")
+	sortBufferSize := cobrautil.MustGetUint(cmd, "sort-buffer-size")
+	var buffered []*v1.ReadRelationshipsResponse
+
+	var deduplicator *relationshipDeduplicator
+	if cobrautil.MustGetBool(cmd, "deduplicate") {
+		deduplicator = newRelationshipDeduplicator(cobrautil.MustGetUint(cmd, "deduplicate-max-size"))
+	}
+
+	request := &v1.ReadRelationshipsRequest{RelationshipFilter: filter}
+
+	limit := cobrautil.MustGetUint32(cmd, "page-limit")
+	request.OptionalLimit = limit
+	request.Consistency = consistency
+
+	var readAt *v1.ZedToken
 	lastCursor := request.OptionalCursor
+	var pages, received uint
+
+	// A read interrupted by a retryable error is resumed from the cursor of
+	// the last relationship read, which is only possible before any
+	// relationship has been read if the server returned no cursor.
+	disableRetries := cobrautil.MustGetBool(cmd, "disable-retries")
+	maxRetries := cobrautil.MustGetUint(cmd, "max-retries")
+	backoffInterval := backoff.NewExponentialBackOff()
+	backoffInterval.InitialInterval = readRetryBackoff
+	backoffInterval.MaxElapsedTime = 0
+	backoffInterval.Reset()
+	var retries uint
+	shouldRetry := func(err error, relCount uint32) bool {
+		if disableRetries || retries >= maxRetries || !isRetryableReadError(err) || cmd.Context().Err() != nil {
+			return false
+		}
+		if relCount > 0 && lastCursor == nil {
+			return false
+		}
+
+		retries++
+		bo := backoffInterval.NextBackOff()
+		log.Warn().Err(err).Uint("attempt", retries).Uint("max-retries", maxRetries).Stringer("backoff", bo).Msg("relationship read interrupted, resuming from the last relationship read")
+		time.Sleep(bo)
+		return true
+	}
+
+readPages:
 	for {
 		request.OptionalCursor = lastCursor
 		var cursorToken string
@@ -282,6 +772,9 @@ func readRelationships(cmd *cobra.Command, args []string) error {
 		log.Trace().Interface("request", request).Str("cursor", cursorToken).Msg("reading relationships page")
 		readRelClient, err := spicedbClient.ReadRelationships(cmd.Context(), request)
 		if err != nil {
+			if shouldRetry(err, 0) {
+				continue
+			}
 			return err
 		}
 
@@ -297,35 +790,144 @@ func readRelationships(cmd *cobra.Command, args []string) error {
 			}
 
 			if err != nil {
+				if shouldRetry(err, relCount) {
+					continue readPages
+				}
 				return err
 			}
 
 			lastCursor = msg.AfterResultCursor
+			if readAt == nil {
+				readAt = msg.ReadAt
+			}
 			relCount++
-			if err := printRelationship(cmd, msg); err != nil {
+			received++
+			if !hasSubjectIDPrefix(msg.Relationship, subjectIDPrefix) {
+				continue
+			}
+			if deduplicator != nil {
+				key := tuple.V1StringRelationshipWithoutCaveatOrExpiration(msg.Relationship)
+				if deduplicator.seen(key) {
+					log.Debug().Str("relationship", key).Msg("skipping duplicate relationship")
+					continue
+				}
+			}
+
+			if sortOutput {
+				if uint(len(buffered)) >= sortBufferSize {
+					return fmt.Errorf("more than %d relationships matched; narrow the filter or raise --sort-buffer-size", sortBufferSize)
+				}
+				buffered = append(buffered, msg)
+				continue
+			}
+
+			if err := emit(msg); err != nil {
 				return err
 			}
 		}
+		pages++
 
 		if relCount < limit || limit == 0 {
-			return nil
+			break
 		}
 
 		if relCount > limit {
 			log.Warn().Uint32("limit-specified", limit).Uint32("relationships-received", relCount).Msg("page limit ignored, pagination may not be supported by the server, consider updating SpiceDB")
-			return nil
+			break
+		}
+	}
+
+	sortReadRelationships(buffered)
+	for _, msg := range buffered {
+		if err := emit(msg); err != nil {
+			return err
 		}
 	}
+
+	if cobrautil.MustGetBool(cmd, "paginate-summary") {
+		printPaginationSummary(pages, received, lastCursor)
+	}
+
+	if cobrautil.MustGetBool(cmd, "include-revision") {
+		return printReadAt(cmd, readAt)
+	}
+	return nil
+}
+
+// printPaginationSummary outputs to stderr how many pages and relationships a
+// read received, and the cursor to resume it from.
+func printPaginationSummary(pages, relationships uint, lastCursor *v1.Cursor) {
+	cursor := "none"
+	if lastCursor.GetToken() != "" {
+		cursor = lastCursor.GetToken()
+	}
+	console.Errorf("read %s and %s; last cursor: %s\n",
+		english.Plural(int(pages), "page", ""),
+		english.Plural(int(relationships), "relationship", ""),
+		cursor)
+}
+
+// readRetryBackoff is the initial backoff before resuming an interrupted read.
+var readRetryBackoff = 50 * time.Millisecond
+
+func isRetryableReadError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// printReadAt outputs the revision relationships were read at, which is only
+// known if at least one relationship was read.
+func printReadAt(cmd *cobra.Command, readAt *v1.ZedToken) error {
+	if readAt == nil {
+		log.Warn().Msg("no relationships were read, so the revision read at is unknown")
+		return nil
+	}
+
+	if cobrautil.MustGetBool(cmd, "json") || cobrautil.MustGetBool(cmd, "json-relationship") {
+		readAtJSON, err := json.Marshal(struct {
+			ReadAt string `json:"readAt"`
+		}{readAt.Token})
+		if err != nil {
+			return err
+		}
+		console.Println(string(readAtJSON))
+		return nil
+	}
+	console.Errorf("read at: %s\n", readAt.Token)
+	return nil
+}
+
+// sortReadRelationships sorts the responses lexically by the string form of
+// their relationships.
+func sortReadRelationships(msgs []*v1.ReadRelationshipsResponse) {
+	keys := make(map[*v1.ReadRelationshipsResponse]string, len(msgs))
+	for _, msg := range msgs {
+		keys[msg] = tuple.MustV1StringRelationship(msg.Relationship)
+	}
+	slices.SortFunc(msgs, func(a, b *v1.ReadRelationshipsResponse) int {
+		return strings.Compare(keys[a], keys[b])
+	})
 }
 
 func printRelationship(cmd *cobra.Command, msg *v1.ReadRelationshipsResponse) error {
-	if cobrautil.MustGetBool(cmd, "json") {
-		prettyProto, err := PrettyProto(msg)
+	if cobrautil.MustGetBool(cmd, "json-relationship") {
+		relJSON, err := relationshipToJSON(msg.Relationship)
 		if err != nil {
 			return err
 		}
 
-		console.Println(string(prettyProto))
+		console.Println(relJSON)
+	} else if cobrautil.MustGetBool(cmd, "json") {
+		encoded, err := jsonProtoFromCmd(cmd, msg)
+		if err != nil {
+			return err
+		}
+
+		console.Println(string(encoded))
 	} else {
 		relString, err := relationshipToString(msg.Relationship)
 		if err != nil {
@@ -337,6 +939,71 @@ func printRelationship(cmd *cobra.Command, msg *v1.ReadRelationshipsResponse) er
 	return nil
 }
 
+func existsCmdFunc(cmd *cobra.Command, args []string) error {
+	rel, err := argsToRelationship(args)
+	if err != nil {
+		return err
+	}
+
+	consistency, err := consistencyFromCmd(cmd)
+	if err != nil {
+		return err
+	}
+
+	spicedbClient, err := client.NewClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	exists, err := relationshipExists(cmd.Context(), spicedbClient, rel, consistency)
+	if err != nil {
+		return err
+	}
+
+	if !cobrautil.MustGetBool(cmd, "quiet") {
+		console.Println(exists)
+	}
+
+	if !exists {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// relationshipExists returns whether the relationship, ignoring any caveat or
+// expiration, exists.
+func relationshipExists(ctx context.Context, spicedbClient client.Client, rel *v1.Relationship, consistency *v1.Consistency) (bool, error) {
+	request := &v1.ReadRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:       rel.Resource.ObjectType,
+			OptionalResourceId: rel.Resource.ObjectId,
+			OptionalRelation:   rel.Relation,
+			OptionalSubjectFilter: &v1.SubjectFilter{
+				SubjectType:       rel.Subject.Object.ObjectType,
+				OptionalSubjectId: rel.Subject.Object.ObjectId,
+				OptionalRelation:  &v1.SubjectFilter_RelationFilter{Relation: rel.Subject.OptionalRelation},
+			},
+		},
+		Consistency:   consistency,
+		OptionalLimit: 1,
+	}
+
+	log.Trace().Interface("request", request).Msg("reading relationship")
+	stream, err := spicedbClient.ReadRelationships(ctx, request)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func argsToRelationship(args []string) (*v1.Relationship, error) {
 	if len(args) != 3 {
 		return nil, fmt.Errorf("expected 3 arguments, but got %d", len(args))
@@ -361,6 +1028,36 @@ func relationshipToString(rel *v1.Relationship) (string, error) {
 	return relString, nil
 }
 
+// relationshipToJSON returns the relationship, including any caveat and
+// expiration, as a single line of JSON.
+func relationshipToJSON(rel *v1.Relationship) (string, error) {
+	encoded, err := CompactProto(rel)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// relationshipCaveatContextString returns the caveated relationship as
+// `resource relation subject caveat_name {context-json}`.
+func relationshipCaveatContextString(rel *v1.Relationship) (string, error) {
+	relString := tuple.V1StringRelationshipWithoutCaveatOrExpiration(rel)
+	relString = strings.Replace(relString, "@", " ", 1)
+	relString = strings.Replace(relString, "#", " ", 1)
+
+	caveatContext := map[string]any{}
+	if rel.OptionalCaveat.Context != nil {
+		caveatContext = rel.OptionalCaveat.Context.AsMap()
+	}
+
+	contextJSON, err := json.Marshal(caveatContext)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal caveat context: %w", err)
+	}
+
+	return fmt.Sprintf("%s %s %s", relString, rel.OptionalCaveat.CaveatName, contextJSON), nil
+}
+
 // parseRelationshipLine splits a line of update input that comes from stdin
 // and returns the fields representing the 3 arguments. This is to handle
 // the fact that relationships specified via stdin can't escape spaces like
@@ -417,6 +1114,20 @@ func tupleToRel(resource, relation, subject string) (*v1.Relationship, error) {
 	return tuple.ParseV1Rel(resource + "#" + relation + "@" + subject)
 }
 
+// LookupRelationshipParser wraps a parser of lookup results, which name a
+// permission in place of a relation, and replaces that permission with the
+// given relation.
+func LookupRelationshipParser(parser RelationshipParser, relation string) RelationshipParser {
+	return func() (*v1.Relationship, error) {
+		rel, err := parser()
+		if err != nil {
+			return nil, err
+		}
+		rel.Relation = relation
+		return rel, nil
+	}
+}
+
 func SliceRelationshipParser(args []string) RelationshipParser {
 	ran := false
 	return func() (*v1.Relationship, error) {
@@ -428,9 +1139,12 @@ func SliceRelationshipParser(args []string) RelationshipParser {
 	}
 }
 
-func writeUpdates(ctx context.Context, spicedbClient client.Client, updates []*v1.RelationshipUpdate, json bool) error {
+// writeUpdates writes the updates in a single request, printing the revision
+// they were written at, which is also returned. Nothing is written if there
+// are no updates.
+func writeUpdates(ctx context.Context, spicedbClient client.Client, updates []*v1.RelationshipUpdate, json bool) (*v1.ZedToken, error) {
 	if len(updates) == 0 {
-		return nil
+		return nil, nil
 	}
 	request := &v1.WriteRelationshipsRequest{
 		Updates:               updates,
@@ -440,13 +1154,13 @@ func writeUpdates(ctx context.Context, spicedbClient client.Client, updates []*v
 	log.Trace().Interface("request", request).Msg("writing relationships")
 	resp, err := spicedbClient.WriteRelationships(ctx, request)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if json {
 		prettyProto, err := PrettyProto(resp)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		console.Println(string(prettyProto))
@@ -454,7 +1168,7 @@ func writeUpdates(ctx context.Context, spicedbClient client.Client, updates []*v
 		console.Println(resp.WrittenAt.GetToken())
 	}
 
-	return nil
+	return resp.WrittenAt, nil
 }
 
 // RelationshipParser is a closure that can produce relationships.
@@ -468,9 +1182,15 @@ var ErrExhaustedRelationships = errors.New("exhausted all relationships")
 
 func writeRelationshipCmdFunc(operation v1.RelationshipUpdate_Operation, input *os.File) func(cmd *cobra.Command, args []string) error {
 	return func(cmd *cobra.Command, args []string) error {
+		fromLookup := cobrautil.MustGetString(cmd, "from-lookup")
 		parser := SliceRelationshipParser(args)
 		if isArgsViaFile(input) && len(args) == 0 {
 			parser = FileRelationshipParser(input)
+			if fromLookup != "" {
+				parser = LookupRelationshipParser(parser, fromLookup)
+			}
+		} else if fromLookup != "" {
+			return errors.New("--from-lookup reads lookup results from stdin and cannot be combined with arguments")
 		}
 
 		spicedbClient, err := client.NewClient(cmd)
@@ -482,10 +1202,81 @@ func writeRelationshipCmdFunc(operation v1.RelationshipUpdate_Operation, input *
 		updateBatch := make([]*v1.RelationshipUpdate, 0)
 		doJSON := cobrautil.MustGetBool(cmd, "json")
 
+		var deduplicator *relationshipDeduplicator
+		if cobrautil.MustGetBool(cmd, "dedupe") {
+			deduplicator = newRelationshipDeduplicator(0)
+		}
+		var sorter *relationshipSorter
+		if cobrautil.MustGetBool(cmd, "dedupe-disk") {
+			sorter = newRelationshipSorter(dedupeDiskRunSize)
+			defer func() {
+				if err := sorter.Close(); err != nil {
+					log.Warn().Err(err).Msg("failed to remove dedupe file")
+				}
+			}()
+
+			parser, err = sorter.sortedParser(parser)
+			if err != nil {
+				return err
+			}
+		}
+		var duplicates int
+
+		continueOnError := cobrautil.MustGetBool(cmd, "continue-on-error")
+		var failed int
+
+		var summary writeSummary
+		var writtenAt *v1.ZedToken
+		flush := func() error {
+			batchWrittenAt, err := writeUpdates(cmd.Context(), spicedbClient, updateBatch, doJSON)
+			if err != nil {
+				if !continueOnError {
+					return err
+				}
+
+				rels := make([]string, 0, len(updateBatch))
+				for _, update := range updateBatch {
+					rels = append(rels, tuple.MustV1StringRelationship(update.Relationship))
+				}
+				log.Error().Err(err).Strs("relationships", rels).Msg("failed to write batch of relationships, continuing")
+				failed += len(updateBatch)
+				return nil
+			}
+			if len(updateBatch) > 0 {
+				summary.Relationships += len(updateBatch)
+				summary.Batches++
+				writtenAt = batchWrittenAt
+			}
+			return nil
+		}
+
 		for {
 			rel, err := parser()
 			if errors.Is(err, ErrExhaustedRelationships) {
-				return writeUpdates(cmd.Context(), spicedbClient, updateBatch, doJSON)
+				if err := flush(); err != nil {
+					return err
+				}
+				if err := recordConsistencyToken(cmd, writtenAt); err != nil {
+					return err
+				}
+				if sorter != nil {
+					duplicates = sorter.duplicates
+				}
+				if deduplicator != nil || sorter != nil {
+					console.Errorf("skipped %s\n", english.Plural(duplicates, "duplicate relationship", ""))
+				}
+				if continueOnError {
+					console.Errorf("%d relationships succeeded, %d failed\n", summary.Relationships, failed)
+				}
+				if cobrautil.MustGetBool(cmd, "summary") {
+					if err := summary.print(doJSON); err != nil {
+						return err
+					}
+				}
+				if failed > 0 {
+					return errWriteRelationshipsFailed
+				}
+				return nil
 			} else if err != nil {
 				return err
 			}
@@ -496,12 +1287,24 @@ func writeRelationshipCmdFunc(operation v1.RelationshipUpdate_Operation, input *
 				}
 			}
 
+			if deduplicator != nil {
+				key, err := relationshipKey(rel)
+				if err != nil {
+					return err
+				}
+				if deduplicator.seen(key) {
+					log.Debug().Str("relationship", key).Msg("skipping duplicate relationship")
+					duplicates++
+					continue
+				}
+			}
+
 			updateBatch = append(updateBatch, &v1.RelationshipUpdate{
 				Operation:    operation,
 				Relationship: rel,
 			})
 			if len(updateBatch) == batchSize {
-				if err := writeUpdates(cmd.Context(), spicedbClient, updateBatch, doJSON); err != nil {
+				if err := flush(); err != nil {
 					return err
 				}
 				updateBatch = nil
@@ -510,6 +1313,29 @@ func writeRelationshipCmdFunc(operation v1.RelationshipUpdate_Operation, input *
 	}
 }
 
+var errWriteRelationshipsFailed = errors.New("one or more batches of relationships failed to be written")
+
+// writeSummary counts the relationships written by a write command, and the
+// batches they were written in.
+type writeSummary struct {
+	Relationships int `json:"relationships"`
+	Batches       int `json:"batches"`
+}
+
+func (s writeSummary) print(asJSON bool) error {
+	if asJSON {
+		summaryJSON, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		console.Println(string(summaryJSON))
+		return nil
+	}
+
+	console.Errorf("wrote %s in %s\n", english.Plural(s.Relationships, "relationship", ""), english.Plural(s.Batches, "batch", "batches"))
+	return nil
+}
+
 func handleCaveatFlag(cmd *cobra.Command, rel *v1.Relationship) error {
 	caveatString := cobrautil.MustGetString(cmd, "caveat")
 	if caveatString != "" {
@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/zed/internal/client"
+)
+
+func registerRelationshipApplyCmd(relationshipCmd *cobra.Command) {
+	relationshipCmd.AddCommand(applyCmd)
+	applyCmd.Flags().IntP("batch-size", "b", 100, "batch size when writing the changes")
+	applyCmd.Flags().Bool("json", false, "output as JSON")
+	registerConsistencyTokenFileFlag(applyCmd.Flags())
+
+	relationshipCmd.AddCommand(txCmd)
+	txCmd.Flags().Int("max-updates", 1000, "maximum number of updates the server accepts in a single write, as set by SpiceDB's --write-relationships-max-updates-per-call")
+	txCmd.Flags().Bool("json", false, "output as JSON")
+	registerConsistencyTokenFileFlag(txCmd.Flags())
+}
+
+const applyCmdHelpLong = `Writes a changeset of relationships, each with its own operation, read from a file or stdin.
+
+Each line is an operation followed by a relationship, which is either text (e.g.
+"document:1 reader user:2" or "document:1#reader@user:2") or a JSON-encoded Relationship.
+The operation is one of:
+
+  + or create   create the relationship, failing if it exists
+  ~ or touch    create or update the relationship
+  - or delete   delete the relationship
+
+The output of "zed relationship diff" can be applied as is. Changes are written in
+batches of --batch-size, each of which is applied atomically.`
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <file?>",
+	Short: "Writes relationships with mixed create, touch and delete operations",
+	Long:  applyCmdHelpLong,
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  applyRelationshipsCmdFunc,
+}
+
+const txCmdHelpLong = `Writes a changeset of relationships, read from a file or stdin, in a single transaction.
+
+The changeset is in the same format as for "zed relationship apply", but is sent as
+a single write: either every change is applied or, if any is rejected, none is.
+A changeset larger than --max-updates is refused rather than split, as splitting
+it would lose this guarantee.`
+
+var txCmd = &cobra.Command{
+	Use:   "tx <file?>",
+	Short: "Writes relationships with mixed create, touch and delete operations, all or nothing",
+	Long:  txCmdHelpLong,
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  txRelationshipsCmdFunc,
+}
+
+// openChangeset returns the changeset file given as argument, or stdin.
+func openChangeset(args []string) (io.ReadCloser, error) {
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to open changeset file: %w", err)
+		}
+		return f, nil
+	}
+
+	if !isArgsViaFile(os.Stdin) {
+		return nil, errors.New("must provide a changeset file path or contents via stdin")
+	}
+	return io.NopCloser(os.Stdin), nil
+}
+
+func applyRelationshipsCmdFunc(cmd *cobra.Command, args []string) error {
+	batchSize := cobrautil.MustGetInt(cmd, "batch-size")
+	if batchSize < 1 {
+		return errors.New("batch size must be at least 1")
+	}
+
+	input, err := openChangeset(args)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	spicedbClient, err := client.NewClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	doJSON := cobrautil.MustGetBool(cmd, "json")
+	var writtenAt *v1.ZedToken
+	err = forEachChangesetBatch(input, batchSize, func(batch []*v1.RelationshipUpdate) error {
+		writtenAt, err = writeUpdates(cmd.Context(), spicedbClient, batch, doJSON)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return recordConsistencyToken(cmd, writtenAt)
+}
+
+func txRelationshipsCmdFunc(cmd *cobra.Command, args []string) error {
+	maxUpdates := cobrautil.MustGetInt(cmd, "max-updates")
+	if maxUpdates < 1 {
+		return errors.New("max updates must be at least 1")
+	}
+
+	input, err := openChangeset(args)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	// A batch one larger than the maximum only fills up if the changeset is
+	// too large; otherwise, the final batch is the whole changeset.
+	var updates []*v1.RelationshipUpdate
+	err = forEachChangesetBatch(input, maxUpdates+1, func(batch []*v1.RelationshipUpdate) error {
+		if len(batch) > maxUpdates {
+			return fmt.Errorf("changeset has more than %d updates, which cannot be written in a single transaction; use zed relationship apply to write it in batches, or raise --max-updates if the server allows it", maxUpdates)
+		}
+		updates = batch
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(updates) == 0 {
+		return errors.New("changeset is empty")
+	}
+
+	spicedbClient, err := client.NewClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	log.Debug().Int("updates", len(updates)).Msg("writing changeset in a single transaction")
+	writtenAt, err := writeUpdates(cmd.Context(), spicedbClient, updates, cobrautil.MustGetBool(cmd, "json"))
+	if err != nil {
+		return err
+	}
+	return recordConsistencyToken(cmd, writtenAt)
+}
+
+// forEachChangesetBatch parses each non-empty line of the changeset as an
+// operation followed by a relationship, and calls fn with them in batches of
+// batchSize, followed by a final batch of the remainder, if any.
+func forEachChangesetBatch(r io.Reader, batchSize int, fn func([]*v1.RelationshipUpdate) error) error {
+	return forEachLineBatch(r, batchSize, "change", parseRelationshipUpdateLine, fn)
+}
+
+var updateOperations = map[string]v1.RelationshipUpdate_Operation{
+	"+":      v1.RelationshipUpdate_OPERATION_CREATE,
+	"create": v1.RelationshipUpdate_OPERATION_CREATE,
+	"~":      v1.RelationshipUpdate_OPERATION_TOUCH,
+	"touch":  v1.RelationshipUpdate_OPERATION_TOUCH,
+	"-":      v1.RelationshipUpdate_OPERATION_DELETE,
+	"delete": v1.RelationshipUpdate_OPERATION_DELETE,
+}
+
+// parseRelationshipUpdateLine parses a line of an operation followed by a
+// relationship in any of the forms accepted by relationship diff.
+func parseRelationshipUpdateLine(line string) (*v1.RelationshipUpdate, error) {
+	idx := strings.IndexFunc(line, unicode.IsSpace)
+	if idx == -1 || strings.TrimSpace(line[idx:]) == "" {
+		return nil, fmt.Errorf("expected an operation followed by a relationship, but got %q", line)
+	}
+	operationName, rest := line[:idx], line[idx+1:]
+
+	operation, ok := updateOperations[strings.ToLower(operationName)]
+	if !ok {
+		return nil, fmt.Errorf("unknown operation %q: should be one of +, ~, -, create, touch, delete", operationName)
+	}
+
+	rel, err := parseDesiredRelationship(strings.TrimSpace(rest))
+	if err != nil {
+		return nil, err
+	}
+	return &v1.RelationshipUpdate{Operation: operation, Relationship: rel}, nil
+}
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/zed/internal/client"
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+func TestParseRelationshipUpdateLine(t *testing.T) {
+	for _, tt := range []struct {
+		line              string
+		expectedOperation v1.RelationshipUpdate_Operation
+		expectedRel       string
+		expectedErr       string
+	}{
+		{line: "+ document:1 reader user:1", expectedOperation: v1.RelationshipUpdate_OPERATION_CREATE, expectedRel: "document:1#reader@user:1"},
+		{line: "~ document:1#reader@user:1", expectedOperation: v1.RelationshipUpdate_OPERATION_TOUCH, expectedRel: "document:1#reader@user:1"},
+		{line: "- document:1#reader@user:1", expectedOperation: v1.RelationshipUpdate_OPERATION_DELETE, expectedRel: "document:1#reader@user:1"},
+		{line: "TOUCH\tdocument:1 reader user:1[cav]", expectedOperation: v1.RelationshipUpdate_OPERATION_TOUCH, expectedRel: "document:1#reader@user:1[cav]"},
+		{line: `delete {"resource":{"objectType":"document","objectId":"1"},"relation":"reader","subject":{"object":{"objectType":"user","objectId":"1"}}}`, expectedOperation: v1.RelationshipUpdate_OPERATION_DELETE, expectedRel: "document:1#reader@user:1"},
+		{line: "document:1#reader@user:1", expectedErr: "expected an operation followed by a relationship"},
+		{line: "+", expectedErr: "expected an operation followed by a relationship"},
+		{line: "* document:1#reader@user:1", expectedErr: `unknown operation "*"`},
+	} {
+		t.Run(tt.line, func(t *testing.T) {
+			update, err := parseRelationshipUpdateLine(tt.line)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedOperation, update.Operation)
+			require.Equal(t, tt.expectedRel, tuple.MustV1StringRelationship(update.Relationship))
+		})
+	}
+}
+
+func TestApplyRelationshipsCmdFunc(t *testing.T) {
+	changeset := filepath.Join(t.TempDir(), "changes.txt")
+	require.NoError(t, os.WriteFile(changeset, []byte("+ resource:1 viewer user:1\n\n- resource:1#viewer@user:2\n~ resource:1#viewer@user:3\n"), 0o600))
+
+	update := func(operation v1.RelationshipUpdate_Operation, rel string) *v1.RelationshipUpdate {
+		return &v1.RelationshipUpdate{Operation: operation, Relationship: tuple.MustParseV1Rel(rel)}
+	}
+	mock := &mockClient{t: t, expectedWrites: []*v1.WriteRelationshipsRequest{
+		{Updates: []*v1.RelationshipUpdate{
+			update(v1.RelationshipUpdate_OPERATION_CREATE, "resource:1#viewer@user:1"),
+			update(v1.RelationshipUpdate_OPERATION_DELETE, "resource:1#viewer@user:2"),
+		}},
+		{Updates: []*v1.RelationshipUpdate{
+			update(v1.RelationshipUpdate_OPERATION_TOUCH, "resource:1#viewer@user:3"),
+		}},
+	}}
+
+	originalClient := client.NewClient
+	client.NewClient = func(*cobra.Command) (client.Client, error) { return mock, nil }
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	tokenFile := filepath.Join(t.TempDir(), "zedtoken")
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file", FlagValue: tokenFile},
+		zedtesting.IntFlag{FlagName: "batch-size", FlagValue: 2},
+		zedtesting.BoolFlag{FlagName: "json"})
+	require.NoError(t, applyRelationshipsCmdFunc(cmd, []string{changeset}))
+	require.Empty(t, mock.expectedWrites)
+
+	contents, err := os.ReadFile(tokenFile)
+	require.NoError(t, err)
+	require.Equal(t, "test\n", string(contents))
+
+	cmd = zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
+		zedtesting.IntFlag{FlagName: "batch-size", FlagValue: 0},
+		zedtesting.BoolFlag{FlagName: "json"})
+	require.ErrorContains(t, applyRelationshipsCmdFunc(cmd, []string{changeset}), "batch size must be at least 1")
+}
+
+func TestTxRelationshipsCmdFunc(t *testing.T) {
+	changeset := filepath.Join(t.TempDir(), "changes.txt")
+	require.NoError(t, os.WriteFile(changeset, []byte("+ resource:1 viewer user:1\n\n- resource:1#viewer@user:2\n~ resource:1#viewer@user:3\n"), 0o600))
+
+	update := func(operation v1.RelationshipUpdate_Operation, rel string) *v1.RelationshipUpdate {
+		return &v1.RelationshipUpdate{Operation: operation, Relationship: tuple.MustParseV1Rel(rel)}
+	}
+	mock := &mockClient{t: t, expectedWrites: []*v1.WriteRelationshipsRequest{
+		{Updates: []*v1.RelationshipUpdate{
+			update(v1.RelationshipUpdate_OPERATION_CREATE, "resource:1#viewer@user:1"),
+			update(v1.RelationshipUpdate_OPERATION_DELETE, "resource:1#viewer@user:2"),
+			update(v1.RelationshipUpdate_OPERATION_TOUCH, "resource:1#viewer@user:3"),
+		}},
+	}}
+
+	originalClient := client.NewClient
+	client.NewClient = func(*cobra.Command) (client.Client, error) { return mock, nil }
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	// A changeset larger than the server accepts is refused, not split.
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
+		zedtesting.IntFlag{FlagName: "max-updates", FlagValue: 2},
+		zedtesting.BoolFlag{FlagName: "json"})
+	require.ErrorContains(t, txRelationshipsCmdFunc(cmd, []string{changeset}), "more than 2 updates")
+	require.Len(t, mock.expectedWrites, 1)
+
+	cmd = zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
+		zedtesting.IntFlag{FlagName: "max-updates", FlagValue: 3},
+		zedtesting.BoolFlag{FlagName: "json"})
+	require.NoError(t, txRelationshipsCmdFunc(cmd, []string{changeset}))
+	require.Empty(t, mock.expectedWrites)
+}
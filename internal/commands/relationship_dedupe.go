@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func registerDedupeFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("dedupe", false, "skip relationships already written by this command, comparing resource, relation, subject and caveat")
+	cmd.Flags().Bool("dedupe-disk", false, "like --dedupe, but with bounded memory: all relationships are read and sorted in temporary files first, then written in sorted order")
+	cmd.MarkFlagsMutuallyExclusive("dedupe", "dedupe-disk")
+}
+
+// relationshipKey is the key of a relationship when skipping duplicates: two
+// relationships are duplicates when their resource, relation, subject and
+// caveat are the same.
+func relationshipKey(rel *v1.Relationship) (string, error) {
+	key, err := tuple.V1StringRelationship(rel)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute key of relationship: %w", err)
+	}
+	return key, nil
+}
+
+// relationshipDeduplicator remembers the keys of the relationships it has
+// seen in memory, up to a maximum number so its memory is bounded.
+type relationshipDeduplicator struct {
+	maxSize uint
+	keys    map[string]struct{}
+	full    bool
+}
+
+// newRelationshipDeduplicator returns a deduplicator remembering up to maxSize
+// keys, or every key if maxSize is 0.
+func newRelationshipDeduplicator(maxSize uint) *relationshipDeduplicator {
+	return &relationshipDeduplicator{maxSize: maxSize, keys: make(map[string]struct{})}
+}
+
+// seen returns whether the key was seen before, remembering it otherwise. Once
+// maxSize keys are remembered, a warning is logged and new keys are no longer
+// remembered.
+func (d *relationshipDeduplicator) seen(key string) bool {
+	if _, ok := d.keys[key]; ok {
+		return true
+	}
+
+	if d.maxSize > 0 && uint(len(d.keys)) >= d.maxSize {
+		if !d.full {
+			d.full = true
+			log.Warn().Uint("deduplicate-max-size", d.maxSize).Msg("too many relationships to deduplicate, further duplicates may be output; consider raising --deduplicate-max-size")
+		}
+		return false
+	}
+
+	d.keys[key] = struct{}{}
+	return false
+}
+
+// dedupeDiskRunSize is the number of keys relationshipSorter sorts in memory
+// before spilling them to a temporary file.
+const dedupeDiskRunSize = 1 << 20
+
+// relationshipSorter skips duplicate relationships with bounded memory by
+// sorting their keys externally: keys are sorted in memory in runs of up to
+// runSize, each run is spilled to a temporary file, and the runs are then
+// merged, skipping repeated keys.
+type relationshipSorter struct {
+	runSize    int
+	keys       []string
+	runs       []*os.File
+	duplicates int
+}
+
+func newRelationshipSorter(runSize int) *relationshipSorter {
+	return &relationshipSorter{runSize: runSize}
+}
+
+func (s *relationshipSorter) add(key string) error {
+	s.keys = append(s.keys, key)
+	if len(s.keys) >= s.runSize {
+		return s.spill()
+	}
+	return nil
+}
+
+// spill writes the sorted keys held in memory to a new run.
+func (s *relationshipSorter) spill() error {
+	if len(s.keys) == 0 {
+		return nil
+	}
+
+	run, err := os.CreateTemp("", "zed-dedupe-")
+	if err != nil {
+		return fmt.Errorf("failed to create dedupe file: %w", err)
+	}
+	s.runs = append(s.runs, run)
+
+	slices.Sort(s.keys)
+	w := bufio.NewWriter(run)
+	for i, key := range s.keys {
+		if i > 0 && key == s.keys[i-1] {
+			s.duplicates++
+			continue
+		}
+		_, _ = w.WriteString(key)
+		_ = w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write dedupe file: %w", err)
+	}
+
+	s.keys = s.keys[:0]
+	return nil
+}
+
+// sortedParser reads every relationship from parser and returns a parser
+// yielding each distinct one once, in key order.
+func (s *relationshipSorter) sortedParser(parser RelationshipParser) (RelationshipParser, error) {
+	for {
+		rel, err := parser()
+		if errors.Is(err, ErrExhaustedRelationships) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		key, err := relationshipKey(rel)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.add(key); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.spill(); err != nil {
+		return nil, err
+	}
+
+	// Merge the runs by repeatedly taking the smallest of their next keys.
+	// There are few enough runs that a linear scan is cheap next to reading
+	// the keys.
+	readers := make([]*bufio.Reader, len(s.runs))
+	heads := make([]string, len(s.runs))
+	advance := func(i int) error {
+		line, err := readers[i].ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			readers[i] = nil
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read dedupe file: %w", err)
+		}
+		heads[i] = strings.TrimSuffix(line, "\n")
+		return nil
+	}
+	for i, run := range s.runs {
+		if _, err := run.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to read dedupe file: %w", err)
+		}
+		readers[i] = bufio.NewReader(run)
+		if err := advance(i); err != nil {
+			return nil, err
+		}
+	}
+
+	var last string
+	return func() (*v1.Relationship, error) {
+		for {
+			smallest := -1
+			for i, reader := range readers {
+				if reader != nil && (smallest < 0 || heads[i] < heads[smallest]) {
+					smallest = i
+				}
+			}
+			if smallest < 0 {
+				return nil, ErrExhaustedRelationships
+			}
+
+			key := heads[smallest]
+			if err := advance(smallest); err != nil {
+				return nil, err
+			}
+			if key == last {
+				s.duplicates++
+				continue
+			}
+			last = key
+
+			rel, err := tuple.ParseV1Rel(key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read dedupe file: %w", err)
+			}
+			return rel, nil
+		}
+	}, nil
+}
+
+// Close removes the temporary files of the runs.
+func (s *relationshipSorter) Close() error {
+	var errs []error
+	for _, run := range s.runs {
+		if err := run.Close(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, os.Remove(run.Name()))
+	}
+	return errors.Join(errs...)
+}
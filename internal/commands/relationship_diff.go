@@ -0,0 +1,278 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/console"
+)
+
+func registerRelationshipDiffCmd(relationshipCmd *cobra.Command) {
+	relationshipCmd.AddCommand(diffCmd)
+	diffCmd.Flags().Bool("apply", false, "write the changes needed for the permissions system to match the file, after confirmation")
+	diffCmd.Flags().IntP("batch-size", "b", 100, "batch size when applying changes")
+	diffCmd.Flags().Uint32("page-limit", 1000, "limit of relations read per page")
+	diffCmd.Flags().Bool("json", false, "output the write responses as JSON when applying changes")
+	registerConsistencyTokenFileFlag(diffCmd.Flags())
+}
+
+const diffCmdHelpLong = `Compares the relationships in a file against those in the permissions system.
+
+Each line of the file is a relationship, either as text (e.g. "document:1 reader user:2"
+or "document:1#reader@user:2") or as a JSON-encoded Relationship. Only the resource types
+present in the file are compared.
+
+Relationships only in the file are printed with "+", relationships only in the
+permissions system with "-", and relationships whose caveat differs with "~".`
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <file>",
+	Short: "Compares the relationships in a file against the permissions system",
+	Long:  diffCmdHelpLong,
+	Args:  cobra.ExactArgs(1),
+	RunE:  diffRelationshipsCmdFunc,
+}
+
+// relationshipDiff holds the changes required for the live relationships to
+// match the desired ones.
+type relationshipDiff struct {
+	toCreate []*v1.Relationship
+	toUpdate []*v1.Relationship
+	toDelete []*v1.Relationship
+}
+
+func (d relationshipDiff) empty() bool {
+	return len(d.toCreate) == 0 && len(d.toUpdate) == 0 && len(d.toDelete) == 0
+}
+
+func diffRelationshipsCmdFunc(cmd *cobra.Command, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open relationships file: %w", err)
+	}
+	defer f.Close()
+
+	desired, err := parseDesiredRelationships(f)
+	if err != nil {
+		return err
+	}
+
+	spicedbClient, err := client.NewClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	resourceTypes := make(map[string]struct{})
+	for _, rel := range desired {
+		resourceTypes[rel.Resource.ObjectType] = struct{}{}
+	}
+
+	var live []*v1.Relationship
+	pageLimit := cobrautil.MustGetUint32(cmd, "page-limit")
+	for _, resourceType := range slices.Sorted(maps.Keys(resourceTypes)) {
+		err := readAllRelationships(cmd.Context(), spicedbClient, &v1.RelationshipFilter{ResourceType: resourceType}, pageLimit, func(rel *v1.Relationship) error {
+			live = append(live, rel)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	diff := diffRelationships(desired, live)
+	for _, change := range []struct {
+		marker string
+		rels   []*v1.Relationship
+	}{
+		{"+", diff.toCreate},
+		{"~", diff.toUpdate},
+		{"-", diff.toDelete},
+	} {
+		for _, rel := range change.rels {
+			console.Printf("%s %s\n", change.marker, tuple.MustV1StringRelationship(rel))
+		}
+	}
+
+	if diff.empty() {
+		console.Errorf("no differences found\n")
+		return nil
+	}
+
+	console.Errorf("%d to create, %d to update, %d to delete\n", len(diff.toCreate), len(diff.toUpdate), len(diff.toDelete))
+	if !cobrautil.MustGetBool(cmd, "apply") {
+		return nil
+	}
+
+	batchSize := cobrautil.MustGetInt(cmd, "batch-size")
+	if batchSize < 1 {
+		return errors.New("batch size must be at least 1")
+	}
+
+	if err := ConfirmDestructive(cmd, "apply these changes"); err != nil {
+		return err
+	}
+
+	writtenAt, err := applyRelationshipDiff(cmd.Context(), spicedbClient, diff, batchSize, cobrautil.MustGetBool(cmd, "json"))
+	if err != nil {
+		return err
+	}
+	return recordConsistencyToken(cmd, writtenAt)
+}
+
+// parseDesiredRelationships reads one relationship per non-empty line, either
+// as text or as a JSON-encoded Relationship.
+func parseDesiredRelationships(r io.Reader) ([]*v1.Relationship, error) {
+	var rels []*v1.Relationship
+	err := forEachRelationshipBatch(r, desiredRelationshipsBatchSize, func(batch []*v1.Relationship) error {
+		rels = append(rels, batch...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rels, nil
+}
+
+// desiredRelationshipsBatchSize is the size of the batches in which the
+// desired relationships are parsed before being collected.
+const desiredRelationshipsBatchSize = 1000
+
+func parseDesiredRelationship(line string) (*v1.Relationship, error) {
+	if strings.HasPrefix(line, "{") {
+		rel := &v1.Relationship{}
+		if err := protojson.Unmarshal([]byte(line), rel); err != nil {
+			return nil, err
+		}
+		return rel, nil
+	}
+
+	if strings.IndexFunc(line, unicode.IsSpace) >= 0 {
+		res, rel, subj, err := parseRelationshipLine(line)
+		if err != nil {
+			return nil, err
+		}
+		return tupleToRel(res, rel, subj)
+	}
+
+	return tuple.ParseV1Rel(line)
+}
+
+// readAllRelationships pages through every relationship matching the filter,
+// stopping at the first error returned by each.
+func readAllRelationships(ctx context.Context, spicedbClient client.Client, filter *v1.RelationshipFilter, pageLimit uint32, each func(*v1.Relationship) error) error {
+	request := &v1.ReadRelationshipsRequest{
+		RelationshipFilter: filter,
+		Consistency:        &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		OptionalLimit:      pageLimit,
+	}
+
+	for {
+		log.Trace().Interface("request", request).Msg("reading relationships page")
+		stream, err := spicedbClient.ReadRelationships(ctx, request)
+		if err != nil {
+			return err
+		}
+
+		var count uint32
+		for {
+			msg, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return err
+			}
+
+			count++
+			request.OptionalCursor = msg.AfterResultCursor
+			if err := each(msg.Relationship); err != nil {
+				return err
+			}
+		}
+
+		if pageLimit == 0 || count < pageLimit {
+			return nil
+		}
+	}
+}
+
+// diffRelationships returns the changes required for live to match desired.
+// Relationships are matched ignoring their caveat; a matching relationship
+// whose caveat differs is reported as an update.
+func diffRelationships(desired, live []*v1.Relationship) relationshipDiff {
+	liveByKey := make(map[string]*v1.Relationship, len(live))
+	for _, rel := range live {
+		liveByKey[tuple.V1StringRelationshipWithoutCaveatOrExpiration(rel)] = rel
+	}
+
+	var diff relationshipDiff
+	desiredKeys := make(map[string]struct{}, len(desired))
+	for _, rel := range desired {
+		key := tuple.V1StringRelationshipWithoutCaveatOrExpiration(rel)
+		if _, ok := desiredKeys[key]; ok {
+			continue
+		}
+		desiredKeys[key] = struct{}{}
+
+		existing, ok := liveByKey[key]
+		switch {
+		case !ok:
+			diff.toCreate = append(diff.toCreate, rel)
+		case tuple.MustV1StringRelationship(existing) != tuple.MustV1StringRelationship(rel):
+			diff.toUpdate = append(diff.toUpdate, rel)
+		}
+	}
+
+	for _, rel := range live {
+		if _, ok := desiredKeys[tuple.V1StringRelationshipWithoutCaveatOrExpiration(rel)]; !ok {
+			diff.toDelete = append(diff.toDelete, rel)
+		}
+	}
+
+	return diff
+}
+
+func applyRelationshipDiff(ctx context.Context, spicedbClient client.Client, diff relationshipDiff, batchSize int, json bool) (*v1.ZedToken, error) {
+	updates := make([]*v1.RelationshipUpdate, 0, len(diff.toCreate)+len(diff.toUpdate)+len(diff.toDelete))
+	for _, rel := range slices.Concat(diff.toCreate, diff.toUpdate) {
+		updates = append(updates, &v1.RelationshipUpdate{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: rel})
+	}
+	for _, rel := range diff.toDelete {
+		updates = append(updates, &v1.RelationshipUpdate{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: rel})
+	}
+
+	return writeUpdatesInBatches(ctx, spicedbClient, updates, batchSize, json)
+}
+
+// writeUpdatesInBatches writes the updates in requests of at most batchSize
+// updates each, and returns the revision of the final one.
+func writeUpdatesInBatches(ctx context.Context, spicedbClient client.Client, updates []*v1.RelationshipUpdate, batchSize int, json bool) (*v1.ZedToken, error) {
+	if batchSize < 1 {
+		return nil, errors.New("batch size must be at least 1")
+	}
+
+	var writtenAt *v1.ZedToken
+	for start := 0; start < len(updates); start += batchSize {
+		end := min(start+batchSize, len(updates))
+		batchWrittenAt, err := writeUpdates(ctx, spicedbClient, updates[start:end], json)
+		if err != nil {
+			return nil, err
+		}
+		writtenAt = batchWrittenAt
+	}
+	return writtenAt, nil
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/console"
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+func TestParseDesiredRelationships(t *testing.T) {
+	input := `test/resource:1 reader test/user:1
+
+test/resource:1#writer@test/user:2[some_caveat:{"a":1}]
+{"resource":{"objectType":"test/resource","objectId":"2"},"relation":"reader","subject":{"object":{"objectType":"test/user","objectId":"3"}}}
+`
+
+	rels, err := parseDesiredRelationships(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"test/resource:1#reader@test/user:1",
+		`test/resource:1#writer@test/user:2[some_caveat:{"a":1}]`,
+		"test/resource:2#reader@test/user:3",
+	}, relationshipStrings(rels))
+
+	_, err = parseDesiredRelationships(strings.NewReader("test/resource:1 reader\n"))
+	require.ErrorContains(t, err, "failed to parse relationship on line 1")
+}
+
+func TestDiffRelationships(t *testing.T) {
+	parse := func(rels ...string) []*v1.Relationship {
+		parsed := make([]*v1.Relationship, 0, len(rels))
+		for _, rel := range rels {
+			parsed = append(parsed, tuple.MustParseV1Rel(rel))
+		}
+		return parsed
+	}
+
+	diff := diffRelationships(
+		parse(
+			"test/resource:1#reader@test/user:1",
+			"test/resource:1#reader@test/user:1",
+			"test/resource:1#reader@test/user:2",
+			`test/resource:1#writer@test/user:3[some_caveat:{"a":2}]`,
+		),
+		parse(
+			"test/resource:1#reader@test/user:1",
+			`test/resource:1#writer@test/user:3[some_caveat:{"a":1}]`,
+			"test/resource:2#reader@test/user:4",
+		),
+	)
+
+	require.Equal(t, []string{"test/resource:1#reader@test/user:2"}, relationshipStrings(diff.toCreate))
+	require.Equal(t, []string{`test/resource:1#writer@test/user:3[some_caveat:{"a":2}]`}, relationshipStrings(diff.toUpdate))
+	require.Equal(t, []string{"test/resource:2#reader@test/user:4"}, relationshipStrings(diff.toDelete))
+	require.True(t, diffRelationships(parse("test/resource:1#reader@test/user:1"), parse("test/resource:1#reader@test/user:1")).empty())
+}
+
+func TestDiffRelationshipsCmdFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+	client.NewClient = zedtesting.ClientFromConn(conn)
+
+	c, err := client.NewClient(nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: tuple.MustParseV1Rel("test/resource:1#reader@test/user:1")},
+			{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: tuple.MustParseV1Rel("test/resource:1#writer@test/user:2")},
+		},
+	})
+	require.NoError(t, err)
+
+	previousPrintf := console.Printf
+	previousErrorf := console.Errorf
+	previousConfirm, previousInteractive := ConfirmPrompt, ConfirmInteractive
+	defer func() {
+		console.Printf = previousPrintf
+		console.Errorf = previousErrorf
+		ConfirmPrompt, ConfirmInteractive = previousConfirm, previousInteractive
+	}()
+	var lines []string
+	console.Printf = func(format string, a ...any) {
+		lines = append(lines, strings.TrimSpace(fmt.Sprintf(format, a...)))
+	}
+	console.Errorf = func(string, ...any) {}
+
+	f := fileFromStrings(t, []string{
+		"test/resource:1 reader test/user:1",
+		"test/resource:2 reader test/user:3",
+	})
+
+	// Without --apply, only the differences are reported.
+	cmd := testDiffCommand(t, false)
+	require.NoError(t, diffRelationshipsCmdFunc(cmd, []string{f.Name()}))
+	require.Equal(t, []string{
+		"+ test/resource:2#reader@test/user:3",
+		"- test/resource:1#writer@test/user:2",
+	}, lines)
+	assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource"}, 2)
+
+	// Without a terminal, applying requires --yes.
+	ConfirmInteractive = func() bool { return false }
+	cmd = testDiffCommand(t, true)
+	require.ErrorContains(t, diffRelationshipsCmdFunc(cmd, []string{f.Name()}), "set --yes")
+	assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource", OptionalRelation: "writer"}, 1)
+
+	// Declining the confirmation leaves the system untouched.
+	ConfirmInteractive = func() bool { return true }
+	ConfirmPrompt = func(string) (bool, error) { return false, nil }
+	require.ErrorContains(t, diffRelationshipsCmdFunc(cmd, []string{f.Name()}), "aborted")
+	assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource", OptionalRelation: "writer"}, 1)
+
+	// Confirming converges the system to the file.
+	ConfirmPrompt = func(string) (bool, error) { return true, nil }
+	require.NoError(t, diffRelationshipsCmdFunc(cmd, []string{f.Name()}))
+	assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource", OptionalRelation: "writer"}, 0)
+	assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource", OptionalResourceId: "2"}, 1)
+
+	lines = nil
+	require.NoError(t, diffRelationshipsCmdFunc(testDiffCommand(t, false), []string{f.Name()}))
+	require.Empty(t, lines)
+}
+
+func relationshipStrings(rels []*v1.Relationship) []string {
+	relStrings := make([]string, 0, len(rels))
+	for _, rel := range rels {
+		relStrings = append(relStrings, tuple.MustV1StringRelationship(rel))
+	}
+	return relStrings
+}
+
+func testDiffCommand(t *testing.T, apply bool) *cobra.Command {
+	return zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
+		zedtesting.BoolFlag{FlagName: "apply", FlagValue: apply},
+		zedtesting.BoolFlag{FlagName: "yes"},
+		zedtesting.IntFlag{FlagName: "batch-size", FlagValue: 1},
+		zedtesting.UintFlag32{FlagName: "page-limit", FlagValue: 1},
+		zedtesting.BoolFlag{FlagName: "json"})
+}
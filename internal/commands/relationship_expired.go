@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/console"
+)
+
+func registerRelationshipListExpiredCmd(relationshipCmd *cobra.Command) {
+	relationshipCmd.AddCommand(listExpiredCmd)
+	listExpiredCmd.Flags().String("subject-filter", "", "optional subject filter")
+	listExpiredCmd.Flags().Uint32("page-limit", 1000, "limit of relations read per page")
+	listExpiredCmd.Flags().Bool("delete", false, "delete the expired relationships that were found")
+	listExpiredCmd.Flags().IntP("batch-size", "b", 100, "batch size when deleting expired relationships")
+	listExpiredCmd.Flags().Bool("json", false, "output the delete responses as JSON")
+	registerConsistencyTokenFileFlag(listExpiredCmd.Flags())
+}
+
+const listExpiredCmdHelpLong = `Lists the relationships matching the provided pattern whose expiration has passed.
+
+Relationships are read and their expiration compared against the local clock, so the
+output is the same whether or not the permissions system removes expired relationships.
+
+Depending on its configuration, SpiceDB may already exclude expired relationships from
+reads and garbage collect them, in which case nothing is found and nothing needs deleting.
+
+With --delete, the expired relationships that were found are deleted in batches.`
+
+var listExpiredCmd = &cobra.Command{
+	Use:               "list-expired <resource_type:optional_resource_id> <optional_relation> <optional_subject_type:optional_subject_id#optional_subject_relation>",
+	Short:             "Lists, and optionally deletes, relationships whose expiration has passed",
+	Long:              listExpiredCmdHelpLong,
+	Args:              cobra.RangeArgs(1, 3),
+	ValidArgsFunction: GetArgs(ResourceID, Permission, SubjectTypeWithOptionalRelation),
+	RunE:              listExpiredRelationshipsCmdFunc,
+}
+
+func listExpiredRelationshipsCmdFunc(cmd *cobra.Command, args []string) error {
+	filter, subjectIDPrefix, err := buildRelationshipsFilter(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	spicedbClient, err := client.NewClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var expired []*v1.Relationship
+	err = readAllRelationships(cmd.Context(), spicedbClient, filter, cobrautil.MustGetUint32(cmd, "page-limit"), func(rel *v1.Relationship) error {
+		if hasSubjectIDPrefix(rel, subjectIDPrefix) && relationshipExpired(rel, now) {
+			expired = append(expired, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range expired {
+		console.Println(tuple.MustV1StringRelationship(rel))
+	}
+
+	if len(expired) == 0 {
+		console.Errorf("no expired relationships found; SpiceDB may already exclude them from reads\n")
+		return nil
+	}
+
+	console.Errorf("%d expired relationships found\n", len(expired))
+	if !cobrautil.MustGetBool(cmd, "delete") {
+		return nil
+	}
+
+	updates := make([]*v1.RelationshipUpdate, 0, len(expired))
+	for _, rel := range expired {
+		updates = append(updates, &v1.RelationshipUpdate{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: rel})
+	}
+	deletedAt, err := writeUpdatesInBatches(cmd.Context(), spicedbClient, updates, cobrautil.MustGetInt(cmd, "batch-size"), cobrautil.MustGetBool(cmd, "json"))
+	if err != nil {
+		return err
+	}
+	return recordConsistencyToken(cmd, deletedAt)
+}
+
+// relationshipExpired returns whether the relationship has an expiration at
+// or before now.
+func relationshipExpired(rel *v1.Relationship, now time.Time) bool {
+	expiresAt := rel.GetOptionalExpiresAt()
+	return expiresAt != nil && !expiresAt.AsTime().After(now)
+}
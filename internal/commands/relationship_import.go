@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/zed/internal/client"
+)
+
+func registerRelationshipImportCmd(relationshipCmd *cobra.Command) {
+	relationshipCmd.AddCommand(importRelationshipsCmd)
+	importRelationshipsCmd.Flags().IntP("batch-size", "b", 1000, "batch size when writing the relationships")
+	importRelationshipsCmd.Flags().String("schema-file", "", "path to a schema to write before the relationships")
+	importRelationshipsCmd.Flags().Bool("json", false, "output as JSON")
+	registerConsistencyTokenFileFlag(importRelationshipsCmd.Flags())
+}
+
+const importRelationshipsCmdHelpLong = `Touches the relationships in a file or stdin, one per line.
+
+Each line is a relationship, either as text (e.g. "document:1 reader user:2" or
+"document:1#reader@user:2") or as a JSON-encoded Relationship, such as the output
+of "zed backup parse-relationships". Relationships are written in batches of
+--batch-size, each of which is applied atomically.`
+
+var importRelationshipsCmd = &cobra.Command{
+	Use:   "import <file?>",
+	Short: "Writes the relationships in a file of one relationship per line",
+	Long:  importRelationshipsCmdHelpLong,
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  importRelationshipsCmdFunc,
+}
+
+func importRelationshipsCmdFunc(cmd *cobra.Command, args []string) error {
+	var input io.Reader = os.Stdin
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open relationships file: %w", err)
+		}
+		defer f.Close()
+		input = f
+	} else if !isArgsViaFile(os.Stdin) {
+		return errors.New("must provide a relationships file path or contents via stdin")
+	}
+
+	batchSize := cobrautil.MustGetInt(cmd, "batch-size")
+	if batchSize < 1 {
+		return errors.New("batch size must be at least 1")
+	}
+	doJSON := cobrautil.MustGetBool(cmd, "json")
+
+	spicedbClient, err := client.NewClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	if schemaFile := cobrautil.MustGetString(cmd, "schema-file"); schemaFile != "" {
+		schemaBytes, err := os.ReadFile(schemaFile)
+		if err != nil {
+			return fmt.Errorf("failed to read schema file: %w", err)
+		}
+
+		request := &v1.WriteSchemaRequest{Schema: string(schemaBytes)}
+		log.Trace().Interface("request", request).Msg("writing schema")
+		if _, err := spicedbClient.WriteSchema(cmd.Context(), request); err != nil {
+			return fmt.Errorf("failed to write schema: %w", err)
+		}
+	}
+
+	var writtenAt *v1.ZedToken
+	err = forEachRelationshipBatch(input, batchSize, func(rels []*v1.Relationship) error {
+		batch := make([]*v1.RelationshipUpdate, 0, len(rels))
+		for _, rel := range rels {
+			batch = append(batch, &v1.RelationshipUpdate{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: rel})
+		}
+		writtenAt, err = writeUpdates(cmd.Context(), spicedbClient, batch, doJSON)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return recordConsistencyToken(cmd, writtenAt)
+}
+
+// forEachRelationshipBatch parses each non-empty line of r as a relationship,
+// either as text or as a JSON-encoded Relationship, and calls fn with them in
+// batches of batchSize, followed by a final batch of the remainder, if any.
+func forEachRelationshipBatch(r io.Reader, batchSize int, fn func([]*v1.Relationship) error) error {
+	return forEachLineBatch(r, batchSize, "relationship", parseDesiredRelationship, fn)
+}
+
+// forEachLineBatch parses each non-empty line of r, described as what in
+// errors, and calls fn with the parsed values in batches of batchSize,
+// followed by a final batch of the remainder, if any.
+func forEachLineBatch[T any](r io.Reader, batchSize int, what string, parse func(string) (T, error), fn func([]T) error) error {
+	if batchSize < 1 {
+		return errors.New("batch size must be at least 1")
+	}
+
+	var batch []T
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		parsed, err := parse(line)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s on line %d: %w", what, lineNumber, err)
+		}
+
+		batch = append(batch, parsed)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return fn(batch)
+}
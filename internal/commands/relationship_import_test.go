@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/authzed/zed/internal/client"
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+type schemaWritingClient struct {
+	*mockClient
+	writtenSchemas []string
+}
+
+func (c *schemaWritingClient) WriteSchema(_ context.Context, in *v1.WriteSchemaRequest, _ ...grpc.CallOption) (*v1.WriteSchemaResponse, error) {
+	c.writtenSchemas = append(c.writtenSchemas, in.Schema)
+	return &v1.WriteSchemaResponse{}, nil
+}
+
+func TestImportRelationshipsCmdFunc(t *testing.T) {
+	dir := t.TempDir()
+	relsFile := filepath.Join(dir, "relationships.txt")
+	require.NoError(t, os.WriteFile(relsFile, []byte("resource:1 viewer user:1\n\nresource:1#viewer@user:2\nresource:2 viewer user:3[cav:{\"a\":1}]\n"), 0o600))
+	schemaFile := filepath.Join(dir, "schema.zed")
+	require.NoError(t, os.WriteFile(schemaFile, []byte(testSchema), 0o600))
+
+	touch := func(rel string) *v1.RelationshipUpdate {
+		return &v1.RelationshipUpdate{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: tuple.MustParseV1Rel(rel)}
+	}
+	mock := &schemaWritingClient{mockClient: &mockClient{t: t, expectedWrites: []*v1.WriteRelationshipsRequest{
+		{Updates: []*v1.RelationshipUpdate{
+			touch("resource:1#viewer@user:1"),
+			touch("resource:1#viewer@user:2"),
+		}},
+		{Updates: []*v1.RelationshipUpdate{
+			touch(`resource:2#viewer@user:3[cav:{"a":1}]`),
+		}},
+	}}}
+
+	originalClient := client.NewClient
+	client.NewClient = func(*cobra.Command) (client.Client, error) { return mock, nil }
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
+		zedtesting.IntFlag{FlagName: "batch-size", FlagValue: 2},
+		zedtesting.StringFlag{FlagName: "schema-file", FlagValue: schemaFile},
+		zedtesting.BoolFlag{FlagName: "json"})
+	require.NoError(t, importRelationshipsCmdFunc(cmd, []string{relsFile}))
+	require.Empty(t, mock.expectedWrites)
+	require.Equal(t, []string{testSchema}, mock.writtenSchemas)
+}
+
+func TestForEachRelationshipBatch(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		input     string
+		batchSize int
+		expected  [][]string
+		err       string
+	}{
+		{"empty", "", 2, nil, ""},
+		{"partial final batch", "res:1 rel sub:1\n\nres:1#rel@sub:2\nres:1 rel sub:3\n", 2, [][]string{{"res:1#rel@sub:1", "res:1#rel@sub:2"}, {"res:1#rel@sub:3"}}, ""},
+		{"full final batch", "res:1 rel sub:1\nres:1 rel sub:2\n", 2, [][]string{{"res:1#rel@sub:1", "res:1#rel@sub:2"}}, ""},
+		{"invalid line", "res:1 rel sub:1\nnot a relationship\n", 2, nil, "failed to parse relationship on line 2"},
+		{"zero batch size", "res:1 rel sub:1\n", 0, nil, "batch size must be at least 1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var batches [][]string
+			err := forEachRelationshipBatch(strings.NewReader(tt.input), tt.batchSize, func(rels []*v1.Relationship) error {
+				batch := make([]string, 0, len(rels))
+				for _, rel := range rels {
+					batch = append(batch, tuple.MustV1StringRelationship(rel))
+				}
+				batches = append(batches, batch)
+				return nil
+			})
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, batches)
+		})
+	}
+}
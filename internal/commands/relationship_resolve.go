@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/console"
+)
+
+// subjectResolver prints the subjects that a subject with a relation, such as
+// group:eng#member, resolves to, by reading the relationships of that relation
+// and of the subject relations found in turn. Only relations are resolved:
+// subjects of permissions are not read.
+type subjectResolver struct {
+	client      client.Client
+	consistency *v1.Consistency
+	maxDepth    uint
+	maxFanOut   uint32
+}
+
+// subjectResolverFromCmd returns the resolver for the --resolve flags of
+// read, or nil if subjects are not to be resolved.
+func subjectResolverFromCmd(cmd *cobra.Command, spicedbClient client.Client, consistency *v1.Consistency) (*subjectResolver, error) {
+	maxDepth := cobrautil.MustGetUint(cmd, "resolve")
+	if maxDepth == 0 {
+		return nil, nil
+	}
+
+	for _, flag := range []string{"json", "json-relationship", "show-caveat-context-only"} {
+		if cobrautil.MustGetBool(cmd, flag) {
+			return nil, fmt.Errorf("--resolve cannot be used with --%s", flag)
+		}
+	}
+	if cobrautil.MustGetString(cmd, "output-template") != "" {
+		return nil, errors.New("--resolve cannot be used with --output-template")
+	}
+
+	maxFanOut := cobrautil.MustGetUint32(cmd, "resolve-limit")
+	if maxFanOut == 0 {
+		return nil, errors.New("--resolve-limit must be at least 1")
+	}
+
+	return &subjectResolver{
+		client:      spicedbClient,
+		consistency: consistency,
+		maxDepth:    maxDepth,
+		maxFanOut:   maxFanOut,
+	}, nil
+}
+
+// printResolved prints the subjects the subject resolves to, indented beneath
+// the relationship it was the subject of.
+func (r *subjectResolver) printResolved(ctx context.Context, subject *v1.SubjectReference) error {
+	if subject.OptionalRelation == "" {
+		return nil
+	}
+	return r.resolve(ctx, subject, 1, map[string]struct{}{tuple.V1StringSubjectRef(subject): {}})
+}
+
+// resolve prints the subjects of the subject's relation at the given depth,
+// with path holding the subjects being resolved, to detect cycles.
+func (r *subjectResolver) resolve(ctx context.Context, subject *v1.SubjectReference, depth uint, path map[string]struct{}) error {
+	subjects, truncated, err := r.readSubjects(ctx, subject)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", tuple.V1StringSubjectRef(subject), err)
+	}
+
+	indent := strings.Repeat("  ", int(depth))
+	for _, resolved := range subjects {
+		resolvedString := tuple.V1StringSubjectRef(resolved)
+		if _, ok := path[resolvedString]; ok {
+			console.Printf("%s%s (cycle)\n", indent, resolvedString)
+			continue
+		}
+		console.Println(indent + resolvedString)
+
+		if resolved.OptionalRelation == "" || depth >= r.maxDepth {
+			continue
+		}
+
+		path[resolvedString] = struct{}{}
+		err := r.resolve(ctx, resolved, depth+1, path)
+		delete(path, resolvedString)
+		if err != nil {
+			return err
+		}
+	}
+
+	if truncated {
+		console.Printf("%s... more subjects not shown (--resolve-limit %d)\n", indent, r.maxFanOut)
+	}
+	return nil
+}
+
+// readSubjects reads up to maxFanOut subjects of the subject's relation,
+// returning whether there were more.
+func (r *subjectResolver) readSubjects(ctx context.Context, subject *v1.SubjectReference) ([]*v1.SubjectReference, bool, error) {
+	request := &v1.ReadRelationshipsRequest{
+		Consistency: r.consistency,
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:       subject.Object.ObjectType,
+			OptionalResourceId: subject.Object.ObjectId,
+			OptionalRelation:   subject.OptionalRelation,
+		},
+		OptionalLimit: r.maxFanOut + 1,
+	}
+	log.Trace().Interface("request", request).Msg("resolving subject")
+
+	stream, err := r.client.ReadRelationships(ctx, request)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var subjects []*v1.SubjectReference
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		subjects = append(subjects, msg.Relationship.Subject)
+	}
+
+	if uint32(len(subjects)) > r.maxFanOut {
+		return subjects[:r.maxFanOut], true, nil
+	}
+	return subjects, false, nil
+}
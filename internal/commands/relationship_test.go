@@ -6,20 +6,29 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/console"
 	zedtesting "github.com/authzed/zed/internal/testing"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/rs/zerolog"
+	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const testSchema = `definition test/resource {
@@ -75,6 +84,44 @@ func TestRelationshipToString(t *testing.T) {
 	}
 }
 
+func TestRelationshipCaveatContextString(t *testing.T) {
+	for _, tt := range []struct {
+		rawRel   string
+		expected string
+	}{
+		{
+			"res:123#rel@resource:1234[caveat_name]",
+			"res:123 rel resource:1234 caveat_name {}",
+		},
+		{
+			`res:123#rel@resource:1234#anotherrel[caveat_name:{"num":1234,"ip":"10.0.0.1"}]`,
+			`res:123 rel resource:1234#anotherrel caveat_name {"ip":"10.0.0.1","num":1234}`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.rawRel, func(t *testing.T) {
+			rel := tuple.MustParseV1Rel(tt.rawRel)
+			out, err := relationshipCaveatContextString(rel)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, out)
+		})
+	}
+}
+
+func TestRelationshipToJSON(t *testing.T) {
+	rel := tuple.MustParseV1Rel(`res:123#rel@resource:1234#anotherrel[caveat_name:{"num":1234}][expiration:2030-01-01T00:00:00Z]`)
+	out, err := relationshipToJSON(rel)
+	require.NoError(t, err)
+	require.NotContains(t, out, "\n")
+	require.NotContains(t, out, " ")
+
+	roundTripped := &v1.Relationship{}
+	require.NoError(t, protojson.Unmarshal([]byte(out), roundTripped))
+	require.Equal(t, tuple.MustV1StringRelationship(rel), tuple.MustV1StringRelationship(roundTripped))
+	require.Contains(t, out, `"caveatName":"caveat_name"`)
+	require.Contains(t, out, `"optionalExpiresAt":"2030-01-01T00:00:00Z"`)
+}
+
 func TestArgsToRelationship(t *testing.T) {
 	for _, tt := range []struct {
 		args     []string
@@ -276,6 +323,12 @@ func TestWriteRelationshipCmdFuncFromTTY(t *testing.T) {
 	cmd.Flags().Int("batch-size", 100, "")
 	cmd.Flags().Bool("json", true, "")
 	cmd.Flags().String("caveat", `cav:{"letters": ["a", "b", "c"]}`, "")
+	cmd.Flags().String("from-lookup", "", "")
+	cmd.Flags().Bool("summary", false, "")
+	cmd.Flags().Bool("dedupe", false, "")
+	cmd.Flags().Bool("dedupe-disk", false, "")
+	cmd.Flags().Bool("continue-on-error", false, "")
+	cmd.Flags().String("consistency-token-file", "", "")
 
 	err = f(cmd, []string{"resource:1", "view", "user:1"})
 	require.NoError(t, err)
@@ -322,6 +375,12 @@ func TestWriteRelationshipCmdFuncArgsTakePrecedence(t *testing.T) {
 	cmd.Flags().Int("batch-size", 100, "")
 	cmd.Flags().Bool("json", true, "")
 	cmd.Flags().String("caveat", "", "")
+	cmd.Flags().String("from-lookup", "", "")
+	cmd.Flags().Bool("summary", false, "")
+	cmd.Flags().Bool("dedupe", false, "")
+	cmd.Flags().Bool("dedupe-disk", false, "")
+	cmd.Flags().Bool("continue-on-error", false, "")
+	cmd.Flags().String("consistency-token-file", "", "")
 
 	err := f(cmd, []string{"resource:1", "viewer", "user:1"})
 	require.NoError(t, err)
@@ -365,6 +424,12 @@ func TestWriteRelationshipCmdFuncFromStdin(t *testing.T) {
 	cmd.Flags().Int("batch-size", 100, "")
 	cmd.Flags().Bool("json", true, "")
 	cmd.Flags().String("caveat", "", "")
+	cmd.Flags().String("from-lookup", "", "")
+	cmd.Flags().Bool("summary", false, "")
+	cmd.Flags().Bool("dedupe", false, "")
+	cmd.Flags().Bool("dedupe-disk", false, "")
+	cmd.Flags().Bool("continue-on-error", false, "")
+	cmd.Flags().String("consistency-token-file", "", "")
 
 	err := f(cmd, nil)
 	require.NoError(t, err)
@@ -409,14 +474,293 @@ func TestWriteRelationshipCmdFuncFromStdinBatch(t *testing.T) {
 		client.NewClient = originalClient
 	}()
 
+	previous := console.Println
+	defer func() {
+		console.Println = previous
+	}()
+	var lines []string
+	console.Println = func(values ...any) {
+		lines = append(lines, fmt.Sprint(values...))
+	}
+
 	f := writeRelationshipCmdFunc(v1.RelationshipUpdate_OPERATION_TOUCH, fi)
 	cmd := &cobra.Command{}
 	cmd.Flags().Int("batch-size", 1, "")
 	cmd.Flags().Bool("json", true, "")
 	cmd.Flags().String("caveat", "", "")
+	cmd.Flags().String("from-lookup", "", "")
+	cmd.Flags().Bool("summary", true, "")
+	cmd.Flags().Bool("dedupe", false, "")
+	cmd.Flags().Bool("dedupe-disk", false, "")
+	cmd.Flags().Bool("continue-on-error", false, "")
+	cmd.Flags().String("consistency-token-file", "", "")
 
 	err := f(cmd, nil)
 	require.NoError(t, err)
+	require.Len(t, lines, 3)
+	require.JSONEq(t, `{"relationships":2,"batches":2}`, lines[2])
+}
+
+func TestWriteRelationshipCmdFuncFromLookup(t *testing.T) {
+	mock := func(*cobra.Command) (client.Client, error) {
+		return &mockClient{t: t, expectedWrites: []*v1.WriteRelationshipsRequest{{
+			Updates: []*v1.RelationshipUpdate{
+				{
+					Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+					Relationship: tuple.MustParseV1Rel("resource:1#viewer@user:1"),
+				},
+				{
+					Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+					Relationship: tuple.MustParseV1Rel("resource:2#viewer@group:eng#member"),
+				},
+			},
+		}}}, nil
+	}
+
+	originalFunc := isFileTerminal
+	isFileTerminal = func(_ *os.File) bool {
+		return false
+	}
+	defer func() {
+		isFileTerminal = originalFunc
+	}()
+
+	// Lines as printed by the lookup commands with --as-relationships.
+	fi := fileFromStrings(t, []string{
+		"resource:1 view user:1",
+		"resource:2 view group:eng#member",
+	})
+	defer func() {
+		require.NoError(t, fi.Close())
+	}()
+	t.Cleanup(func() {
+		_ = os.Remove(fi.Name())
+	})
+
+	originalClient := client.NewClient
+	client.NewClient = mock
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	f := writeRelationshipCmdFunc(v1.RelationshipUpdate_OPERATION_TOUCH, fi)
+	cmd := &cobra.Command{}
+	cmd.Flags().Int("batch-size", 100, "")
+	cmd.Flags().Bool("json", true, "")
+	cmd.Flags().String("caveat", "", "")
+	cmd.Flags().String("from-lookup", "viewer", "")
+	cmd.Flags().Bool("summary", false, "")
+	cmd.Flags().Bool("dedupe", false, "")
+	cmd.Flags().Bool("dedupe-disk", false, "")
+	cmd.Flags().Bool("continue-on-error", false, "")
+	cmd.Flags().String("consistency-token-file", "", "")
+
+	err := f(cmd, []string{"resource:1", "view", "user:1"})
+	require.ErrorContains(t, err, "cannot be combined with arguments")
+
+	err = f(cmd, nil)
+	require.NoError(t, err)
+}
+
+func TestWriteRelationshipCmdFuncDedupe(t *testing.T) {
+	for _, flag := range []string{"dedupe", "dedupe-disk"} {
+		t.Run(flag, func(t *testing.T) {
+			// --dedupe-disk writes the relationships in sorted order.
+			expected := []string{"resource:1#viewer@user:1", "resource:1#viewer@user:2", `resource:1#viewer@user:1[cav:{"a":1}]`}
+			if flag == "dedupe-disk" {
+				expected = []string{"resource:1#viewer@user:1", `resource:1#viewer@user:1[cav:{"a":1}]`, "resource:1#viewer@user:2"}
+			}
+			updates := make([]*v1.RelationshipUpdate, 0, len(expected))
+			for _, rel := range expected {
+				updates = append(updates, &v1.RelationshipUpdate{
+					Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+					Relationship: tuple.MustParseV1Rel(rel),
+				})
+			}
+			mock := func(*cobra.Command) (client.Client, error) {
+				return &mockClient{t: t, expectedWrites: []*v1.WriteRelationshipsRequest{{Updates: updates}}}, nil
+			}
+
+			originalFunc := isFileTerminal
+			isFileTerminal = func(_ *os.File) bool {
+				return false
+			}
+			defer func() {
+				isFileTerminal = originalFunc
+			}()
+
+			fi := fileFromStrings(t, []string{
+				"resource:1 viewer user:1",
+				"resource:1 viewer user:2",
+				"resource:1 viewer user:1",
+				`resource:1 viewer user:1[cav:{"a":1}]`,
+				"resource:1 viewer user:2",
+			})
+			defer func() {
+				require.NoError(t, fi.Close())
+			}()
+			t.Cleanup(func() {
+				_ = os.Remove(fi.Name())
+			})
+
+			originalClient := client.NewClient
+			client.NewClient = mock
+			defer func() {
+				client.NewClient = originalClient
+			}()
+
+			previous := console.Errorf
+			defer func() {
+				console.Errorf = previous
+			}()
+			var lines []string
+			console.Errorf = func(format string, a ...any) {
+				lines = append(lines, fmt.Sprintf(format, a...))
+			}
+
+			f := writeRelationshipCmdFunc(v1.RelationshipUpdate_OPERATION_TOUCH, fi)
+			cmd := &cobra.Command{}
+			cmd.Flags().Int("batch-size", 100, "")
+			cmd.Flags().Bool("json", false, "")
+			cmd.Flags().String("caveat", "", "")
+			cmd.Flags().String("from-lookup", "", "")
+			cmd.Flags().Bool("summary", false, "")
+			cmd.Flags().Bool("dedupe", flag == "dedupe", "")
+			cmd.Flags().Bool("dedupe-disk", flag == "dedupe-disk", "")
+			cmd.Flags().Bool("continue-on-error", false, "")
+			cmd.Flags().String("consistency-token-file", "", "")
+
+			require.NoError(t, f(cmd, nil))
+			require.Equal(t, []string{"skipped 2 duplicate relationships\n"}, lines)
+		})
+	}
+}
+
+func TestWriteRelationshipCmdFuncContinueOnError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+	client.NewClient = zedtesting.ClientFromConn(conn)
+
+	c, err := client.NewClient(nil)
+	require.NoError(t, err)
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	originalFunc := isFileTerminal
+	isFileTerminal = func(_ *os.File) bool {
+		return false
+	}
+	defer func() {
+		isFileTerminal = originalFunc
+	}()
+
+	previous := console.Errorf
+	defer func() {
+		console.Errorf = previous
+	}()
+	var lines []string
+	console.Errorf = func(format string, a ...any) {
+		lines = append(lines, fmt.Sprintf(format, a...))
+	}
+
+	for _, continueOnError := range []bool{false, true} {
+		lines = nil
+		fi := fileFromStrings(t, []string{
+			"test/resource:1 reader test/user:1",
+			"test/resource:2 unknown test/user:1",
+			"test/resource:3 reader test/user:1",
+		})
+		t.Cleanup(func() {
+			_ = fi.Close()
+			_ = os.Remove(fi.Name())
+		})
+
+		f := writeRelationshipCmdFunc(v1.RelationshipUpdate_OPERATION_TOUCH, fi)
+		cmd := &cobra.Command{}
+		cmd.SetContext(ctx)
+		cmd.Flags().Int("batch-size", 1, "")
+		cmd.Flags().Bool("json", false, "")
+		cmd.Flags().String("caveat", "", "")
+		cmd.Flags().String("from-lookup", "", "")
+		cmd.Flags().Bool("summary", false, "")
+		cmd.Flags().Bool("dedupe", false, "")
+		cmd.Flags().Bool("dedupe-disk", false, "")
+		cmd.Flags().Bool("continue-on-error", continueOnError, "")
+		cmd.Flags().String("consistency-token-file", "", "")
+
+		err = f(cmd, nil)
+		if !continueOnError {
+			// The failing batch aborts the write.
+			require.ErrorContains(t, err, "unknown")
+			assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource"}, 1)
+			continue
+		}
+
+		require.ErrorIs(t, err, errWriteRelationshipsFailed)
+		require.Equal(t, []string{"2 relationships succeeded, 1 failed\n"}, lines)
+		assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource"}, 2)
+	}
+}
+
+func TestRelationshipSorter(t *testing.T) {
+	// A run size smaller than the input, so that duplicates are found both
+	// within and across runs.
+	sorter := newRelationshipSorter(3)
+	input := []string{
+		"resource:3#viewer@user:1",
+		"resource:1#viewer@user:1",
+		"resource:3#viewer@user:1",
+		"resource:2#viewer@user:1",
+		"resource:1#viewer@user:1",
+		`resource:1#viewer@user:1[cav:{"a":1}]`,
+		"resource:4#viewer@user:1",
+	}
+	rels := func() (*v1.Relationship, error) {
+		if len(input) == 0 {
+			return nil, ErrExhaustedRelationships
+		}
+		rel := tuple.MustParseV1Rel(input[0])
+		input = input[1:]
+		return rel, nil
+	}
+
+	parser, err := sorter.sortedParser(rels)
+	require.NoError(t, err)
+	require.Len(t, sorter.runs, 3)
+
+	var sorted []string
+	for {
+		rel, err := parser()
+		if errors.Is(err, ErrExhaustedRelationships) {
+			break
+		}
+		require.NoError(t, err)
+		sorted = append(sorted, tuple.MustV1StringRelationship(rel))
+	}
+	require.Equal(t, []string{
+		"resource:1#viewer@user:1",
+		`resource:1#viewer@user:1[cav:{"a":1}]`,
+		"resource:2#viewer@user:1",
+		"resource:3#viewer@user:1",
+		"resource:4#viewer@user:1",
+	}, sorted)
+	require.Equal(t, 2, sorter.duplicates)
+
+	require.NoError(t, sorter.Close())
+	for _, run := range sorter.runs {
+		require.NoFileExists(t, run.Name())
+	}
 }
 
 func TestWriteRelationshipCmdFuncFromFailsWithCaveatArg(t *testing.T) {
@@ -454,6 +798,12 @@ func TestWriteRelationshipCmdFuncFromFailsWithCaveatArg(t *testing.T) {
 	cmd.Flags().Int("batch-size", 1, "")
 	cmd.Flags().Bool("json", true, "")
 	cmd.Flags().String("caveat", `cav:{"letters": ["a", "b", "c"]}`, "")
+	cmd.Flags().String("from-lookup", "", "")
+	cmd.Flags().Bool("summary", false, "")
+	cmd.Flags().Bool("dedupe", false, "")
+	cmd.Flags().Bool("dedupe-disk", false, "")
+	cmd.Flags().Bool("continue-on-error", false, "")
+	cmd.Flags().String("consistency-token-file", "", "")
 
 	err := f(cmd, nil)
 	require.ErrorContains(t, err, "cannot specify a caveat in both the relationship and the --caveat flag")
@@ -479,11 +829,327 @@ func fileFromStrings(t *testing.T, strings []string) *os.File {
 	return file
 }
 
+// startTestServer starts a test server, points client.NewClient at it until the
+// end of the test, and returns a client for it.
+func startTestServer(t *testing.T) (context.Context, client.Client) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	t.Cleanup(func() {
+		client.NewClient = originalClient
+	})
+	client.NewClient = zedtesting.ClientFromConn(conn)
+
+	c, err := client.NewClient(nil)
+	require.NoError(t, err)
+	return ctx, c
+}
+
+// newReadCommand returns a command with every flag of the read command, at
+// its default with full consistency, unless overridden by one of overrides.
+func newReadCommand(t *testing.T, overrides ...any) *cobra.Command {
+	t.Helper()
+
+	flags := []any{
+		zedtesting.BoolFlag{FlagName: "consistency-full", FlagValue: true},
+		zedtesting.StringFlag{FlagName: "consistency-at-least"},
+		zedtesting.BoolFlag{FlagName: "consistency-min-latency"},
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
+		zedtesting.StringFlag{FlagName: "consistency-at-exactly"},
+		zedtesting.StringFlag{FlagName: "revision"},
+		zedtesting.StringFlag{FlagName: "subject-filter"},
+		zedtesting.UintFlag32{FlagName: "page-limit", FlagValue: 100},
+		zedtesting.StringFlag{FlagName: "output-template"},
+		zedtesting.BoolFlag{FlagName: "show-caveat-context-only"},
+		zedtesting.BoolFlag{FlagName: "json"},
+		zedtesting.BoolFlag{FlagName: "compact"},
+		zedtesting.BoolFlag{FlagName: "json-relationship"},
+		zedtesting.BoolFlag{FlagName: "sort"},
+		zedtesting.UintFlag{FlagName: "sort-buffer-size"},
+		zedtesting.BoolFlag{FlagName: "include-revision"},
+		zedtesting.UintFlag{FlagName: "resolve"},
+		zedtesting.UintFlag32{FlagName: "resolve-limit", FlagValue: 100},
+		zedtesting.BoolFlag{FlagName: "disable-retries"},
+		zedtesting.UintFlag{FlagName: "max-retries"},
+		zedtesting.BoolFlag{FlagName: "deduplicate"},
+		zedtesting.UintFlag{FlagName: "deduplicate-max-size", FlagValue: 1_000_000},
+		zedtesting.BoolFlag{FlagName: "paginate-summary"},
+	}
+
+	flagName := func(flag any) string {
+		switch f := flag.(type) {
+		case zedtesting.StringFlag:
+			return f.FlagName
+		case zedtesting.BoolFlag:
+			return f.FlagName
+		case zedtesting.UintFlag:
+			return f.FlagName
+		case zedtesting.UintFlag32:
+			return f.FlagName
+		default:
+			t.Fatalf("unexpected read flag type: %T", f)
+			return ""
+		}
+	}
+
+	for _, override := range overrides {
+		i := slices.IndexFunc(flags, func(flag any) bool { return flagName(flag) == flagName(override) })
+		require.NotEqual(t, -1, i, "unknown read flag %q", flagName(override))
+		flags[i] = override
+	}
+	return zedtesting.CreateTestCobraCommandWithFlagValue(t, flags...)
+}
+
+func TestReadRelationshipsSorted(t *testing.T) {
+	ctx, c := startTestServer(t)
+
+	_, err := c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	var updates []*v1.RelationshipUpdate
+	for _, id := range []string{"9", "10", "2", "1"} {
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.MustParseV1Rel(fmt.Sprintf("test/resource:%s#reader@test/user:1", id)),
+		})
+	}
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+	require.NoError(t, err)
+
+	previous := console.Println
+	defer func() {
+		console.Println = previous
+	}()
+	var lines []string
+	console.Println = func(values ...any) {
+		lines = append(lines, fmt.Sprint(values...))
+	}
+
+	readCommand := func(sortBufferSize uint) *cobra.Command {
+		return newReadCommand(t,
+			zedtesting.UintFlag32{FlagName: "page-limit", FlagValue: 3},
+			zedtesting.BoolFlag{FlagName: "sort", FlagValue: true},
+			zedtesting.UintFlag{FlagName: "sort-buffer-size", FlagValue: sortBufferSize})
+	}
+
+	require.NoError(t, readRelationships(readCommand(4), []string{"test/resource"}))
+	require.Equal(t, []string{
+		"test/resource:1 reader test/user:1",
+		"test/resource:10 reader test/user:1",
+		"test/resource:2 reader test/user:1",
+		"test/resource:9 reader test/user:1",
+	}, lines)
+
+	lines = nil
+	err = readRelationships(readCommand(3), []string{"test/resource"})
+	require.ErrorContains(t, err, "more than 3 relationships matched")
+	require.Empty(t, lines)
+}
+
+func TestReadRelationshipsIncludeRevision(t *testing.T) {
+	ctx, c := startTestServer(t)
+
+	_, err := c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	resp, err := c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{{
+		Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+		Relationship: tuple.MustParseV1Rel("test/resource:1#reader@test/user:1"),
+	}}})
+	require.NoError(t, err)
+
+	previous := console.Println
+	defer func() {
+		console.Println = previous
+	}()
+	var lines []string
+	console.Println = func(values ...any) {
+		lines = append(lines, fmt.Sprint(values...))
+	}
+
+	readCommand := newReadCommand(t,
+		zedtesting.StringFlag{FlagName: "consistency-at-exactly", FlagValue: resp.WrittenAt.Token},
+		zedtesting.BoolFlag{FlagName: "consistency-full", FlagValue: false},
+		zedtesting.BoolFlag{FlagName: "json-relationship", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "include-revision", FlagValue: true})
+
+	require.NoError(t, readRelationships(readCommand, []string{"test/resource"}))
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"objectId":"1"`)
+	require.JSONEq(t, fmt.Sprintf(`{"readAt":%q}`, resp.WrittenAt.Token), lines[1])
+}
+
+func TestReadRelationshipsPaginateSummary(t *testing.T) {
+	ctx, c := startTestServer(t)
+
+	_, err := c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	var updates []*v1.RelationshipUpdate
+	for i := range 5 {
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.MustParseV1Rel(fmt.Sprintf("test/resource:%d#reader@test/user:1", i)),
+		})
+	}
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+	require.NoError(t, err)
+
+	previousPrintln, previousErrorf := console.Println, console.Errorf
+	defer func() {
+		console.Println, console.Errorf = previousPrintln, previousErrorf
+	}()
+	var lines, summary []string
+	console.Println = func(values ...any) {
+		lines = append(lines, fmt.Sprint(values...))
+	}
+	console.Errorf = func(format string, a ...any) {
+		summary = append(summary, fmt.Sprintf(format, a...))
+	}
+
+	readCommand := newReadCommand(t,
+		zedtesting.UintFlag32{FlagName: "page-limit", FlagValue: 2},
+		zedtesting.BoolFlag{FlagName: "paginate-summary", FlagValue: true})
+
+	require.NoError(t, readRelationships(readCommand, []string{"test/resource"}))
+	require.Len(t, lines, 5)
+	require.Len(t, summary, 1)
+	require.Regexp(t, `^read 3 pages and 5 relationships; last cursor: \S+\n$`, summary[0])
+	require.NotContains(t, summary[0], "last cursor: none")
+}
+
+func TestReadRelationshipsResolve(t *testing.T) {
+	ctx, c := startTestServer(t)
+
+	_, err := c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: `definition test/user {}
+
+definition test/group {
+	relation member: test/user | test/group#member
+}
+
+definition test/resource {
+	relation reader: test/user | test/group#member
+}`})
+	require.NoError(t, err)
+
+	var updates []*v1.RelationshipUpdate
+	for _, rel := range []string{
+		"test/resource:1#reader@test/group:eng#member",
+		"test/group:eng#member@test/user:1",
+		"test/group:eng#member@test/group:sre#member",
+		"test/group:sre#member@test/user:2",
+		"test/group:sre#member@test/group:eng#member",
+		"test/group:sre#member@test/group:ops#member",
+		"test/group:ops#member@test/user:3",
+	} {
+		updates = append(updates, &v1.RelationshipUpdate{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: tuple.MustParseV1Rel(rel)})
+	}
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+	require.NoError(t, err)
+
+	previousPrintln := console.Println
+	previousPrintf := console.Printf
+	defer func() {
+		console.Println = previousPrintln
+		console.Printf = previousPrintf
+	}()
+	var lines []string
+	console.Println = func(values ...any) {
+		lines = append(lines, fmt.Sprint(values...))
+	}
+	console.Printf = func(format string, a ...any) {
+		lines = append(lines, strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"))
+	}
+
+	readCommand := func(depth uint, limit uint32) *cobra.Command {
+		return newReadCommand(t,
+			zedtesting.UintFlag{FlagName: "resolve", FlagValue: depth},
+			zedtesting.UintFlag32{FlagName: "resolve-limit", FlagValue: limit})
+	}
+
+	require.NoError(t, readRelationships(readCommand(2, 100), []string{"test/resource"}))
+	require.Equal(t, []string{
+		"test/resource:1 reader test/group:eng#member",
+		"  test/group:sre#member",
+		"    test/group:eng#member (cycle)",
+		"    test/group:ops#member",
+		"    test/user:2",
+		"  test/user:1",
+	}, lines)
+
+	lines = nil
+	require.NoError(t, readRelationships(readCommand(1, 1), []string{"test/resource"}))
+	require.Equal(t, []string{
+		"test/resource:1 reader test/group:eng#member",
+		"  test/group:sre#member",
+		"  ... more subjects not shown (--resolve-limit 1)",
+	}, lines)
+}
+
+func TestRelationshipExists(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	c, err := zedtesting.ClientFromConn(conn)(nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: `definition test/user {
+	relation member: test/user
+}
+
+definition test/resource {
+	relation reader: test/user | test/user#member
+}`})
+	require.NoError(t, err)
+
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: tuple.MustParseV1Rel("test/resource:1#reader@test/user:1")},
+			{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: tuple.MustParseV1Rel("test/resource:1#reader@test/user:2#member")},
+		},
+	})
+	require.NoError(t, err)
+
+	fullyConsistent := &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}}
+	for _, tt := range []struct {
+		rel    string
+		exists bool
+	}{
+		{"test/resource:1#reader@test/user:1", true},
+		{"test/resource:1#reader@test/user:2#member", true},
+		{"test/resource:1#reader@test/user:2", false},
+		{"test/resource:1#reader@test/user:1#member", false},
+		{"test/resource:2#reader@test/user:1", false},
+	} {
+		t.Run(tt.rel, func(t *testing.T) {
+			exists, err := relationshipExists(ctx, c, tuple.MustParseV1Rel(tt.rel), fullyConsistent)
+			require.NoError(t, err)
+			require.Equal(t, tt.exists, exists)
+		})
+	}
+}
+
 func TestBuildRelationshipsFilter(t *testing.T) {
 	tests := []struct {
-		name     string
-		args     []string
-		expected *v1.RelationshipFilter
+		name           string
+		args           []string
+		expected       *v1.RelationshipFilter
+		expectedPrefix string
 	}{
 		{
 			name:     "resource type",
@@ -520,6 +1186,16 @@ func TestBuildRelationshipsFilter(t *testing.T) {
 				OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "sub", OptionalSubjectId: "321"},
 			},
 		},
+		{
+			name: "resource type, relation, subject type, subject ID prefix",
+			args: []string{"res", "view", "sub:tenant1-%"},
+			expected: &v1.RelationshipFilter{
+				ResourceType:          "res",
+				OptionalRelation:      "view",
+				OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "sub"},
+			},
+			expectedPrefix: "tenant1-",
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -529,8 +1205,9 @@ func TestBuildRelationshipsFilter(t *testing.T) {
 			cmd := &cobra.Command{}
 			cmd.Flags().String("subject-filter", "", "")
 
-			filter, err := buildRelationshipsFilter(cmd, tt.args)
+			filter, subjectIDPrefix, err := buildRelationshipsFilter(cmd, tt.args)
 			require.NoError(t, err)
+			require.Equal(t, tt.expectedPrefix, subjectIDPrefix, "subject ID prefixes do not match")
 			require.Equal(t, tt.expected.ResourceType, filter.ResourceType, "resource types do not match")
 			require.Equal(t, tt.expected.OptionalResourceId, filter.OptionalResourceId, "resource IDs do not match")
 			require.Equal(t, tt.expected.OptionalRelation, filter.OptionalRelation, "relations do not match")
@@ -580,9 +1257,14 @@ func TestBulkDeleteForcing(t *testing.T) {
 
 	client.NewClient = zedtesting.ClientFromConn(conn)
 	testCmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
 		zedtesting.StringFlag{FlagName: "subject-filter"},
 		zedtesting.UintFlag32{FlagName: "optional-limit", FlagValue: 1},
-		zedtesting.BoolFlag{FlagName: "force", FlagValue: true})
+		zedtesting.BoolFlag{FlagName: "force", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "all-resource-types"},
+		zedtesting.IntFlag{FlagName: "parallelism", FlagValue: 4},
+		zedtesting.BoolFlag{FlagName: "progress-accurate"},
+		zedtesting.BoolFlag{FlagName: "yes", FlagValue: true})
 	c, err := client.NewClient(testCmd)
 	require.NoError(t, err)
 
@@ -630,9 +1312,14 @@ func TestBulkDeleteManyForcing(t *testing.T) {
 
 	client.NewClient = zedtesting.ClientFromConn(conn)
 	testCmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
 		zedtesting.StringFlag{FlagName: "subject-filter"},
 		zedtesting.UintFlag32{FlagName: "optional-limit", FlagValue: 1},
-		zedtesting.BoolFlag{FlagName: "force", FlagValue: true})
+		zedtesting.BoolFlag{FlagName: "force", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "all-resource-types"},
+		zedtesting.IntFlag{FlagName: "parallelism", FlagValue: 4},
+		zedtesting.BoolFlag{FlagName: "progress-accurate"},
+		zedtesting.BoolFlag{FlagName: "yes", FlagValue: true})
 	c, err := client.NewClient(testCmd)
 	require.NoError(t, err)
 
@@ -672,9 +1359,14 @@ func TestBulkDeleteNotForcing(t *testing.T) {
 
 	client.NewClient = zedtesting.ClientFromConn(conn)
 	testCmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
 		zedtesting.StringFlag{FlagName: "subject-filter"},
 		zedtesting.UintFlag32{FlagName: "optional-limit", FlagValue: 1},
-		zedtesting.BoolFlag{FlagName: "force", FlagValue: false})
+		zedtesting.BoolFlag{FlagName: "force", FlagValue: false},
+		zedtesting.BoolFlag{FlagName: "all-resource-types"},
+		zedtesting.IntFlag{FlagName: "parallelism", FlagValue: 4},
+		zedtesting.BoolFlag{FlagName: "progress-accurate"},
+		zedtesting.BoolFlag{FlagName: "yes", FlagValue: true})
 	c, err := client.NewClient(testCmd)
 	require.NoError(t, err)
 
@@ -704,6 +1396,263 @@ func TestBulkDeleteNotForcing(t *testing.T) {
 	assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource"}, 3)
 }
 
+func TestBulkDeleteSubjectIDPrefix(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	client.NewClient = zedtesting.ClientFromConn(conn)
+	testCmd := func(force bool) *cobra.Command {
+		return zedtesting.CreateTestCobraCommandWithFlagValue(t,
+			zedtesting.StringFlag{FlagName: "consistency-token-file"},
+			zedtesting.StringFlag{FlagName: "subject-filter"},
+			zedtesting.UintFlag32{FlagName: "optional-limit", FlagValue: 2},
+			zedtesting.BoolFlag{FlagName: "force", FlagValue: force},
+			zedtesting.BoolFlag{FlagName: "all-resource-types"},
+			zedtesting.IntFlag{FlagName: "parallelism", FlagValue: 4},
+			zedtesting.BoolFlag{FlagName: "progress-accurate"},
+			zedtesting.BoolFlag{FlagName: "yes", FlagValue: true})
+	}
+	c, err := client.NewClient(nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	var updates []*v1.RelationshipUpdate
+	for _, subjectID := range []string{"tenant1-a", "tenant1-b", "tenant1-c", "tenant2-a"} {
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.MustParseV1Rel("test/resource:1#reader@test/user:" + subjectID),
+		})
+	}
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+	require.NoError(t, err)
+
+	err = bulkDeleteRelationships(testCmd(false), []string{"test/resource", "reader", "test/user:tenant1-%"})
+	require.ErrorContains(t, err, "more than 2 relationships were found")
+	assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource"}, 4)
+
+	err = bulkDeleteRelationships(testCmd(true), []string{"test/resource", "reader", "test/user:tenant1-%"})
+	require.NoError(t, err)
+	assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource"}, 1)
+	assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{
+		ResourceType:          "test/resource",
+		OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "test/user", OptionalSubjectId: "tenant2-a"},
+	}, 1)
+}
+
+func TestBulkDeleteResourceIDPrefix(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	client.NewClient = zedtesting.ClientFromConn(conn)
+	testCmd := func(force, progressAccurate bool) *cobra.Command {
+		return zedtesting.CreateTestCobraCommandWithFlagValue(t,
+			zedtesting.StringFlag{FlagName: "consistency-token-file"},
+			zedtesting.StringFlag{FlagName: "subject-filter"},
+			zedtesting.UintFlag32{FlagName: "optional-limit", FlagValue: 2},
+			zedtesting.BoolFlag{FlagName: "force", FlagValue: force},
+			zedtesting.BoolFlag{FlagName: "all-resource-types"},
+			zedtesting.IntFlag{FlagName: "parallelism", FlagValue: 4},
+			zedtesting.BoolFlag{FlagName: "progress-accurate", FlagValue: progressAccurate},
+			zedtesting.BoolFlag{FlagName: "yes", FlagValue: true})
+	}
+	c, err := client.NewClient(nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	writeResources := func(resourceIDs ...string) {
+		var updates []*v1.RelationshipUpdate
+		for _, resourceID := range resourceIDs {
+			updates = append(updates, &v1.RelationshipUpdate{
+				Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+				Relationship: tuple.MustParseV1Rel("test/resource:" + resourceID + "#reader@test/user:1"),
+			})
+		}
+		_, err := c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+		require.NoError(t, err)
+	}
+	tenant1 := []string{"tenant1-a", "tenant1-b", "tenant1-c", "tenant1-d", "tenant1-e"}
+	writeResources(append(tenant1, "tenant2-a")...)
+
+	// Without --force, more matches than the limit are refused.
+	err = bulkDeleteRelationships(testCmd(false, false), []string{"test/resource:tenant1-%"})
+	require.ErrorContains(t, err, "more than 2 relationships were found")
+	assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource"}, 6)
+
+	// With --force, the prefix is deleted across several partial deletions
+	// until none are left, without touching other resources.
+	for _, progressAccurate := range []bool{false, true} {
+		err = bulkDeleteRelationships(testCmd(true, progressAccurate), []string{"test/resource:tenant1-%"})
+		require.NoError(t, err)
+		assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource", OptionalResourceIdPrefix: "tenant1-"}, 0)
+		assertRelationshipCount(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource", OptionalResourceId: "tenant2-a"}, 1)
+
+		writeResources(tenant1...)
+	}
+}
+
+func TestBulkDeleteAllResourceTypes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	client.NewClient = zedtesting.ClientFromConn(conn)
+	testCmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
+		zedtesting.StringFlag{FlagName: "subject-filter"},
+		zedtesting.UintFlag32{FlagName: "optional-limit", FlagValue: 1},
+		zedtesting.BoolFlag{FlagName: "force", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "all-resource-types", FlagValue: true},
+		zedtesting.IntFlag{FlagName: "parallelism", FlagValue: 2},
+		zedtesting.BoolFlag{FlagName: "progress-accurate"},
+		zedtesting.BoolFlag{FlagName: "yes", FlagValue: true})
+	c, err := client.NewClient(testCmd)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema + "\n\ndefinition test/group {\n\trelation member: test/user\n}"})
+	require.NoError(t, err)
+
+	var updates []*v1.RelationshipUpdate
+	for _, rel := range []string{
+		"test/resource:1#reader@test/user:1",
+		"test/resource:2#writer@test/user:2",
+		"test/group:1#member@test/user:1",
+		"test/group:2#member@test/user:2",
+		"test/group:3#member@test/user:3",
+	} {
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.MustParseV1Rel(rel),
+		})
+	}
+	_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+	require.NoError(t, err)
+
+	err = bulkDeleteRelationships(testCmd, []string{"test/resource"})
+	require.ErrorContains(t, err, "cannot provide a pattern with --all-resource-types")
+
+	previous := console.Println
+	defer func() {
+		console.Println = previous
+	}()
+	var printed []string
+	console.Println = func(values ...any) {
+		printed = append(printed, fmt.Sprint(values...))
+	}
+
+	err = bulkDeleteRelationships(testCmd, nil)
+	require.NoError(t, err)
+	assertRelationshipsEmpty(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/resource"})
+	assertRelationshipsEmpty(ctx, t, c, &v1.RelationshipFilter{ResourceType: "test/group"})
+
+	// The printed zedtoken follows the deletions of every resource type.
+	require.Len(t, printed, 1)
+	for _, resourceType := range []string{"test/resource", "test/group"} {
+		stream, err := c.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+			Consistency:        &v1.Consistency{Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: &v1.ZedToken{Token: printed[0]}}},
+			RelationshipFilter: &v1.RelationshipFilter{ResourceType: resourceType},
+		})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.ErrorIs(t, err, io.EOF)
+	}
+
+	// With nothing left to delete, counted deletions print no zedtoken.
+	testCmd = zedtesting.CreateTestCobraCommandWithFlagValue(t,
+		zedtesting.StringFlag{FlagName: "consistency-token-file"},
+		zedtesting.StringFlag{FlagName: "subject-filter"},
+		zedtesting.UintFlag32{FlagName: "optional-limit", FlagValue: 1},
+		zedtesting.BoolFlag{FlagName: "force", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "all-resource-types", FlagValue: true},
+		zedtesting.IntFlag{FlagName: "parallelism", FlagValue: 2},
+		zedtesting.BoolFlag{FlagName: "progress-accurate", FlagValue: true},
+		zedtesting.BoolFlag{FlagName: "yes", FlagValue: true})
+	require.NoError(t, bulkDeleteRelationships(testCmd, nil))
+	require.Len(t, printed, 1)
+}
+
+func TestDeleteRelationshipsMatchingFilterProgress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := zedtesting.NewTestServer(ctx, t)
+	go func() {
+		require.NoError(t, srv.Run(ctx))
+	}()
+	conn, err := srv.GRPCDialContext(ctx)
+	require.NoError(t, err)
+
+	c, err := zedtesting.ClientFromConn(conn)(nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: testSchema})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name          string
+		countFirst    bool
+		expectedCount int
+	}{
+		{"counted", true, 5},
+		{"not counted", false, 5},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var updates []*v1.RelationshipUpdate
+			for i := range 5 {
+				updates = append(updates, &v1.RelationshipUpdate{
+					Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+					Relationship: tuple.MustParseV1Rel(fmt.Sprintf("test/resource:%d#reader@test/user:1", i)),
+				})
+			}
+			_, err = c.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+			require.NoError(t, err)
+
+			bar := progressbar.NewOptions(-1, progressbar.OptionSetWriter(io.Discard))
+			filter := &v1.RelationshipFilter{ResourceType: "test/resource"}
+			deletedAt, deleted, err := deleteRelationshipsMatchingFilter(ctx, c, filter, 2, true, tt.countFirst, bar)
+			require.NoError(t, err)
+			require.NotEmpty(t, deletedAt.GetToken())
+			require.Equal(t, tt.expectedCount, deleted)
+			require.Equal(t, int64(tt.expectedCount), bar.State().CurrentNum)
+			assertRelationshipsEmpty(ctx, t, c, filter)
+		})
+	}
+}
+
 func assertRelationshipsEmpty(ctx context.Context, t *testing.T, c client.Client, filter *v1.RelationshipFilter) {
 	t.Helper()
 
@@ -737,3 +1686,209 @@ func assertRelationshipCount(ctx context.Context, t *testing.T, c client.Client,
 	require.NoError(t, rrCli.CloseSend())
 	require.Equal(t, count, relCount)
 }
+
+type readRelationshipsStream struct {
+	grpc.ClientStream
+	msgs []*v1.ReadRelationshipsResponse
+}
+
+func (s *readRelationshipsStream) Recv() (*v1.ReadRelationshipsResponse, error) {
+	if len(s.msgs) == 0 {
+		return nil, io.EOF
+	}
+	msg := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return msg, nil
+}
+
+type readRelationshipsClient struct {
+	*mockClient
+	rels []*v1.Relationship
+}
+
+func (c *readRelationshipsClient) ReadRelationships(_ context.Context, _ *v1.ReadRelationshipsRequest, _ ...grpc.CallOption) (grpc.ServerStreamingClient[v1.ReadRelationshipsResponse], error) {
+	stream := &readRelationshipsStream{}
+	for _, rel := range c.rels {
+		stream.msgs = append(stream.msgs, &v1.ReadRelationshipsResponse{Relationship: rel})
+	}
+	return stream, nil
+}
+
+// interruptedReadClient serves its relationships with a cursor of their index,
+// failing the stream with Unavailable after failAfter relationships the first
+// failures times it is read.
+type interruptedReadClient struct {
+	*mockClient
+	rels      []*v1.Relationship
+	failAfter int
+	failures  int
+	cursors   []string
+}
+
+type interruptedReadStream struct {
+	readRelationshipsStream
+	err error
+}
+
+func (s *interruptedReadStream) Recv() (*v1.ReadRelationshipsResponse, error) {
+	if len(s.msgs) == 0 && s.err != nil {
+		return nil, s.err
+	}
+	return s.readRelationshipsStream.Recv()
+}
+
+func (c *interruptedReadClient) ReadRelationships(_ context.Context, req *v1.ReadRelationshipsRequest, _ ...grpc.CallOption) (grpc.ServerStreamingClient[v1.ReadRelationshipsResponse], error) {
+	start := 0
+	if req.OptionalCursor != nil {
+		c.cursors = append(c.cursors, req.OptionalCursor.Token)
+		start, _ = strconv.Atoi(req.OptionalCursor.Token)
+	}
+
+	stream := &interruptedReadStream{}
+	for i := start; i < len(c.rels); i++ {
+		if c.failures > 0 && i-start == c.failAfter {
+			c.failures--
+			stream.err = status.Error(codes.Unavailable, "connection reset")
+			break
+		}
+		stream.msgs = append(stream.msgs, &v1.ReadRelationshipsResponse{
+			Relationship:      c.rels[i],
+			AfterResultCursor: &v1.Cursor{Token: strconv.Itoa(i + 1)},
+		})
+	}
+	return stream, nil
+}
+
+func TestReadRelationshipsResumesAfterInterruption(t *testing.T) {
+	originalBackoff := readRetryBackoff
+	readRetryBackoff = time.Millisecond
+	defer func() {
+		readRetryBackoff = originalBackoff
+	}()
+
+	var rels []*v1.Relationship
+	var expected []string
+	for i := 0; i < 5; i++ {
+		rels = append(rels, tuple.MustParseV1Rel(fmt.Sprintf("test/resource:%d#reader@test/user:1", i)))
+		expected = append(expected, fmt.Sprintf("test/resource:%d reader test/user:1", i))
+	}
+
+	previous := console.Println
+	defer func() {
+		console.Println = previous
+	}()
+	var lines []string
+	console.Println = func(values ...any) {
+		lines = append(lines, fmt.Sprint(values...))
+	}
+
+	readCommand := func(disableRetries bool, maxRetries uint) *cobra.Command {
+		return newReadCommand(t,
+			zedtesting.UintFlag32{FlagName: "page-limit"},
+			zedtesting.BoolFlag{FlagName: "disable-retries", FlagValue: disableRetries},
+			zedtesting.UintFlag{FlagName: "max-retries", FlagValue: maxRetries})
+	}
+
+	originalClient := client.NewClient
+	defer func() {
+		client.NewClient = originalClient
+	}()
+
+	for _, tt := range []struct {
+		name            string
+		failures        int
+		disableRetries  bool
+		maxRetries      uint
+		expectedCursors []string
+		expectedErr     string
+	}{
+		{"resumes from the last cursor", 2, false, 10, []string{"2", "4"}, ""},
+		{"retries disabled", 1, true, 10, nil, "connection reset"},
+		{"retry budget exhausted", 2, false, 1, []string{"2"}, "connection reset"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			lines = nil
+			c := &interruptedReadClient{mockClient: &mockClient{t: t}, rels: rels, failAfter: 2, failures: tt.failures}
+			client.NewClient = func(*cobra.Command) (client.Client, error) { return c, nil }
+
+			err := readRelationships(readCommand(tt.disableRetries, tt.maxRetries), []string{"test/resource"})
+			require.Equal(t, tt.expectedCursors, c.cursors)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, expected, lines)
+		})
+	}
+}
+
+func TestRelationshipDeduplicator(t *testing.T) {
+	d := newRelationshipDeduplicator(2)
+	seen := func(rel string) bool {
+		return d.seen(tuple.V1StringRelationshipWithoutCaveatOrExpiration(tuple.MustParseV1Rel(rel)))
+	}
+	require.False(t, seen("test/resource:1#reader@test/user:1"))
+	require.False(t, seen("test/resource:2#reader@test/user:1"))
+	require.True(t, seen("test/resource:1#reader@test/user:1"))
+	require.True(t, seen(`test/resource:1#reader@test/user:1[test:{"a":1}]`))
+
+	// Past the maximum size, new relationships are not remembered.
+	require.False(t, seen("test/resource:3#reader@test/user:1"))
+	require.False(t, seen("test/resource:3#reader@test/user:1"))
+	require.True(t, seen("test/resource:2#reader@test/user:1"))
+}
+
+func TestListExpiredRelationships(t *testing.T) {
+	withExpiration := func(relString string, expiresAt time.Time) *v1.Relationship {
+		rel := tuple.MustParseV1Rel(relString)
+		rel.OptionalExpiresAt = timestamppb.New(expiresAt)
+		return rel
+	}
+	expired := withExpiration("test/resource:1#reader@test/user:1", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	rels := []*v1.Relationship{
+		expired,
+		withExpiration("test/resource:2#reader@test/user:1", time.Now().Add(time.Hour)),
+		tuple.MustParseV1Rel("test/resource:3#reader@test/user:1"),
+	}
+
+	expiredLine := "test/resource:1#reader@test/user:1[expiration:2020-01-01T00:00:00Z]"
+	for _, tt := range []struct {
+		name           string
+		delete         bool
+		expectedWrites []*v1.WriteRelationshipsRequest
+		expectedLines  []string
+	}{
+		{"list only", false, nil, []string{expiredLine}},
+		{"delete", true, []*v1.WriteRelationshipsRequest{{Updates: []*v1.RelationshipUpdate{
+			{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: expired},
+		}}}, []string{expiredLine, "test"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &readRelationshipsClient{mockClient: &mockClient{t: t, expectedWrites: tt.expectedWrites}, rels: rels}
+			originalClient := client.NewClient
+			client.NewClient = func(*cobra.Command) (client.Client, error) { return mock, nil }
+			previousPrintln, previousErrorf := console.Println, console.Errorf
+			var lines []string
+			console.Println = func(values ...any) { lines = append(lines, fmt.Sprint(values...)) }
+			console.Errorf = func(string, ...any) {}
+			defer func() {
+				client.NewClient = originalClient
+				console.Println, console.Errorf = previousPrintln, previousErrorf
+			}()
+
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.StringFlag{FlagName: "consistency-token-file"},
+				zedtesting.StringFlag{FlagName: "subject-filter"},
+				zedtesting.UintFlag32{FlagName: "page-limit"},
+				zedtesting.BoolFlag{FlagName: "delete", FlagValue: tt.delete},
+				zedtesting.IntFlag{FlagName: "batch-size", FlagValue: 100},
+				zedtesting.BoolFlag{FlagName: "json"})
+			require.NoError(t, listExpiredRelationshipsCmdFunc(cmd, []string{"test/resource"}))
+			// Neither the relationship expiring in the future nor the one
+			// without an expiration is listed; deleting prints the zedtoken.
+			require.Equal(t, tt.expectedLines, lines)
+			require.Empty(t, mock.expectedWrites)
+		})
+	}
+}
@@ -2,8 +2,20 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/caveats"
+	caveattypes "github.com/authzed/spicedb/pkg/caveats/types"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/generator"
+	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/jzelinskie/cobrautil/v2"
 	"github.com/jzelinskie/stringz"
 	"github.com/rs/zerolog/log"
@@ -20,6 +32,10 @@ func RegisterSchemaCmd(rootCmd *cobra.Command) *cobra.Command {
 
 	schemaCmd.AddCommand(schemaReadCmd)
 	schemaReadCmd.Flags().Bool("json", false, "output as JSON")
+	schemaReadCmd.Flags().Bool("structured", false, "compile the schema and output its definitions, relations, permissions and caveats as JSON")
+
+	schemaCmd.AddCommand(schemaDependentsCmd)
+	schemaDependentsCmd.Flags().Bool("json", false, "output as JSON")
 
 	return schemaCmd
 }
@@ -31,15 +47,33 @@ var (
 	}
 
 	schemaReadCmd = &cobra.Command{
-		Use:               "read",
-		Short:             "Read the schema of a permissions system",
-		Args:              cobra.ExactArgs(0),
-		ValidArgsFunction: cobra.NoFileCompletions,
+		Use:   "read [schema-file]",
+		Short: "Read the schema of a permissions system",
+		Long: `Read the schema of a permissions system.
+
+When used with --structured, a local schema file can be provided in place of
+reading the schema from the permissions system.`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: FileExtensionCompletions("zed"),
 		RunE:              schemaReadCmdFunc,
 	}
 )
 
-func schemaReadCmdFunc(cmd *cobra.Command, _ []string) error {
+func schemaReadCmdFunc(cmd *cobra.Command, args []string) error {
+	structured := cobrautil.MustGetBool(cmd, "structured")
+	if len(args) > 0 {
+		if !structured {
+			return errors.New("reading a schema file requires --structured")
+		}
+
+		schemaBytes, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read schema file: %w", err)
+		}
+
+		return printStructuredSchema(string(schemaBytes))
+	}
+
 	client, err := client.NewClient(cmd)
 	if err != nil {
 		return err
@@ -52,6 +86,10 @@ func schemaReadCmdFunc(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
+	if structured {
+		return printStructuredSchema(resp.SchemaText)
+	}
+
 	if cobrautil.MustGetBool(cmd, "json") {
 		prettyProto, err := PrettyProto(resp)
 		if err != nil {
@@ -66,6 +104,166 @@ func schemaReadCmdFunc(cmd *cobra.Command, _ []string) error {
 	return nil
 }
 
+type structuredSchema struct {
+	Definitions []structuredDefinition `json:"definitions"`
+	Caveats     []structuredCaveat     `json:"caveats"`
+}
+
+type structuredDefinition struct {
+	Name        string                 `json:"name"`
+	Relations   []structuredRelation   `json:"relations"`
+	Permissions []structuredPermission `json:"permissions"`
+}
+
+type structuredRelation struct {
+	Name         string   `json:"name"`
+	AllowedTypes []string `json:"allowed_types"`
+}
+
+type structuredPermission struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+type structuredCaveat struct {
+	Name       string            `json:"name"`
+	Parameters map[string]string `json:"parameters"`
+	Expression string            `json:"expression"`
+}
+
+func printStructuredSchema(schemaText string) error {
+	structured, err := structuredSchemaFromText(schemaText)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.MarshalIndent(structured, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	console.Println(string(encoded))
+	return nil
+}
+
+// structuredSchemaFromText compiles the given schema and converts the
+// compiled definitions into their structured form.
+func structuredSchemaFromText(schemaText string) (*structuredSchema, error) {
+	compiled, err := compiler.Compile(
+		compiler.InputSchema{Source: "schema", SchemaString: schemaText},
+		compiler.AllowUnprefixedObjectType(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling schema: %w", err)
+	}
+
+	structured := &structuredSchema{
+		Definitions: make([]structuredDefinition, 0, len(compiled.ObjectDefinitions)),
+		Caveats:     make([]structuredCaveat, 0, len(compiled.CaveatDefinitions)),
+	}
+
+	for _, def := range compiled.ObjectDefinitions {
+		sdef := structuredDefinition{
+			Name:        def.Name,
+			Relations:   []structuredRelation{},
+			Permissions: []structuredPermission{},
+		}
+
+		for _, rel := range def.Relation {
+			if rel.UsersetRewrite != nil {
+				expression, err := permissionExpression(rel)
+				if err != nil {
+					return nil, err
+				}
+
+				sdef.Permissions = append(sdef.Permissions, structuredPermission{Name: rel.Name, Expression: expression})
+				continue
+			}
+
+			srel := structuredRelation{Name: rel.Name, AllowedTypes: []string{}}
+			for _, allowed := range rel.GetTypeInformation().GetAllowedDirectRelations() {
+				srel.AllowedTypes = append(srel.AllowedTypes, allowedTypeString(allowed))
+			}
+			sdef.Relations = append(sdef.Relations, srel)
+		}
+
+		structured.Definitions = append(structured.Definitions, sdef)
+	}
+
+	for _, caveat := range compiled.CaveatDefinitions {
+		parameterTypes, err := caveattypes.DecodeParameterTypes(caveat.ParameterTypes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameters on caveat %s: %w", caveat.Name, err)
+		}
+
+		deserialized, err := caveats.DeserializeCaveat(caveat.SerializedExpression, parameterTypes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression on caveat %s: %w", caveat.Name, err)
+		}
+
+		expression, err := deserialized.ExprString()
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression on caveat %s: %w", caveat.Name, err)
+		}
+
+		parameters := make(map[string]string, len(parameterTypes))
+		for name, paramType := range parameterTypes {
+			parameters[name] = paramType.String()
+		}
+
+		structured.Caveats = append(structured.Caveats, structuredCaveat{
+			Name:       caveat.Name,
+			Parameters: parameters,
+			Expression: strings.TrimSpace(expression),
+		})
+	}
+
+	sort.Slice(structured.Caveats, func(i, j int) bool {
+		return structured.Caveats[i].Name < structured.Caveats[j].Name
+	})
+	return structured, nil
+}
+
+// permissionExpression returns the userset rewrite of a permission as it
+// would appear in the schema DSL.
+func permissionExpression(rel *core.Relation) (string, error) {
+	// Generate without metadata so that doc comments are not included.
+	source, err := generator.GenerateRelationSource(&core.Relation{
+		Name:           rel.Name,
+		UsersetRewrite: rel.UsersetRewrite,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error generating permission %s: %w", rel.Name, err)
+	}
+
+	_, expression, ok := strings.Cut(source, " = ")
+	if !ok {
+		return "", fmt.Errorf("error generating permission %s: missing expression", rel.Name)
+	}
+	return strings.TrimSpace(expression), nil
+}
+
+func allowedTypeString(allowed *core.AllowedRelation) string {
+	allowedType := allowed.Namespace
+	if allowed.GetPublicWildcard() != nil {
+		allowedType += ":*"
+	} else if relation := allowed.GetRelation(); relation != "" && relation != tuple.Ellipsis {
+		allowedType += "#" + relation
+	}
+
+	var traits []string
+	if allowed.GetRequiredCaveat() != nil {
+		traits = append(traits, allowed.RequiredCaveat.CaveatName)
+	}
+	if allowed.GetRequiredExpiration() != nil {
+		traits = append(traits, "expiration")
+	}
+	if len(traits) > 0 {
+		allowedType += " with " + strings.Join(traits, " and ")
+	}
+	return allowedType
+}
+
 // ReadSchema calls read schema for the client and returns the schema found.
 func ReadSchema(ctx context.Context, client client.Client) (string, error) {
 	request := &v1.ReadSchemaRequest{}
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/zed/internal/client"
+	"github.com/authzed/zed/internal/console"
+	"github.com/authzed/zed/internal/printers"
+)
+
+var schemaDependentsCmd = &cobra.Command{
+	Use:   "dependents <definition> [schema-file]",
+	Short: "List the relations and permissions that depend on a definition",
+	Long: `List the relations and permissions that depend on a definition.
+
+A relation depends on the definition when the definition (or a dependent
+relation or permission) is one of its allowed subject types. A permission
+depends on the definition when it transitively uses a dependent relation or
+permission, including through arrows.
+
+Useful to understand the impact of removing a definition. A local schema
+file can be provided in place of reading the schema from the permissions
+system.`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: GetArgs(ResourceType),
+	RunE:              schemaDependentsCmdFunc,
+}
+
+// schemaDependent is a relation or permission that depends on a definition.
+type schemaDependent struct {
+	Definition string `json:"definition"`
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+}
+
+func schemaDependentsCmdFunc(cmd *cobra.Command, args []string) error {
+	var schemaText string
+	if len(args) > 1 {
+		schemaBytes, err := os.ReadFile(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to read schema file: %w", err)
+		}
+		schemaText = string(schemaBytes)
+	} else {
+		client, err := client.NewClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		schemaText, err = ReadSchema(cmd.Context(), client)
+		if err != nil {
+			return err
+		}
+	}
+
+	dependents, err := schemaDependentsFromText(schemaText, args[0])
+	if err != nil {
+		return err
+	}
+
+	if cobrautil.MustGetBool(cmd, "json") {
+		encoded, err := json.MarshalIndent(dependents, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		console.Println(string(encoded))
+		return nil
+	}
+
+	if len(dependents) == 0 {
+		console.Printf("nothing depends on %s\n", args[0])
+		return nil
+	}
+
+	rows := make([][]string, 0, len(dependents))
+	for _, dependent := range dependents {
+		rows = append(rows, []string{dependent.Definition, dependent.Name, dependent.Kind})
+	}
+
+	var buf bytes.Buffer
+	printers.PrintTable(&buf, []string{"definition", "name", "kind"}, rows)
+	console.Print(buf.String())
+	return nil
+}
+
+// schemaDependentsFromText compiles the given schema and returns every
+// relation and permission that depends on the named definition, in schema
+// order.
+func schemaDependentsFromText(schemaText string, definition string) ([]schemaDependent, error) {
+	compiled, err := compiler.Compile(
+		compiler.InputSchema{Source: "schema", SchemaString: schemaText},
+		compiler.AllowUnprefixedObjectType(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling schema: %w", err)
+	}
+
+	definitionsByName := make(map[string]*core.NamespaceDefinition, len(compiled.ObjectDefinitions))
+	for _, def := range compiled.ObjectDefinitions {
+		definitionsByName[def.Name] = def
+	}
+	if _, ok := definitionsByName[definition]; !ok {
+		return nil, fmt.Errorf("definition %s not found in schema", definition)
+	}
+
+	// Relations and permissions can depend on each other in any order, so
+	// iterate until no new dependents are found.
+	dependent := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, def := range compiled.ObjectDefinitions {
+			for _, rel := range def.Relation {
+				key := def.Name + "#" + rel.Name
+				if dependent[key] {
+					continue
+				}
+
+				var references []string
+				if rel.UsersetRewrite != nil {
+					references = rewriteReferences(def.Name, rel.UsersetRewrite, definitionsByName)
+				} else {
+					for _, allowed := range rel.GetTypeInformation().GetAllowedDirectRelations() {
+						if allowed.Namespace == definition {
+							dependent[key] = true
+							changed = true
+							break
+						}
+						if relation := allowed.GetRelation(); relation != "" {
+							references = append(references, allowed.Namespace+"#"+relation)
+						}
+					}
+				}
+
+				for _, reference := range references {
+					if !dependent[key] && dependent[reference] {
+						dependent[key] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+
+	dependents := []schemaDependent{}
+	for _, def := range compiled.ObjectDefinitions {
+		for _, rel := range def.Relation {
+			if !dependent[def.Name+"#"+rel.Name] {
+				continue
+			}
+
+			kind := "relation"
+			if rel.UsersetRewrite != nil {
+				kind = "permission"
+			}
+			dependents = append(dependents, schemaDependent{Definition: def.Name, Name: rel.Name, Kind: kind})
+		}
+	}
+	return dependents, nil
+}
+
+// rewriteReferences returns the relations and permissions, as
+// definition#name, used by a permission's userset rewrite. Arrows reference
+// both their tupleset relation and the computed relation on each of the
+// tupleset's allowed subject types.
+func rewriteReferences(definition string, rewrite *core.UsersetRewrite, definitionsByName map[string]*core.NamespaceDefinition) []string {
+	var operation *core.SetOperation
+	switch {
+	case rewrite.GetUnion() != nil:
+		operation = rewrite.GetUnion()
+	case rewrite.GetIntersection() != nil:
+		operation = rewrite.GetIntersection()
+	default:
+		operation = rewrite.GetExclusion()
+	}
+
+	arrowReferences := func(tupleset, computed string) []string {
+		references := []string{definition + "#" + tupleset}
+		for _, rel := range definitionsByName[definition].GetRelation() {
+			if rel.Name != tupleset {
+				continue
+			}
+			for _, allowed := range rel.GetTypeInformation().GetAllowedDirectRelations() {
+				references = append(references, allowed.Namespace+"#"+computed)
+			}
+		}
+		return references
+	}
+
+	var references []string
+	for _, child := range operation.GetChild() {
+		switch {
+		case child.GetComputedUserset() != nil:
+			references = append(references, definition+"#"+child.GetComputedUserset().Relation)
+		case child.GetUsersetRewrite() != nil:
+			references = append(references, rewriteReferences(definition, child.GetUsersetRewrite(), definitionsByName)...)
+		case child.GetTupleToUserset() != nil:
+			ttu := child.GetTupleToUserset()
+			references = append(references, arrowReferences(ttu.GetTupleset().GetRelation(), ttu.GetComputedUserset().GetRelation())...)
+		case child.GetFunctionedTupleToUserset() != nil:
+			ttu := child.GetFunctionedTupleToUserset()
+			references = append(references, arrowReferences(ttu.GetTupleset().GetRelation(), ttu.GetComputedUserset().GetRelation())...)
+		}
+	}
+	return references
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructuredSchemaFromText(t *testing.T) {
+	schema := `use expiration
+
+caveat only_on_tuesday(day_of_week string) {
+	day_of_week == 'tuesday'
+}
+
+definition user {}
+
+definition group {
+	relation member: user | group#member
+}
+
+definition document {
+	/** reader can read */
+	relation reader: user | user:* | group#member with only_on_tuesday | user with expiration
+	relation writer: user
+	permission view = reader + writer - nil
+	permission edit = writer & reader
+}`
+
+	structured, err := structuredSchemaFromText(schema)
+	require.NoError(t, err)
+	require.Equal(t, &structuredSchema{
+		Definitions: []structuredDefinition{
+			{Name: "user", Relations: []structuredRelation{}, Permissions: []structuredPermission{}},
+			{
+				Name:        "group",
+				Relations:   []structuredRelation{{Name: "member", AllowedTypes: []string{"user", "group#member"}}},
+				Permissions: []structuredPermission{},
+			},
+			{
+				Name: "document",
+				Relations: []structuredRelation{
+					{Name: "reader", AllowedTypes: []string{"user", "user:*", "group#member with only_on_tuesday", "user with expiration"}},
+					{Name: "writer", AllowedTypes: []string{"user"}},
+				},
+				Permissions: []structuredPermission{
+					{Name: "view", Expression: "reader + writer - nil"},
+					{Name: "edit", Expression: "writer & reader"},
+				},
+			},
+		},
+		Caveats: []structuredCaveat{
+			{
+				Name:       "only_on_tuesday",
+				Parameters: map[string]string{"day_of_week": "string"},
+				Expression: `day_of_week == "tuesday"`,
+			},
+		},
+	}, structured)
+
+	_, err = structuredSchemaFromText("definition user { relation foo: missing }")
+	require.ErrorContains(t, err, "error compiling schema")
+}
+
+func TestSchemaDependentsFromText(t *testing.T) {
+	schema := `definition user {}
+
+definition team {}
+
+definition group {
+	relation member: user | group#member
+}
+
+definition folder {
+	relation owner: team
+	relation viewer: group#member
+	permission view = viewer + owner
+}
+
+definition document {
+	relation parent: folder
+	relation writer: team
+	permission view = parent->view
+	permission edit = writer
+}`
+
+	dependents, err := schemaDependentsFromText(schema, "user")
+	require.NoError(t, err)
+	require.Equal(t, []schemaDependent{
+		{Definition: "group", Name: "member", Kind: "relation"},
+		{Definition: "folder", Name: "viewer", Kind: "relation"},
+		{Definition: "folder", Name: "view", Kind: "permission"},
+		{Definition: "document", Name: "view", Kind: "permission"},
+	}, dependents)
+
+	dependents, err = schemaDependentsFromText(schema, "folder")
+	require.NoError(t, err)
+	require.Equal(t, []schemaDependent{
+		{Definition: "document", Name: "parent", Kind: "relation"},
+		{Definition: "document", Name: "view", Kind: "permission"},
+	}, dependents)
+
+	dependents, err = schemaDependentsFromText(schema, "document")
+	require.NoError(t, err)
+	require.Empty(t, dependents)
+
+	_, err = schemaDependentsFromText(schema, "unknown")
+	require.ErrorContains(t, err, "definition unknown not found in schema")
+}
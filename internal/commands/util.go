@@ -1,18 +1,27 @@
 package commands
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"text/template"
 
 	"github.com/TylerBrock/colorjson"
 	"github.com/authzed/authzed-go/pkg/requestmeta"
 	"github.com/jzelinskie/cobrautil/v2"
 	"github.com/jzelinskie/stringz"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/zed/internal/console"
 )
 
 // ParseSubject parses the given subject string into its namespace, object ID
@@ -36,25 +45,90 @@ func ParseType(s string) (namespace, relation string) {
 	return
 }
 
+func registerCaveatContextFileFlags(flags *pflag.FlagSet) {
+	flags.String("caveat-context-file", "", "path to a file containing the caveat context, in JSON form")
+	flags.Bool("caveat-context-merge", false, "deep-merge --caveat-context over --caveat-context-file instead of requiring only one of them")
+}
+
 // GetCaveatContext returns the entered caveat caveat, if any.
 func GetCaveatContext(cmd *cobra.Command) (*structpb.Struct, error) {
 	contextString := cobrautil.MustGetString(cmd, "caveat-context")
-	if len(contextString) == 0 {
+	contextFile := cobrautil.MustGetStringExpanded(cmd, "caveat-context-file")
+	if contextString == "" && contextFile == "" {
 		return nil, nil
 	}
 
-	return ParseCaveatContext(contextString)
+	if contextString != "" && contextFile != "" && !cobrautil.MustGetBool(cmd, "caveat-context-merge") {
+		return nil, errors.New("cannot specify both --caveat-context and --caveat-context-file without --caveat-context-merge")
+	}
+
+	var contextMap map[string]any
+	if contextFile != "" {
+		contextBytes, err := os.ReadFile(contextFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read caveat context file: %w", err)
+		}
+
+		contextMap, err = parseCaveatContextMap(string(contextBytes))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if contextString != "" {
+		inlineContextMap, err := parseCaveatContextMap(contextString)
+		if err != nil {
+			return nil, err
+		}
+		contextMap = mergeCaveatContexts(contextMap, inlineContextMap)
+	}
+
+	return caveatContextFromMap(contextMap)
+}
+
+// mergeCaveatContexts deep-merges override into base, with the values in
+// override taking precedence.
+func mergeCaveatContexts(base, override map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range override {
+		baseMap, baseIsMap := merged[key].(map[string]any)
+		overrideMap, overrideIsMap := value.(map[string]any)
+		if baseIsMap && overrideIsMap {
+			merged[key] = mergeCaveatContexts(baseMap, overrideMap)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
 }
 
 // ParseCaveatContext parses the given context JSON string into caveat context,
 // if valid.
 func ParseCaveatContext(contextString string) (*structpb.Struct, error) {
+	contextMap, err := parseCaveatContextMap(contextString)
+	if err != nil {
+		return nil, err
+	}
+
+	return caveatContextFromMap(contextMap)
+}
+
+// parseCaveatContextMap parses the given context JSON string into a map.
+func parseCaveatContextMap(contextString string) (map[string]any, error) {
 	contextMap := map[string]any{}
 	err := json.Unmarshal([]byte(contextString), &contextMap)
 	if err != nil {
 		return nil, fmt.Errorf("invalid caveat context JSON: %w", err)
 	}
+	return contextMap, nil
+}
 
+// caveatContextFromMap converts the parsed context map into caveat context.
+func caveatContextFromMap(contextMap map[string]any) (*structpb.Struct, error) {
 	context, err := structpb.NewStruct(contextMap)
 	if err != nil {
 		return nil, fmt.Errorf("could not construct caveat context: %w", err)
@@ -62,6 +136,36 @@ func ParseCaveatContext(contextString string) (*structpb.Struct, error) {
 	return context, err
 }
 
+// CompactProto returns the given protocol buffer as a single line of JSON.
+func CompactProto(m proto.Message) ([]byte, error) {
+	encoded, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// protojson randomly varies its whitespace, so it is always compacted.
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, encoded); err != nil {
+		return nil, err
+	}
+	return compacted.Bytes(), nil
+}
+
+// registerCompactFlag registers the --compact flag of commands that output
+// JSON messages.
+func registerCompactFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("compact", false, "with --json, output each message as a single line of JSON instead of indented")
+}
+
+// jsonProtoFromCmd returns the given protocol buffer as JSON, on a single line
+// if --compact was given, or pretty otherwise.
+func jsonProtoFromCmd(cmd *cobra.Command, m proto.Message) ([]byte, error) {
+	if cobrautil.MustGetBool(cmd, "compact") {
+		return CompactProto(m)
+	}
+	return PrettyProto(m)
+}
+
 // PrettyProto returns the given protocol buffer formatted into pretty text.
 func PrettyProto(m proto.Message) ([]byte, error) {
 	encoded, err := protojson.Marshal(m)
@@ -84,6 +188,47 @@ func PrettyProto(m proto.Message) ([]byte, error) {
 	return pretty, nil
 }
 
+// ConfirmPrompt defines an (overridable) function for asking the operator, on
+// stderr, to confirm an operation. It returns whether they answered yes.
+var ConfirmPrompt = func(prompt string) (bool, error) {
+	console.Errorf("%s", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// ConfirmInteractive defines an (overridable) function reporting whether
+// stdin is a terminal, on which a confirmation prompt can be answered.
+var ConfirmInteractive = func() bool {
+	return isFileTerminal(os.Stdin)
+}
+
+// ConfirmDestructive asks the operator to confirm the destructive operation,
+// unless --yes is set. Without a terminal to prompt on, --yes is required,
+// rather than waiting on an answer that will never come.
+func ConfirmDestructive(cmd *cobra.Command, operation string) error {
+	if cobrautil.MustGetBool(cmd, "yes") {
+		return nil
+	}
+
+	if !ConfirmInteractive() {
+		return fmt.Errorf("%s requires confirmation: set --yes when not running in a terminal", operation)
+	}
+
+	confirmed, err := ConfirmPrompt(operation + "? [y/N] ")
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return fmt.Errorf("aborted: %s was not confirmed", operation)
+	}
+	return nil
+}
+
 // InjectRequestID adds the value of the --request-id flag to the
 // context of the given command.
 func InjectRequestID(cmd *cobra.Command, _ []string) error {
@@ -95,3 +240,45 @@ func InjectRequestID(cmd *cobra.Command, _ []string) error {
 
 	return nil
 }
+
+// registerOutputTemplateFlag registers the --output-template flag, which is
+// mutually exclusive with --json.
+func registerOutputTemplateFlag(cmd *cobra.Command, fields string) {
+	cmd.Flags().String("output-template", "", "Go text/template used to render each result, with fields from the "+fields)
+	cmd.MarkFlagsMutuallyExclusive("json", "output-template")
+}
+
+// registerAsRelationshipsFlag registers the --as-relationships flag, which
+// prints lookup results in the format read by the --from-lookup flag of the
+// relationship write commands.
+func registerAsRelationshipsFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("as-relationships", false, "print each result as a 'resource:id permission subject:id' line, for piping into 'zed relationship touch --from-lookup <relation>'")
+	cmd.MarkFlagsMutuallyExclusive("as-relationships", "json", "output-template")
+}
+
+// outputTemplateFromCmd parses the template provided via --output-template,
+// returning nil if none was provided.
+func outputTemplateFromCmd(cmd *cobra.Command) (*template.Template, error) {
+	text := cobrautil.MustGetString(cmd, "output-template")
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("output-template").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// printWithTemplate renders the result through the template and prints it as
+// a single line.
+func printWithTemplate(tmpl *template.Template, result any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, result); err != nil {
+		return fmt.Errorf("error rendering output template: %w", err)
+	}
+
+	console.Println(buf.String())
+	return nil
+}
@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/zed/internal/console"
+	zedtesting "github.com/authzed/zed/internal/testing"
+)
+
+func TestOutputTemplate(t *testing.T) {
+	previous := console.Println
+	defer func() {
+		console.Println = previous
+	}()
+	var lines []any
+	console.Println = func(values ...any) {
+		lines = append(lines, values...)
+	}
+
+	rel := tuple.MustParseV1Rel("test/resource:1#reader@test/user:2")
+
+	for _, tt := range []struct {
+		name        string
+		template    string
+		expected    []any
+		expectedErr string
+	}{
+		{name: "no template"},
+		{
+			name:     "renders fields",
+			template: "{{.Resource.ObjectType}}:{{.Resource.ObjectId}} {{.Subject.Object.ObjectId}}",
+			expected: []any{"test/resource:1 2"},
+		},
+		{
+			name:        "invalid template",
+			template:    "{{.Resource",
+			expectedErr: "invalid output template",
+		},
+		{
+			name:        "unknown field",
+			template:    "{{.Unknown}}",
+			expectedErr: "error rendering output template",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			lines = nil
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t, zedtesting.StringFlag{FlagName: "output-template", FlagValue: tt.template})
+
+			tmpl, err := outputTemplateFromCmd(cmd)
+			if err == nil && tmpl != nil {
+				err = printWithTemplate(tmpl, rel)
+			}
+
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, lines)
+		})
+	}
+}
+
+func TestGetCaveatContext(t *testing.T) {
+	f := fileFromStrings(t, []string{`{"ip": "10.0.0.1", "nested": {"a": 1, "b": 2}}`})
+
+	for _, tt := range []struct {
+		name        string
+		inline      string
+		file        string
+		merge       bool
+		expected    map[string]any
+		expectedErr string
+	}{
+		{"none", "", "", false, nil, ""},
+		{"inline only", `{"ip": "10.0.0.2"}`, "", false, map[string]any{"ip": "10.0.0.2"}, ""},
+		{"file only", "", f.Name(), false, map[string]any{"ip": "10.0.0.1", "nested": map[string]any{"a": 1.0, "b": 2.0}}, ""},
+		{"both without merge", `{"ip": "10.0.0.2"}`, f.Name(), false, nil, "without --caveat-context-merge"},
+		{
+			"merged with inline precedence",
+			`{"ip": "10.0.0.2", "nested": {"b": 3, "c": 4}}`,
+			f.Name(),
+			true,
+			map[string]any{"ip": "10.0.0.2", "nested": map[string]any{"a": 1.0, "b": 3.0, "c": 4.0}},
+			"",
+		},
+		{"invalid inline", `{`, "", false, nil, "invalid caveat context JSON"},
+		{"missing file", "", "/does/not/exist.json", false, nil, "failed to read caveat context file"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t,
+				zedtesting.StringFlag{FlagName: "caveat-context", FlagValue: tt.inline},
+				zedtesting.StringFlag{FlagName: "caveat-context-file", FlagValue: tt.file},
+				zedtesting.BoolFlag{FlagName: "caveat-context-merge", FlagValue: tt.merge})
+
+			caveatContext, err := GetCaveatContext(cmd)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			if tt.expected == nil {
+				require.Nil(t, caveatContext)
+				return
+			}
+			require.Equal(t, tt.expected, caveatContext.AsMap())
+		})
+	}
+}
+
+func TestJSONProtoFromCmd(t *testing.T) {
+	msg := &v1.LookupResourcesResponse{
+		ResourceObjectId: "1",
+		Permissionship:   v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION,
+	}
+
+	cmd := zedtesting.CreateTestCobraCommandWithFlagValue(t, zedtesting.BoolFlag{FlagName: "compact", FlagValue: true})
+	encoded, err := jsonProtoFromCmd(cmd, msg)
+	require.NoError(t, err)
+	require.Equal(t, `{"resourceObjectId":"1","permissionship":"LOOKUP_PERMISSIONSHIP_HAS_PERMISSION"}`, string(encoded))
+
+	cmd = zedtesting.CreateTestCobraCommandWithFlagValue(t, zedtesting.BoolFlag{FlagName: "compact"})
+	encoded, err = jsonProtoFromCmd(cmd, msg)
+	require.NoError(t, err)
+	require.Contains(t, string(encoded), "\n")
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"container/list"
 	"context"
 	"fmt"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"github.com/authzed/zed/internal/console"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +23,8 @@ var (
 	watchRevision            string
 	watchTimestamps          bool
 	watchRelationshipFilters []string
+	watchOnlyChanged         bool
+	watchChangeWindow        int
 )
 
 func RegisterWatchCmd(rootCmd *cobra.Command) *cobra.Command {
@@ -38,6 +42,8 @@ func RegisterWatchRelationshipCmd(parentCmd *cobra.Command) *cobra.Command {
 	watchRelationshipsCmd.Flags().StringVar(&watchRevision, "revision", "", "optional revision at which to start watching")
 	watchRelationshipsCmd.Flags().BoolVar(&watchTimestamps, "timestamp", false, "shows timestamp of incoming update events")
 	watchRelationshipsCmd.Flags().StringSliceVar(&watchRelationshipFilters, "filter", nil, "optional filter(s) for the watch stream. Example: `optional_resource_type:optional_resource_id_or_prefix#optional_relation@optional_subject_filter`")
+	watchRelationshipsCmd.Flags().BoolVar(&watchOnlyChanged, "only-changed", false, "suppress updates that do not change the state of a recently seen relationship (e.g. repeated touches)")
+	watchRelationshipsCmd.Flags().IntVar(&watchChangeWindow, "only-changed-window", 10_000, "number of recently seen relationships remembered when --only-changed is set")
 	return watchRelationshipsCmd
 }
 
@@ -57,6 +63,15 @@ var watchRelationshipsCmd = &cobra.Command{
 }
 
 func watchCmdFunc(cmd *cobra.Command, _ []string) error {
+	var tracker *changeTracker
+	if watchOnlyChanged {
+		var err error
+		tracker, err = newChangeTracker(watchChangeWindow)
+		if err != nil {
+			return err
+		}
+	}
+
 	console.Printf("starting watch stream over types %v and revision %v\n", watchObjectTypes, watchRevision)
 
 	cli, err := client.NewClient(cmd)
@@ -107,6 +122,16 @@ func watchCmdFunc(cmd *cobra.Command, _ []string) error {
 			}
 
 			for _, update := range resp.Updates {
+				if tracker != nil {
+					changed, err := tracker.changed(update)
+					if err != nil {
+						return err
+					}
+					if !changed {
+						continue
+					}
+				}
+
 				if watchTimestamps {
 					console.Printf("%v: ", time.Now())
 				}
@@ -140,6 +165,64 @@ func watchCmdFunc(cmd *cobra.Command, _ []string) error {
 	}
 }
 
+// changeTracker remembers the last known state of recently seen relationships
+// in order to detect updates which do not change anything, such as a TOUCH of
+// a relationship that was already written with the same caveat. Once window
+// relationships are remembered, the least recently seen one is forgotten.
+type changeTracker struct {
+	window int
+	recent *list.List
+	seen   map[string]*list.Element
+}
+
+// trackedRelationship is the last known state of a relationship remembered by
+// a changeTracker.
+type trackedRelationship struct {
+	key   string
+	state string
+}
+
+func newChangeTracker(window int) (*changeTracker, error) {
+	if window < 1 {
+		return nil, fmt.Errorf("invalid --only-changed-window %d: must be greater than zero", window)
+	}
+
+	return &changeTracker{window: window, recent: list.New(), seen: make(map[string]*list.Element, window)}, nil
+}
+
+// changed returns whether the update transitions the relationship into a
+// different state than the one last seen.
+func (ct *changeTracker) changed(update *v1.RelationshipUpdate) (bool, error) {
+	key := tuple.V1StringRelationshipWithoutCaveatOrExpiration(update.Relationship)
+
+	// The state of a relationship is either deleted, or its full form including
+	// the caveat, so that changing the caveat of a relationship is reported.
+	var state string
+	if update.Operation != v1.RelationshipUpdate_OPERATION_DELETE {
+		var err error
+		state, err = tuple.V1StringRelationship(update.Relationship)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if element, ok := ct.seen[key]; ok {
+		tracked := element.Value.(*trackedRelationship)
+		changed := tracked.state != state
+		tracked.state = state
+		ct.recent.MoveToFront(element)
+		return changed, nil
+	}
+
+	ct.seen[key] = ct.recent.PushFront(&trackedRelationship{key: key, state: state})
+	if ct.recent.Len() > ct.window {
+		oldest := ct.recent.Back()
+		ct.recent.Remove(oldest)
+		delete(ct.seen, oldest.Value.(*trackedRelationship).key)
+	}
+	return true, nil
+}
+
 func parseRelationshipFilter(relFilterStr string) (*v1.RelationshipFilter, error) {
 	relFilter := &v1.RelationshipFilter{}
 	pieces := strings.Split(relFilterStr, "@")
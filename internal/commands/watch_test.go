@@ -5,6 +5,11 @@ import (
 	"testing"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/zed/internal/client"
 )
 
 func TestParseRelationshipFilter(t *testing.T) {
@@ -108,3 +113,50 @@ func TestParseRelationshipFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestChangeTracker(t *testing.T) {
+	tracker, err := newChangeTracker(2)
+	require.NoError(t, err)
+
+	changed := func(op v1.RelationshipUpdate_Operation, rel string) bool {
+		changed, err := tracker.changed(&v1.RelationshipUpdate{Operation: op, Relationship: tuple.MustParseV1Rel(rel)})
+		require.NoError(t, err)
+		return changed
+	}
+
+	require.True(t, changed(v1.RelationshipUpdate_OPERATION_TOUCH, "res:1#viewer@user:1"))
+	require.False(t, changed(v1.RelationshipUpdate_OPERATION_TOUCH, "res:1#viewer@user:1"))
+	require.False(t, changed(v1.RelationshipUpdate_OPERATION_CREATE, "res:1#viewer@user:1"))
+	require.True(t, changed(v1.RelationshipUpdate_OPERATION_TOUCH, "res:1#viewer@user:1[somecaveat]"))
+	require.True(t, changed(v1.RelationshipUpdate_OPERATION_DELETE, "res:1#viewer@user:1"))
+	require.False(t, changed(v1.RelationshipUpdate_OPERATION_DELETE, "res:1#viewer@user:1"))
+
+	// Relationships falling out of the window are reported again.
+	require.True(t, changed(v1.RelationshipUpdate_OPERATION_TOUCH, "res:2#viewer@user:1"))
+	require.True(t, changed(v1.RelationshipUpdate_OPERATION_TOUCH, "res:3#viewer@user:1"))
+	require.True(t, changed(v1.RelationshipUpdate_OPERATION_DELETE, "res:1#viewer@user:1"))
+
+	// Seeing a relationship again keeps it in the window.
+	require.False(t, changed(v1.RelationshipUpdate_OPERATION_DELETE, "res:1#viewer@user:1"))
+	require.True(t, changed(v1.RelationshipUpdate_OPERATION_TOUCH, "res:4#viewer@user:1"))
+	require.False(t, changed(v1.RelationshipUpdate_OPERATION_DELETE, "res:1#viewer@user:1"))
+
+	_, err = newChangeTracker(0)
+	require.Error(t, err)
+}
+
+func TestWatchCmdFuncValidatesChangeWindowBeforeDialing(t *testing.T) {
+	originalClient := client.NewClient
+	client.NewClient = func(*cobra.Command) (client.Client, error) {
+		require.Fail(t, "unexpected client creation")
+		return nil, nil
+	}
+	originalOnlyChanged, originalWindow := watchOnlyChanged, watchChangeWindow
+	watchOnlyChanged, watchChangeWindow = true, 0
+	defer func() {
+		client.NewClient = originalClient
+		watchOnlyChanged, watchChangeWindow = originalOnlyChanged, originalWindow
+	}()
+
+	require.ErrorContains(t, watchCmdFunc(&cobra.Command{}, nil), "invalid --only-changed-window 0")
+}
@@ -33,6 +33,50 @@ var Println = func(values ...any) {
 	}
 }
 
+// ProgressMode controls whether and where progress bars are rendered.
+type ProgressMode string
+
+const (
+	// ProgressAuto renders progress bars to stderr only when it is a terminal.
+	ProgressAuto ProgressMode = "auto"
+
+	// ProgressNone never renders progress bars.
+	ProgressNone ProgressMode = "none"
+
+	// ProgressStderr always renders progress bars to stderr, even when it is
+	// not a terminal.
+	ProgressStderr ProgressMode = "stderr"
+)
+
+var progressMode = ProgressAuto
+
+// SetProgressMode sets the mode used by all subsequently created progress bars.
+func SetProgressMode(mode string) error {
+	switch m := ProgressMode(mode); m {
+	case ProgressAuto, ProgressNone, ProgressStderr:
+		progressMode = m
+		return nil
+	default:
+		return fmt.Errorf("unknown progress mode %q: should be one of auto, none, stderr", mode)
+	}
+}
+
+// ProgressEnabled reports whether progress output has not been disabled.
+func ProgressEnabled() bool {
+	return progressMode != ProgressNone
+}
+
+func progressVisible() bool {
+	switch progressMode {
+	case ProgressNone:
+		return false
+	case ProgressStderr:
+		return true
+	default:
+		return isatty.IsTerminal(os.Stderr.Fd())
+	}
+}
+
 // CreateProgressBar creates a new progress bar with the given description and defaults adjusted to zed's UX experience
 func CreateProgressBar(description string) *progressbar.ProgressBar {
 	bar := progressbar.NewOptions(-1,
@@ -40,7 +84,7 @@ func CreateProgressBar(description string) *progressbar.ProgressBar {
 		progressbar.OptionSetRenderBlankState(true),
 		progressbar.OptionSetVisibility(false),
 	)
-	if isatty.IsTerminal(os.Stderr.Fd()) {
+	if progressVisible() {
 		bar = progressbar.NewOptions64(-1,
 			progressbar.OptionSetDescription(description),
 			progressbar.OptionSetWriter(os.Stderr),
@@ -0,0 +1,22 @@
+package console
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetProgressMode(t *testing.T) {
+	defer func() {
+		progressMode = ProgressAuto
+	}()
+
+	require.NoError(t, SetProgressMode("none"))
+	require.False(t, progressVisible())
+
+	require.NoError(t, SetProgressMode("stderr"))
+	require.True(t, progressVisible())
+
+	require.ErrorContains(t, SetProgressMode("stdout"), `unknown progress mode "stdout"`)
+	require.Equal(t, ProgressStderr, progressMode)
+}
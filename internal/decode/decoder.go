@@ -23,6 +23,9 @@ import (
 
 var playgroundPattern = regexp.MustCompile("^.*/s/.*/schema|relationships|assertions|expected.*$")
 
+// stdin is the reader used for the "-" argument; overridden in tests.
+var stdin io.Reader = os.Stdin
+
 // SchemaRelationships holds the schema (as a string) and a list of
 // relationships (as a string) in the format from the devtools download API.
 type SchemaRelationships struct {
@@ -34,7 +37,9 @@ type SchemaRelationships struct {
 type Func func(out interface{}) ([]byte, bool, error)
 
 // DecoderForURL returns the appropriate decoder for a given URL.
-// Some URLs have special handling to dereference to the actual file.
+// Some URLs have special handling to dereference to the actual file and
+// local directories are decoded as a single validation file.
+// A "-" URL reads either a schema or a validation file from stdin.
 func DecoderForURL(u *url.URL) (d Func, err error) {
 	switch s := u.Scheme; s {
 	case "file":
@@ -42,6 +47,10 @@ func DecoderForURL(u *url.URL) (d Func, err error) {
 	case "http", "https":
 		d = httpDecoder(u)
 	case "":
+		if u.Path == "-" {
+			d = stdinDecoder()
+			return
+		}
 		d = fileDecoder(u)
 	default:
 		err = fmt.Errorf("%s scheme not supported", s)
@@ -50,6 +59,10 @@ func DecoderForURL(u *url.URL) (d Func, err error) {
 }
 
 func fileDecoder(u *url.URL) Func {
+	if info, err := os.Stat(u.Path); err == nil && info.IsDir() {
+		return directoryDecoder(u.Path)
+	}
+
 	return func(out interface{}) ([]byte, bool, error) {
 		file, err := os.Open(u.Path)
 		if err != nil {
@@ -64,6 +77,19 @@ func fileDecoder(u *url.URL) Func {
 	}
 }
 
+func stdinDecoder() Func {
+	return func(out interface{}) ([]byte, bool, error) {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read from stdin: %w", err)
+		}
+		// A schemaFile referenced from stdin is resolved relative to the
+		// working directory.
+		isOnlySchema, err := unmarshalAsYAMLOrSchemaWithFile(data, out, "-")
+		return data, isOnlySchema, err
+	}
+}
+
 func httpDecoder(u *url.URL) Func {
 	rewriteURL(u)
 	return directHTTPDecoder(u)
@@ -2,6 +2,7 @@ package decode
 
 import (
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/authzed/spicedb/pkg/validationfile"
@@ -173,3 +174,49 @@ schema:
 		})
 	}
 }
+
+func TestStdinDecoder(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           string
+		isOnlySchema bool
+		outSchema    string
+	}{
+		{
+			name:         "schema",
+			in:           `definition user {}`,
+			isOnlySchema: true,
+			outSchema:    `definition user {}`,
+		},
+		{
+			name: "validation file",
+			in: `schema: |-
+  definition user {}
+relationships: ""
+`,
+			isOnlySchema: false,
+			outSchema:    `definition user {}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := stdin
+			defer func() {
+				stdin = previous
+			}()
+			stdin = strings.NewReader(tt.in)
+
+			u, err := url.Parse("-")
+			require.NoError(t, err)
+			d, err := DecoderForURL(u)
+			require.NoError(t, err)
+
+			block := validationfile.ValidationFile{}
+			data, isOnlySchema, err := d(&block)
+			require.NoError(t, err)
+			require.Equal(t, tt.in, string(data))
+			require.Equal(t, tt.isOnlySchema, isOnlySchema)
+			require.Equal(t, tt.outSchema, block.Schema.Schema)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package decode
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/validationfile"
+	"github.com/authzed/spicedb/pkg/validationfile/blocks"
+	"gopkg.in/yaml.v3"
+)
+
+var directoryFileExtensions = []string{".yaml", ".yml", ".zaml", ".zed"}
+
+// directoryDecoder decodes every validation file (.yaml, .yml, .zaml) and
+// schema file (.zed) directly within the directory as one logical validation
+// file:
+//
+//   - exactly one file must define the schema, either inline, via schemaFile
+//     or by being a schema file
+//   - relationships are unioned, with duplicates across files removed
+//   - assertions and expected relations are concatenated
+//
+// The returned bytes are the contents of every file, starting with the one
+// that defined the schema, and the source positions of assertions and
+// expected relations are offset to refer to their lines within them.
+func directoryDecoder(dir string) Func {
+	return func(out interface{}) ([]byte, bool, error) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, false, err
+		}
+
+		var (
+			files       []directoryFile
+			schemaIndex = -1
+		)
+		for _, entry := range entries {
+			if entry.IsDir() || !slices.Contains(directoryFileExtensions, filepath.Ext(entry.Name())) {
+				continue
+			}
+
+			filename := filepath.Join(dir, entry.Name())
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				return nil, false, err
+			}
+
+			file := directoryFile{name: filename, data: data}
+			file.isOnlySchema, err = unmarshalDirectoryFile(data, &file.parsed, filename)
+			if err != nil {
+				return data, file.isOnlySchema, fmt.Errorf("failed to decode %s: %w", filename, err)
+			}
+
+			if file.parsed.Schema.Schema != "" {
+				if schemaIndex >= 0 {
+					return nil, false, fmt.Errorf("schema defined in both %s and %s; a directory must contain exactly one schema", files[schemaIndex].name, filename)
+				}
+				schemaIndex = len(files)
+			}
+			files = append(files, file)
+		}
+
+		if schemaIndex < 0 {
+			return nil, false, fmt.Errorf("no schema found in directory %s", dir)
+		}
+
+		// The schema file comes first, so that schema errors keep referring to
+		// its lines in the merged contents.
+		schemaFile := files[schemaIndex]
+		files = append([]directoryFile{schemaFile}, slices.Delete(files, schemaIndex, schemaIndex+1)...)
+		isOnlySchema := len(files) == 1 && files[0].isOnlySchema
+
+		var (
+			merged   validationfile.ValidationFile
+			contents bytes.Buffer
+			seenRels = make(map[string]struct{})
+		)
+		merged.Schema = files[0].parsed.Schema
+		for i, file := range files {
+			// Files are preceded by a comment naming them, except for the
+			// schema file when it is alone or a validation file: the comment
+			// takes the place of the 'schema:' line expected by schema errors.
+			if i > 0 || (!isOnlySchema && file.isOnlySchema) {
+				fmt.Fprintf(&contents, "# %s\n", file.name)
+			}
+			offsetSourcePositions(&file.parsed, bytes.Count(contents.Bytes(), []byte("\n")))
+			contents.Write(file.data)
+			if !bytes.HasSuffix(file.data, []byte("\n")) {
+				contents.WriteByte('\n')
+			}
+
+			for _, rel := range file.parsed.Relationships.Relationships {
+				relString := tuple.MustString(rel)
+				if _, ok := seenRels[relString]; ok {
+					continue
+				}
+				seenRels[relString] = struct{}{}
+				merged.Relationships.Relationships = append(merged.Relationships.Relationships, rel)
+			}
+
+			merged.Assertions.AssertTrue = append(merged.Assertions.AssertTrue, file.parsed.Assertions.AssertTrue...)
+			merged.Assertions.AssertCaveated = append(merged.Assertions.AssertCaveated, file.parsed.Assertions.AssertCaveated...)
+			merged.Assertions.AssertFalse = append(merged.Assertions.AssertFalse, file.parsed.Assertions.AssertFalse...)
+
+			for objectRelation, subjects := range file.parsed.ExpectedRelations.ValidationMap {
+				if merged.ExpectedRelations.ValidationMap == nil {
+					merged.ExpectedRelations.ValidationMap = make(blocks.ValidationMap)
+				}
+				merged.ExpectedRelations.ValidationMap[objectRelation] = append(merged.ExpectedRelations.ValidationMap[objectRelation], subjects...)
+			}
+		}
+
+		relStrings := make([]string, 0, len(merged.Relationships.Relationships))
+		for _, rel := range merged.Relationships.Relationships {
+			relStrings = append(relStrings, tuple.MustString(rel))
+		}
+		merged.Relationships.RelationshipsString = strings.Join(relStrings, "\n")
+
+		switch out := out.(type) {
+		case *validationfile.ValidationFile:
+			*out = merged
+		case *SchemaRelationships:
+			out.Schema = merged.Schema.Schema
+			out.Relationships = merged.Relationships.RelationshipsString
+		default:
+			return nil, false, fmt.Errorf("cannot decode a directory into %T", out)
+		}
+		return contents.Bytes(), isOnlySchema, nil
+	}
+}
+
+// directoryFile is a single decoded file within a directory.
+type directoryFile struct {
+	name         string
+	data         []byte
+	parsed       validationfile.ValidationFile
+	isOnlySchema bool
+}
+
+// offsetSourcePositions moves the source positions of the assertions and
+// expected relations of the file down by the number of lines.
+func offsetSourcePositions(parsed *validationfile.ValidationFile, lines int) {
+	if lines == 0 {
+		return
+	}
+
+	for _, assertions := range [][]blocks.Assertion{parsed.Assertions.AssertTrue, parsed.Assertions.AssertCaveated, parsed.Assertions.AssertFalse} {
+		for i := range assertions {
+			assertions[i].SourcePosition.LineNumber += lines
+		}
+	}
+
+	validationMap := make(blocks.ValidationMap, len(parsed.ExpectedRelations.ValidationMap))
+	for objectRelation, subjects := range parsed.ExpectedRelations.ValidationMap {
+		objectRelation.SourcePosition.LineNumber += lines
+		for i := range subjects {
+			subjects[i].SourcePosition.LineNumber += lines
+		}
+		validationMap[objectRelation] = subjects
+	}
+	parsed.ExpectedRelations.ValidationMap = validationMap
+}
+
+// unmarshalDirectoryFile decodes a single file within a directory. Unlike a
+// standalone file, a YAML file within a directory need not define a schema.
+func unmarshalDirectoryFile(data []byte, out *validationfile.ValidationFile, filename string) (bool, error) {
+	if filepath.Ext(filename) == ".zed" {
+		return true, compileSchemaFromData(data, out)
+	}
+
+	if strings.Contains(string(data), "schemaFile:") {
+		return unmarshalAsYAMLOrSchemaWithFile(data, out, filename)
+	}
+
+	return false, yaml.Unmarshal(data, out)
+}
@@ -0,0 +1,102 @@
+package decode
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/authzed/spicedb/pkg/validationfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectoryDecoder(t *testing.T) {
+	writeFiles := func(t *testing.T, files map[string]string) string {
+		dir := t.TempDir()
+		for name, contents := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o600))
+		}
+		return dir
+	}
+
+	t.Run("merges files", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"schema.zed": "definition user {}\ndefinition document {\n\trelation reader: user\n}",
+			"a.yaml": `relationships: |-
+  document:1#reader@user:1
+  document:1#reader@user:2
+assertions:
+  assertTrue:
+    - document:1#reader@user:1
+`,
+			"b.yaml": `relationships: |-
+  document:1#reader@user:2
+  document:2#reader@user:3
+assertions:
+  assertFalse:
+    - document:2#reader@user:1
+`,
+			"README.md": "ignored",
+		})
+
+		decoder, err := DecoderForURL(&url.URL{Path: dir})
+		require.NoError(t, err)
+
+		var parsed validationfile.ValidationFile
+		data, isOnlySchema, err := decoder(&parsed)
+		require.NoError(t, err)
+		require.False(t, isOnlySchema)
+		require.Contains(t, string(data), "definition document")
+
+		// Source positions refer to the lines of the merged contents.
+		lines := strings.Split(string(data), "\n")
+		require.Equal(t, "definition user {}", lines[1])
+		require.Equal(t, "    - document:1#reader@user:1", lines[parsed.Assertions.AssertTrue[0].SourcePosition.LineNumber-1])
+		require.Equal(t, "    - document:2#reader@user:1", lines[parsed.Assertions.AssertFalse[0].SourcePosition.LineNumber-1])
+		require.Contains(t, parsed.Schema.Schema, "definition document")
+		require.Len(t, parsed.Relationships.Relationships, 3)
+		require.Equal(t, "document:1#reader@user:1\ndocument:1#reader@user:2\ndocument:2#reader@user:3", parsed.Relationships.RelationshipsString)
+		require.Len(t, parsed.Assertions.AssertTrue, 1)
+		require.Len(t, parsed.Assertions.AssertFalse, 1)
+
+		var p SchemaRelationships
+		_, _, err = decoder(&p)
+		require.NoError(t, err)
+		require.Contains(t, p.Schema, "definition user")
+		require.Equal(t, parsed.Relationships.RelationshipsString, p.Relationships)
+	})
+
+	t.Run("schema alone", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"schema.zed": "definition user {}",
+		})
+
+		var parsed validationfile.ValidationFile
+		data, isOnlySchema, err := directoryDecoder(dir)(&parsed)
+		require.NoError(t, err)
+		require.True(t, isOnlySchema)
+		require.Equal(t, "definition user {}\n", string(data))
+	})
+
+	t.Run("requires a schema", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"a.yaml": "relationships: |-\n  document:1#reader@user:1\n",
+		})
+
+		var parsed validationfile.ValidationFile
+		_, _, err := directoryDecoder(dir)(&parsed)
+		require.ErrorContains(t, err, "no schema found")
+	})
+
+	t.Run("rejects multiple schemas", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"a.zed":  "definition user {}",
+			"b.yaml": "schema: |-\n  definition user {}\n",
+		})
+
+		var parsed validationfile.ValidationFile
+		_, _, err := directoryDecoder(dir)(&parsed)
+		require.ErrorContains(t, err, "exactly one schema")
+	})
+}
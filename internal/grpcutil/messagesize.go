@@ -0,0 +1,181 @@
+package grpcutil
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Compile-time assertion that the message size interceptors implement the
+// gRPC client interceptor interfaces.
+var (
+	_ grpc.UnaryClientInterceptor  = UnaryAutoGrowMessageSize
+	_ grpc.StreamClientInterceptor = StreamAutoGrowMessageSize
+)
+
+var sizeErrorRegEx = regexp.MustCompile(`received message larger than max \((\d+) vs. (\d+)\)`)
+
+// maxMessageSizeGrowths bounds how many times a single call is retried with a
+// larger maximum message size.
+const maxMessageSizeGrowths = 3
+
+// NecessaryMessageSize returns the size, in bytes, of the message reported by
+// a ResourceExhausted "received message larger than max" error.
+func NecessaryMessageSize(err error) (int, bool) {
+	if status.Code(err) != codes.ResourceExhausted {
+		return 0, false
+	}
+
+	matches := sizeErrorRegEx.FindStringSubmatch(err.Error())
+	if len(matches) != 3 {
+		return 0, false
+	}
+
+	necessaryByteCount, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, false
+	}
+	return necessaryByteCount, true
+}
+
+// UnaryAutoGrowMessageSize implements a gRPC unary interceptor that retries
+// calls whose response exceeds the maximum message size, allowing twice the
+// size of the rejected message.
+func UnaryAutoGrowMessageSize(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	callOpts ...grpc.CallOption,
+) error {
+	err := invoker(ctx, method, req, reply, cc, callOpts...)
+	for range maxMessageSizeGrowths {
+		necessaryByteCount, ok := NecessaryMessageSize(err)
+		if !ok {
+			return err
+		}
+
+		maxMessageSize := 2 * necessaryByteCount
+		log.Debug().Str("method", method).Int("max-message-size", maxMessageSize).Msg("retrying with a larger maximum message size")
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(maxMessageSize))
+		err = invoker(ctx, method, req, reply, cc, callOpts...)
+	}
+	return err
+}
+
+// StreamAutoGrowMessageSize implements a gRPC stream interceptor that restarts
+// server-streaming calls whose first response exceeds the maximum message
+// size, allowing twice the size of the rejected message.
+//
+// Only the first response is covered: once a response has been received,
+// restarting the stream would return it again, so a later response exceeding
+// the maximum message size fails the stream with the ResourceExhausted error
+// as-is, and the maximum must be raised with --max-message-size instead.
+func StreamAutoGrowMessageSize(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	callOpts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if desc.ClientStreams {
+		return stream, nil
+	}
+
+	return &autoGrowStream{
+		ClientStream: stream,
+		ctx:          ctx,
+		desc:         desc,
+		cc:           cc,
+		method:       method,
+		streamer:     streamer,
+		callOpts:     callOpts,
+	}, nil
+}
+
+type autoGrowStream struct {
+	grpc.ClientStream
+
+	ctx      context.Context
+	desc     *grpc.StreamDesc
+	cc       *grpc.ClientConn
+	method   string
+	streamer grpc.Streamer
+	callOpts []grpc.CallOption
+
+	request    interface{}
+	closedSend bool
+	received   bool
+	growths    int
+}
+
+func (s *autoGrowStream) SendMsg(m interface{}) error {
+	s.request = m
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *autoGrowStream) CloseSend() error {
+	s.closedSend = true
+	return s.ClientStream.CloseSend()
+}
+
+func (s *autoGrowStream) RecvMsg(m interface{}) error {
+	for {
+		err := s.ClientStream.RecvMsg(m)
+		if err == nil {
+			s.received = true
+			return nil
+		}
+
+		necessaryByteCount, ok := NecessaryMessageSize(err)
+		if ok && s.received {
+			log.Debug().Str("method", s.method).Msg("cannot restart stream with a larger maximum message size after its first response")
+		}
+		if !ok || s.received || s.growths >= maxMessageSizeGrowths {
+			return err
+		}
+
+		if restartErr := s.restart(2 * necessaryByteCount); restartErr != nil {
+			log.Debug().Err(restartErr).Str("method", s.method).Msg("failed to restart stream with a larger maximum message size")
+			return err
+		}
+	}
+}
+
+func (s *autoGrowStream) restart(maxMessageSize int) error {
+	s.growths++
+	log.Debug().Str("method", s.method).Int("max-message-size", maxMessageSize).Msg("restarting stream with a larger maximum message size")
+
+	s.callOpts = append(s.callOpts, grpc.MaxCallRecvMsgSize(maxMessageSize))
+	stream, err := s.streamer(s.ctx, s.desc, s.cc, s.method, s.callOpts...)
+	if err != nil {
+		return err
+	}
+
+	if s.request != nil {
+		if err := stream.SendMsg(s.request); err != nil {
+			return err
+		}
+	}
+
+	if s.closedSend {
+		if err := stream.CloseSend(); err != nil {
+			return err
+		}
+	}
+
+	s.ClientStream = stream
+	return nil
+}
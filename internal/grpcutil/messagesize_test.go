@@ -0,0 +1,168 @@
+package grpcutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func sizeError(necessary int) error {
+	return status.Errorf(codes.ResourceExhausted, "grpc: received message larger than max (%d vs. 4194304)", necessary)
+}
+
+func maxRecvMsgSize(callOpts []grpc.CallOption) int {
+	size := 0
+	for _, opt := range callOpts {
+		if sizeOpt, ok := opt.(grpc.MaxRecvMsgSizeCallOption); ok {
+			size = sizeOpt.MaxRecvMsgSize
+		}
+	}
+	return size
+}
+
+func TestNecessaryMessageSize(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		err      error
+		expected int
+		ok       bool
+	}{
+		{"nil", nil, 0, false},
+		{"not a status", errors.New("received message larger than max (1234 vs. 45)"), 0, false},
+		{"wrong code", status.Error(codes.Unavailable, "received message larger than max (1234 vs. 45)"), 0, false},
+		{"without sizes", status.Error(codes.ResourceExhausted, "received message larger than max"), 0, false},
+		{"with sizes", sizeError(1234), 1234, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			necessary, ok := NecessaryMessageSize(tt.err)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expected, necessary)
+		})
+	}
+}
+
+func TestUnaryAutoGrowMessageSize(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		errs          []error
+		expectedSizes []int
+		expectedErr   string
+	}{
+		{
+			name:          "success",
+			errs:          []error{nil},
+			expectedSizes: []int{0},
+		},
+		{
+			name:          "other error",
+			errs:          []error{errors.New("boom")},
+			expectedSizes: []int{0},
+			expectedErr:   "boom",
+		},
+		{
+			name:          "grows once",
+			errs:          []error{sizeError(5000000), nil},
+			expectedSizes: []int{0, 10000000},
+		},
+		{
+			name:          "bounded",
+			errs:          []error{sizeError(1), sizeError(2), sizeError(3), sizeError(4)},
+			expectedSizes: []int{0, 2, 4, 6},
+			expectedErr:   "received message larger than max (4 vs. 4194304)",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var sizes []int
+			invoker := func(_ context.Context, _ string, _, _ any, _ *grpc.ClientConn, callOpts ...grpc.CallOption) error {
+				sizes = append(sizes, maxRecvMsgSize(callOpts))
+				return tt.errs[len(sizes)-1]
+			}
+
+			err := UnaryAutoGrowMessageSize(context.Background(), "/test/Method", nil, nil, nil, invoker)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expectedSizes, sizes)
+		})
+	}
+}
+
+type fakeClientStream struct {
+	grpc.ClientStream
+
+	sent       []any
+	closedSend bool
+	recvErrs   []error
+}
+
+func (f *fakeClientStream) SendMsg(m any) error {
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+func (f *fakeClientStream) CloseSend() error {
+	f.closedSend = true
+	return nil
+}
+
+func (f *fakeClientStream) RecvMsg(_ any) error {
+	if len(f.recvErrs) == 0 {
+		return io.EOF
+	}
+	err := f.recvErrs[0]
+	f.recvErrs = f.recvErrs[1:]
+	return err
+}
+
+func TestStreamAutoGrowMessageSize(t *testing.T) {
+	t.Run("restarts before the first response", func(t *testing.T) {
+		var streams []*fakeClientStream
+		var sizes []int
+		streamer := func(_ context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+			sizes = append(sizes, maxRecvMsgSize(callOpts))
+			stream := &fakeClientStream{}
+			if len(streams) == 0 {
+				stream.recvErrs = []error{sizeError(100)}
+			} else {
+				stream.recvErrs = []error{nil}
+			}
+			streams = append(streams, stream)
+			return stream, nil
+		}
+
+		stream, err := StreamAutoGrowMessageSize(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/test/Method", streamer)
+		require.NoError(t, err)
+		require.NoError(t, stream.SendMsg("request"))
+		require.NoError(t, stream.CloseSend())
+
+		require.NoError(t, stream.RecvMsg(nil))
+		require.ErrorIs(t, stream.RecvMsg(nil), io.EOF)
+		require.Equal(t, []int{0, 200}, sizes)
+		require.Len(t, streams, 2)
+		require.Equal(t, []any{"request"}, streams[1].sent)
+		require.True(t, streams[1].closedSend)
+	})
+
+	t.Run("does not restart after a response", func(t *testing.T) {
+		var calls int
+		streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			calls++
+			return &fakeClientStream{recvErrs: []error{nil, sizeError(100)}}, nil
+		}
+
+		stream, err := StreamAutoGrowMessageSize(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/test/Method", streamer)
+		require.NoError(t, err)
+		require.NoError(t, stream.RecvMsg(nil))
+		require.ErrorContains(t, stream.RecvMsg(nil), fmt.Sprintf("(%d vs.", 100))
+		require.Equal(t, 1, calls)
+	})
+}
@@ -3,19 +3,36 @@ package printers
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/dustin/go-humanize/english"
 	"github.com/gookit/color"
 )
 
+// CheckTraceOptions configures how DisplayCheckTrace prints a check trace.
+type CheckTraceOptions struct {
+	// HasError marks the check as having failed, so that cycles in the trace
+	// are highlighted.
+	HasError bool
+
+	// MaxDepth, if greater than zero, replaces subproblems nested deeper than
+	// MaxDepth levels by a marker counting the levels left out.
+	MaxDepth int
+
+	// HideCached leaves out subproblems resolved from a cache, along with their
+	// own subproblems, and replaces them by a marker counting them.
+	HideCached bool
+}
+
 // DisplayCheckTrace prints out the check trace found in the given debug message.
-func DisplayCheckTrace(checkTrace *v1.CheckDebugTrace, tp *TreePrinter, hasError bool) {
-	displayCheckTrace(checkTrace, tp, hasError, map[string]struct{}{})
+func DisplayCheckTrace(checkTrace *v1.CheckDebugTrace, tp *TreePrinter, opts CheckTraceOptions) {
+	displayCheckTrace(checkTrace, tp, opts, map[string]struct{}{}, 1)
 }
 
-func displayCheckTrace(checkTrace *v1.CheckDebugTrace, tp *TreePrinter, hasError bool, encountered map[string]struct{}) {
+func displayCheckTrace(checkTrace *v1.CheckDebugTrace, tp *TreePrinter, opts CheckTraceOptions, encountered map[string]struct{}, depth int) {
 	red := color.FgRed.Render
 	green := color.FgGreen.Render
 	cyan := color.FgCyan.Render
@@ -82,13 +99,13 @@ func displayCheckTrace(checkTrace *v1.CheckDebugTrace, tp *TreePrinter, hasError
 		default:
 			additional = cyan(fmt.Sprintf(" (cached by %s)", sourceKind))
 		}
-	} else if hasError && isPartOfCycle(checkTrace, map[string]struct{}{}) {
+	} else if opts.HasError && isPartOfCycle(checkTrace, map[string]struct{}{}) {
 		hasPermission = orange("!")
 		resourceColor = white
 	}
 
 	isEndOfCycle := false
-	if hasError {
+	if opts.HasError {
 		key := cycleKey(checkTrace)
 		_, isEndOfCycle = encountered[key]
 		if isEndOfCycle {
@@ -155,14 +172,132 @@ func displayCheckTrace(checkTrace *v1.CheckDebugTrace, tp *TreePrinter, hasError
 	}
 
 	if checkTrace.GetSubProblems() != nil {
+		if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+			if levels := checkTraceDepth(checkTrace) - 1; levels > 0 {
+				tp.Child(faint(fmt.Sprintf("…(%d more %s)", levels, english.PluralWord(levels, "level", ""))))
+			}
+			return
+		}
+
+		hidden := 0
 		for _, subProblem := range checkTrace.GetSubProblems().Traces {
-			displayCheckTrace(subProblem, tp, hasError, encountered)
+			if opts.HideCached && subProblem.GetWasCachedResult() {
+				hidden++
+				continue
+			}
+			displayCheckTrace(subProblem, tp, opts, encountered, depth+1)
+		}
+		if hidden > 0 {
+			tp.Child(faint(fmt.Sprintf("…(%d cached %s hidden)", hidden, english.PluralWord(hidden, "subproblem", ""))))
 		}
 	} else if checkTrace.Result == v1.CheckDebugTrace_PERMISSIONSHIP_HAS_PERMISSION {
 		tp.Child(purple(fmt.Sprintf("%s:%s %s", checkTrace.Subject.Object.ObjectType, checkTrace.Subject.Object.ObjectId, checkTrace.Subject.OptionalRelation)))
 	}
 }
 
+// CheckTraceLegend returns a key to the symbols and colors used by
+// DisplayCheckTrace, to be printed beneath the trace.
+func CheckTraceLegend() string {
+	return strings.Join([]string{
+		"legend:",
+		fmt.Sprintf("  %s has permission   %s no permission   %s missing caveat context   %s unspecified   %s part of a cycle",
+			color.FgGreen.Render("✓"),
+			color.FgRed.Render("⨉"),
+			color.FgMagenta.Render("?"),
+			color.FgYellow.Render("∵"),
+			color.C256(166).Sprint("!"),
+		),
+		fmt.Sprintf("  %s   %s   %s   %s   %s   %s",
+			color.C256(35).Sprint("permission"),
+			color.C256(166).Sprint("relation"),
+			color.C256(198).Sprint("caveat"),
+			color.C256(99).Sprint("subject found"),
+			color.FgCyan.Render("(cached)"),
+			color.C256(166).Sprint("(cycle)"),
+		),
+	}, "\n")
+}
+
+// checkTraceDepth returns the number of levels in the given check trace,
+// counting the trace itself.
+func checkTraceDepth(checkTrace *v1.CheckDebugTrace) int {
+	deepest := 0
+	for _, subProblem := range checkTrace.GetSubProblems().GetTraces() {
+		deepest = max(deepest, checkTraceDepth(subProblem))
+	}
+	return deepest + 1
+}
+
+// CaveatEvaluations returns the evaluation info of every caveat found in the
+// given check trace, in the order in which DisplayCheckTrace displays them.
+func CaveatEvaluations(checkTrace *v1.CheckDebugTrace) []*v1.CaveatEvalInfo {
+	var evaluations []*v1.CaveatEvalInfo
+	if checkTrace.GetCaveatEvaluationInfo() != nil {
+		evaluations = append(evaluations, checkTrace.CaveatEvaluationInfo)
+	}
+
+	for _, subProblem := range checkTrace.GetSubProblems().GetTraces() {
+		evaluations = append(evaluations, CaveatEvaluations(subProblem)...)
+	}
+	return evaluations
+}
+
+// WhyNot summarizes why the subject of the given check trace was not granted
+// the permission, returning one entry per failed path. Each entry is the
+// chain of relations and permissions walked, followed by why it failed.
+// Entries are sorted, as SpiceDB returns sub-problems in the order they were
+// dispatched.
+func WhyNot(checkTrace *v1.CheckDebugTrace) []string {
+	reasons := whyNot(checkTrace, nil)
+	slices.Sort(reasons)
+	return reasons
+}
+
+func whyNot(checkTrace *v1.CheckDebugTrace, path []string) []string {
+	if checkTrace.Result == v1.CheckDebugTrace_PERMISSIONSHIP_HAS_PERMISSION {
+		return nil
+	}
+
+	key := cycleKey(checkTrace)
+	isCycle := slices.Contains(path, key)
+	path = append(slices.Clone(path), key)
+	pathString := strings.Join(path, " → ")
+	if isCycle {
+		return []string{pathString + ": cycle"}
+	}
+
+	if info := checkTrace.GetCaveatEvaluationInfo(); info != nil {
+		switch info.Result {
+		case v1.CaveatEvalInfo_RESULT_MISSING_SOME_CONTEXT:
+			missing := strings.Join(info.GetPartialCaveatInfo().GetMissingRequiredContext(), ", ")
+			return []string{fmt.Sprintf("%s: caveat %s is missing context: %s", pathString, info.CaveatName, missing)}
+
+		case v1.CaveatEvalInfo_RESULT_FALSE:
+			return []string{fmt.Sprintf("%s: caveat %s evaluated to false: %s", pathString, info.CaveatName, info.Expression)}
+		}
+	}
+
+	subProblems := checkTrace.GetSubProblems().GetTraces()
+	if len(subProblems) == 0 {
+		if checkTrace.PermissionType == v1.CheckDebugTrace_PERMISSION_TYPE_RELATION {
+			return []string{pathString + ": no relationship to the subject"}
+		}
+		return []string{pathString + ": no path to the subject"}
+	}
+
+	var reasons []string
+	for _, subProblem := range subProblems {
+		reasons = append(reasons, whyNot(subProblem, path)...)
+	}
+
+	// Every branch found the subject, so it must have been excluded or
+	// missing from another branch of an intersection.
+	if len(reasons) == 0 {
+		return []string{pathString + ": the subject was excluded or is missing from an intersection"}
+	}
+	return reasons
+}
+
 func cycleKey(checkTrace *v1.CheckDebugTrace) string {
 	return fmt.Sprintf("%s#%s", tuple.V1StringObjectRef(checkTrace.Resource), checkTrace.Permission)
 }
@@ -0,0 +1,192 @@
+package printers
+
+import (
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/gookit/color"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaveatEvaluations(t *testing.T) {
+	first := &v1.CaveatEvalInfo{CaveatName: "first", Result: v1.CaveatEvalInfo_RESULT_TRUE}
+	second := &v1.CaveatEvalInfo{CaveatName: "second", Result: v1.CaveatEvalInfo_RESULT_FALSE}
+	third := &v1.CaveatEvalInfo{CaveatName: "third", Result: v1.CaveatEvalInfo_RESULT_MISSING_SOME_CONTEXT}
+
+	trace := &v1.CheckDebugTrace{
+		CaveatEvaluationInfo: first,
+		Resolution: &v1.CheckDebugTrace_SubProblems_{SubProblems: &v1.CheckDebugTrace_SubProblems{
+			Traces: []*v1.CheckDebugTrace{
+				{
+					Resolution: &v1.CheckDebugTrace_SubProblems_{SubProblems: &v1.CheckDebugTrace_SubProblems{
+						Traces: []*v1.CheckDebugTrace{{CaveatEvaluationInfo: second}},
+					}},
+				},
+				{CaveatEvaluationInfo: third},
+				{},
+			},
+		}},
+	}
+
+	require.Equal(t, []*v1.CaveatEvalInfo{first, second, third}, CaveatEvaluations(trace))
+	require.Empty(t, CaveatEvaluations(&v1.CheckDebugTrace{}))
+}
+
+func TestWhyNot(t *testing.T) {
+	trace := func(resource, permission string, permissionType v1.CheckDebugTrace_PermissionType, result v1.CheckDebugTrace_Permissionship, subProblems ...*v1.CheckDebugTrace) *v1.CheckDebugTrace {
+		checkTrace := &v1.CheckDebugTrace{
+			Resource:       &v1.ObjectReference{ObjectType: "document", ObjectId: resource},
+			Permission:     permission,
+			PermissionType: permissionType,
+			Result:         result,
+		}
+		if len(subProblems) > 0 {
+			checkTrace.Resolution = &v1.CheckDebugTrace_SubProblems_{SubProblems: &v1.CheckDebugTrace_SubProblems{Traces: subProblems}}
+		}
+		return checkTrace
+	}
+	const (
+		relation   = v1.CheckDebugTrace_PERMISSION_TYPE_RELATION
+		permission = v1.CheckDebugTrace_PERMISSION_TYPE_PERMISSION
+		has        = v1.CheckDebugTrace_PERMISSIONSHIP_HAS_PERMISSION
+		no         = v1.CheckDebugTrace_PERMISSIONSHIP_NO_PERMISSION
+		caveated   = v1.CheckDebugTrace_PERMISSIONSHIP_CONDITIONAL_PERMISSION
+	)
+
+	missingContext := trace("1", "editor", relation, caveated)
+	missingContext.CaveatEvaluationInfo = &v1.CaveatEvalInfo{
+		CaveatName:        "on_network",
+		Result:            v1.CaveatEvalInfo_RESULT_MISSING_SOME_CONTEXT,
+		PartialCaveatInfo: &v1.PartialCaveatInfo{MissingRequiredContext: []string{"ip"}},
+		Expression:        "ip in cidr",
+	}
+
+	for _, tt := range []struct {
+		name     string
+		trace    *v1.CheckDebugTrace
+		expected []string
+	}{
+		{
+			name:     "has permission",
+			trace:    trace("1", "view", permission, has, trace("1", "viewer", relation, has)),
+			expected: nil,
+		},
+		{
+			name:  "empty relations and missing context",
+			trace: trace("1", "view", permission, no, trace("1", "viewer", relation, no), missingContext),
+			expected: []string{
+				"document:1#view → document:1#editor: caveat on_network is missing context: ip",
+				"document:1#view → document:1#viewer: no relationship to the subject",
+			},
+		},
+		{
+			name:  "excluded",
+			trace: trace("1", "view", permission, no, trace("1", "viewer", relation, has), trace("1", "banned", relation, has)),
+			expected: []string{
+				"document:1#view: the subject was excluded or is missing from an intersection",
+			},
+		},
+		{
+			name:  "nested permission without paths",
+			trace: trace("1", "view", permission, no, trace("2", "view", permission, no)),
+			expected: []string{
+				"document:1#view → document:2#view: no path to the subject",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, WhyNot(tt.trace))
+		})
+	}
+}
+
+func TestDisplayCheckTraceMaxDepth(t *testing.T) {
+	trace := func(resource string, subProblems ...*v1.CheckDebugTrace) *v1.CheckDebugTrace {
+		checkTrace := &v1.CheckDebugTrace{
+			Resource:   &v1.ObjectReference{ObjectType: "folder", ObjectId: resource},
+			Permission: "view",
+			Result:     v1.CheckDebugTrace_PERMISSIONSHIP_NO_PERMISSION,
+		}
+		if len(subProblems) > 0 {
+			checkTrace.Resolution = &v1.CheckDebugTrace_SubProblems_{SubProblems: &v1.CheckDebugTrace_SubProblems{Traces: subProblems}}
+		}
+		return checkTrace
+	}
+	root := trace("root", trace("child", trace("grandchild", trace("leaf"))), trace("sibling"))
+
+	for _, tt := range []struct {
+		name     string
+		maxDepth int
+		included []string
+		excluded []string
+		marker   string
+	}{
+		{"full tree", 0, []string{"folder:root", "folder:child", "folder:grandchild", "folder:leaf", "folder:sibling"}, nil, ""},
+		{"deeper than the tree", 10, []string{"folder:leaf"}, nil, ""},
+		{"root only", 1, []string{"folder:root"}, []string{"folder:child", "folder:sibling"}, "…(3 more levels)"},
+		{"two levels", 2, []string{"folder:child", "folder:sibling"}, []string{"folder:grandchild"}, "…(2 more levels)"},
+		{"three levels", 3, []string{"folder:grandchild"}, []string{"folder:leaf"}, "…(1 more level)"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := NewTreePrinter()
+			DisplayCheckTrace(root, tp, CheckTraceOptions{MaxDepth: tt.maxDepth})
+			output := color.ClearCode(tp.String())
+
+			for _, included := range tt.included {
+				require.Contains(t, output, included)
+			}
+			for _, excluded := range tt.excluded {
+				require.NotContains(t, output, excluded)
+			}
+			if tt.marker != "" {
+				require.Contains(t, output, tt.marker)
+			} else {
+				require.NotContains(t, output, "more level")
+			}
+		})
+	}
+}
+
+func TestDisplayCheckTraceHideCached(t *testing.T) {
+	trace := func(resource string, cached bool, subProblems ...*v1.CheckDebugTrace) *v1.CheckDebugTrace {
+		checkTrace := &v1.CheckDebugTrace{
+			Resource:   &v1.ObjectReference{ObjectType: "folder", ObjectId: resource},
+			Permission: "view",
+			Result:     v1.CheckDebugTrace_PERMISSIONSHIP_NO_PERMISSION,
+		}
+		if cached {
+			checkTrace.Resolution = &v1.CheckDebugTrace_WasCachedResult{WasCachedResult: true}
+		} else if len(subProblems) > 0 {
+			checkTrace.Resolution = &v1.CheckDebugTrace_SubProblems_{SubProblems: &v1.CheckDebugTrace_SubProblems{Traces: subProblems}}
+		}
+		return checkTrace
+	}
+	root := trace("root", false,
+		trace("fresh", false, trace("cachedgrandchild", true)),
+		trace("cachedchild", true),
+		trace("cachedsibling", true),
+	)
+
+	tp := NewTreePrinter()
+	DisplayCheckTrace(root, tp, CheckTraceOptions{})
+	output := color.ClearCode(tp.String())
+	require.Contains(t, output, "folder:cachedchild view (cached)")
+	require.NotContains(t, output, "hidden")
+
+	tp = NewTreePrinter()
+	DisplayCheckTrace(root, tp, CheckTraceOptions{HideCached: true})
+	output = color.ClearCode(tp.String())
+	require.Contains(t, output, "folder:fresh")
+	require.NotContains(t, output, "cachedchild")
+	require.NotContains(t, output, "cachedsibling")
+	require.NotContains(t, output, "cachedgrandchild")
+	require.Contains(t, output, "…(2 cached subproblems hidden)")
+	require.Contains(t, output, "…(1 cached subproblem hidden)")
+}
+
+func TestCheckTraceLegend(t *testing.T) {
+	legend := color.ClearCode(CheckTraceLegend())
+	for _, entry := range []string{"✓ has permission", "⨉ no permission", "? missing caveat context", "! part of a cycle", "permission", "relation", "caveat", "(cached)", "(cycle)"} {
+		require.Contains(t, legend, entry)
+	}
+}
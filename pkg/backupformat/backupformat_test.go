@@ -3,6 +3,7 @@ package backupformat
 import (
 	"bytes"
 	"encoding/base64"
+	"os"
 	"testing"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
@@ -133,6 +134,68 @@ func TestWriteAndRead(t *testing.T) {
 	}
 }
 
+func TestWriteAndReadIncremental(t *testing.T) {
+	require := require.New(t)
+
+	caveatContext, err := structpb.NewStruct(map[string]any{"intVal": 123})
+	require.NoError(err)
+
+	expectedUpdates := []*v1.RelationshipUpdate{
+		{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: &v1.Relationship{
+			Resource: &v1.ObjectReference{ObjectType: "document", ObjectId: "1"},
+			Relation: "viewer",
+			Subject:  &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "1"}},
+		}},
+		{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: &v1.Relationship{
+			Resource: &v1.ObjectReference{ObjectType: "document", ObjectId: "1"},
+			Relation: "viewer",
+			Subject:  &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "group", ObjectId: "eng"}, OptionalRelation: "member"},
+		}},
+		{Operation: v1.RelationshipUpdate_OPERATION_CREATE, Relationship: &v1.Relationship{
+			Resource:       &v1.ObjectReference{ObjectType: "document", ObjectId: "2"},
+			Relation:       "viewer",
+			Subject:        &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "2"}},
+			OptionalCaveat: &v1.ContextualizedCaveat{CaveatName: "somecaveat", Context: caveatContext},
+		}},
+	}
+
+	f, err := os.CreateTemp(t.TempDir(), "incremental")
+	require.NoError(err)
+	defer f.Close()
+	enc, err := NewIncrementalEncoder(f, "definition user {}", &v1.ZedToken{Token: "since"})
+	require.NoError(err)
+	for _, update := range expectedUpdates {
+		require.NoError(enc.Append(update))
+	}
+	enc.SetZedToken(&v1.ZedToken{Token: "through"})
+	require.NoError(enc.Close())
+
+	contents, err := os.ReadFile(f.Name())
+	require.NoError(err)
+	buf := bytes.NewBuffer(contents)
+
+	_, err = NewDecoder(bytes.NewReader(buf.Bytes()))
+	require.ErrorIs(err, ErrIncrementalBackup)
+
+	dec, err := NewIncrementalDecoder(bytes.NewReader(buf.Bytes()))
+	require.NoError(err)
+	require.Equal("definition user {}", dec.Schema())
+	require.Equal("since", dec.SinceZedToken().Token)
+	require.Equal("through", dec.ZedToken().Token)
+
+	for _, expected := range expectedUpdates {
+		update, err := dec.Next()
+		require.NoError(err)
+		require.Equal(expected.Operation, update.Operation)
+		requireRelationshipEqual(require, expected.Relationship, update.Relationship)
+	}
+
+	update, err := dec.Next()
+	require.NoError(err)
+	require.Nil(update)
+	require.NoError(dec.Close())
+}
+
 func requireRelationshipEqual(require *require.Assertions, expected, received *v1.Relationship) {
 	require.Equal(expected.Resource.ObjectType, received.Resource.ObjectType)
 	require.Equal(expected.Resource.ObjectId, received.Resource.ObjectId)
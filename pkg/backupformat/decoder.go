@@ -26,6 +26,10 @@ func NewDecoder(r io.Reader) (*Decoder, error) {
 	}
 
 	md := dec.Metadata()
+	if _, ok := md[metadataKeySinceZT]; ok {
+		return nil, ErrIncrementalBackup
+	}
+
 	var zedToken *v1.ZedToken
 
 	if token, ok := md[metadataKeyZT]; ok {
@@ -85,8 +89,10 @@ func (d *Decoder) Next() (*v1.Relationship, error) {
 		return nil, fmt.Errorf("unable to decode relationship from avro stream: %w", err)
 	}
 
-	flat := nextRelIFace.(RelationshipV1)
+	return unflattenRelationship(nextRelIFace.(RelationshipV1))
+}
 
+func unflattenRelationship(flat RelationshipV1) (*v1.Relationship, error) {
 	rel := &v1.Relationship{
 		Resource: &v1.ObjectReference{
 			ObjectType: flat.ObjectType,
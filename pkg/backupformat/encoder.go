@@ -11,6 +11,12 @@ import (
 )
 
 func NewEncoder(w io.Writer, schema string, token *v1.ZedToken) (*Encoder, error) {
+	return NewEncoderWithCodec(w, schema, token, ocf.Snappy)
+}
+
+// NewEncoderWithCodec creates a new Encoder that compresses the written blocks
+// with the given codec.
+func NewEncoderWithCodec(w io.Writer, schema string, token *v1.ZedToken, codec ocf.CodecName) (*Encoder, error) {
 	avroSchema, err := avroSchemaV1()
 	if err != nil {
 		return nil, fmt.Errorf("unable to create avro schema: %w", err)
@@ -24,7 +30,7 @@ func NewEncoder(w io.Writer, schema string, token *v1.ZedToken) (*Encoder, error
 		metadataKeyZT: []byte(token.Token),
 	}
 
-	enc, err := ocf.NewEncoder(avroSchema, w, ocf.WithCodec(ocf.Snappy), ocf.WithMetadata(md))
+	enc, err := ocf.NewEncoder(avroSchema, w, ocf.WithCodec(codec), ocf.WithMetadata(md))
 	if err != nil {
 		return nil, fmt.Errorf("unable to create encoder: %w", err)
 	}
@@ -43,22 +49,9 @@ type Encoder struct {
 }
 
 func (e *Encoder) Append(rel *v1.Relationship) error {
-	var toEncode RelationshipV1
-
-	toEncode.ObjectType = rel.Resource.ObjectType
-	toEncode.ObjectID = rel.Resource.ObjectId
-	toEncode.Relation = rel.Relation
-	toEncode.SubjectObjectType = rel.Subject.Object.ObjectType
-	toEncode.SubjectObjectID = rel.Subject.Object.ObjectId
-	toEncode.SubjectRelation = rel.Subject.OptionalRelation
-	if rel.OptionalCaveat != nil {
-		contextBytes, err := proto.Marshal(rel.OptionalCaveat.Context)
-		if err != nil {
-			return fmt.Errorf("error marshaling caveat context: %w", err)
-		}
-
-		toEncode.CaveatName = rel.OptionalCaveat.CaveatName
-		toEncode.CaveatContext = contextBytes
+	toEncode, err := flattenRelationship(rel)
+	if err != nil {
+		return err
 	}
 
 	if err := e.enc.Encode(toEncode); err != nil {
@@ -68,6 +61,28 @@ func (e *Encoder) Append(rel *v1.Relationship) error {
 	return nil
 }
 
+func flattenRelationship(rel *v1.Relationship) (RelationshipV1, error) {
+	var flat RelationshipV1
+
+	flat.ObjectType = rel.Resource.ObjectType
+	flat.ObjectID = rel.Resource.ObjectId
+	flat.Relation = rel.Relation
+	flat.SubjectObjectType = rel.Subject.Object.ObjectType
+	flat.SubjectObjectID = rel.Subject.Object.ObjectId
+	flat.SubjectRelation = rel.Subject.OptionalRelation
+	if rel.OptionalCaveat != nil {
+		contextBytes, err := proto.Marshal(rel.OptionalCaveat.Context)
+		if err != nil {
+			return flat, fmt.Errorf("error marshaling caveat context: %w", err)
+		}
+
+		flat.CaveatName = rel.OptionalCaveat.CaveatName
+		flat.CaveatContext = contextBytes
+	}
+
+	return flat, nil
+}
+
 func (e *Encoder) Close() error {
 	if err := e.enc.Flush(); err != nil {
 		return fmt.Errorf("unable to flush encoder: %w", err)
@@ -0,0 +1,250 @@
+package backupformat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/hamba/avro/v2/ocf"
+)
+
+// ErrIncrementalBackup is returned by NewDecoder for incremental backups,
+// which must be read with NewIncrementalDecoder.
+var ErrIncrementalBackup = errors.New("file is an incremental backup")
+
+var operationNames = map[v1.RelationshipUpdate_Operation]string{
+	v1.RelationshipUpdate_OPERATION_CREATE: "create",
+	v1.RelationshipUpdate_OPERATION_TOUCH:  "touch",
+	v1.RelationshipUpdate_OPERATION_DELETE: "delete",
+}
+
+// maxZedTokenLength is the space reserved in the header of an incremental
+// backup for the revision through which its updates were recorded.
+const maxZedTokenLength = 1024
+
+// zedTokenPlaceholder holds the space reserved for the revision until it is
+// known; the decoder trims the padding left around shorter revisions.
+var zedTokenPlaceholder = bytes.Repeat([]byte{' '}, maxZedTokenLength)
+
+// NewIncrementalEncoder creates an encoder of the relationship updates made
+// after the since revision. As updates are appended as they are received, the
+// revision through which they were recorded is only set once they all are,
+// with SetZedToken, and written into the header on Close: w must be seekable.
+func NewIncrementalEncoder(w io.WriteSeeker, schema string, since *v1.ZedToken) (*IncrementalEncoder, error) {
+	avroSchema, err := avroIncrementalSchemaV1()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create avro schema: %w", err)
+	}
+
+	if since == nil {
+		return nil, errors.New("missing expected token")
+	}
+
+	start, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("incremental backups must be written to a seekable file: %w", err)
+	}
+
+	md := map[string][]byte{
+		metadataKeyZT:      zedTokenPlaceholder,
+		metadataKeySinceZT: []byte(since.Token),
+	}
+
+	header := &headerWriter{w: w, recording: true}
+	enc, err := ocf.NewEncoder(avroSchema, header, ocf.WithCodec(ocf.Snappy), ocf.WithMetadata(md))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create encoder: %w", err)
+	}
+	header.recording = false
+
+	placeholderOffset := bytes.Index(header.written, zedTokenPlaceholder)
+	if placeholderOffset < 0 {
+		return nil, errors.New("unable to find space reserved for the revision in the header")
+	}
+
+	if err := enc.Encode(SchemaV1{
+		SchemaText: schema,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to encode SpiceDB schema object: %w", err)
+	}
+
+	return &IncrementalEncoder{enc: enc, w: w, tokenOffset: start + int64(placeholderOffset)}, nil
+}
+
+// headerWriter records the bytes written while recording is set.
+type headerWriter struct {
+	w         io.Writer
+	recording bool
+	written   []byte
+}
+
+func (h *headerWriter) Write(p []byte) (int, error) {
+	if h.recording {
+		h.written = append(h.written, p...)
+	}
+	return h.w.Write(p)
+}
+
+type IncrementalEncoder struct {
+	enc         *ocf.Encoder
+	w           io.WriteSeeker
+	tokenOffset int64
+	token       *v1.ZedToken
+}
+
+func (e *IncrementalEncoder) Append(update *v1.RelationshipUpdate) error {
+	operation, ok := operationNames[update.Operation]
+	if !ok {
+		return fmt.Errorf("unsupported relationship update operation: %s", update.Operation)
+	}
+
+	rel, err := flattenRelationship(update.Relationship)
+	if err != nil {
+		return err
+	}
+
+	if err := e.enc.Encode(RelationshipUpdateV1{Operation: operation, Relationship: rel}); err != nil {
+		return fmt.Errorf("unable to encode relationship update: %w", err)
+	}
+
+	return nil
+}
+
+// SetZedToken sets the revision up to and including which the appended
+// updates were made.
+func (e *IncrementalEncoder) SetZedToken(token *v1.ZedToken) {
+	e.token = token
+}
+
+// Close flushes the appended updates and writes the revision set with
+// SetZedToken into the header.
+func (e *IncrementalEncoder) Close() error {
+	if err := e.enc.Flush(); err != nil {
+		return fmt.Errorf("unable to flush encoder: %w", err)
+	}
+
+	if e.token == nil {
+		return errors.New("missing expected token")
+	}
+	if len(e.token.Token) > maxZedTokenLength {
+		return fmt.Errorf("revision is longer than the %d bytes reserved for it", maxZedTokenLength)
+	}
+
+	end, err := e.w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("unable to write revision: %w", err)
+	}
+	if _, err := e.w.Seek(e.tokenOffset, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to write revision: %w", err)
+	}
+	padded := append([]byte(e.token.Token), zedTokenPlaceholder[len(e.token.Token):]...)
+	if _, err := e.w.Write(padded); err != nil {
+		return fmt.Errorf("unable to write revision: %w", err)
+	}
+	if _, err := e.w.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to write revision: %w", err)
+	}
+	return nil
+}
+
+func NewIncrementalDecoder(r io.Reader) (*IncrementalDecoder, error) {
+	dec, err := ocf.NewDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ocf decoder: %w", err)
+	}
+
+	md := dec.Metadata()
+	since, ok := md[metadataKeySinceZT]
+	if !ok {
+		return nil, errors.New("file is not an incremental backup")
+	}
+	token, ok := md[metadataKeyZT]
+	if !ok {
+		return nil, errors.New("incremental backup has no revision")
+	}
+
+	if !dec.HasNext() {
+		return nil, errors.New("avro stream contains no schema object")
+	}
+
+	var decodedSchema any
+	if err := dec.Decode(&decodedSchema); err != nil {
+		return nil, fmt.Errorf("unable to decode schema object: %w", err)
+	}
+
+	schema, ok := decodedSchema.(SchemaV1)
+	if !ok {
+		return nil, fmt.Errorf("received schema object of wrong type: %T", decodedSchema)
+	}
+
+	return &IncrementalDecoder{
+		dec:      dec,
+		schema:   schema.SchemaText,
+		since:    &v1.ZedToken{Token: string(since)},
+		zedToken: &v1.ZedToken{Token: string(bytes.TrimRight(token, " "))},
+	}, nil
+}
+
+// IncrementalDecoder reads the relationship updates of an incremental backup
+// in the order they were made.
+type IncrementalDecoder struct {
+	dec      *ocf.Decoder
+	schema   string
+	since    *v1.ZedToken
+	zedToken *v1.ZedToken
+}
+
+func (d *IncrementalDecoder) Schema() string {
+	return d.schema
+}
+
+// SinceZedToken returns the revision after which the updates were made.
+func (d *IncrementalDecoder) SinceZedToken() *v1.ZedToken {
+	return d.since
+}
+
+// ZedToken returns the revision through which the updates were recorded.
+func (d *IncrementalDecoder) ZedToken() *v1.ZedToken {
+	return d.zedToken
+}
+
+func (d *IncrementalDecoder) Close() error {
+	return nil
+}
+
+// Next returns the next relationship update, or nil once there are none left.
+func (d *IncrementalDecoder) Next() (*v1.RelationshipUpdate, error) {
+	if !d.dec.HasNext() {
+		return nil, nil
+	}
+
+	var nextUpdateIFace any
+	if err := d.dec.Decode(&nextUpdateIFace); err != nil {
+		return nil, fmt.Errorf("unable to decode relationship update from avro stream: %w", err)
+	}
+
+	flat, ok := nextUpdateIFace.(RelationshipUpdateV1)
+	if !ok {
+		return nil, fmt.Errorf("received relationship update of wrong type: %T", nextUpdateIFace)
+	}
+
+	var operation v1.RelationshipUpdate_Operation
+	for op, name := range operationNames {
+		if strings.EqualFold(name, flat.Operation) {
+			operation = op
+		}
+	}
+	if operation == v1.RelationshipUpdate_OPERATION_UNSPECIFIED {
+		return nil, fmt.Errorf("unknown relationship update operation: %q", flat.Operation)
+	}
+
+	rel, err := unflattenRelationship(flat.Relationship)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.RelationshipUpdate{Operation: operation, Relationship: rel}, nil
+}
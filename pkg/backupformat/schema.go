@@ -11,6 +11,7 @@ import (
 func init() {
 	avro.DefaultConfig.Register(spiceDBBackupNamespace+"."+schemaV1SchemaName, SchemaV1{})
 	avro.DefaultConfig.Register(spiceDBBackupNamespace+"."+relationshipV1SchemaName, RelationshipV1{})
+	avro.DefaultConfig.Register(spiceDBBackupNamespace+"."+relationshipUpdateV1SchemaName, RelationshipUpdateV1{})
 }
 
 type RelationshipV1 struct {
@@ -24,6 +25,13 @@ type RelationshipV1 struct {
 	CaveatContext     []byte `avro:"caveat_context"`
 }
 
+// RelationshipUpdateV1 is a change to a relationship recorded in an
+// incremental backup, with an operation of create, touch or delete.
+type RelationshipUpdateV1 struct {
+	Operation    string         `avro:"operation"`
+	Relationship RelationshipV1 `avro:"relationship"`
+}
+
 type SchemaV1 struct {
 	SchemaText string `avro:"schema_text"`
 }
@@ -31,12 +39,20 @@ type SchemaV1 struct {
 const (
 	spiceDBBackupNamespace = "com.authzed.spicedb.backup"
 
-	relationshipV1SchemaName = "relationship_v1"
-	schemaV1SchemaName       = "schema_v1"
+	relationshipV1SchemaName       = "relationship_v1"
+	relationshipUpdateV1SchemaName = "relationship_update_v1"
+	schemaV1SchemaName             = "schema_v1"
 
-	metadataKeyZT = "com.authzed.spicedb.zedtoken.v1"
+	metadataKeyZT      = "com.authzed.spicedb.zedtoken.v1"
+	metadataKeySinceZT = "com.authzed.spicedb.zedtoken.since.v1"
 )
 
+// recordNames are the names of the record schemas of the structs that may be
+// nested in other records.
+var recordNames = map[reflect.Type]string{
+	reflect.TypeOf(RelationshipV1{}): relationshipV1SchemaName,
+}
+
 func avroSchemaV1() (string, error) {
 	relationshipSchema, err := recordSchemaFromAvroStruct(
 		relationshipV1SchemaName,
@@ -65,6 +81,36 @@ func avroSchemaV1() (string, error) {
 	return string(serialized), err
 }
 
+// avroIncrementalSchemaV1 is the schema of incremental backups, which record
+// relationship updates in place of relationships.
+func avroIncrementalSchemaV1() (string, error) {
+	updateSchema, err := recordSchemaFromAvroStruct(
+		relationshipUpdateV1SchemaName,
+		spiceDBBackupNamespace,
+		RelationshipUpdateV1{},
+	)
+	if err != nil {
+		return "", fmt.Errorf("unable to create schema: %w", err)
+	}
+
+	schemaSchema, err := recordSchemaFromAvroStruct(
+		schemaV1SchemaName,
+		spiceDBBackupNamespace,
+		SchemaV1{},
+	)
+	if err != nil {
+		return "", fmt.Errorf("unable to create avro SpiceDB schema schema: %w", err)
+	}
+
+	unionSchema, err := avro.NewUnionSchema([]avro.Schema{updateSchema, schemaSchema})
+	if err != nil {
+		return "", fmt.Errorf("unable to create avro union schema: %w", err)
+	}
+
+	serialized, err := unionSchema.MarshalJSON()
+	return string(serialized), err
+}
+
 func recordSchemaFromAvroStruct(name, namespace string, avroStruct any) (*avro.RecordSchema, error) {
 	v := reflect.TypeOf(avroStruct)
 	schemaFields := make([]*avro.Field, 0, v.NumField())
@@ -76,20 +122,30 @@ func recordSchemaFromAvroStruct(name, namespace string, avroStruct any) (*avro.R
 		}
 		fieldGoType := f.Type
 
-		var fieldType avro.Type
+		var fieldSchema avro.Schema
 		switch fieldGoType.Kind() {
 		case reflect.String:
-			fieldType = avro.String
+			fieldSchema = avro.NewPrimitiveSchema(avro.String, nil)
 		case reflect.Slice:
 			if fieldGoType.Elem().Kind() != reflect.Uint8 {
 				return nil, errors.New("unable to build schema for slice, only byte slices are supported")
 			}
-			fieldType = avro.Bytes
+			fieldSchema = avro.NewPrimitiveSchema(avro.Bytes, nil)
+		case reflect.Struct:
+			recordName, ok := recordNames[fieldGoType]
+			if !ok {
+				return nil, fmt.Errorf("unsupported nested struct: %s", fieldGoType)
+			}
+			recordSchema, err := recordSchemaFromAvroStruct(recordName, namespace, reflect.Zero(fieldGoType).Interface())
+			if err != nil {
+				return nil, err
+			}
+			fieldSchema = recordSchema
 		default:
 			return nil, fmt.Errorf("unsupported struct kind: %s", fieldGoType)
 		}
 
-		schemaField, err := avro.NewField(fieldName, avro.NewPrimitiveSchema(fieldType, nil))
+		schemaField, err := avro.NewField(fieldName, fieldSchema)
 		if err != nil {
 			return nil, fmt.Errorf("unable to create avro schema field: %w", err)
 		}